@@ -0,0 +1,543 @@
+package gitdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+type (
+	// AdminOptions configures the handler returned by AdminHandler.
+	AdminOptions struct {
+		// SigningKey, if set, makes the handler serve the records of a
+		// collection as JSON to the URLs signed with it by SignURL, until
+		// they expire, so they can be shared without accounts.
+		SigningKey []byte
+
+		// Authorizer, if set, is asked before every request to read or
+		// write a collection, and to sync, which writes the whole DB.
+		// Denied requests fail with 403 Forbidden, and the index only
+		// lists the collections that can be read. Signed URLs need no
+		// authorization.
+		Authorizer Authorizer
+
+		// RateLimiter, if set, is asked before every request, and the
+		// ones it refuses fail with 429 Too Many Requests, like with a
+		// RateLimit.
+		RateLimiter RateLimiter
+	}
+
+	adminHandler struct {
+		db    DB
+		opts  AdminOptions
+		token string
+	}
+
+	adminCollection struct {
+		Path    string
+		Records int
+		Err     error
+	}
+
+	adminRecord struct {
+		Index  int
+		Hash   string
+		JSON   string
+		Fields []adminField
+	}
+
+	adminField struct {
+		Name  string
+		Kind  string
+		Value string
+	}
+
+	adminCommit struct {
+		Hash    string
+		Author  string
+		When    time.Time
+		Message string
+	}
+)
+
+const adminHistoryLength = 20
+
+var adminTemplate = template.Must(template.New("admin").Funcs(template.FuncMap{
+	"query": url.QueryEscape,
+	"record": func(token, path string, r adminRecord) interface{} {
+		return struct {
+			Token  string
+			Path   string
+			Record adminRecord
+		}{token, path, r}
+	},
+}).Parse(`{{define "header"}}<!doctype html>
+<html><head><meta charset="utf-8"><title>gitdb</title>
+<style>body{font-family:sans-serif;margin:2em}table{border-collapse:collapse}td,th{border:1px solid #ccc;padding:4px 8px;text-align:left;vertical-align:top}textarea{width:40em;height:6em;font-family:monospace}.err{color:#b00}</style>
+</head><body><h1><a href="./">gitdb</a></h1>{{end}}
+{{define "index"}}{{template "header"}}
+<form method="post" action="sync"><input type="hidden" name="token" value="{{.Token}}"><button>Sync with remote</button></form>
+<table><tr><th>Collection</th><th>Records</th></tr>
+{{range .Collections}}<tr><td><a href="collection?path={{query .Path}}">{{.Path}}</a></td><td>{{if .Err}}<span class="err">{{.Err}}</span>{{else}}{{.Records}}{{end}}</td></tr>
+{{end}}</table></body></html>{{end}}
+{{define "record"}}<form method="post" action="record">
+<input type="hidden" name="token" value="{{.Token}}"><input type="hidden" name="path" value="{{.Path}}">
+<input type="hidden" name="index" value="{{.Record.Index}}"><input type="hidden" name="hash" value="{{.Record.Hash}}">
+{{if .Record.Fields}}<table>{{range .Record.Fields}}<tr><th>{{.Name}}</th><td>
+{{if eq .Kind "bool"}}<input type="checkbox" name="f.{{.Name}}" value="true"{{if eq .Value "true"}} checked{{end}}>
+{{else if eq .Kind "number"}}<input type="number" step="any" name="f.{{.Name}}" value="{{.Value}}">
+{{else if eq .Kind "string"}}<input type="text" name="f.{{.Name}}" value="{{.Value}}">
+{{else}}<textarea name="f.{{.Name}}">{{.Value}}</textarea>{{end}}
+</td></tr>{{end}}</table>
+{{else}}<textarea name="json">{{.Record.JSON}}</textarea>{{end}}
+<button name="action" value="save">Save</button>{{if ge .Record.Index 0}} <button name="action" value="delete">Delete</button>{{end}}
+</form>{{end}}
+{{define "collection"}}{{template "header"}}
+<h2>{{.Path}}</h2>
+{{range .Records}}<h3>#{{.Index}}</h3>{{template "record" (record $.Token $.Path .)}}{{end}}
+<h3>New record</h3>{{template "record" (record .Token .Path .New)}}
+<h2>History</h2>
+<table>{{range .History}}<tr><td><code>{{.Hash}}</code></td><td>{{.When.Format "2006-01-02 15:04:05"}}</td><td>{{.Author}}</td><td>{{.Message}}</td></tr>
+{{else}}<tr><td>No commits</td></tr>{{end}}</table></body></html>{{end}}`))
+
+// AdminHandler returns an http.Handler for operators to browse and edit the
+// records of the collections created by NewCollection, see their history
+// and sync with the remote. Edits are committed and pushed right away.
+// Collections with a struct Model get one form input per field. Forms carry
+// a token random to the handler, so other sites cannot post to it, and the
+// hash of the record shown, so edits of a record changed in the meantime
+// fail with 409 Conflict instead of overwriting another one. Every record
+// of the collection is listed, expired ones included, and edits leave the
+// others as they are; collections holding records that cannot be decoded,
+// like quarantined ones, cannot be edited. The handler has no
+// authentication of its own; mount it behind one, e.g. with
+// http.StripPrefix("/admin/", db.AdminHandler(AdminOptions{})), letting
+// through the data URLs made by SignURL if SigningKey is set. To limit
+// what users can do, have it give their roles to the context of their
+// requests with WithRole and set the Authorizer to a RolePolicy.
+func (db DB) AdminHandler(opts AdminOptions) http.Handler {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	return adminHandler{db: db, opts: opts, token: hex.EncodeToString(b[:])}
+}
+
+// SignURL returns the URL, relative to the handler, of the records of the
+// collection at path as JSON, valid until expires. The signature is an
+// HMAC-SHA256 of the path and the expiry under SigningKey, so changing
+// either invalidates the URL.
+func (opts AdminOptions) SignURL(path string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return "data?path=" + url.QueryEscape(path) + "&expires=" + exp + "&sig=" + opts.signature(path, exp)
+}
+
+func (opts AdminOptions) signature(path, expires string) string {
+	mac := hmac.New(sha256.New, opts.SigningKey)
+	mac.Write([]byte(path + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.opts.RateLimiter != nil && !h.opts.RateLimiter.Allow(r) {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	var err error
+	switch strings.TrimPrefix(r.URL.Path, "/") {
+	case "":
+		err = h.index(r.Context(), w)
+	case "collection":
+		if !h.authorize(w, r, "read", r.URL.Query().Get("path")) {
+			return
+		}
+		err = h.collection(w, r.URL.Query().Get("path"))
+	case "data":
+		if len(h.opts.SigningKey) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		if !h.checkSignature(w, r) {
+			return
+		}
+		err = h.data(w, r.URL.Query().Get("path"))
+	case "record":
+		if !h.checkPost(w, r) || !h.authorize(w, r, "write", r.PostForm.Get("path")) {
+			return
+		}
+		err = h.saveRecord(w, r)
+	case "sync":
+		if !h.checkPost(w, r) || !h.authorize(w, r, "write", "") {
+			return
+		}
+		if err = h.sync(r.Context()); err == nil {
+			http.Redirect(w, r, "./", http.StatusSeeOther)
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// checkPost writes an error and returns false unless r is a POST with the
+// token of the handler.
+func (h adminHandler) checkPost(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(r.PostForm.Get("token")), []byte(h.token)) != 1 {
+		http.Error(w, "invalid token", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// authorize writes an error and returns false unless the Authorizer lets
+// r perform op on path.
+func (h adminHandler) authorize(w http.ResponseWriter, r *http.Request, op, path string) bool {
+	if h.opts.Authorizer == nil {
+		return true
+	}
+	if err := h.opts.Authorizer.Authorize(r.Context(), op, path); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// checkSignature writes an error and returns false unless r has the
+// signature of its path and expiry and has not expired.
+func (h adminHandler) checkSignature(w http.ResponseWriter, r *http.Request) bool {
+	q := r.URL.Query()
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || !hmac.Equal([]byte(q.Get("sig")), []byte(h.opts.signature(q.Get("path"), q.Get("expires")))) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return false
+	}
+	if time.Now().Unix() > expires {
+		http.Error(w, "link expired", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func (h adminHandler) find(path string) *Collection {
+	for _, c := range h.db.state().managedCollections() {
+		if c.Path == path {
+			return c
+		}
+	}
+	return nil
+}
+
+func (h adminHandler) index(ctx context.Context, w http.ResponseWriter) error {
+	data := struct {
+		Token       string
+		Collections []adminCollection
+	}{Token: h.token}
+	for _, c := range h.db.state().managedCollections() {
+		if h.opts.Authorizer != nil && h.opts.Authorizer.Authorize(ctx, "read", c.Path) != nil {
+			continue
+		}
+		var records []json.RawMessage
+		err := c.Read(&records)
+		data.Collections = append(data.Collections, adminCollection{Path: c.Path, Records: len(records), Err: err})
+	}
+	return adminTemplate.ExecuteTemplate(w, "index", data)
+}
+
+func (h adminHandler) collection(w http.ResponseWriter, path string) error {
+	c := h.find(path)
+	if c == nil {
+		return fmt.Errorf("unknown collection %s", path)
+	}
+	records, err := c.adminRecords()
+	if err != nil {
+		return err
+	}
+	data := struct {
+		Token   string
+		Path    string
+		Records []adminRecord
+		New     adminRecord
+		History []adminCommit
+	}{Token: h.token, Path: c.Path}
+	for i := 0; i < records.Len(); i++ {
+		data.Records = append(data.Records, c.adminRecord(i, records.Index(i)))
+	}
+	data.New = c.adminRecord(-1, reflect.New(records.Type().Elem()).Elem())
+	if data.History, err = h.history(c); err != nil {
+		return err
+	}
+	return adminTemplate.ExecuteTemplate(w, "collection", data)
+}
+
+// data writes the records of the collection at path as JSON, without the
+// expired ones.
+func (h adminHandler) data(w http.ResponseWriter, path string) error {
+	c := h.find(path)
+	if c == nil {
+		return fmt.Errorf("unknown collection %s", path)
+	}
+	records := []interface{}{}
+	generic := *c
+	generic.JSON.UseNumber = true
+	if err := generic.Read(&records); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(records)
+}
+
+// adminRecords reads every record of the collection, expired ones
+// included, as its Model, or as generic maps if it has none, to rewrite
+// the collection. Numbers of interface{} values are kept as json.Number so
+// they are written back as they are, and records that cannot be decoded
+// fail the read instead of being dropped.
+func (c Collection) adminRecords() (reflect.Value, error) {
+	records := reflect.New(reflect.SliceOf(c.recordType()))
+	opts := c.jsonOptions()
+	opts.UseNumber = true
+	if err := opts.readCollection(filepath.Join(c.db.Local, c.Path), records.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	removeNulls(records.Interface())
+	return records.Elem(), nil
+}
+
+// recordType returns the type of the Model of the collection, or of
+// generic maps if it has none.
+func (c Collection) recordType() reflect.Type {
+	if c.Model != nil {
+		return reflect.TypeOf(c.Model)
+	}
+	return reflect.TypeOf(map[string]interface{}{})
+}
+
+func (c Collection) adminRecord(index int, record reflect.Value) adminRecord {
+	r := adminRecord{Index: index}
+	if index >= 0 {
+		r.Hash = adminRecordHash(record)
+	}
+	if b, err := json.MarshalIndent(record.Interface(), "", "  "); err == nil {
+		r.JSON = string(b)
+	}
+	v := indirectValue(record)
+	if !v.IsValid() && record.Kind() == reflect.Ptr {
+		v = reflect.New(record.Type().Elem()).Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		if index < 0 {
+			r.JSON = "{}"
+		}
+		return r
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := jsonName(sf)
+		if sf.PkgPath != "" || name == "" {
+			continue
+		}
+		f := adminField{Name: name}
+		fv := v.Field(i)
+		switch fv.Kind() {
+		case reflect.Bool:
+			f.Kind, f.Value = "bool", strconv.FormatBool(fv.Bool())
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			f.Kind = "number"
+			f.Value = fmt.Sprint(fv.Interface())
+		case reflect.String:
+			f.Kind, f.Value = "string", fv.String()
+		default:
+			b, _ := json.MarshalIndent(fv.Interface(), "", "  ")
+			f.Kind, f.Value = "json", string(b)
+		}
+		r.Fields = append(r.Fields, f)
+	}
+	return r
+}
+
+// adminRecordHash returns the hash of the JSON of record, to tell if it
+// changed between showing and saving it.
+func adminRecordHash(record reflect.Value) string {
+	b, _ := json.Marshal(record.Interface())
+	sum := sha1.Sum(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// parseAdminRecord decodes the submitted form into a record of type t.
+func parseAdminRecord(r *http.Request, t reflect.Type) (reflect.Value, error) {
+	record := reflect.New(t)
+	st := t
+	for st.Kind() == reflect.Ptr {
+		st = st.Elem()
+	}
+	if st.Kind() != reflect.Struct {
+		dec := json.NewDecoder(strings.NewReader(r.PostForm.Get("json")))
+		dec.UseNumber()
+		err := dec.Decode(record.Interface())
+		return record.Elem(), err
+	}
+	fields := map[string]json.RawMessage{}
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		name := jsonName(sf)
+		if sf.PkgPath != "" || name == "" {
+			continue
+		}
+		value := r.PostForm.Get("f." + name)
+		switch sf.Type.Kind() {
+		case reflect.Bool:
+			fields[name] = json.RawMessage(strconv.FormatBool(value == "true"))
+		case reflect.String:
+			b, _ := json.Marshal(value)
+			fields[name] = b
+		default:
+			if value == "" {
+				continue
+			}
+			fields[name] = json.RawMessage(value)
+		}
+	}
+	b, err := json.Marshal(fields)
+	if err != nil {
+		return record.Elem(), err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(record.Interface()); err != nil {
+		return record.Elem(), err
+	}
+	return record.Elem(), nil
+}
+
+func (h adminHandler) saveRecord(w http.ResponseWriter, r *http.Request) error {
+	c := h.find(r.PostForm.Get("path"))
+	if c == nil {
+		return fmt.Errorf("unknown collection %s", r.PostForm.Get("path"))
+	}
+	index, err := strconv.Atoi(r.PostForm.Get("index"))
+	if err != nil {
+		return err
+	}
+
+	unlock := h.db.lock()
+	records, err := c.adminRecords()
+	if err != nil {
+		unlock()
+		return err
+	}
+	if index >= records.Len() {
+		unlock()
+		return fmt.Errorf("record %d not found in %s", index, c.Path)
+	}
+	if index >= 0 && adminRecordHash(records.Index(index)) != r.PostForm.Get("hash") {
+		unlock()
+		http.Error(w, fmt.Sprintf("record %d of %s changed, reload and try again", index, c.Path), http.StatusConflict)
+		return nil
+	}
+	info := CommitInfo{Paths: []string{c.Path}, Records: 1}
+	if r.PostForm.Get("action") == "delete" && index >= 0 {
+		records = reflect.AppendSlice(records.Slice(0, index), records.Slice(index+1, records.Len()))
+		info.Op = "delete"
+		info.Message = fmt.Sprintf("admin: delete record %d of %s", index, c.Path)
+	} else {
+		record, err := parseAdminRecord(r, records.Type().Elem())
+		if err != nil {
+			unlock()
+			return err
+		}
+		if index < 0 {
+			records = reflect.Append(records, record)
+			info.Op = "add"
+			info.Message = fmt.Sprintf("admin: add record to %s", c.Path)
+		} else {
+			records.Index(index).Set(record)
+			info.Op = "update"
+			info.Message = fmt.Sprintf("admin: update record %d of %s", index, c.Path)
+		}
+	}
+	err = c.Write(records.Interface())
+	if err == nil {
+		err = h.db.Add(c.Path)
+	}
+	if err == nil {
+		err = h.db.commitOp(info)
+	}
+	unlock()
+	if err != nil {
+		return err
+	}
+	if err := h.db.Push(); err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	http.Redirect(w, r, "collection?path="+url.QueryEscape(c.Path), http.StatusSeeOther)
+	return nil
+}
+
+func (h adminHandler) sync(ctx context.Context) error {
+	return h.db.sync(ctx)
+}
+
+// history returns the latest commits changing the files of c.
+func (h adminHandler) history(c *Collection) ([]adminCommit, error) {
+	r, err := git.PlainOpen(h.db.Local)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Head(); err != nil {
+		return nil, nil
+	}
+	iter, err := r.Log(&git.LogOptions{
+		PathFilter: func(name string) bool {
+			return c.owns(filepath.FromSlash(name))
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var commits []adminCommit
+	err = iter.ForEach(func(commit *object.Commit) error {
+		commits = append(commits, adminCommit{
+			Hash:    commit.Hash.String()[:8],
+			Author:  commit.Author.Name,
+			When:    commit.Author.When,
+			Message: strings.SplitN(commit.Message, "\n", 2)[0],
+		})
+		if len(commits) == adminHistoryLength {
+			return storer.ErrStop
+		}
+		return nil
+	})
+	return commits, err
+}
@@ -0,0 +1,259 @@
+package gitdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	adminTokenPattern = regexp.MustCompile(`name="token" value="(\w+)"`)
+	adminHashPattern  = regexp.MustCompile(`name="index" value="(\d+)"><input type="hidden" name="hash" value="(\w+)"`)
+)
+
+// adminPage returns the token of the handler and the hashes of the
+// records shown on the page of the collection at path.
+func adminPage(t *testing.T, srv *httptest.Server, path string) (string, []string) {
+	t.Helper()
+	res, err := http.Get(srv.URL + "/collection?path=" + url.QueryEscape(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: %d %s", path, res.StatusCode, b)
+	}
+	m := adminTokenPattern.FindSubmatch(b)
+	if m == nil {
+		t.Fatalf("no token in %s", b)
+	}
+	var hashes []string
+	for _, h := range adminHashPattern.FindAllSubmatch(b, -1) {
+		hashes = append(hashes, string(h[2]))
+	}
+	return string(m[1]), hashes
+}
+
+func adminPost(t *testing.T, srv *httptest.Server, form url.Values) int {
+	t.Helper()
+	res, err := http.PostForm(srv.URL+"/record", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestAdminToken(t *testing.T) {
+	db := newTestDB(t)
+	c := db.NewCollection("items.json")
+	if err := c.Write([]map[string]interface{}{{"id": 1}}); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(db.AdminHandler(AdminOptions{}))
+	defer srv.Close()
+	_, hashes := adminPage(t, srv, c.Path)
+	form := url.Values{"path": {c.Path}, "index": {"0"}, "hash": {hashes[0]}, "json": {`{"id":2}`}}
+	if code := adminPost(t, srv, form); code != http.StatusForbidden {
+		t.Errorf("post without token: %d, want %d", code, http.StatusForbidden)
+	}
+	form.Set("token", "wrong")
+	if code := adminPost(t, srv, form); code != http.StatusForbidden {
+		t.Errorf("post with wrong token: %d, want %d", code, http.StatusForbidden)
+	}
+}
+
+func TestAdminKeepsOtherRecords(t *testing.T) {
+	db := newTestDB(t)
+	c := db.NewCollection("items.json")
+	c.ExpiresAtField = "expires_at"
+	content := `[
+{"id":9007199254740993,"name":"big"},
+{"expires_at":1,"id":2,"name":"expired"},
+{"id":3,"name":"edit me"},
+null
+]
+`
+	if err := ioutil.WriteFile(filepath.Join(db.Local, c.Path), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Add(c.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Commit("init"); err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(db.AdminHandler(AdminOptions{}))
+	defer srv.Close()
+	token, hashes := adminPage(t, srv, c.Path)
+	if len(hashes) != 3 {
+		t.Fatalf("got %d records, want 3", len(hashes))
+	}
+
+	stale := url.Values{"token": {token}, "path": {c.Path}, "index": {"2"}, "hash": {hashes[1]}, "json": {`{"id":3,"name":"x"}`}}
+	if code := adminPost(t, srv, stale); code != http.StatusConflict {
+		t.Errorf("post with the hash of another record: %d, want %d", code, http.StatusConflict)
+	}
+
+	// the push fails without a remote, after the commit
+	edit := url.Values{"token": {token}, "path": {c.Path}, "index": {"2"}, "hash": {hashes[2]}, "json": {`{"id":3,"name":"edited"}`}}
+	adminPost(t, srv, edit)
+	got := readTestFile(t, db, c.Path)
+	for _, want := range []string{`"id":9007199254740993`, `"name":"expired"`, `"name":"edited"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%s not found in %s", want, got)
+		}
+	}
+}
+
+func TestAdminSignedURL(t *testing.T) {
+	db := newTestDB(t)
+	c := db.NewCollection("items.json")
+	if err := c.Write([]map[string]interface{}{{"id": 9007199254740993}}); err != nil {
+		t.Fatal(err)
+	}
+	opts := AdminOptions{SigningKey: []byte("secret")}
+	srv := httptest.NewServer(db.AdminHandler(opts))
+	defer srv.Close()
+	get := func(path string) (int, string) {
+		t.Helper()
+		res, err := http.Get(srv.URL + "/" + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, string(b)
+	}
+
+	signed := opts.SignURL(c.Path, time.Now().Add(time.Hour))
+	if code, body := get(signed); code != http.StatusOK || body != "[{\"id\":9007199254740993}]\n" {
+		t.Errorf("signed URL: %d %s", code, body)
+	}
+	other := db.NewCollection("other.json")
+	tests := []string{
+		strings.Replace(signed, "items.json", "other.json", 1),
+		strings.Replace(signed, "&expires=", "&expires=1", 1),
+		AdminOptions{SigningKey: []byte("guess")}.SignURL(other.Path, time.Now().Add(time.Hour)),
+		"data?path=" + c.Path,
+	}
+	for _, path := range tests {
+		if code, body := get(path); code != http.StatusForbidden || body != "invalid signature\n" {
+			t.Errorf("%s: %d %s", path, code, body)
+		}
+	}
+	if code, body := get(opts.SignURL(c.Path, time.Now().Add(-time.Second))); code != http.StatusForbidden || body != "link expired\n" {
+		t.Errorf("expired URL: %d %s", code, body)
+	}
+
+	unsigned := httptest.NewServer(db.AdminHandler(AdminOptions{}))
+	defer unsigned.Close()
+	res, err := http.Get(unsigned.URL + "/" + signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("data without a signing key: %d, want %d", res.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestAdminAuthorizer(t *testing.T) {
+	db := newTestDB(t)
+	items := db.NewCollection("items.json")
+	other := db.NewCollection("other.json")
+	for _, c := range []*Collection{items, other} {
+		if err := c.Write([]map[string]interface{}{{"id": 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := db.AdminHandler(AdminOptions{Authorizer: RolePolicy{
+		"reader": {Read: []string{"*"}},
+		"editor": {Write: []string{"items.json"}},
+	}})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(WithRole(r.Context(), r.Header.Get("X-Role"))))
+	}))
+	defer srv.Close()
+	do := func(role, method, path string, form url.Values) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+"/"+path, strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Role", role)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		b, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.StatusCode, string(b)
+	}
+
+	_, body := do("reader", http.MethodGet, "collection?path=items.json", nil)
+	m := adminTokenPattern.FindStringSubmatch(body)
+	h := adminHashPattern.FindStringSubmatch(body)
+	if m == nil || h == nil {
+		t.Fatalf("no token or hash in %s", body)
+	}
+	edit := url.Values{"token": {m[1]}, "path": {items.Path}, "index": {"0"}, "hash": {h[2]}, "json": {`{"id":2}`}}
+	sync := url.Values{"token": {m[1]}}
+
+	if code, body := do("reader", http.MethodPost, "record", edit); code != http.StatusForbidden || body != "role \"reader\" may not write items.json\n" {
+		t.Errorf("reader edit: %d %s", code, body)
+	}
+	if code, _ := do("reader", http.MethodPost, "sync", sync); code != http.StatusForbidden {
+		t.Errorf("reader sync: %d, want %d", code, http.StatusForbidden)
+	}
+	if code, _ := do("editor", http.MethodGet, "collection?path=other.json", nil); code != http.StatusForbidden {
+		t.Errorf("editor reading other.json: %d, want %d", code, http.StatusForbidden)
+	}
+	if code, _ := do("", http.MethodGet, "collection?path=items.json", nil); code != http.StatusForbidden {
+		t.Errorf("request without a role: %d, want %d", code, http.StatusForbidden)
+	}
+	if _, body := do("editor", http.MethodGet, "", nil); !strings.Contains(body, "items.json") || strings.Contains(body, "other.json") {
+		t.Errorf("editor index: %s", body)
+	}
+
+	// the push fails without a remote, after the commit
+	do("editor", http.MethodPost, "record", edit)
+	if got := readTestFile(t, db, items.Path); !strings.Contains(got, `"id":2`) {
+		t.Errorf("editor edit not saved: %s", got)
+	}
+}
+
+func TestAdminRateLimiter(t *testing.T) {
+	db := newTestDB(t)
+	srv := httptest.NewServer(db.AdminHandler(AdminOptions{
+		RateLimiter: NewRateLimit(Limit{}, Limit{Rate: 0.001, Burst: 2}, nil),
+	}))
+	defer srv.Close()
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		res, err := http.Get(srv.URL + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != want {
+			t.Errorf("request %d: %d, want %d", i, res.StatusCode, want)
+		}
+	}
+}
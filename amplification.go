@@ -0,0 +1,99 @@
+package gitdb
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// amplificationWarnSize and amplificationWarnRatio are the size of a
+	// rewritten file and the ratio of its size to the bytes changed from
+	// which Write warns, once per file.
+	amplificationWarnSize  = 1 << 20
+	amplificationWarnRatio = 100
+)
+
+type (
+	// WriteAmplification compares the bytes written to a file by the Write
+	// of a collection or an object with the bytes of the lines they
+	// actually changed, since the start of the process.
+	WriteAmplification struct {
+		Path         string
+		Writes       int
+		BytesWritten int64
+		BytesChanged int64
+	}
+)
+
+// Ratio returns BytesWritten divided by BytesChanged, or by 1 if nothing
+// changed.
+func (a WriteAmplification) Ratio() float64 {
+	changed := a.BytesChanged
+	if changed < 1 {
+		changed = 1
+	}
+	return float64(a.BytesWritten) / float64(changed)
+}
+
+// writeTracked writes w to the file at path, relative to the repository,
+// and records its write amplification.
+func (db DB) writeTracked(ctx context.Context, path string, w *bytes.Buffer) error {
+	full := filepath.Join(db.Local, path)
+	before, _ := ioutil.ReadFile(full)
+	after := w.Bytes()
+	if err := writeFileContext(ctx, full, bytes.NewReader(after)); err != nil {
+		return err
+	}
+	db.state().recordWrite(path, before, after)
+	return nil
+}
+
+func (s *repoState) recordWrite(path string, before, after []byte) {
+	var changed int64
+	for _, l := range diffLines(splitLines(string(before)), splitLines(string(after))) {
+		if l.op != ' ' {
+			changed += int64(len(l.text)) + 1
+		}
+	}
+	s.amplificationMu.Lock()
+	defer s.amplificationMu.Unlock()
+	if s.amplification == nil {
+		s.amplification = map[string]*WriteAmplification{}
+		s.amplificationWarned = map[string]bool{}
+	}
+	a := s.amplification[path]
+	if a == nil {
+		a = &WriteAmplification{Path: path}
+		s.amplification[path] = a
+	}
+	a.Writes++
+	a.BytesWritten += int64(len(after))
+	a.BytesChanged += changed
+	if len(after) >= amplificationWarnSize && int64(len(after)) > changed*amplificationWarnRatio && !s.amplificationWarned[path] {
+		s.amplificationWarned[path] = true
+		log.Println("warning: writing", changed, "changed bytes rewrote", len(after), "bytes of", path,
+			"- consider RecordKeyField or ChunkSize for large collections")
+	}
+}
+
+// writeAmplification returns the write amplification of every file written,
+// highest ratio first.
+func (s *repoState) writeAmplification() []WriteAmplification {
+	s.amplificationMu.Lock()
+	defer s.amplificationMu.Unlock()
+	var list []WriteAmplification
+	for _, a := range s.amplification {
+		list = append(list, *a)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if ri, rj := list[i].Ratio(), list[j].Ratio(); ri != rj {
+			return ri > rj
+		}
+		return list[i].Path < list[j].Path
+	})
+	return list
+}
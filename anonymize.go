@@ -0,0 +1,212 @@
+package gitdb
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+type (
+	// Anonymizer returns the value replacing value, a value decoded from
+	// JSON, in an anonymized export.
+	Anonymizer func(value interface{}) interface{}
+
+	// AnonymizeRules maps fields, by JSON name or dotted path of JSON
+	// names like "address.street", to the Anonymizer replacing their
+	// values, or to the name of a built-in one: "redact", "hash", "email",
+	// "name" or "mask". See ExportAnonymized.
+	AnonymizeRules map[string]interface{}
+)
+
+var (
+	fakeFirstNames = []string{"Alex", "Sam", "Jordan", "Taylor", "Morgan", "Casey", "Riley", "Jamie", "Avery", "Quinn"}
+	fakeLastNames  = []string{"Smith", "Lee", "Garcia", "Chen", "Brown", "Kim", "Martin", "Silva", "Novak", "Khan"}
+)
+
+func (c Collection) MustExportAnonymized(w io.Writer, rules AnonymizeRules) {
+	if err := c.ExportAnonymized(w, rules); err != nil {
+		panic(err)
+	}
+}
+
+// ExportAnonymized writes to w a copy of the collection, in the format of
+// its file, with the values of the fields of rules, and of the fields of
+// the Model tagged like `gitdb:"anonymize=email"`, replaced, so production
+// data can be shared with developers and staging. Rules override tags.
+// Each item of arrays is replaced on its own.
+//
+// The built-in anonymizers "hash", "email" and "name" derive the fake
+// value from the real one with a key made for each export, so equal values
+// stay equal within an export, keeping joins between records, but cannot
+// be recovered. "redact" replaces strings with "[redacted]" and other
+// values with null, and "mask" keeps the last 4 characters of strings.
+// Null values are left null.
+func (c Collection) ExportAnonymized(w io.Writer, rules AnonymizeRules) error {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	builtin := builtinAnonymizers(key)
+	fields := map[string]Anonymizer{}
+	if c.Model != nil {
+		for path, name := range anonymizeTags(reflect.TypeOf(c.Model), "") {
+			fn, ok := builtin[name]
+			if !ok {
+				return fmt.Errorf("ExportAnonymized: unknown anonymizer %q for %s", name, path)
+			}
+			fields[path] = fn
+		}
+	}
+	for path, rule := range rules {
+		switch r := rule.(type) {
+		case string:
+			fn, ok := builtin[r]
+			if !ok {
+				return fmt.Errorf("ExportAnonymized: unknown anonymizer %q for %s", r, path)
+			}
+			fields[path] = fn
+		case Anonymizer:
+			fields[path] = r
+		case func(interface{}) interface{}:
+			fields[path] = r
+		default:
+			return fmt.Errorf("ExportAnonymized: rule for %s must be a name or an Anonymizer, got %T", path, rule)
+		}
+	}
+
+	c.JSON.UseNumber = true
+	var records []interface{}
+	if err := c.readAll(&records); err != nil {
+		return err
+	}
+	for i := range records {
+		for path, fn := range fields {
+			records[i] = anonymizePath(records[i], strings.Split(path, "."), fn)
+		}
+	}
+	buf := writeWith(c.JSONPCallbackName, JSONOptions{}, records)
+	defer putBuffer(buf)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// anonymizePath replaces with fn the values at path in v, going through
+// arrays along the way.
+func anonymizePath(v interface{}, path []string, fn Anonymizer) interface{} {
+	switch x := v.(type) {
+	case []interface{}:
+		for i := range x {
+			x[i] = anonymizePath(x[i], path, fn)
+		}
+		return x
+	case map[string]interface{}:
+		value, ok := x[path[0]]
+		if !ok {
+			return x
+		}
+		if len(path) > 1 {
+			x[path[0]] = anonymizePath(value, path[1:], fn)
+		} else {
+			x[path[0]] = anonymizeValue(value, fn)
+		}
+		return x
+	}
+	return v
+}
+
+// anonymizeValue replaces v with fn, or each item of v with fn if v is an
+// array.
+func anonymizeValue(v interface{}, fn Anonymizer) interface{} {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		for i := range x {
+			x[i] = anonymizeValue(x[i], fn)
+		}
+		return x
+	}
+	return fn(v)
+}
+
+// anonymizeTags returns the dotted JSON paths of the fields of t tagged
+// `gitdb:"anonymize=name"`, and their anonymizer names, looking into
+// nested structs.
+func anonymizeTags(t reflect.Type, prefix string) map[string]string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	tags := map[string]string{}
+	if t.Kind() != reflect.Struct {
+		return tags
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		if sf.Anonymous && sf.Tag.Get("json") == "" {
+			for path, name := range anonymizeTags(sf.Type, prefix) {
+				tags[path] = name
+			}
+			continue
+		}
+		name := jsonName(sf)
+		if name == "" {
+			continue
+		}
+		if rule, ok := gitdbTag(sf)["anonymize"]; ok {
+			tags[prefix+name] = rule
+			continue
+		}
+		for path, rule := range anonymizeTags(sf.Type, prefix+name+".") {
+			tags[path] = rule
+		}
+	}
+	return tags
+}
+
+func builtinAnonymizers(key []byte) map[string]Anonymizer {
+	digest := func(v interface{}) []byte {
+		mac := hmac.New(sha256.New, key)
+		fmt.Fprint(mac, v)
+		return mac.Sum(nil)
+	}
+	return map[string]Anonymizer{
+		"redact": func(v interface{}) interface{} {
+			if _, ok := v.(string); ok {
+				return "[redacted]"
+			}
+			return nil
+		},
+		"hash": func(v interface{}) interface{} {
+			return hex.EncodeToString(digest(v)[:8])
+		},
+		"email": func(v interface{}) interface{} {
+			return "user-" + hex.EncodeToString(digest(v)[:4]) + "@example.com"
+		},
+		"name": func(v interface{}) interface{} {
+			d := digest(v)
+			first := binary.BigEndian.Uint32(d) % uint32(len(fakeFirstNames))
+			last := binary.BigEndian.Uint32(d[4:]) % uint32(len(fakeLastNames))
+			return fakeFirstNames[first] + " " + fakeLastNames[last]
+		},
+		"mask": func(v interface{}) interface{} {
+			s, ok := v.(string)
+			if !ok {
+				s = fmt.Sprint(v)
+			}
+			r := []rune(s)
+			for i := 0; i < len(r)-4; i++ {
+				r[i] = '*'
+			}
+			return string(r)
+		},
+	}
+}
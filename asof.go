@@ -0,0 +1,165 @@
+package gitdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func (db DB) MustAsOf(t time.Time) *DB {
+	view, err := db.AsOf(t)
+	if err != nil {
+		panic(err)
+	}
+	return view
+}
+
+// AsOf returns a read-only copy of db holding the files of the last commit
+// of the current branch, following first parents, made at or before t, so
+// that several collections can be read consistently as they were then.
+// Collections and objects have to be created from the returned DB; their
+// Write, as well as Add, Commit and Push, fail. The files of each commit
+// are extracted once, inside the git directory; Close the returned DB when
+// done with it so they can be removed. Only the last few commits no view
+// uses are kept.
+func (db DB) AsOf(t time.Time) (*DB, error) {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return nil, err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return nil, fmt.Errorf("AsOf: %v", err)
+	}
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	for commit.Committer.When.After(t) {
+		if commit, err = commit.Parent(0); err == object.ErrParentNotFound {
+			return nil, fmt.Errorf("AsOf: no commit at or before %s", t.Format(time.RFC3339))
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	dir, err := db.extractCommit(commit)
+	if err != nil {
+		return nil, err
+	}
+	view := db
+	view.Local = dir
+	view.asOf = commit.Hash.String()
+	return &view, nil
+}
+
+// extractCommit writes the files of commit into a directory named after
+// it, unless it already exists, and returns the directory.
+func (db DB) extractCommit(commit *object.Commit) (string, error) {
+	snapshots.Lock()
+	defer snapshots.Unlock()
+	parent := filepath.Join(gitDir(db.Local), "gitdb", "asof")
+	dir := filepath.Join(parent, commit.Hash.String())
+	if !isDir(dir) {
+		if err := extractTree(parent, dir, commit); err != nil {
+			return "", err
+		}
+	}
+	snapshots.refs[dir]++
+	now := time.Now()
+	os.Chtimes(dir, now, now)
+	return dir, evictSnapshots(parent)
+}
+
+// releaseSnapshot releases dir, a directory returned by extractCommit,
+// and removes the snapshots no longer used beyond maxSnapshots.
+func releaseSnapshot(dir string) error {
+	snapshots.Lock()
+	defer snapshots.Unlock()
+	if snapshots.refs[dir]--; snapshots.refs[dir] <= 0 {
+		delete(snapshots.refs, dir)
+	}
+	return evictSnapshots(filepath.Dir(dir))
+}
+
+// evictSnapshots removes the snapshots in parent no AsOf view uses,
+// except the maxSnapshots last used ones.
+func evictSnapshots(parent string) error {
+	infos, err := ioutil.ReadDir(parent)
+	if err != nil {
+		return err
+	}
+	var unused []os.FileInfo
+	for _, info := range infos {
+		dir := filepath.Join(parent, info.Name())
+		if info.IsDir() && !strings.HasPrefix(info.Name(), "tmp") && snapshots.refs[dir] == 0 {
+			unused = append(unused, info)
+		}
+	}
+	sort.Slice(unused, func(i, j int) bool {
+		return unused[i].ModTime().After(unused[j].ModTime())
+	})
+	for i := maxSnapshots; i < len(unused); i++ {
+		if err := os.RemoveAll(filepath.Join(parent, unused[i].Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractTree writes the files of commit into dir, through a temporary
+// directory in parent.
+func extractTree(parent, dir string, commit *object.Commit) error {
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempDir(parent, "tmp")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		content, err := f.Contents()
+		if err != nil {
+			return err
+		}
+		return writeFile(filepath.Join(tmp, f.Name), bytes.NewReader([]byte(content)))
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil && !isDir(dir) {
+		return err
+	}
+	return nil
+}
+
+// maxSnapshots is the number of snapshots of commits kept for AsOf once
+// no view uses them.
+const maxSnapshots = 4
+
+// snapshots counts the AsOf views using each snapshot directory.
+var snapshots = struct {
+	sync.Mutex
+	refs map[string]int
+}{refs: map[string]int{}}
+
+// checkWritable returns an error if db is a read-only DB returned by AsOf.
+func (db DB) checkWritable(op string) error {
+	if db.asOf == "" {
+		return nil
+	}
+	return fmt.Errorf("%s: read-only as of commit %s", op, db.asOf[:7])
+}
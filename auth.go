@@ -0,0 +1,67 @@
+package gitdb
+
+import (
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+// SetAuth sets the auth method used to talk to the remote, taking
+// precedence over the SSH key set by SetSSHKey.
+func (db *DB) SetAuth(auth transport.AuthMethod) {
+	db.auth = auth
+}
+
+func (db DB) authMethod() transport.AuthMethod {
+	if db.auth != nil {
+		return db.auth
+	}
+	if db.sshKeys != nil {
+		return db.sshKeys
+	}
+	return nil
+}
+
+// AzureDevOpsAuth returns the auth method for an Azure DevOps personal
+// access token, which is sent as basic auth with an empty user name. Azure
+// DevOps also needs EnableAzureDevOps to be called.
+func AzureDevOpsAuth(pat string) transport.AuthMethod {
+	return &http.BasicAuth{
+		Password: pat,
+	}
+}
+
+// EnableAzureDevOps stops go-git from filtering out the multi_ack
+// capabilities, which Azure DevOps only serves clients supporting, as
+// recommended by go-git. It changes transport.UnsupportedCapabilities, so
+// it applies to every remote of the process; call it once at startup,
+// before talking to any remote.
+func EnableAzureDevOps() {
+	var kept []capability.Capability
+	for _, c := range transport.UnsupportedCapabilities {
+		if c != capability.MultiACK && c != capability.MultiACKDetailed {
+			kept = append(kept, c)
+		}
+	}
+	transport.UnsupportedCapabilities = kept
+}
+
+// BitbucketAppPasswordAuth returns the auth method for a Bitbucket Cloud
+// app password, which must be used with the account user name (not the
+// email address).
+func BitbucketAppPasswordAuth(username, appPassword string) transport.AuthMethod {
+	return &http.BasicAuth{
+		Username: username,
+		Password: appPassword,
+	}
+}
+
+// BitbucketAccessTokenAuth returns the auth method for a Bitbucket Cloud
+// repository, project or workspace access token, which Bitbucket expects
+// with the fixed user name x-token-auth.
+func BitbucketAccessTokenAuth(token string) transport.AuthMethod {
+	return &http.BasicAuth{
+		Username: "x-token-auth",
+		Password: token,
+	}
+}
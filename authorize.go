@@ -0,0 +1,84 @@
+package gitdb
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+)
+
+type (
+	// Authorizer decides whether the request of ctx may perform op, "read"
+	// or "write", on the collection at path, or on the whole DB if path
+	// is empty, like syncing with the remote. It returns why not as an
+	// error.
+	Authorizer interface {
+		Authorize(ctx context.Context, op, path string) error
+	}
+
+	// AuthorizerFunc is a function used as an Authorizer.
+	AuthorizerFunc func(ctx context.Context, op, path string) error
+
+	// RolePolicy is an Authorizer granting each role the operations of
+	// its RoleGrants. The role of a request is the one given to its
+	// context by WithRole, so an authentication in front of the handler
+	// sets it; requests without a role in the policy are denied.
+	RolePolicy map[string]RoleGrants
+
+	// RoleGrants lists the globs, in the syntax of path.Match, of the
+	// paths of the collections a role may read and write, like
+	// "products.json" or "logs/*". Paths use slashes and * does not match
+	// them. Writing implies reading. The empty path of the whole DB is
+	// only matched by "*".
+	RoleGrants struct {
+		Read  []string
+		Write []string
+	}
+
+	roleKey struct{}
+)
+
+func (f AuthorizerFunc) Authorize(ctx context.Context, op, path string) error {
+	return f(ctx, op, path)
+}
+
+// WithRole returns a copy of ctx holding the role used by RolePolicy.
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleOf returns the role given to ctx by WithRole.
+func RoleOf(ctx context.Context) (string, bool) {
+	role, ok := ctx.Value(roleKey{}).(string)
+	return role, ok
+}
+
+func (p RolePolicy) Authorize(ctx context.Context, op, name string) error {
+	role, ok := RoleOf(ctx)
+	if !ok {
+		return fmt.Errorf("no role")
+	}
+	grants, ok := p[role]
+	if !ok {
+		return fmt.Errorf("unknown role %q", role)
+	}
+	var globs []string
+	switch op {
+	case "read":
+		globs = append(append(globs, grants.Read...), grants.Write...)
+	case "write":
+		globs = grants.Write
+	default:
+		return fmt.Errorf("unknown operation %q", op)
+	}
+	name = filepath.ToSlash(name)
+	for _, glob := range globs {
+		if ok, _ := path.Match(glob, name); ok {
+			return nil
+		}
+	}
+	if name == "" {
+		return fmt.Errorf("role %q may not %s the database", role, op)
+	}
+	return fmt.Errorf("role %q may not %s %s", role, op, name)
+}
@@ -0,0 +1,51 @@
+package gitdb
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRolePolicy(t *testing.T) {
+	policy := RolePolicy{
+		"admin":  {Write: []string{"*", "*/*"}},
+		"editor": {Read: []string{"*"}, Write: []string{"products.json", "logs/*"}},
+		"reader": {Read: []string{"products.json"}},
+	}
+	tests := []struct {
+		role string
+		op   string
+		path string
+		err  string
+	}{
+		{"admin", "write", "", ""},
+		{"admin", "read", "logs/a.json", ""},
+		{"editor", "read", "users.json", ""},
+		{"editor", "read", "logs/a.json", ""},
+		{"editor", "write", "products.json", ""},
+		{"editor", "write", "logs/a.json", ""},
+		{"editor", "write", "logs/a/b.json", `role "editor" may not write logs/a/b.json`},
+		{"editor", "write", "users.json", `role "editor" may not write users.json`},
+		{"editor", "write", "", `role "editor" may not write the database`},
+		{"reader", "read", "products.json", ""},
+		{"reader", "read", "", `role "reader" may not read the database`},
+		{"reader", "write", "products.json", `role "reader" may not write products.json`},
+		{"reader", "delete", "products.json", `unknown operation "delete"`},
+		{"guest", "read", "products.json", `unknown role "guest"`},
+	}
+	for _, test := range tests {
+		err := policy.Authorize(WithRole(context.Background(), test.role), test.op, test.path)
+		if got := errorText(err); got != test.err {
+			t.Errorf("%s %s %s: got error %q, want %q", test.role, test.op, test.path, got, test.err)
+		}
+	}
+	if err := policy.Authorize(context.Background(), "read", "products.json"); err == nil {
+		t.Error("request without a role authorized")
+	}
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
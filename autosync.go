@@ -0,0 +1,171 @@
+package gitdb
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+type (
+	// Syncer pulls from and pushes to the remote in the background, see
+	// AutoSync.
+	Syncer struct {
+		db       DB
+		state    *repoState
+		interval time.Duration
+		stop     chan struct{}
+		run      sync.Mutex
+
+		mu     sync.Mutex
+		status SyncStatus
+	}
+
+	// SyncStatus describes the state of a Syncer.
+	SyncStatus struct {
+		// Paused is true between Pause and Resume.
+		Paused bool
+
+		// Running is true while a sync is in progress.
+		Running bool
+
+		// LastRun is the time the last sync finished, successful or not,
+		// and LastSuccess the time the last successful one did.
+		LastRun     time.Time
+		LastSuccess time.Time
+
+		// LastError is the error of the last sync, nil if it succeeded.
+		LastError error
+
+		// NextRun is the time of the next scheduled sync, zero when
+		// paused or stopped.
+		NextRun time.Time
+	}
+)
+
+// AutoSync pulls from the remote, then pushes to it, at every interval in
+// the background until Stop or Close is called, like the sync of
+// AdminHandler. Errors are logged and kept in the Status of the returned
+// Syncer.
+func (db DB) AutoSync(interval time.Duration) *Syncer {
+	s := &Syncer{
+		db:       db,
+		state:    db.state(),
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+	s.status.NextRun = time.Now().Add(interval)
+	s.state.addSyncer(s)
+	s.state.background.Add(1)
+	go s.loop()
+	return s
+}
+
+func (s *Syncer) loop() {
+	defer s.state.background.Done()
+	defer s.state.removeSyncer(s)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			paused := s.status.Paused
+			if !paused {
+				s.status.NextRun = time.Now().Add(s.interval)
+			}
+			s.mu.Unlock()
+			if paused {
+				continue
+			}
+			if err := s.SyncNow(context.Background()); err != nil {
+				log.Println("error syncing", err)
+			}
+		}
+	}
+}
+
+// Pause stops scheduled syncs, like during maintenance, until Resume is
+// called. A sync in progress is not interrupted, and SyncNow still
+// syncs.
+func (s *Syncer) Pause() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Paused = true
+	s.status.NextRun = time.Time{}
+}
+
+// Resume restarts the scheduled syncs stopped by Pause, from the next
+// tick of the interval.
+func (s *Syncer) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.stop:
+		return
+	default:
+	}
+	s.status.Paused = false
+	s.status.NextRun = time.Now().Add(s.interval)
+}
+
+// SyncNow syncs right away, even when paused, waiting for a sync in
+// progress to finish first, and returns its error.
+func (s *Syncer) SyncNow(ctx context.Context) error {
+	s.run.Lock()
+	defer s.run.Unlock()
+	s.mu.Lock()
+	s.status.Running = true
+	s.mu.Unlock()
+
+	err := s.db.reportError("sync", s.db.sync(ctx))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Running = false
+	s.status.LastRun = time.Now()
+	s.status.LastError = err
+	if err == nil {
+		s.status.LastSuccess = s.status.LastRun
+	}
+	return err
+}
+
+// Status returns the current state of s.
+func (s *Syncer) Status() SyncStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// Stop stops the scheduled syncs. A sync in progress is not interrupted;
+// Close waits for it.
+func (s *Syncer) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	s.status.NextRun = time.Time{}
+}
+
+// sync pulls from the remote, then pushes to it.
+func (db DB) sync(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := db.pull(ctx); err != nil {
+		return err
+	}
+	db.state().refreshCaches()
+	if err := db.push(ctx); err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
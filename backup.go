@@ -0,0 +1,145 @@
+package gitdb
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func (db DB) MustBackup(w io.Writer) {
+	if err := db.Backup(w); err != nil {
+		panic(err)
+	}
+}
+
+// Backup writes to w a tar archive of the repository, with all its refs
+// and objects, laid out like a bare repository. Uncommitted changes are not
+// included. Use RestoreBackup to restore it.
+func (db DB) Backup(w io.Writer) error {
+	defer db.lock()()
+
+	root := filepath.Join(db.Local, ".git")
+	tw := tar.NewWriter(w)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(root, path)
+		if err != nil || name == "." {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		// the index and the journal only describe the worktree
+		if name == "index" || name == "gitdb" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = name
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func MustRestoreBackup(r io.Reader, local string) {
+	if err := RestoreBackup(r, local); err != nil {
+		panic(err)
+	}
+}
+
+// RestoreBackup extracts the archive written by Backup into a new repository
+// at local and checks out HEAD, so a DB can be used on it again without
+// the remote. It fails if local already contains a repository.
+func RestoreBackup(r io.Reader, local string) error {
+	root := filepath.Join(local, ".git")
+	if _, err := os.Stat(root); err == nil {
+		return git.ErrRepositoryAlreadyExists
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(filepath.FromSlash(hdr.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("RestoreBackup: invalid path %s", hdr.Name)
+		}
+		path := filepath.Join(root, name)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeFile(path, tr); err != nil {
+				return err
+			}
+		}
+	}
+
+	repo, err := git.PlainOpen(local)
+	if err != nil {
+		return err
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return err
+	}
+	if cfg.Core.IsBare {
+		cfg.Core.IsBare = false
+		if err := repo.SetConfig(cfg); err != nil {
+			return err
+		}
+	}
+	head, err := repo.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	w, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return w.Reset(&git.ResetOptions{
+		Mode:   git.HardReset,
+		Commit: head.Hash(),
+	})
+}
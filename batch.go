@@ -0,0 +1,100 @@
+package gitdb
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// SetCommitBatching makes Commit return immediately and commit everything
+// staged so far once window has passed since the first Commit of a batch,
+// so bursts of writes end up in a single commit. A Push requested while a
+// batch is pending is made after the batch is committed. A zero window
+// restores immediate commits.
+func (db *DB) SetCommitBatching(window time.Duration) {
+	db.commitBatch = window
+}
+
+func (s *repoState) scheduleCommit(db DB, msg string) {
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+	s.commitMessages = append(s.commitMessages, msg)
+	if s.commitTimer != nil {
+		return
+	}
+	s.background.Add(1)
+	s.commitTimer = time.AfterFunc(db.commitBatch, func() {
+		defer s.background.Done()
+		s.commitMu.Lock()
+		s.commitTimer = nil
+		s.commitMu.Unlock()
+		if err := s.commitBatch(db); err != nil {
+			log.Println("error committing batch", err)
+			db.reportError("commit", err)
+		}
+	})
+}
+
+// pushAfterCommit reports whether a batch is pending, in which case it will
+// be pushed once committed.
+func (s *repoState) pushAfterCommit() bool {
+	s.commitMu.Lock()
+	defer s.commitMu.Unlock()
+	if s.commitTimer == nil {
+		return false
+	}
+	s.commitPush = true
+	return true
+}
+
+// flushCommit commits the pending batch, if any, right away.
+func (s *repoState) flushCommit(db DB) error {
+	s.commitMu.Lock()
+	pending := s.commitTimer != nil && s.commitTimer.Stop()
+	s.commitTimer = nil
+	s.commitMu.Unlock()
+	if !pending {
+		return nil
+	}
+	defer s.background.Done()
+	return s.commitBatch(db)
+}
+
+func (s *repoState) commitBatch(db DB) error {
+	s.commitMu.Lock()
+	messages, push := s.commitMessages, s.commitPush
+	s.commitMessages, s.commitPush = nil, false
+	s.commitMu.Unlock()
+	if len(messages) == 0 {
+		return nil
+	}
+	unlock := db.lock()
+	err := db.commit(batchMessage(messages))
+	unlock()
+	if err != nil || !push {
+		return err
+	}
+	db.commitBatch = 0
+	if err := db.Push(); err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
+
+func batchMessage(messages []string) string {
+	var unique []string
+	seen := map[string]bool{}
+	for _, msg := range messages {
+		if !seen[msg] {
+			seen[msg] = true
+			unique = append(unique, msg)
+		}
+	}
+	if len(unique) == 1 {
+		return unique[0]
+	}
+	return fmt.Sprintf("%d changes\n\n%s", len(messages), strings.Join(unique, "\n"))
+}
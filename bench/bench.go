@@ -0,0 +1,336 @@
+// Package bench benchmarks the main paths of gitdb, reading, writing,
+// upserting, committing, pushing and pulling collections of generated
+// records, and compares the results with a baseline to catch performance
+// regressions:
+//
+//	results, err := bench.Run(dir, 10000, 100000)
+//	regressions := bench.Compare(baseline, results, 0.2)
+//
+// Benchmarks run with testing.Benchmark, so they do not need go test; see
+// the gitdb-bench command.
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/caiguanhao/gitdb"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+)
+
+// Sizes are the numbers of records of the datasets benchmarked by default.
+var Sizes = []int{10000, 100000, 1000000}
+
+type (
+	// Record is a record of the generated datasets, about 200 bytes of
+	// JSON.
+	Record struct {
+		ID        int       `json:"id"`
+		Name      string    `json:"name"`
+		Email     string    `json:"email"`
+		Score     float64   `json:"score"`
+		Tags      []string  `json:"tags"`
+		Active    bool      `json:"active"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+
+	// Result is the result of a benchmark, named like "Write/10000".
+	Result struct {
+		Name        string `json:"name"`
+		Records     int    `json:"records"`
+		N           int    `json:"n"`
+		NsPerOp     int64  `json:"nsPerOp"`
+		BytesPerOp  int64  `json:"bytesPerOp"`
+		AllocsPerOp int64  `json:"allocsPerOp"`
+	}
+
+	// Regression is a benchmark slower than its baseline.
+	Regression struct {
+		Name     string
+		Baseline int64
+		Current  int64
+		// Change is the relative increase of the time per operation,
+		// 0.25 for 25% slower.
+		Change float64
+	}
+
+	benchmark struct {
+		name string
+		fn   func(b *testing.B, env *env)
+	}
+
+	// env is a local repository holding a dataset, a bare remote and
+	// another clone pushing changes for Pull to fetch.
+	env struct {
+		db, other *gitdb.DB
+		records   []Record
+		rand      *rand.Rand
+	}
+)
+
+var benchmarks = []benchmark{
+	{"Read", benchRead},
+	{"Write", benchWrite},
+	{"Upsert", benchUpsert},
+	{"Commit", benchCommit},
+	{"Push", benchPush},
+	{"Pull", benchPull},
+}
+
+var tags = []string{"alpha", "beta", "gamma", "delta", "epsilon", "zeta"}
+
+// Dataset returns n records, the same ones for the same n.
+func Dataset(n int) []Record {
+	rnd := rand.New(rand.NewSource(int64(n)))
+	created := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	records := make([]Record, n)
+	for i := range records {
+		name := fmt.Sprintf("user%08d", i)
+		records[i] = Record{
+			ID:        i + 1,
+			Name:      name,
+			Email:     name + "@example.com",
+			Score:     float64(rnd.Intn(100000)) / 100,
+			Tags:      []string{tags[rnd.Intn(len(tags))], tags[rnd.Intn(len(tags))]},
+			Active:    rnd.Intn(2) == 0,
+			CreatedAt: created.Add(time.Duration(rnd.Intn(1e6)) * time.Minute),
+		}
+	}
+	return records
+}
+
+// Run runs every benchmark for the datasets of sizes, or of Sizes if none
+// is given, in repositories created in dir, which must not exist or be
+// empty.
+func Run(dir string, sizes ...int) ([]Result, error) {
+	if len(sizes) == 0 {
+		sizes = Sizes
+	}
+	var results []Result
+	for _, n := range sizes {
+		env, err := newEnv(filepath.Join(dir, strconv.Itoa(n)), n)
+		if err != nil {
+			return nil, err
+		}
+		for _, bm := range benchmarks {
+			var failure error
+			res := testing.Benchmark(func(b *testing.B) {
+				b.ReportAllocs()
+				defer func() {
+					if r := recover(); r != nil {
+						failure = fmt.Errorf("%s/%d: %v", bm.name, n, r)
+						b.SkipNow()
+					}
+				}()
+				bm.fn(b, env)
+			})
+			if failure != nil {
+				return nil, failure
+			}
+			results = append(results, Result{
+				Name:        fmt.Sprintf("%s/%d", bm.name, n),
+				Records:     n,
+				N:           res.N,
+				NsPerOp:     res.NsPerOp(),
+				BytesPerOp:  res.AllocedBytesPerOp(),
+				AllocsPerOp: res.AllocsPerOp(),
+			})
+		}
+	}
+	return results, nil
+}
+
+// Compare returns the results at least tolerance slower than the ones of
+// baseline with the same name, 0.2 meaning 20% slower.
+func Compare(baseline, results []Result, tolerance float64) []Regression {
+	base := map[string]Result{}
+	for _, r := range baseline {
+		base[r.Name] = r
+	}
+	var regressions []Regression
+	for _, r := range results {
+		b, ok := base[r.Name]
+		if !ok || b.NsPerOp <= 0 {
+			continue
+		}
+		change := float64(r.NsPerOp)/float64(b.NsPerOp) - 1
+		if change >= tolerance {
+			regressions = append(regressions, Regression{
+				Name:     r.Name,
+				Baseline: b.NsPerOp,
+				Current:  r.NsPerOp,
+				Change:   change,
+			})
+		}
+	}
+	return regressions
+}
+
+func (r Regression) String() string {
+	return fmt.Sprintf("%s: %s/op, was %s/op (%+.1f%%)", r.Name,
+		time.Duration(r.Current), time.Duration(r.Baseline), r.Change*100)
+}
+
+// WriteResults writes results as JSON, to be read back by ReadResults as a
+// baseline.
+func WriteResults(w io.Writer, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(results)
+}
+
+// ReadResults reads results written by WriteResults.
+func ReadResults(r io.Reader) ([]Result, error) {
+	var results []Result
+	err := json.NewDecoder(r).Decode(&results)
+	return results, err
+}
+
+func newEnv(dir string, n int) (*env, error) {
+	remote := filepath.Join(dir, "remote.git")
+	if _, err := git.PlainInit(remote, true); err != nil {
+		return nil, err
+	}
+	local := filepath.Join(dir, "local")
+	r, err := git.PlainInit(local, false)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{remote},
+	})
+	if err != nil {
+		return nil, err
+	}
+	e := &env{
+		db:      gitdb.NewDB(remote, local),
+		records: Dataset(n),
+		rand:    rand.New(rand.NewSource(1)),
+	}
+	e.db.SetUser("bench", "bench@example.com")
+	if err := e.db.NewCollection("records.json").Write(e.records); err != nil {
+		return nil, err
+	}
+	if err := e.commit(e.db); err != nil {
+		return nil, err
+	}
+	if err := e.db.Push(); err != nil {
+		return nil, err
+	}
+	other := filepath.Join(dir, "other")
+	if _, err := git.PlainClone(other, false, &git.CloneOptions{URL: remote}); err != nil {
+		return nil, err
+	}
+	e.other = gitdb.NewDB(remote, other)
+	e.other.SetUser("other", "other@example.com")
+	return e, nil
+}
+
+// change changes a random record.
+func (e *env) change() {
+	e.records[e.rand.Intn(len(e.records))].Score = float64(e.rand.Intn(100000)) / 100
+}
+
+func (e *env) commit(db *gitdb.DB) error {
+	if err := db.Add("records.json"); err != nil {
+		return err
+	}
+	return db.Commit()
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func benchRead(b *testing.B, e *env) {
+	c := e.db.NewCollection("records.json")
+	for i := 0; i < b.N; i++ {
+		var records []Record
+		must(c.Read(&records))
+	}
+}
+
+func benchWrite(b *testing.B, e *env) {
+	c := e.db.NewCollection("records.json")
+	for i := 0; i < b.N; i++ {
+		e.change()
+		must(c.Write(e.records))
+	}
+}
+
+// benchUpsert upserts 1% of the records, at least one, and commits.
+func benchUpsert(b *testing.B, e *env) {
+	c := e.db.NewCollection("records.json")
+	batch := len(e.records) / 100
+	if batch < 1 {
+		batch = 1
+	}
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		changes := make([]Record, batch)
+		start := e.rand.Intn(len(e.records) - batch + 1)
+		copy(changes, e.records[start:start+batch])
+		for j := range changes {
+			changes[j].Score++
+		}
+		b.StartTimer()
+		_, err := c.Upsert(changes, "id", false)
+		must(err)
+	}
+	// keep the records in sync with the file for the next benchmarks
+	b.StopTimer()
+	e.records = nil
+	must(c.Read(&e.records))
+}
+
+func benchCommit(b *testing.B, e *env) {
+	c := e.db.NewCollection("records.json")
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		e.change()
+		must(c.Write(e.records))
+		b.StartTimer()
+		must(e.commit(e.db))
+	}
+}
+
+func benchPush(b *testing.B, e *env) {
+	c := e.db.NewCollection("records.json")
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		e.change()
+		must(c.Write(e.records))
+		must(e.commit(e.db))
+		b.StartTimer()
+		must(e.db.Push())
+	}
+}
+
+// benchPull pulls a change pushed from another clone.
+func benchPull(b *testing.B, e *env) {
+	b.StopTimer()
+	must(e.other.Pull())
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		c := e.other.NewCollection("records.json")
+		e.change()
+		must(c.Write(e.records))
+		must(e.commit(e.other))
+		must(e.other.Push())
+		b.StartTimer()
+		must(e.db.Pull())
+	}
+	b.StopTimer()
+	e.records = nil
+	must(e.db.NewCollection("records.json").Read(&e.records))
+}
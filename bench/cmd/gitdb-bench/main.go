@@ -0,0 +1,93 @@
+// Command gitdb-bench runs the benchmarks of package bench and fails when
+// they are slower than a baseline:
+//
+//	gitdb-bench -sizes 10000,100000 -out baseline.json
+//	gitdb-bench -sizes 10000,100000 -baseline baseline.json -tolerance 0.2
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/caiguanhao/gitdb/bench"
+)
+
+func main() {
+	sizes := flag.String("sizes", "10000,100000,1000000", "comma separated numbers of records")
+	dir := flag.String("dir", "", "directory of the repositories, a temporary one by default")
+	out := flag.String("out", "", "file to write the results to, as JSON")
+	baseline := flag.String("baseline", "", "file of results to compare with")
+	tolerance := flag.Float64("tolerance", 0.2, "slowdown from the baseline reported as a regression")
+	flag.Parse()
+
+	var ns []int
+	for _, s := range strings.Split(*sizes, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			log.Fatalln("bad size", s)
+		}
+		ns = append(ns, n)
+	}
+	if *dir == "" {
+		tmp, err := ioutil.TempDir("", "gitdb-bench")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer os.RemoveAll(tmp)
+		*dir = tmp
+	}
+
+	// gitdb logs every commit and fetch
+	log.SetOutput(ioutil.Discard)
+	results, err := bench.Run(*dir, ns...)
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "benchmark\tn\ttime/op\tB/op\tallocs/op\t")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%d\t\n", r.Name, r.N, time.Duration(r.NsPerOp), r.BytesPerOp, r.AllocsPerOp)
+	}
+	w.Flush()
+
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if err := bench.WriteResults(f, results); err != nil {
+			log.Fatalln(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	if *baseline != "" {
+		f, err := os.Open(*baseline)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		base, err := bench.ReadResults(f)
+		f.Close()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		regressions := bench.Compare(base, results, *tolerance)
+		for _, r := range regressions {
+			fmt.Println("regression:", r)
+		}
+		if len(regressions) > 0 {
+			os.Exit(1)
+		}
+	}
+}
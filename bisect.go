@@ -0,0 +1,137 @@
+package gitdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type (
+	// BisectResult is the commit found by Bisect.
+	BisectResult struct {
+		Commit  string
+		Author  string
+		When    time.Time
+		Message string
+		// Tested is the number of versions bad was called with.
+		Tested int
+	}
+)
+
+func (db DB) MustBisect(path string, dest interface{}, bad func(dest interface{}) bool) *BisectResult {
+	result, err := db.Bisect(path, dest, bad)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// Bisect finds the first commit where bad reports the content of the
+// collection or object at path as bad, like git bisect: the versions of
+// path at the commits changing it are decoded into dest, a pointer reset
+// before each of them, and searched with bad, which must hold for the
+// current version and then keep holding once it does. The options of the
+// collection are used if it was created with NewCollection.
+func (db DB) Bisect(path string, dest interface{}, bad func(dest interface{}) bool) (*BisectResult, error) {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, fmt.Errorf("Bisect: dest must be a non-nil pointer, got %T", dest)
+	}
+	c := db.findCollection(path)
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Head(); err != nil {
+		return nil, fmt.Errorf("Bisect: %v", err)
+	}
+	iter, err := r.Log(&git.LogOptions{
+		PathFilter: func(name string) bool {
+			return c.owns(filepath.FromSlash(name))
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var versions []*object.Commit
+	err = iter.ForEach(func(commit *object.Commit) error {
+		versions = append([]*object.Commit{commit}, versions...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("Bisect: no commits change %s", path)
+	}
+	result := &BisectResult{}
+	test := func(commit *object.Commit) (bool, error) {
+		result.Tested++
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+		if err := c.decodeCommit(commit, dest); err != nil {
+			return false, fmt.Errorf("Bisect: %s: %v", commit.Hash.String()[:7], err)
+		}
+		return bad(dest), nil
+	}
+	lo, hi := 0, len(versions)-1
+	if ok, err := test(versions[hi]); err != nil || !ok {
+		if err == nil {
+			err = fmt.Errorf("Bisect: %s is not bad at %s", path, versions[hi].Hash.String()[:7])
+		}
+		return nil, err
+	}
+	for lo < hi {
+		mid := (lo + hi) / 2
+		ok, err := test(versions[mid])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	commit := versions[lo]
+	result.Commit = commit.Hash.String()
+	result.Author = commit.Author.Name
+	result.When = commit.Author.When
+	result.Message = strings.SplitN(commit.Message, "\n", 2)[0]
+	return result, nil
+}
+
+// decodeCommit decodes the content of the collection at commit into dest,
+// which is left untouched if the collection does not exist there.
+func (c Collection) decodeCommit(commit *object.Commit, dest interface{}) error {
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	content, ok, err := treeFile(tree, c.Path)
+	if err != nil {
+		return err
+	}
+	var m chunkManifest
+	if c.RecordKeyField != "" || ok && json.Unmarshal(content, &m) == nil && len(m.Chunks) > 0 {
+		records, _, err := treeRecords(tree, &c)
+		if err != nil {
+			return err
+		}
+		if content, err = json.Marshal(records); err != nil {
+			return err
+		}
+	} else if !ok {
+		return nil
+	}
+	opts := c.jsonOptions()
+	return opts.decode(dest, func(dest interface{}) error {
+		return opts.newDecoder(bytes.NewReader(content)).Decode(dest)
+	})
+}
@@ -0,0 +1,114 @@
+package gitdb
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+func (db DB) MustRemoteBranches(ctx context.Context) []string {
+	branches, err := db.RemoteBranches(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return branches
+}
+
+// RemoteBranches returns the names of the branches of the remote, sorted,
+// like git ls-remote --heads, without cloning it.
+func (db DB) RemoteBranches(ctx context.Context) ([]string, error) {
+	refs, err := db.listRemote(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("RemoteBranches: %w", err)
+	}
+	var branches []string
+	for _, ref := range refs {
+		if ref.Name().IsBranch() {
+			branches = append(branches, ref.Name().Short())
+		}
+	}
+	sort.Strings(branches)
+	return branches, nil
+}
+
+func (db DB) MustRemoteDefaultBranch(ctx context.Context) string {
+	branch, err := db.RemoteDefaultBranch(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return branch
+}
+
+// RemoteDefaultBranch returns the name of the branch HEAD of the remote
+// points to. For servers not advertising it, it returns the branch at the
+// same commit as HEAD, preferring "main" and "master".
+func (db DB) RemoteDefaultBranch(ctx context.Context) (string, error) {
+	refs, err := db.listRemote(ctx)
+	if err != nil {
+		return "", fmt.Errorf("RemoteDefaultBranch: %w", err)
+	}
+	var head *plumbing.Reference
+	for _, ref := range refs {
+		if ref.Name() == plumbing.HEAD {
+			head = ref
+		}
+	}
+	if head == nil {
+		return "", fmt.Errorf("RemoteDefaultBranch: remote has no HEAD")
+	}
+	if head.Type() == plumbing.SymbolicReference {
+		return head.Target().Short(), nil
+	}
+	var candidates []string
+	for _, ref := range refs {
+		if ref.Name().IsBranch() && ref.Hash() == head.Hash() {
+			candidates = append(candidates, ref.Name().Short())
+		}
+	}
+	sort.Strings(candidates)
+	for _, name := range []string{"main", "master"} {
+		for _, c := range candidates {
+			if c == name {
+				return c, nil
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("RemoteDefaultBranch: no branch at HEAD %s", head.Hash().String()[:7])
+	}
+	return candidates[0], nil
+}
+
+// listRemote returns the references of Remote, or of the remote of the
+// local repository if Remote is empty.
+func (db DB) listRemote(ctx context.Context) ([]*plumbing.Reference, error) {
+	if err := db.checkRemotes(nil); err != nil {
+		return nil, err
+	}
+	var remote *git.Remote
+	if db.Remote != "" {
+		remote = git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+			Name: db.GetRemoteName(),
+			URLs: []string{db.Remote},
+		})
+	} else {
+		r, err := git.PlainOpen(db.Local)
+		if err != nil {
+			return nil, err
+		}
+		if err := db.checkRemotes(r); err != nil {
+			return nil, err
+		}
+		if remote, err = r.Remote(db.GetRemoteName()); err != nil {
+			return nil, err
+		}
+	}
+	return remote.ListContext(ctx, &git.ListOptions{
+		Auth: db.authMethod(),
+	})
+}
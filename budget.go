@@ -0,0 +1,114 @@
+package gitdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+var ErrTooLarge = errors.New("too large")
+
+type (
+	// SizeViolation describes a file, or the record at Index of a
+	// collection if Index is not -1, that exceeds its size limit.
+	SizeViolation struct {
+		Path  string
+		Index int
+		Size  int64
+		Limit int64
+	}
+)
+
+func (v SizeViolation) Error() string {
+	if v.Index < 0 {
+		return fmt.Sprintf("%s: file is %d bytes, limit is %d: %s", v.Path, v.Size, v.Limit, ErrTooLarge)
+	}
+	return fmt.Sprintf("%s[%d]: record is %d bytes, limit is %d: %s", v.Path, v.Index, v.Size, v.Limit, ErrTooLarge)
+}
+
+func (v SizeViolation) Is(target error) bool {
+	return target == ErrTooLarge
+}
+
+// checkFileSize returns a SizeViolation if size exceeds limit, or
+// db.MaxFileSize if limit is not set, and warns when it comes within 10%
+// of it.
+func (db DB) checkFileSize(path string, size int, limit int64) error {
+	if limit <= 0 {
+		limit = db.MaxFileSize
+	}
+	if limit <= 0 {
+		return nil
+	}
+	if int64(size) > limit {
+		return &SizeViolation{Path: path, Index: -1, Size: int64(size), Limit: limit}
+	}
+	if int64(size) > limit-limit/10 {
+		log.Println("warning:", path, "is", size, "bytes, close to the limit of", limit)
+	}
+	return nil
+}
+
+func (db DB) MustCheckBudgets() []SizeViolation {
+	violations, err := db.CheckBudgets()
+	if err != nil {
+		panic(err)
+	}
+	return violations
+}
+
+// CheckBudgets reports the files of HEAD over their size limit and the
+// records of collections created by NewCollection over MaxRecordSize.
+func (db DB) CheckBudgets() ([]SizeViolation, error) {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := headTree(r)
+	if err != nil {
+		return nil, err
+	}
+	collections := db.state().managedCollections()
+	var violations []SizeViolation
+	if tree != nil {
+		err = tree.Files().ForEach(func(f *object.File) error {
+			limit := db.MaxFileSize
+			for _, c := range collections {
+				if c.MaxFileSize > 0 && (f.Name == filepath.ToSlash(c.Path) || isChunkOf(f.Name, c.Path) || c.RecordKeyField != "" && strings.HasPrefix(f.Name, filepath.ToSlash(c.Path)+"/")) {
+					limit = c.MaxFileSize
+				}
+			}
+			if limit > 0 && f.Size > limit {
+				violations = append(violations, SizeViolation{Path: f.Name, Index: -1, Size: f.Size, Limit: limit})
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range collections {
+		if c.MaxRecordSize <= 0 {
+			continue
+		}
+		var records []json.RawMessage
+		if err := readCollection(filepath.Join(db.Local, c.Path), &records); err != nil {
+			return nil, err
+		}
+		for i, record := range records {
+			if string(record) == "null" {
+				continue
+			}
+			if int64(len(record)) > c.MaxRecordSize {
+				violations = append(violations, SizeViolation{Path: c.Path, Index: i, Size: int64(len(record)), Limit: c.MaxRecordSize})
+			}
+		}
+	}
+	return violations, nil
+}
@@ -0,0 +1,124 @@
+package gitdb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// maxPooledBuffer is the capacity above which buffers are dropped instead
+// of kept for reuse, so one huge write does not pin its memory.
+const maxPooledBuffer = 8 << 20
+
+type (
+	// BufferStats counts the serialization buffers taken from the pool
+	// shared by every DB of the process, how many of them were reused and
+	// how many were too large to be kept, and the memory reserved by the
+	// writes in progress in the repository and how many writes waited for
+	// the limit set by SetWriteMemoryLimit.
+	BufferStats struct {
+		Gets      int64
+		Reused    int64
+		Discarded int64
+
+		MemoryInUse int64
+		MemoryWaits int64
+	}
+
+	writeMemory struct {
+		mu    sync.Mutex
+		cond  *sync.Cond
+		used  int64
+		waits int64
+	}
+)
+
+var (
+	bufferPool = sync.Pool{
+		New: func() interface{} {
+			atomic.AddInt64(&bufferAllocs, 1)
+			return new(bytes.Buffer)
+		},
+	}
+	bufferGets, bufferAllocs, bufferDiscards int64
+)
+
+func getBuffer() *bytes.Buffer {
+	atomic.AddInt64(&bufferGets, 1)
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer returns w to the pool. w must not be used after.
+func putBuffer(w *bytes.Buffer) {
+	if w.Cap() > maxPooledBuffer {
+		atomic.AddInt64(&bufferDiscards, 1)
+		return
+	}
+	w.Reset()
+	bufferPool.Put(w)
+}
+
+// SetWriteMemoryLimit limits the memory, in bytes, that the Writes of the
+// collections and objects of the repository serialize into at the same
+// time, so a burst of large writes waits instead of ballooning the memory
+// of the process. Each write reserves the current size of its files, the
+// best guess of the size of their new content, and one write larger than
+// the limit runs alone. Zero means no limit.
+func (db *DB) SetWriteMemoryLimit(n int64) {
+	db.writeMemoryLimit = n
+}
+
+// reserveMemory waits until n bytes fit in the write memory limit and
+// reserves them, returning the func releasing them.
+func (db DB) reserveMemory(n int64) func() {
+	m := &db.state().memory
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cond == nil {
+		m.cond = sync.NewCond(&m.mu)
+	}
+	if limit := db.writeMemoryLimit; limit > 0 && m.used > 0 && m.used+n > limit {
+		m.waits++
+		for m.used > 0 && m.used+n > limit {
+			m.cond.Wait()
+		}
+	}
+	m.used += n
+	return func() {
+		m.mu.Lock()
+		m.used -= n
+		m.mu.Unlock()
+		m.cond.Broadcast()
+	}
+}
+
+// reserveFiles reserves the size of the files of the collection or object
+// at path.
+func (db DB) reserveFiles(path string) func() {
+	if db.writeMemoryLimit <= 0 {
+		return func() {}
+	}
+	files, _, _ := dataFiles(filepath.Join(db.Local, path))
+	var size int64
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			size += info.Size()
+		}
+	}
+	return db.reserveMemory(size)
+}
+
+func (s *repoState) bufferStats() BufferStats {
+	gets := atomic.LoadInt64(&bufferGets)
+	stats := BufferStats{
+		Gets:      gets,
+		Reused:    gets - atomic.LoadInt64(&bufferAllocs),
+		Discarded: atomic.LoadInt64(&bufferDiscards),
+	}
+	s.memory.mu.Lock()
+	stats.MemoryInUse, stats.MemoryWaits = s.memory.used, s.memory.waits
+	s.memory.mu.Unlock()
+	return stats
+}
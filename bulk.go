@@ -0,0 +1,261 @@
+package gitdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+type (
+	// RecordIterator returns the records to import one by one, then
+	// io.EOF.
+	RecordIterator interface {
+		Next() (interface{}, error)
+	}
+
+	// RecordIteratorFunc is a function used as a RecordIterator.
+	RecordIteratorFunc func() (interface{}, error)
+
+	// RecordSkipper is implemented by iterators that can skip the records
+	// already imported when an import resumes, instead of having them read
+	// and dropped.
+	RecordSkipper interface {
+		Skip(n int64) error
+	}
+
+	BulkImportOptions struct {
+		// BatchSize is the number of records written at a time, 10000 by
+		// default.
+		BatchSize int
+
+		// CommitEvery is the number of batches between commits, 1 by
+		// default.
+		CommitEvery int
+
+		// Checkpoint is the path of the file recording the progress of
+		// the import, by default inside the .git directory.
+		Checkpoint string
+
+		// Progress, if set, is called after every batch.
+		Progress func(BulkImportProgress)
+	}
+
+	BulkImportProgress struct {
+		// Records is the number of records imported, including the
+		// ones of an interrupted import that was resumed.
+		Records int64
+		Chunks  int
+		Commits int
+		Done    bool
+	}
+
+	bulkCheckpoint struct {
+		Records int64    `json:"records"`
+		Chunks  []string `json:"chunks"`
+		Count   int      `json:"count"`
+	}
+)
+
+func (f RecordIteratorFunc) Next() (interface{}, error) {
+	return f()
+}
+
+func (c Collection) MustBulkImport(records RecordIterator, opts BulkImportOptions) BulkImportProgress {
+	progress, err := c.BulkImport(records, opts)
+	if err != nil {
+		panic(err)
+	}
+	return progress
+}
+
+// BulkImport appends the records of the iterator to the chunked collection
+// c in batches, writing new chunk files without reading the existing ones,
+// and commits every CommitEvery batches. After each commit, the progress
+// is saved to a checkpoint file, so that calling BulkImport again with an
+// iterator starting from the beginning resumes after the last committed
+// record. The checkpoint is removed once the import is complete.
+func (c Collection) BulkImport(records RecordIterator, opts BulkImportOptions) (progress BulkImportProgress, err error) {
+	if c.ChunkSize <= 0 {
+		return progress, fmt.Errorf("BulkImport: %s has no ChunkSize", c.Path)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 10000
+	}
+	if opts.CommitEvery <= 0 {
+		opts.CommitEvery = 1
+	}
+	if opts.Checkpoint == "" {
+		opts.Checkpoint = filepath.Join(gitDir(c.db.Local), "gitdb", "imports", url.PathEscape(filepath.ToSlash(c.Path))+".json")
+	}
+
+	state, err := c.startImport(opts.Checkpoint)
+	if err != nil {
+		return
+	}
+	progress.Records, progress.Chunks = state.Records, len(state.Chunks)
+	if state.Records > 0 {
+		if skipper, ok := records.(RecordSkipper); ok {
+			err = skipper.Skip(state.Records)
+		} else {
+			for i := int64(0); i < state.Records && err == nil; i++ {
+				_, err = records.Next()
+			}
+		}
+		if err != nil {
+			return progress, fmt.Errorf("BulkImport: resuming after %d records: %v", state.Records, err)
+		}
+	}
+
+	committed := state.Records
+	for batches := 1; !progress.Done; batches++ {
+		var batch []json.RawMessage
+		for len(batch) < opts.BatchSize {
+			record, e := records.Next()
+			if e == io.EOF {
+				progress.Done = true
+				break
+			}
+			if e != nil {
+				return progress, e
+			}
+			record = c.prepare(record)
+			if err = c.jsonOptions().check(record); err != nil {
+				return progress, fmt.Errorf("BulkImport: %v", err)
+			}
+			batch = append(batch, marshalRecord(record, c.jsonOptions()))
+		}
+		if len(batch) > 0 || progress.Done && progress.Records > committed {
+			if err = c.importBatch(&state, batch); err != nil {
+				return
+			}
+			progress.Records, progress.Chunks = state.Records, len(state.Chunks)
+			if progress.Done || batches%opts.CommitEvery == 0 {
+				if err = c.commitImport(state, opts.Checkpoint, progress.Records-committed, progress.Done); err != nil {
+					return
+				}
+				committed = progress.Records
+				progress.Commits++
+			}
+		}
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+	return progress, nil
+}
+
+// startImport returns the state saved by an interrupted import, or the
+// current chunks of the collection. The records of a collection written as
+// a single file are moved into chunks first.
+func (c Collection) startImport(checkpoint string) (bulkCheckpoint, error) {
+	var state bulkCheckpoint
+	if b, err := ioutil.ReadFile(checkpoint); err == nil {
+		return state, json.Unmarshal(b, &state)
+	} else if !os.IsNotExist(err) {
+		return state, err
+	}
+	path := filepath.Join(c.db.Local, c.Path)
+	m, err := readManifest(path)
+	if err != nil {
+		return state, err
+	}
+	if m == nil {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return state, nil
+		}
+		var records []json.RawMessage
+		if err := readJson(path, &records); err != nil {
+			return state, err
+		}
+		err := c.importBatch(&state, withoutNulls(records))
+		state.Records = 0
+		return state, err
+	}
+	state.Chunks, state.Count = m.Chunks, m.Count
+	return state, nil
+}
+
+func withoutNulls(records []json.RawMessage) []json.RawMessage {
+	var kept []json.RawMessage
+	for _, record := range records {
+		if string(record) != "null" {
+			kept = append(kept, record)
+		}
+	}
+	return kept
+}
+
+// importBatch writes batch as new chunks and updates the manifest.
+func (c Collection) importBatch(state *bulkCheckpoint, batch []json.RawMessage) error {
+	defer c.lock()()
+	var start int
+	var size int64
+	for i := 0; i <= len(batch); i++ {
+		if i < len(batch) && (i == start || size+int64(len(batch[i]))+2 <= c.ChunkSize) {
+			size += int64(len(batch[i])) + 2
+			continue
+		}
+		if i == start {
+			break
+		}
+		name := chunkName(c.Path, len(state.Chunks)+1)
+		w := writeWith(c.JSONPCallbackName, c.jsonOptions(), batch[start:i])
+		if err := c.db.checkFileSize(name, w.Len(), c.MaxFileSize); err != nil {
+			return err
+		}
+		if err := c.db.journal("write", name); err != nil {
+			return err
+		}
+		if err := writeFile(filepath.Join(c.db.Local, name), w); err != nil {
+			return err
+		}
+		state.Chunks = append(state.Chunks, filepath.Base(name))
+		if i < len(batch) {
+			start, size = i, int64(len(batch[i]))+2
+		}
+	}
+	state.Records += int64(len(batch))
+	state.Count += len(batch)
+	if len(state.Chunks) == 0 {
+		return nil
+	}
+	if err := c.db.journal("write", c.Path); err != nil {
+		return err
+	}
+	manifest := chunkManifest{Chunks: state.Chunks, Count: state.Count}
+	return writeFile(filepath.Join(c.db.Local, c.Path), writeWith(c.JSONPCallbackName, c.jsonOptions(), manifest))
+}
+
+// commitImport commits the chunks written so far and saves the checkpoint,
+// or removes it when the import is done.
+func (c Collection) commitImport(state bulkCheckpoint, checkpoint string, records int64, done bool) error {
+	defer c.lock()()
+	if err := c.db.Add(c.Path); err != nil {
+		return err
+	}
+	err := c.db.commit(c.db.formatMessage(CommitInfo{
+		Op:      "import",
+		Paths:   []string{c.Path},
+		Records: int(records),
+		Message: fmt.Sprintf("import %s: %d records", c.Path, records),
+	}))
+	if err != nil {
+		return err
+	}
+	if done {
+		if err := os.Remove(checkpoint); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(checkpoint), 0755)
+	return ioutil.WriteFile(checkpoint, b, 0644)
+}
@@ -0,0 +1,187 @@
+package gitdb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type (
+	// Cached keeps the decoded content of an Object in memory, so that hot
+	// configuration can be read without touching the disk. It is refreshed
+	// after every ForceUpdate, Pull or ReadFresh that updates its DB, after
+	// Set and at every refresh interval.
+	Cached[T any] struct {
+		object Object
+		stop   chan struct{}
+
+		refreshMu sync.Mutex
+		raw       []byte
+
+		mu       sync.RWMutex
+		value    T
+		handlers []func(T)
+	}
+
+	// refresher is a Cached of any type, refreshed by refreshCaches.
+	refresher interface {
+		Refresh() error
+	}
+)
+
+func MustNewCached[T any](object *Object, refreshInterval time.Duration) *Cached[T] {
+	c, err := NewCached[T](object, refreshInterval)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// NewCached reads object into a new T, such as a Config struct, and keeps
+// it in memory until Stop is called:
+//
+//	config, err := gitdb.NewCached[Config](db.NewObject("config.json"), time.Minute)
+//
+// If refreshInterval is not zero, the file is also checked for changes at
+// that interval, which catches writes made by other DB values or
+// processes.
+func NewCached[T any](object *Object, refreshInterval time.Duration) (*Cached[T], error) {
+	c := &Cached[T]{
+		object: *object,
+		stop:   make(chan struct{}),
+	}
+	if err := c.Refresh(); err != nil {
+		return nil, err
+	}
+	object.db.state().addCache(c)
+	if refreshInterval > 0 {
+		go c.refreshEvery(refreshInterval)
+	}
+	return c, nil
+}
+
+// Get returns the cached value. Values such as maps and slices inside it
+// are shared and must not be modified.
+func (c *Cached[T]) Get() T {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.value
+}
+
+// OnChange registers fn to be called with the new value every time a
+// refresh finds the content of the object changed.
+func (c *Cached[T]) OnChange(fn func(T)) {
+	c.mu.Lock()
+	c.handlers = append(c.handlers, fn)
+	c.mu.Unlock()
+}
+
+func (c *Cached[T]) MustSet(value T) {
+	if err := c.Set(value); err != nil {
+		panic(err)
+	}
+}
+
+// Set writes value to the object and refreshes the cache. Like
+// Object.Write, the change still has to be committed.
+func (c *Cached[T]) Set(value T) error {
+	if err := c.object.Write(value); err != nil {
+		return err
+	}
+	return c.Refresh()
+}
+
+// Refresh reads the object again if its file has changed, and calls the
+// OnChange functions if so. A missing file gives the zero value.
+func (c *Cached[T]) Refresh() error {
+	handlers, value, err := c.refresh()
+	for _, fn := range handlers {
+		fn(value)
+	}
+	return err
+}
+
+// refresh updates the value and returns the OnChange functions to call
+// with it, if it has changed since the last refresh.
+func (c *Cached[T]) refresh() ([]func(T), T, error) {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+	var value T
+	raw, err := ioutil.ReadFile(filepath.Join(c.object.db.Local, c.object.Path))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, value, err
+	}
+	if raw == nil {
+		raw = []byte{}
+	}
+	if c.raw != nil && bytes.Equal(raw, c.raw) {
+		return nil, value, nil
+	}
+	if len(raw) > 0 {
+		if err := c.object.Read(&value); err != nil {
+			return nil, value, err
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var handlers []func(T)
+	if c.raw != nil {
+		handlers = append(handlers, c.handlers...)
+	}
+	c.raw, c.value = raw, value
+	return handlers, value, nil
+}
+
+// Stop stops refreshing the cache. Get keeps returning the last value.
+func (c *Cached[T]) Stop() {
+	c.object.db.state().removeCache(c)
+	select {
+	case <-c.stop:
+	default:
+		close(c.stop)
+	}
+}
+
+func (c *Cached[T]) refreshEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+			c.Refresh()
+		}
+	}
+}
+
+func (s *repoState) addCache(c refresher) {
+	s.registry.Lock()
+	s.caches = append(s.caches, c)
+	s.registry.Unlock()
+}
+
+func (s *repoState) removeCache(c refresher) {
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	for i, existing := range s.caches {
+		if existing == c {
+			s.caches = append(s.caches[:i], s.caches[i+1:]...)
+			return
+		}
+	}
+}
+
+// refreshCaches refreshes the Cached objects of the repository after its
+// worktree has been updated from the remote.
+func (s *repoState) refreshCaches() {
+	s.registry.Lock()
+	caches := append([]refresher(nil), s.caches...)
+	s.registry.Unlock()
+	for _, c := range caches {
+		c.Refresh()
+	}
+}
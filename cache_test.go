@@ -0,0 +1,50 @@
+package gitdb
+
+import (
+	"testing"
+)
+
+type cachedConfig struct {
+	Name    string `json:"name"`
+	Retries int    `json:"retries"`
+}
+
+func TestCached(t *testing.T) {
+	db := newTestDB(t)
+	o := db.NewObject("config.json")
+	config, err := NewCached[cachedConfig](o, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer config.Stop()
+	if got := config.Get(); got != (cachedConfig{}) {
+		t.Errorf("got %+v before the file exists, want the zero value", got)
+	}
+
+	var changes []cachedConfig
+	config.OnChange(func(c cachedConfig) {
+		changes = append(changes, c)
+	})
+	if err := config.Set(cachedConfig{Name: "a", Retries: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Get(); got.Name != "a" || got.Retries != 3 {
+		t.Errorf("got %+v after Set", got)
+	}
+
+	if err := o.Write(cachedConfig{Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Get(); got.Name != "b" {
+		t.Errorf("got %+v after a write", got)
+	}
+	if len(changes) != 2 || changes[0].Name != "a" || changes[1].Name != "b" {
+		t.Errorf("OnChange got %+v, want a then b", changes)
+	}
+}
@@ -0,0 +1,352 @@
+package gitdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type (
+	// Changelog lists the record changes of the collections between two
+	// commits.
+	Changelog struct {
+		From, To    string
+		Collections []CollectionChanges
+	}
+
+	// CollectionChanges are the records added, removed and modified in a
+	// collection. Records are matched by the RecordKeyField of the
+	// collection, or by their id field; without one, a modified record
+	// shows up as removed and added.
+	CollectionChanges struct {
+		Path     string
+		KeyField string
+		Added    []json.RawMessage
+		Removed  []json.RawMessage
+		Modified []RecordChange
+	}
+
+	RecordChange struct {
+		Key    string
+		Before json.RawMessage
+		After  json.RawMessage
+	}
+)
+
+// changelogKeyFields are the fields used to match records of collections
+// without a RecordKeyField.
+var changelogKeyFields = []string{"id", "ID", "Id", "key"}
+
+func (db DB) MustChangelog(fromRef, toRef string) *Changelog {
+	changelog, err := db.Changelog(fromRef, toRef)
+	if err != nil {
+		panic(err)
+	}
+	return changelog
+}
+
+// Changelog compares the collections at the revisions fromRef and toRef,
+// like "v1.0.0" and "HEAD", and returns their record changes. Every
+// changed file holding an array of records is compared, along with the
+// chunks and record files of the collections created by NewCollection.
+func (db DB) Changelog(fromRef, toRef string) (*Changelog, error) {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return nil, err
+	}
+	from, err := revisionTree(r, fromRef)
+	if err != nil {
+		return nil, fmt.Errorf("Changelog: %s: %v", fromRef, err)
+	}
+	to, err := revisionTree(r, toRef)
+	if err != nil {
+		return nil, fmt.Errorf("Changelog: %s: %v", toRef, err)
+	}
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, err
+	}
+	collections := db.Collections()
+	paths := map[string]*Collection{}
+	for _, change := range changes {
+		name := change.To.Name
+		if name == "" {
+			name = change.From.Name
+		}
+		owner := &Collection{db: &db, Path: name}
+		for _, c := range collections {
+			if c.owns(name) {
+				owner = c
+				break
+			}
+		}
+		if owner.RecordKeyField == "" && path.Ext(owner.Path) != ".json" {
+			continue
+		}
+		paths[path.Clean(owner.Path)] = owner
+	}
+
+	changelog := &Changelog{From: fromRef, To: toRef}
+	for _, c := range paths {
+		before, ok, err := treeRecords(from, c)
+		if err != nil {
+			return nil, err
+		}
+		after, ok2, err := treeRecords(to, c)
+		if err != nil {
+			return nil, err
+		}
+		if !ok && !ok2 {
+			// not a collection
+			continue
+		}
+		cc := compareRecords(before, after, c.RecordKeyField)
+		cc.Path = c.Path
+		if len(cc.Added)+len(cc.Removed)+len(cc.Modified) > 0 {
+			changelog.Collections = append(changelog.Collections, cc)
+		}
+	}
+	sort.Slice(changelog.Collections, func(i, j int) bool {
+		return changelog.Collections[i].Path < changelog.Collections[j].Path
+	})
+	return changelog, nil
+}
+
+func revisionTree(r *git.Repository, rev string) (*object.Tree, error) {
+	hash, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, err
+	}
+	commit, err := r.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// treeRecords returns the records of c in tree, normalized, and whether c
+// is a collection there. A missing collection has no records.
+func treeRecords(tree *object.Tree, c *Collection) ([]json.RawMessage, bool, error) {
+	name := filepath.ToSlash(c.Path)
+	var records []json.RawMessage
+	if c.RecordKeyField != "" {
+		sub, err := tree.Tree(name)
+		if err == object.ErrDirectoryNotFound {
+			return nil, true, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		err = sub.Files().ForEach(func(f *object.File) error {
+			content, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			records = append(records, json.RawMessage(jsonpContent([]byte(content))))
+			return nil
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		records, err = normalizeRecords(records)
+		return records, true, err
+	}
+	content, ok, err := treeFile(tree, name)
+	if err != nil || !ok {
+		return nil, !ok, err
+	}
+	var m chunkManifest
+	if json.Unmarshal(content, &m) == nil && len(m.Chunks) > 0 {
+		for _, chunk := range m.Chunks {
+			part, _, err := treeFile(tree, path.Join(path.Dir(name), chunk))
+			if err != nil {
+				return nil, false, err
+			}
+			var partRecords []json.RawMessage
+			if err := json.Unmarshal(part, &partRecords); err != nil {
+				return nil, false, fmt.Errorf("%s: %v", chunk, err)
+			}
+			records = append(records, partRecords...)
+		}
+	} else if json.Unmarshal(content, &records) != nil {
+		return nil, false, nil
+	}
+	records, err = normalizeRecords(records)
+	return records, true, err
+}
+
+// treeFile returns the content of the file at name in tree, without its
+// JSONP callback, and whether it exists. Git trees name files with
+// slashes, whatever the system.
+func treeFile(tree *object.Tree, name string) ([]byte, bool, error) {
+	f, err := tree.File(filepath.ToSlash(name))
+	if err == object.ErrFileNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return nil, false, err
+	}
+	return jsonpContent([]byte(content)), true, nil
+}
+
+// jsonpContent returns the JSON inside the JSONP callback of content, if
+// any, like openJson.
+func jsonpContent(content []byte) []byte {
+	a := bytes.IndexAny(content, "[{")
+	x := bytes.IndexByte(content, '(')
+	if x < 0 || a < 0 || x > a {
+		return content
+	}
+	b := bytes.LastIndexAny(content, "}]")
+	y := bytes.LastIndexByte(content, ')')
+	if y > b && y > x {
+		return content[x+1 : y]
+	}
+	return content[x+1:]
+}
+
+// normalizeRecords drops null records and re-encodes the others with
+// sorted keys, so equal records have equal encodings.
+func normalizeRecords(records []json.RawMessage) ([]json.RawMessage, error) {
+	var normalized []json.RawMessage
+	for _, record := range records {
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(record))
+		dec.UseNumber()
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		if v == nil {
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, b)
+	}
+	return normalized, nil
+}
+
+func compareRecords(before, after []json.RawMessage, keyField string) CollectionChanges {
+	if keyField == "" {
+		keyField = recordsKeyField(append(append([]json.RawMessage(nil), before...), after...))
+	}
+	cc := CollectionChanges{KeyField: keyField}
+	if keyField == "" {
+		counts := map[string]int{}
+		for _, record := range before {
+			counts[string(record)]++
+		}
+		for _, record := range after {
+			if counts[string(record)] > 0 {
+				counts[string(record)]--
+			} else {
+				cc.Added = append(cc.Added, record)
+			}
+		}
+		for _, record := range before {
+			if counts[string(record)] > 0 {
+				counts[string(record)]--
+				cc.Removed = append(cc.Removed, record)
+			}
+		}
+		return cc
+	}
+	old := map[string]json.RawMessage{}
+	for _, record := range before {
+		old[recordKey(record, keyField)] = record
+	}
+	seen := map[string]bool{}
+	for _, record := range after {
+		key := recordKey(record, keyField)
+		seen[key] = true
+		prev, ok := old[key]
+		if !ok {
+			cc.Added = append(cc.Added, record)
+		} else if !bytes.Equal(prev, record) {
+			cc.Modified = append(cc.Modified, RecordChange{Key: key, Before: prev, After: record})
+		}
+	}
+	for _, record := range before {
+		if !seen[recordKey(record, keyField)] {
+			cc.Removed = append(cc.Removed, record)
+		}
+	}
+	return cc
+}
+
+// recordsKeyField returns the first of changelogKeyFields every record
+// has.
+func recordsKeyField(records []json.RawMessage) string {
+	if len(records) == 0 {
+		return ""
+	}
+	for _, field := range changelogKeyFields {
+		all := true
+		for _, record := range records {
+			var m map[string]json.RawMessage
+			if json.Unmarshal(record, &m) != nil || m[field] == nil {
+				all = false
+				break
+			}
+		}
+		if all {
+			return field
+		}
+	}
+	return ""
+}
+
+func recordKey(record json.RawMessage, keyField string) string {
+	var m map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(record))
+	dec.UseNumber()
+	if dec.Decode(&m) != nil {
+		return ""
+	}
+	return fmt.Sprint(m[keyField])
+}
+
+// Markdown returns the changelog as a Markdown document.
+func (c Changelog) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Changes from %s to %s\n", c.From, c.To)
+	if len(c.Collections) == 0 {
+		b.WriteString("\nNo changes.\n")
+	}
+	for _, cc := range c.Collections {
+		fmt.Fprintf(&b, "\n## %s\n\n%d added, %d removed, %d modified\n", cc.Path, len(cc.Added), len(cc.Removed), len(cc.Modified))
+		if len(cc.Added) > 0 {
+			b.WriteString("\n### Added\n\n")
+			for _, record := range cc.Added {
+				fmt.Fprintf(&b, "- `%s`\n", record)
+			}
+		}
+		if len(cc.Removed) > 0 {
+			b.WriteString("\n### Removed\n\n")
+			for _, record := range cc.Removed {
+				fmt.Fprintf(&b, "- `%s`\n", record)
+			}
+		}
+		if len(cc.Modified) > 0 {
+			b.WriteString("\n### Modified\n\n")
+			for _, change := range cc.Modified {
+				fmt.Fprintf(&b, "- %s %s: `%s` → `%s`\n", cc.KeyField, change.Key, change.Before, change.After)
+			}
+		}
+	}
+	return b.String()
+}
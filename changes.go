@@ -0,0 +1,92 @@
+package gitdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func (db DB) MustChangesSince(commit string) []FileChange {
+	changes, err := db.ChangesSince(commit)
+	if err != nil {
+		panic(err)
+	}
+	return changes
+}
+
+// ChangesSince returns the files changed from the revision commit, like
+// the hash of the commit a downstream service last synced to, to HEAD,
+// sorted by path, so the service can apply them instead of downloading
+// every file again. The changes of files holding an array of records, or
+// a record of a collection with RecordKeyField, come with their record
+// changes in Records, matched like in Changelog. Records moving between
+// the chunks of a chunked collection show up as removed from one chunk
+// and added to the other.
+func (db DB) ChangesSince(commit string) ([]FileChange, error) {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return nil, err
+	}
+	from, err := revisionTree(r, commit)
+	if err != nil {
+		return nil, fmt.Errorf("ChangesSince: %s: %w", commit, err)
+	}
+	to, err := headTree(r)
+	if err != nil {
+		return nil, fmt.Errorf("ChangesSince: HEAD: %w", err)
+	}
+	changes, err := treeChanges(from, to)
+	if err != nil {
+		return nil, err
+	}
+	collections := db.Collections()
+	for i := range changes {
+		var keyField string
+		single := false
+		for _, c := range collections {
+			if c.owns(changes[i].Path) {
+				keyField = c.RecordKeyField
+				single = keyField != "" && filepath.Ext(changes[i].Path) == recordFileExt &&
+					changes[i].Path != filepath.ToSlash(filepath.Clean(c.Path))
+				break
+			}
+		}
+		before, ok := fileRecords(changes[i].Before, single)
+		after, ok2 := fileRecords(changes[i].After, single)
+		if !ok || !ok2 {
+			continue
+		}
+		cc := compareRecords(before, after, keyField)
+		cc.Path = changes[i].Path
+		changes[i].Records = &cc
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+// fileRecords returns the normalized records of content, an array of
+// records, or a single record if single is true, and false if it holds
+// something else. Missing files have no records.
+func fileRecords(content []byte, single bool) ([]json.RawMessage, bool) {
+	if content == nil {
+		return nil, true
+	}
+	content = jsonpContent(content)
+	var records []json.RawMessage
+	if single {
+		var record map[string]json.RawMessage
+		if json.Unmarshal(content, &record) != nil {
+			return nil, false
+		}
+		records = []json.RawMessage{content}
+	} else if json.Unmarshal(content, &records) != nil {
+		return nil, false
+	}
+	records, err := normalizeRecords(records)
+	return records, err == nil
+}
@@ -0,0 +1,224 @@
+package gitdb
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+type (
+	// ChecksumMismatch is a managed file whose content does not match the
+	// checksum manifest. Actual is empty if the file is missing, Expected
+	// if the manifest does not list it.
+	ChecksumMismatch struct {
+		Path     string
+		Expected string
+		Actual   string
+	}
+
+	ChecksumMismatches []ChecksumMismatch
+)
+
+// SetChecksumManifest makes every Commit write the file at name, relative
+// to the root, listing the SHA-256 checksums of the committed files of the
+// collections and objects created by NewCollection and NewObject, in the
+// format of sha256sum. VerifyChecksums compares the worktree to it.
+func (db *DB) SetChecksumManifest(name string) {
+	db.ChecksumManifest = name
+}
+
+func (m ChecksumMismatch) String() string {
+	switch {
+	case m.Actual == "":
+		return m.Path + ": missing"
+	case m.Expected == "":
+		return m.Path + ": not in manifest"
+	}
+	return fmt.Sprintf("%s: expected %s, got %s", m.Path, m.Expected, m.Actual)
+}
+
+func (m ChecksumMismatches) Error() string {
+	msgs := make([]string, len(m))
+	for i, mismatch := range m {
+		msgs[i] = mismatch.String()
+	}
+	return "checksum mismatches: " + strings.Join(msgs, "; ")
+}
+
+// ownedBy reports whether the file name belongs to the collection or
+// object at one of paths, including chunks and record files.
+func ownedBy(paths []string, name string) bool {
+	for _, path := range paths {
+		if name == path || isChunkOf(name, path) || strings.HasPrefix(name, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeChecksumManifest writes and stages the checksum manifest of the
+// staged managed files.
+func (db DB) writeChecksumManifest(r *git.Repository, w *git.Worktree) error {
+	if db.ChecksumManifest == "" {
+		return nil
+	}
+	manifest := filepath.ToSlash(db.resolve(db.ChecksumManifest))
+	db.Manage(manifest)
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return err
+	}
+	paths := db.releasePaths()
+	var lines []string
+	for _, e := range idx.Entries {
+		if e.Name == manifest || !ownedBy(paths, e.Name) {
+			continue
+		}
+		blob, err := r.BlobObject(e.Hash)
+		if err != nil {
+			return err
+		}
+		rd, err := blob.Reader()
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		_, err = io.Copy(h, rd)
+		rd.Close()
+		if err != nil {
+			return err
+		}
+		lines = append(lines, hex.EncodeToString(h.Sum(nil))+"  "+db.relativeToRoot(e.Name))
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i][66:] < lines[j][66:] })
+	content := strings.Join(lines, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	if err := writeFile(filepath.Join(db.Local, manifest), strings.NewReader(content)); err != nil {
+		return err
+	}
+	_, err = w.Add(manifest)
+	return err
+}
+
+func (db DB) relativeToRoot(name string) string {
+	if db.Root == "" {
+		return name
+	}
+	return strings.TrimPrefix(name, db.Root+"/")
+}
+
+func (db DB) MustVerifyChecksums() ChecksumMismatches {
+	mismatches, err := db.VerifyChecksums()
+	if err != nil {
+		panic(err)
+	}
+	return mismatches
+}
+
+// VerifyChecksums compares the managed files of the worktree to the
+// checksum manifest written by the last Commit, to detect changes made
+// outside of gitdb or corruption. Files of the manifest that are no longer
+// managed are still checked.
+func (db DB) VerifyChecksums() (ChecksumMismatches, error) {
+	if db.ChecksumManifest == "" {
+		return nil, fmt.Errorf("VerifyChecksums: no checksum manifest set")
+	}
+	manifest := filepath.Join(db.Local, db.resolve(db.ChecksumManifest))
+	expected, err := readChecksumManifest(manifest)
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(db.Local, db.resolve(""))
+	actual := map[string]string{}
+	for name := range expected {
+		sum, err := fileChecksum(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		actual[name] = sum
+	}
+	root := filepath.ToSlash(db.resolve(""))
+	for _, path := range db.releasePaths() {
+		full := filepath.Join(db.Local, filepath.FromSlash(path))
+		names := []string{path}
+		if isDir(full) {
+			if names, err = recordFiles(full); err != nil {
+				return nil, err
+			}
+			for i, name := range names {
+				names[i] = path + "/" + filepath.ToSlash(name)
+			}
+		} else if m, err := readManifest(full); err == nil && m != nil {
+			for _, chunk := range m.Chunks {
+				names = append(names, filepath.ToSlash(filepath.Join(filepath.Dir(path), chunk)))
+			}
+		}
+		for _, name := range names {
+			rel := strings.TrimPrefix(name, root+"/")
+			if _, ok := actual[rel]; ok {
+				continue
+			}
+			sum, err := fileChecksum(filepath.Join(db.Local, filepath.FromSlash(name)))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			actual[rel] = sum
+		}
+	}
+	var mismatches ChecksumMismatches
+	for name, sum := range actual {
+		if sum != expected[name] {
+			mismatches = append(mismatches, ChecksumMismatch{Path: name, Expected: expected[name], Actual: sum})
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		return mismatches[i].Path < mismatches[j].Path
+	})
+	return mismatches, nil
+}
+
+func readChecksumManifest(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "  ", 2)
+		if len(parts) == 2 {
+			sums[parts[1]] = parts[0]
+		}
+	}
+	return sums, scanner.Err()
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,230 @@
+package gitdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+type (
+	chunkManifest struct {
+		// Chunks are the chunk file names, relative to the manifest.
+		Chunks []string `json:"chunks"`
+		Count  int      `json:"count"`
+	}
+)
+
+// marshalRecords encodes the records of content, dropping those filtered
+// out by funcs.
+func (c Collection) marshalRecords(content interface{}, funcs ...interface{}) ([]json.RawMessage, error) {
+	rv := reflect.ValueOf(content)
+	var records []json.RawMessage
+	for i := 0; i < rv.Len(); i++ {
+		item, ok := applyFuncs(rv.Index(i), funcs)
+		if !ok {
+			continue
+		}
+		record := marshalRecord(item.Interface(), c.jsonOptions())
+		if c.MaxRecordSize > 0 && int64(len(record)) > c.MaxRecordSize {
+			return nil, &SizeViolation{Path: c.Path, Index: i, Size: int64(len(record)), Limit: c.MaxRecordSize}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func (c Collection) writeChunks(records []json.RawMessage) error {
+	old, err := readManifest(filepath.Join(c.db.Local, c.Path))
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, record := range records {
+		size += int64(len(record)) + 2
+	}
+	files := map[string]*bytes.Buffer{}
+	defer func() {
+		for _, w := range files {
+			putBuffer(w)
+		}
+	}()
+	var chunks []string
+	if size <= c.ChunkSize {
+		files[c.Path] = writeWith(c.JSONPCallbackName, c.jsonOptions(), records)
+	} else {
+		var start int
+		var chunkSize int64
+		for i := 0; i <= len(records); i++ {
+			if i < len(records) && (i == start || chunkSize+int64(len(records[i]))+2 <= c.ChunkSize) {
+				chunkSize += int64(len(records[i])) + 2
+				continue
+			}
+			name := chunkName(c.Path, len(chunks)+1)
+			files[name] = writeWith(c.JSONPCallbackName, c.jsonOptions(), records[start:i])
+			chunks = append(chunks, filepath.Base(name))
+			if i < len(records) {
+				start, chunkSize = i, int64(len(records[i]))+2
+			}
+		}
+		manifest := chunkManifest{Chunks: chunks, Count: len(records)}
+		files[c.Path] = writeWith(c.JSONPCallbackName, c.jsonOptions(), manifest)
+	}
+	names := make([]string, 0, len(files))
+	for name, w := range files {
+		if err := c.db.checkFileSize(name, w.Len(), c.MaxFileSize); err != nil {
+			return err
+		}
+		names = append(names, name)
+	}
+	// write the manifest last so it never lists a chunk not yet written
+	sort.Slice(names, func(i, j int) bool {
+		return names[j] == c.Path || names[i] != c.Path && names[i] < names[j]
+	})
+	for _, name := range names {
+		if err := c.db.journal("write", name); err != nil {
+			return err
+		}
+		if err := writeFileContext(c.context(), filepath.Join(c.db.Local, name), files[name]); err != nil {
+			return err
+		}
+	}
+
+	if old == nil {
+		return nil
+	}
+	current := map[string]bool{}
+	for _, chunk := range chunks {
+		current[chunk] = true
+	}
+	for _, chunk := range old.Chunks {
+		if current[chunk] {
+			continue
+		}
+		name := filepath.Join(filepath.Dir(c.Path), chunk)
+		if err := c.db.journal("delete", name); err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(c.db.Local, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunkName returns the path of chunk n of the collection at path, e.g.
+// products.0001.json for products.json.
+func chunkName(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.%04d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+func isChunkOf(name, path string) bool {
+	name, path = filepath.ToSlash(name), filepath.ToSlash(path)
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext) + "."
+	if !strings.HasPrefix(name, stem) || !strings.HasSuffix(name, ext) {
+		return false
+	}
+	n := strings.TrimSuffix(strings.TrimPrefix(name, stem), ext)
+	if len(n) < 4 {
+		return false
+	}
+	for _, r := range n {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// readManifest returns the chunk manifest stored at path, or nil if path
+// holds plain records.
+func readManifest(path string) (*chunkManifest, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 512)
+	n, _ := f.Read(buf)
+	f.Close()
+	if i := bytes.IndexAny(buf[:n], "[{"); i < 0 || buf[i] != '{' {
+		return nil, nil
+	}
+	var m chunkManifest
+	if err := readJson(path, &m); err != nil || len(m.Chunks) == 0 {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// readCollection reads the records at path into dest, reassembling them
+// from chunk files if path holds a chunk manifest or from record files if
+// path is a directory.
+func readCollection(path string, dest interface{}) error {
+	return JSONOptions{}.readChunks(path, dest)
+}
+
+func (opts JSONOptions) readChunks(path string, dest interface{}) error {
+	if isDir(path) {
+		return opts.readRecords(path, dest)
+	}
+	m, err := readManifest(path)
+	if err != nil {
+		return err
+	}
+	rv := reflect.Indirect(reflect.ValueOf(dest))
+	if m == nil || rv.Kind() != reflect.Slice {
+		return readJsonWith(path, dest, opts)
+	}
+	rv.Set(reflect.MakeSlice(rv.Type(), 0, m.Count))
+	for _, chunk := range m.Chunks {
+		part := reflect.New(rv.Type())
+		if err := readJsonWith(filepath.Join(filepath.Dir(path), chunk), part.Interface(), opts); err != nil {
+			return fmt.Errorf("%s: %w", chunk, err)
+		}
+		rv.Set(reflect.AppendSlice(rv, part.Elem()))
+	}
+	return nil
+}
+
+// withChunks adds to files the changed chunk files of the chunked
+// collections among them, including deleted ones.
+func (db DB) withChunks(w *git.Worktree, files []string) ([]string, error) {
+	var chunked []string
+	for _, c := range db.state().managedCollections() {
+		if c.ChunkSize <= 0 {
+			continue
+		}
+		for _, file := range files {
+			if filepath.Clean(file) == filepath.Clean(c.Path) {
+				chunked = append(chunked, c.Path)
+			}
+		}
+	}
+	if len(chunked) == 0 {
+		return files, nil
+	}
+	s, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+	files = append([]string(nil), files...)
+	for name := range s {
+		for _, path := range chunked {
+			if isChunkOf(name, path) {
+				files = append(files, name)
+			}
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,40 @@
+package gitdb
+
+import (
+	"context"
+)
+
+// Close flushes pending batched commits and background pushes, stops the
+// Syncers of AutoSync, waits for background work to finish and in-flight
+// operations to release the repository lock, then
+// drops the runtime state kept for the local directory. The DB can still be
+// used afterwards, starting with fresh state. Closing a DB returned by AsOf
+// releases the files of its commit.
+func (db DB) Close(ctx context.Context) error {
+	if db.asOf != "" {
+		defer releaseSnapshot(db.Local)
+	}
+	s := db.state()
+	err := s.flushCommit(db)
+	if e := s.flushPush(ctx, db); err == nil {
+		err = e
+	}
+	for _, syncer := range s.registeredSyncers() {
+		syncer.Stop()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.background.Wait()
+		s.writes.lock(LowPriority)()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	states.Delete(stateKey(db.Local))
+	return err
+}
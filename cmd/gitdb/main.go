@@ -0,0 +1,83 @@
+// Command gitdb works with the collections of a gitdb repository. Its gen
+// subcommand infers the schema of an existing collection file and prints
+// the Go struct to read it with, and optionally a TypeScript interface or
+// the JSON schema:
+//
+//	gitdb gen -name Product -package models data/products.json
+//	gitdb gen -ts data/products.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/caiguanhao/gitdb"
+	"github.com/caiguanhao/gitdb/gen"
+)
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "gen":
+		genCommand(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: gitdb gen [flags] file")
+	os.Exit(2)
+}
+
+func genCommand(args []string) {
+	flags := flag.NewFlagSet("gen", flag.ExitOnError)
+	name := flags.String("name", "", "name of the struct, from the file name without its plural s by default")
+	pkg := flags.String("package", "main", "package of the Go source")
+	ts := flags.Bool("ts", false, "print a TypeScript interface too")
+	schema := flags.Bool("schema", false, "print the JSON schema only")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+	path, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *name == "" {
+		base := filepath.Base(path)
+		// products.json holds products
+		*name = strings.TrimSuffix(strings.TrimSuffix(base, filepath.Ext(base)), "s")
+	}
+
+	db := gitdb.NewDB("", filepath.Dir(path))
+	s, err := gen.InferCollection(db.NewCollection(filepath.Base(path)))
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if *schema {
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println(string(b))
+		return
+	}
+	src, err := gen.GoStruct(s, *name, *pkg)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	os.Stdout.Write(src)
+	if *ts {
+		fmt.Println()
+		fmt.Print(gen.TypeScript(s, *name))
+	}
+}
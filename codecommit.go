@@ -0,0 +1,172 @@
+package gitdb
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type (
+	AWSCredentials struct {
+		AccessKeyID     string
+		SecretAccessKey string
+		SessionToken    string
+	}
+
+	// CodeCommitAuth authenticates HTTPS requests to AWS CodeCommit with
+	// credentials signed by SigV4, like the credential helper of the AWS
+	// CLI does. Use it with DB.SetAuth.
+	CodeCommitAuth struct {
+		// Credentials returns the AWS credentials to sign with. If nil,
+		// EnvAWSCredentials is used. Plug the credential chain of the
+		// AWS SDK in here to support roles and SSO.
+		Credentials func() (AWSCredentials, error)
+
+		// Region defaults to the region in the host name of the remote.
+		Region string
+	}
+)
+
+func (a CodeCommitAuth) Name() string {
+	return "codecommit"
+}
+
+func (a CodeCommitAuth) String() string {
+	return a.Name()
+}
+
+// SetAuth implements the AuthMethod of go-git's HTTP transport.
+func (a CodeCommitAuth) SetAuth(r *http.Request) {
+	credentials := a.Credentials
+	if credentials == nil {
+		credentials = EnvAWSCredentials
+	}
+	creds, err := credentials()
+	if err != nil {
+		log.Println("error getting aws credentials", err)
+		return
+	}
+	user, password := codeCommitPassword(creds, r.URL.Hostname(), r.URL.Path, a.Region, time.Now())
+	r.SetBasicAuth(user, password)
+}
+
+// codeCommitPassword returns the user name and the SigV4 based password
+// for the repository at path on host.
+func codeCommitPassword(creds AWSCredentials, host, path, region string, now time.Time) (string, string) {
+	// sign the repository path, not the smart HTTP endpoints below it
+	if parts := strings.SplitN(path, "/", 5); len(parts) > 4 {
+		path = strings.Join(parts[:4], "/")
+	}
+	if region == "" {
+		// git-codecommit.<region>.amazonaws.com
+		if parts := strings.Split(host, "."); len(parts) > 2 {
+			region = parts[1]
+		}
+	}
+	now = now.UTC()
+	timestamp := now.Format("20060102T150405")
+	date := now.Format("20060102")
+	canonical := fmt.Sprintf("GIT\n%s\n\nhost:%s\n\nhost\n", path, host)
+	sum := sha256.Sum256([]byte(canonical))
+	scope := fmt.Sprintf("%s/%s/codecommit/aws4_request", date, region)
+	toSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%s", timestamp, scope, hex.EncodeToString(sum[:]))
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, "codecommit", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	user := creds.AccessKeyID
+	if creds.SessionToken != "" {
+		user += "%" + creds.SessionToken
+	}
+	return user, timestamp + "Z" + signature
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// EnvAWSCredentials reads AWS credentials from the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables, falling
+// back to the AWS_PROFILE (or default) profile of the shared credentials
+// file.
+func EnvAWSCredentials() (AWSCredentials, error) {
+	creds := AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+		return creds, nil
+	}
+	file := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return creds, err
+		}
+		file = filepath.Join(home, ".aws", "credentials")
+	}
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		profile = "default"
+	}
+	return sharedAWSCredentials(file, profile)
+}
+
+func sharedAWSCredentials(file, profile string) (AWSCredentials, error) {
+	var creds AWSCredentials
+	f, err := os.Open(file)
+	if err != nil {
+		return creds, err
+	}
+	defer f.Close()
+	var section string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			section = strings.TrimSpace(strings.Trim(line, "[]"))
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			continue
+		}
+		value := strings.TrimSpace(line[i+1:])
+		switch strings.TrimSpace(line[:i]) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = value
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = value
+		case "aws_session_token":
+			creds.SessionToken = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return creds, err
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, errors.New("no aws credentials found for profile " + profile)
+	}
+	return creds, nil
+}
@@ -0,0 +1,40 @@
+package gitdb
+
+import (
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// SetCollation makes Query sort strings in ORDER BY, and SortByField sort
+// the records of collections without a collation of their own, by the
+// rules of the language lang, a BCP 47 tag like "de" or "sv", instead of
+// by byte value, for lists read by people. Tags that do not parse use the
+// rules common to all languages. An empty lang restores byte order.
+func (db *DB) SetCollation(lang string) {
+	db.collation = lang
+}
+
+// SetCollation makes SortByField sort strings by the rules of the
+// language lang, overriding the collation of the DB. See DB.SetCollation.
+func (c *Collection) SetCollation(lang string) *Collection {
+	c.collation = lang
+	return c
+}
+
+// collator returns a collator for the collation of the collection, or of
+// its DB, or nil if there is none. Collators are not safe for concurrent
+// use, so each sort makes its own.
+func (c Collection) collator() *collate.Collator {
+	lang := c.collation
+	if lang == "" && c.db != nil {
+		lang = c.db.collation
+	}
+	return newCollator(lang)
+}
+
+func newCollator(lang string) *collate.Collator {
+	if lang == "" {
+		return nil
+	}
+	return collate.New(language.Make(lang))
+}
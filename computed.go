@@ -0,0 +1,92 @@
+package gitdb
+
+import (
+	"reflect"
+	"sort"
+)
+
+// AddComputed registers fn, a func(*T), to be called on every record before
+// it is written, after the record's own Compute method if it is a Computer.
+func (c *Collection) AddComputed(fn interface{}) *Collection {
+	c.computed = append(c.computed, fn)
+	return c
+}
+
+// prepare evaluates computed fields, then deduplicates and sorts records
+// according to the options of the collection.
+func (c Collection) prepare(content interface{}) interface{} {
+	content = prepare(content, c.computed)
+	if c.normalize && content != nil {
+		content = normalizeStrings(reflect.ValueOf(content)).Interface()
+	}
+	rv := reflect.ValueOf(content)
+	if rv.Kind() != reflect.Slice {
+		return content
+	}
+	if c.dedup != nil {
+		rv = c.dedup.apply(rv)
+		content = rv.Interface()
+	}
+	if c.less == nil {
+		return content
+	}
+	col := c.collator()
+	sort.SliceStable(content, func(i, j int) bool {
+		a, b := rv.Index(i), rv.Index(j)
+		if isNilValue(a) || isNilValue(b) {
+			return !isNilValue(a)
+		}
+		return c.less(a, b, col)
+	})
+	return content
+}
+
+// prepare returns a copy of content, so the caller's value is left
+// untouched, with the computed fields of its records evaluated.
+func prepare(content interface{}, computed []interface{}) interface{} {
+	rv := reflect.ValueOf(content)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		records := reflect.MakeSlice(reflect.SliceOf(rv.Type().Elem()), rv.Len(), rv.Len())
+		reflect.Copy(records, rv)
+		for i := 0; i < records.Len(); i++ {
+			compute(records.Index(i), computed)
+		}
+		return records.Interface()
+	case reflect.Struct:
+		record := reflect.New(rv.Type()).Elem()
+		record.Set(rv)
+		compute(record, computed)
+		return record.Interface()
+	}
+	return content
+}
+
+func compute(record reflect.Value, computed []interface{}) {
+	if record.Kind() == reflect.Ptr && record.IsNil() {
+		return
+	}
+	if c, ok := recordPtr(record).Interface().(Computer); ok {
+		c.Compute()
+	}
+	for _, fn := range computed {
+		callRecordFunc(reflect.ValueOf(fn), record)
+	}
+}
+
+// recordPtr returns a pointer to record if it is addressable.
+func recordPtr(record reflect.Value) reflect.Value {
+	if record.Kind() != reflect.Ptr && record.CanAddr() {
+		return record.Addr()
+	}
+	return record
+}
+
+// callRecordFunc calls fn with record, or with a pointer to record if fn
+// takes one.
+func callRecordFunc(fn, record reflect.Value) []reflect.Value {
+	if in := fn.Type().In(0); in.Kind() == reflect.Ptr && record.Kind() != reflect.Ptr {
+		record = record.Addr()
+	}
+	return fn.Call([]reflect.Value{record})
+}
@@ -0,0 +1,246 @@
+package gitdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// Config is a DB and its named collections built by LoadConfig.
+	Config struct {
+		DB          *DB
+		Collections map[string]*Collection
+	}
+
+	configFile struct {
+		Remote     string `yaml:"remote"`
+		Local      string `yaml:"local"`
+		RemoteName string `yaml:"remoteName"`
+		Branch     string `yaml:"branch"`
+
+		FallbackRemotes []string `yaml:"fallbackRemotes"`
+		AllowedHosts    []string `yaml:"allowedHosts"`
+
+		User struct {
+			Name  string `yaml:"name"`
+			Email string `yaml:"email"`
+		} `yaml:"user"`
+
+		SSHKey struct {
+			User     string `yaml:"user"`
+			File     string `yaml:"file"`
+			Password string `yaml:"password"`
+		} `yaml:"sshKey"`
+
+		Push struct {
+			Debounce   time.Duration `yaml:"debounce"`
+			MaxPerHour int           `yaml:"maxPerHour"`
+		} `yaml:"push"`
+
+		EnforceReferences bool   `yaml:"enforceReferences"`
+		Submodules        bool   `yaml:"submodules"`
+		Root              string `yaml:"root"`
+		Unmanaged         string `yaml:"unmanaged"`
+		LineEndings       string `yaml:"lineEndings"`
+		ChecksumManifest  string `yaml:"checksumManifest"`
+		MaxFileSize       int64  `yaml:"maxFileSize"`
+		TimeFormat        string `yaml:"timeFormat"`
+		TimeZone          string `yaml:"timeZone"`
+		Collation         string `yaml:"collation"`
+
+		Collections map[string]collectionConfig `yaml:"collections"`
+	}
+
+	collectionConfig struct {
+		Path           string `yaml:"path"`
+		JSONP          string `yaml:"jsonp"`
+		Model          string `yaml:"model"`
+		ExpiresAtField string `yaml:"expiresAtField"`
+		ChunkSize      int64  `yaml:"chunkSize"`
+		MaxFileSize    int64  `yaml:"maxFileSize"`
+		MaxRecordSize  int64  `yaml:"maxRecordSize"`
+
+		JSON struct {
+			DisableHTMLEscape bool `yaml:"disableHTMLEscape"`
+			UseNumber         bool `yaml:"useNumber"`
+			Int64AsString     bool `yaml:"int64AsString"`
+		} `yaml:"json"`
+
+		SortBy struct {
+			Field string `yaml:"field"`
+			Desc  bool   `yaml:"desc"`
+		} `yaml:"sortBy"`
+
+		DedupBy struct {
+			Field string `yaml:"field"`
+			Keep  string `yaml:"keep"`
+		} `yaml:"dedupBy"`
+	}
+)
+
+var models sync.Map
+
+// RegisterModel makes model, a record value such as User{}, available to
+// config files as the model of a collection under name.
+func RegisterModel(name string, model interface{}) {
+	models.Store(name, model)
+}
+
+func MustLoadConfig(path string) *Config {
+	config, err := LoadConfig(path)
+	if err != nil {
+		panic(err)
+	}
+	return config
+}
+
+// LoadConfig builds a DB and its collections from a YAML (or JSON) file.
+// Relative local and SSH key paths are resolved against the directory of
+// the file.
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file configFile
+	if err := yaml.UnmarshalStrict(b, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	db := NewDB(file.Remote, resolve(file.Local))
+	db.SetRemoteName(file.RemoteName)
+	db.SetFallbackRemotes(file.FallbackRemotes...)
+	db.SetAllowedHosts(file.AllowedHosts...)
+	db.SetBranchName(file.Branch)
+	db.SetUser(file.User.Name, file.User.Email)
+	if file.SSHKey.File != "" {
+		pem, err := ioutil.ReadFile(resolve(file.SSHKey.File))
+		if err != nil {
+			return nil, err
+		}
+		user := file.SSHKey.User
+		if user == "" {
+			user = "git"
+		}
+		if err := db.SetSSHKey(user, pem, file.SSHKey.Password); err != nil {
+			return nil, err
+		}
+	}
+	var push []PushOption
+	if file.Push.Debounce > 0 {
+		push = append(push, Debounce(file.Push.Debounce))
+	}
+	if file.Push.MaxPerHour > 0 {
+		push = append(push, MaxPerHour(file.Push.MaxPerHour))
+	}
+	db.SetPushPolicy(push...)
+	db.SetEnforceReferences(file.EnforceReferences)
+	db.SetSubmodules(file.Submodules)
+	db.SetRoot(file.Root)
+	unmanaged, err := parseUnmanagedPolicy(file.Unmanaged)
+	if err != nil {
+		return nil, err
+	}
+	db.SetUnmanagedPolicy(unmanaged)
+	lineEndings, err := parseLineEndings(file.LineEndings)
+	if err != nil {
+		return nil, err
+	}
+	db.SetLineEndings(lineEndings)
+	db.SetChecksumManifest(file.ChecksumManifest)
+	db.MaxFileSize = file.MaxFileSize
+	format, err := parseTimeFormat(file.TimeFormat)
+	if err != nil {
+		return nil, err
+	}
+	var zone *time.Location
+	if file.TimeZone != "" {
+		if zone, err = time.LoadLocation(file.TimeZone); err != nil {
+			return nil, err
+		}
+	}
+	db.SetTimeFormat(format, zone)
+	db.SetCollation(file.Collation)
+
+	config := &Config{
+		DB:          db,
+		Collections: map[string]*Collection{},
+	}
+	for name, cc := range file.Collections {
+		c, err := cc.build(db, name)
+		if err != nil {
+			return nil, fmt.Errorf("collection %s: %w", name, err)
+		}
+		config.Collections[name] = c
+	}
+	return config, nil
+}
+
+// Collection returns the collection named name in the config file, or nil.
+func (config Config) Collection(name string) *Collection {
+	return config.Collections[name]
+}
+
+func (cc collectionConfig) build(db *DB, name string) (*Collection, error) {
+	path := cc.Path
+	if path == "" {
+		path = name + ".json"
+	}
+	c := db.NewCollection(path)
+	c.JSONPCallbackName = cc.JSONP
+	c.ExpiresAtField = cc.ExpiresAtField
+	c.ChunkSize = cc.ChunkSize
+	c.MaxFileSize = cc.MaxFileSize
+	c.MaxRecordSize = cc.MaxRecordSize
+	c.JSON = JSONOptions{
+		DisableHTMLEscape: cc.JSON.DisableHTMLEscape,
+		UseNumber:         cc.JSON.UseNumber,
+		Int64AsString:     cc.JSON.Int64AsString,
+	}
+	if cc.Model != "" {
+		model, ok := models.Load(cc.Model)
+		if !ok {
+			return nil, fmt.Errorf("unknown model %s", cc.Model)
+		}
+		c.Model = model
+	}
+	if cc.SortBy.Field != "" {
+		c.SortByField(cc.SortBy.Field, cc.SortBy.Desc)
+	}
+	if cc.DedupBy.Field != "" {
+		switch strings.ToLower(cc.DedupBy.Keep) {
+		case "", "first":
+			c.DedupBy(cc.DedupBy.Field, KeepFirst)
+		case "last":
+			c.DedupBy(cc.DedupBy.Field, KeepLast)
+		default:
+			return nil, fmt.Errorf("unknown dedup policy %s", cc.DedupBy.Keep)
+		}
+	}
+	return c, nil
+}
+
+func parseTimeFormat(s string) (TimeFormat, error) {
+	switch strings.ToLower(s) {
+	case "", "rfc3339":
+		return TimeRFC3339, nil
+	case "unix":
+		return TimeUnix, nil
+	case "unixmilli":
+		return TimeUnixMilli, nil
+	}
+	return 0, fmt.Errorf("unknown time format %s", s)
+}
@@ -0,0 +1,129 @@
+package gitdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type (
+	// Reader reads collections and objects of a DB as of a single commit,
+	// see ReadConsistent.
+	Reader interface {
+		// Read reads c, a collection of the DB, into dest.
+		Read(c *Collection, dest interface{}) error
+
+		// ReadObject reads o, an object of the DB, into dest.
+		ReadObject(o *Object, dest interface{}) error
+
+		// Commit returns the hash of the commit read.
+		Commit() string
+	}
+
+	snapshotReader struct {
+		commit string
+		tree   *object.Tree
+	}
+)
+
+func (db DB) MustReadConsistent(fn func(r Reader) error) {
+	if err := db.ReadConsistent(fn); err != nil {
+		panic(err)
+	}
+}
+
+// ReadConsistent calls fn with a Reader reading the collections and
+// objects of db as of the commit at HEAD when it is called, so related
+// collections read by fn are never torn apart, even if a Pull or a
+// background sync moves HEAD in the meantime. Files are read from the
+// objects of the commit; changes not committed yet are not seen. The error
+// of fn is returned.
+func (db DB) ReadConsistent(fn func(r Reader) error) error {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return fmt.Errorf("ReadConsistent: %v", err)
+	}
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	return fn(snapshotReader{commit: commit.Hash.String(), tree: tree})
+}
+
+func (s snapshotReader) Read(c *Collection, dest interface{}) error {
+	content, err := s.collection(c)
+	if err != nil || content == nil {
+		return err
+	}
+	defer removeNulls(dest)
+	if err := s.decode(c.jsonOptions(), c.Path, content, dest); err != nil {
+		return err
+	}
+	if c.ExpiresAtField != "" {
+		removeExpired(dest, c.ExpiresAtField, time.Now())
+	}
+	return nil
+}
+
+func (s snapshotReader) ReadObject(o *Object, dest interface{}) error {
+	content, ok, err := treeFile(s.tree, o.Path)
+	if err != nil || !ok {
+		return err
+	}
+	return s.decode(o.db.jsonOptions(), o.Path, content, dest)
+}
+
+func (s snapshotReader) Commit() string {
+	return s.commit
+}
+
+// collection returns the content of c in the commit as a JSON array,
+// joining its chunks or record files, or nil if it does not exist.
+func (s snapshotReader) collection(c *Collection) ([]byte, error) {
+	if c.RecordKeyField == "" {
+		content, ok, err := treeFile(s.tree, c.Path)
+		if err != nil || !ok {
+			return nil, err
+		}
+		var m chunkManifest
+		if json.Unmarshal(content, &m) != nil || len(m.Chunks) == 0 {
+			return content, nil
+		}
+	}
+	records, _, err := treeRecords(s.tree, c)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", c.Path, err)
+	}
+	var b bytes.Buffer
+	b.WriteByte('[')
+	for i, record := range records {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.Write(record)
+	}
+	b.WriteByte(']')
+	return b.Bytes(), nil
+}
+
+func (s snapshotReader) decode(opts JSONOptions, path string, content []byte, dest interface{}) error {
+	err := opts.decode(dest, func(dest interface{}) error {
+		return opts.newDecoder(bytes.NewReader(content)).Decode(dest)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
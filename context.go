@@ -0,0 +1,93 @@
+package gitdb
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// copyChunkSize is how many bytes are copied between two checks of the
+// context.
+const copyChunkSize = 64 << 10
+
+func (c Collection) MustWriteContext(ctx context.Context, content interface{}, funcs ...interface{}) {
+	if err := c.WriteContext(ctx, content, funcs...); err != nil {
+		panic(err)
+	}
+}
+
+// WriteContext is like Write, but stops with the error of ctx once it is
+// done, between files and every 64 KB written, which matters on slow
+// network file systems. A file being written when ctx is done is left
+// unchanged, but the files of a chunked or per-record collection written
+// before stay written, like after a failed Write.
+func (c Collection) WriteContext(ctx context.Context, content interface{}, funcs ...interface{}) error {
+	c.ctx = ctx
+	return c.Write(content, funcs...)
+}
+
+func (c Collection) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// writeFileContext is like writeFile, but if ctx can be done, it writes
+// to a temporary file renamed to path once complete, so a cancelled write
+// leaves the file at path unchanged.
+func writeFileContext(ctx context.Context, path string, r io.Reader) error {
+	if ctx.Done() == nil {
+		return writeFile(path, r)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = copyContext(ctx, f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// copyContext copies src to dst like io.Copy, checking ctx between
+// chunks.
+func copyContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	buf := make([]byte, copyChunkSize)
+	var written int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		n, err := src.Read(buf)
+		if n > 0 {
+			m, werr := dst.Write(buf[:n])
+			written += int64(m)
+			if werr != nil {
+				return written, werr
+			}
+		}
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+}
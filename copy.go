@@ -0,0 +1,183 @@
+package gitdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func MustCopy(src, dst *DB, paths ...string) {
+	if err := Copy(src, dst, paths...); err != nil {
+		panic(err)
+	}
+}
+
+// Copy copies the collections and objects at paths, relative to the roots
+// of the repositories, as committed at the HEAD of src to dst in one
+// commit, like to promote data from a staging repository to production.
+// The chunks and record files of collections, and the files under paths
+// that are directories, are copied too, and the ones of dst missing from
+// src are deleted, so the paths of dst end up the same as in src. Commit
+// hooks of dst run as usual. Use Push to push the commit.
+func Copy(src, dst *DB, paths ...string) error {
+	return copyPaths("Copy", src, dst, paths, false)
+}
+
+func MustCopyWithHistory(src, dst *DB, paths ...string) {
+	if err := CopyWithHistory(src, dst, paths...); err != nil {
+		panic(err)
+	}
+}
+
+// CopyWithHistory is like Copy, but replays in dst, oldest first, each
+// commit of src that changed the paths, with its message, so the history
+// of the data is kept. Commits changing nothing in dst are skipped.
+func CopyWithHistory(src, dst *DB, paths ...string) error {
+	return copyPaths("CopyWithHistory", src, dst, paths, true)
+}
+
+func copyPaths(op string, src, dst *DB, paths []string, history bool) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("%s: no paths", op)
+	}
+	if err := dst.checkWritable(op); err != nil {
+		return err
+	}
+	r, err := git.PlainOpen(src.Local)
+	if err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return fmt.Errorf("%s: %s: %w", op, src.Local, err)
+	}
+	match := src.pathMatcher(paths)
+
+	var commits []*object.Commit
+	if history {
+		iter, err := r.Log(&git.LogOptions{From: head.Hash(), PathFilter: match})
+		if err != nil {
+			return err
+		}
+		err = iter.ForEach(func(c *object.Commit) error {
+			commits = append([]*object.Commit{c}, commits...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		commit, err := r.CommitObject(head.Hash())
+		if err != nil {
+			return err
+		}
+		commits = append(commits, commit)
+	}
+
+	defer dst.lock()()
+	for _, commit := range commits {
+		msg := commit.Message
+		if !history {
+			msg = dst.formatMessage(CommitInfo{
+				Op:      "copy",
+				Paths:   paths,
+				Message: fmt.Sprintf("copy %s from %s", strings.Join(paths, ", "), commit.Hash.String()[:8]),
+			})
+		}
+		if err := copyCommit(r, commit, paths, src, dst); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if err := dst.commit(msg); err != nil {
+			return err
+		}
+	}
+	dst.state().refreshCaches()
+	return nil
+}
+
+// pathMatcher returns a function reporting whether a file of the
+// repository is one of paths, one of their chunks or a file under them.
+func (db DB) pathMatcher(paths []string) func(string) bool {
+	resolved := make([]string, len(paths))
+	for i, p := range paths {
+		resolved[i] = filepath.ToSlash(db.resolve(p))
+	}
+	return func(name string) bool {
+		for _, p := range resolved {
+			if name == p || isChunkOf(name, p) || strings.HasPrefix(name, p+"/") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// copyCommit writes to the worktree of dst the files of commit at paths,
+// deletes the ones of dst missing from commit, and stages them.
+func copyCommit(r *git.Repository, commit *object.Commit, paths []string, src, dst *DB) error {
+	files, err := commitFiles(commit)
+	if err != nil {
+		return err
+	}
+	dstName := func(name string) string {
+		if src.Root != "" {
+			name = strings.TrimPrefix(name, src.Root+"/")
+		}
+		return filepath.ToSlash(dst.resolve(name))
+	}
+	match := src.pathMatcher(paths)
+	copied := map[string]bool{}
+	var names []string
+	for name, hash := range files {
+		if !match(name) {
+			continue
+		}
+		if err := copyBlob(r, hash, filepath.Join(dst.Local, dstName(name))); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		copied[dstName(name)] = true
+		names = append(names, dstName(name))
+	}
+
+	dr, err := git.PlainOpen(dst.Local)
+	if err != nil {
+		return err
+	}
+	if tree, err := headTree(dr); err != nil {
+		return err
+	} else if tree != nil {
+		dstMatch := dst.pathMatcher(paths)
+		err = tree.Files().ForEach(func(f *object.File) error {
+			if copied[f.Name] || !dstMatch(f.Name) {
+				return nil
+			}
+			names = append(names, f.Name)
+			return os.Remove(filepath.Join(dst.Local, filepath.FromSlash(f.Name)))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	sort.Strings(names)
+	return dst.Add(names...)
+}
+
+// copyBlob writes the content of the blob hash of r to path.
+func copyBlob(r *git.Repository, hash plumbing.Hash, path string) error {
+	blob, err := r.BlobObject(hash)
+	if err != nil {
+		return err
+	}
+	rd, err := blob.Reader()
+	if err != nil {
+		return err
+	}
+	defer rd.Close()
+	return writeFile(path, rd)
+}
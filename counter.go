@@ -0,0 +1,176 @@
+package gitdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	counterAttempts = 5
+)
+
+type (
+	// Counter is an object holding a number, like {"value":42}, that is
+	// incremented by committing and pushing every change, for sequential
+	// order numbers or simple metrics.
+	Counter struct {
+		object Object
+	}
+
+	counterValue struct {
+		Value int64 `json:"value"`
+	}
+)
+
+// NewCounter creates the counter at path. A missing file counts as zero.
+func (db *DB) NewCounter(path string) *Counter {
+	return &Counter{object: *db.NewObject(path)}
+}
+
+func (c *Counter) MustValue() int64 {
+	n, err := c.Value()
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// Value returns the current value of the counter.
+func (c *Counter) Value() (int64, error) {
+	var v counterValue
+	err := c.object.Read(&v)
+	return v.Value, err
+}
+
+func (c *Counter) MustIncrement(ctx context.Context) int64 {
+	n, err := c.Increment(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// Increment adds one to the counter under the write lock of the repository,
+// commits the change, bypassing commit batching, and pushes it if the DB
+// has a remote, then returns the new value. If the push is rejected because
+// the remote has new commits, the local commit is undone, the remote is
+// pulled and the increment is tried again, up to 5 times, so that the
+// values returned by processes sharing the remote are unique.
+func (c *Counter) Increment(ctx context.Context) (int64, error) {
+	db := *c.object.db
+	var err error
+	for i := 0; i < counterAttempts; i++ {
+		if i > 0 {
+			if err := db.pull(ctx); err != nil {
+				return 0, fmt.Errorf("Increment: %v", err)
+			}
+		}
+		var n int64
+		var conflict bool
+		n, conflict, err = c.increment(ctx)
+		if !conflict {
+			return n, err
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+	return 0, fmt.Errorf("Increment: %v", err)
+}
+
+// increment increments the counter once and reports whether its push was
+// rejected, in which case the local commit has been undone.
+func (c *Counter) increment(ctx context.Context) (int64, bool, error) {
+	db := *c.object.db
+	defer db.lock()()
+	n, err := c.Value()
+	if err != nil {
+		return 0, false, err
+	}
+	n++
+	if err := c.object.Write(counterValue{Value: n}); err != nil {
+		return 0, false, err
+	}
+	if err := db.Add(c.object.Path); err != nil {
+		return 0, false, err
+	}
+	err = db.commit(db.formatMessage(CommitInfo{
+		Op:      "increment",
+		Paths:   []string{c.object.Path},
+		Message: fmt.Sprintf("increment %s to %d", c.object.Path, n),
+	}))
+	if err != nil {
+		return 0, false, err
+	}
+	if db.Remote == "" {
+		return n, false, nil
+	}
+	err = db.push(ctx)
+	if err == nil || err == git.NoErrAlreadyUpToDate {
+		return n, false, nil
+	}
+	if !errors.Is(err, git.ErrNonFastForwardUpdate) {
+		return 0, false, err
+	}
+	if err := db.undoCommit(c.object.Path); err != nil {
+		return 0, false, err
+	}
+	return 0, true, err
+}
+
+// undoCommit moves the branch back to the parent of HEAD, keeping the
+// changes of the commit in the worktree except the ones of path, which is
+// restored.
+func (db DB) undoCommit(path string) error {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	parent, err := commit.Parent(0)
+	if err == object.ErrParentNotFound {
+		return fmt.Errorf("cannot undo the first commit")
+	}
+	if err != nil {
+		return err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	err = w.Reset(&git.ResetOptions{Mode: git.MixedReset, Commit: parent.Hash})
+	if err != nil {
+		return err
+	}
+	tree, err := parent.Tree()
+	if err != nil {
+		return err
+	}
+	full := filepath.Join(db.Local, path)
+	file, err := tree.File(filepath.ToSlash(path))
+	if err == object.ErrFileNotFound {
+		return os.Remove(full)
+	}
+	if err != nil {
+		return err
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return err
+	}
+	return writeFile(full, strings.NewReader(content))
+}
@@ -0,0 +1,67 @@
+package gitdb
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// newTestClones returns two DBs cloned from the same new bare repository.
+func newTestClones(t *testing.T) (*DB, *DB) {
+	t.Helper()
+	seed := newTestDB(t)
+	if err := ioutil.WriteFile(filepath.Join(seed.Local, "README"), []byte("test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := seed.Add("README"); err != nil {
+		t.Fatal(err)
+	}
+	if err := seed.Commit("init"); err != nil {
+		t.Fatal(err)
+	}
+	remote := t.TempDir()
+	if _, err := git.PlainClone(remote, true, &git.CloneOptions{URL: seed.Local}); err != nil {
+		t.Fatal(err)
+	}
+	var dbs []*DB
+	for _, name := range []string{"a", "b"} {
+		db := NewDB(remote, t.TempDir())
+		db.SetUser(name, name+"@example.com")
+		if err := db.Init(); err != nil {
+			t.Fatal(err)
+		}
+		dbs = append(dbs, db)
+	}
+	return dbs[0], dbs[1]
+}
+
+func TestCounterConflict(t *testing.T) {
+	a, b := newTestClones(t)
+	ctx := context.Background()
+	for i, db := range []*DB{a, b, a} {
+		n, err := db.NewCounter("counter.json").Increment(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != int64(i+1) {
+			t.Errorf("increment %d returned %d", i+1, n)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(b.Local, "README"), []byte("diverged\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Add("README"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Commit("diverge"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.push(ctx); !errors.Is(err, git.ErrNonFastForwardUpdate) {
+		t.Errorf("got push error %v, want %v", err, git.ErrNonFastForwardUpdate)
+	}
+}
@@ -0,0 +1,179 @@
+package gitdb
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+type (
+	netrcAuth struct {
+		path string
+	}
+
+	gitCredentialAuth struct {
+		mu          sync.Mutex
+		credentials map[string][2]string
+	}
+)
+
+// NetrcAuth returns the auth method for HTTPS remotes that sends the login
+// and password of the machine of the remote, or the default ones, from the
+// netrc file at path, read again for every request. An empty path means
+// $NETRC, or .netrc in the home directory (_netrc on Windows), like curl
+// and the git CLI. Use it with DB.SetAuth.
+func NetrcAuth(path string) transport.AuthMethod {
+	return &netrcAuth{path: path}
+}
+
+func (a *netrcAuth) Name() string {
+	return "http-netrc"
+}
+
+func (a *netrcAuth) String() string {
+	return a.Name()
+}
+
+func (a *netrcAuth) SetAuth(r *http.Request) {
+	path := a.path
+	if path == "" {
+		path = os.Getenv("NETRC")
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			log.Println("error finding netrc", err)
+			return
+		}
+		name := ".netrc"
+		if runtime.GOOS == "windows" {
+			name = "_netrc"
+		}
+		path = filepath.Join(home, name)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Println("error reading netrc", err)
+		return
+	}
+	if login, password, ok := netrcMachine(content, r.URL.Hostname()); ok {
+		r.SetBasicAuth(login, password)
+	}
+}
+
+// netrcMachine returns the login and password of host in the netrc
+// content, falling back to the default entry.
+func netrcMachine(content []byte, host string) (login, password string, found bool) {
+	type entry struct {
+		machine         string
+		login, password string
+	}
+	var entries []*entry
+	var current *entry
+	fields := strings.Fields(string(content))
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine", "default":
+			current = &entry{machine: "*"}
+			if fields[i] == "machine" && i+1 < len(fields) {
+				i++
+				current.machine = fields[i]
+			}
+			entries = append(entries, current)
+		case "login", "password", "account":
+			if current == nil || i+1 >= len(fields) {
+				continue
+			}
+			i++
+			if fields[i-1] == "login" {
+				current.login = fields[i]
+			} else if fields[i-1] == "password" {
+				current.password = fields[i]
+			}
+		case "macdef":
+			// macros end at an empty line, which Fields does not keep,
+			// and the default entry must come last anyway
+			i = len(fields)
+		}
+	}
+	for _, machine := range []string{host, "*"} {
+		for _, e := range entries {
+			if e.machine == machine {
+				return e.login, e.password, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// GitCredentialAuth returns the auth method for HTTPS remotes that asks the
+// credential helpers configured for the git CLI, with git credential fill,
+// for the user name and password of each remote, once per host. Use it
+// with DB.SetAuth.
+func GitCredentialAuth() transport.AuthMethod {
+	return &gitCredentialAuth{credentials: map[string][2]string{}}
+}
+
+func (a *gitCredentialAuth) Name() string {
+	return "http-git-credential"
+}
+
+func (a *gitCredentialAuth) String() string {
+	return a.Name()
+}
+
+func (a *gitCredentialAuth) SetAuth(r *http.Request) {
+	key := r.URL.Scheme + "://" + r.URL.Host
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	credential, ok := a.credentials[key]
+	if !ok {
+		var err error
+		if credential, err = gitCredentialFill(r.URL.Scheme, r.URL.Host); err != nil {
+			log.Println("error getting git credential", err)
+			return
+		}
+		a.credentials[key] = credential
+	}
+	r.SetBasicAuth(credential[0], credential[1])
+}
+
+// gitCredentialFill runs git credential fill for host and returns the user
+// name and password.
+func gitCredentialFill(protocol, host string) ([2]string, error) {
+	var out bytes.Buffer
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=%s\nhost=%s\n\n", protocol, host))
+	cmd.Stdout = &out
+	// never prompt on the terminal of a server
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if err := cmd.Run(); err != nil {
+		return [2]string{}, err
+	}
+	var credential [2]string
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "username":
+			credential[0] = kv[1]
+		case "password":
+			credential[1] = kv[1]
+		}
+	}
+	return credential, scanner.Err()
+}
@@ -0,0 +1,55 @@
+package gitdb
+
+import (
+	"reflect"
+)
+
+type (
+	DedupPolicy int
+
+	dedup struct {
+		field  string
+		policy DedupPolicy
+	}
+)
+
+const (
+	KeepFirst DedupPolicy = iota
+	KeepLast
+)
+
+// DedupBy makes Write drop records whose field (Go field name or JSON name)
+// has the same value as another record, keeping the first or the last one
+// according to policy.
+func (c *Collection) DedupBy(field string, policy DedupPolicy) *Collection {
+	c.dedup = &dedup{
+		field:  field,
+		policy: policy,
+	}
+	return c
+}
+
+func (d dedup) apply(records reflect.Value) reflect.Value {
+	n := records.Len()
+	keep := make([]bool, n)
+	seen := map[string]bool{}
+	for k := 0; k < n; k++ {
+		i := k
+		if d.policy == KeepLast {
+			i = n - 1 - k
+		}
+		key, ok := keyOf(records.Index(i), d.field)
+		if ok && seen[key] {
+			continue
+		}
+		seen[key] = true
+		keep[i] = true
+	}
+	kept := reflect.MakeSlice(records.Type(), 0, n)
+	for i := 0; i < n; i++ {
+		if keep[i] {
+			kept = reflect.Append(kept, records.Index(i))
+		}
+	}
+	return kept
+}
@@ -0,0 +1,59 @@
+package gitdb
+
+import (
+	"fmt"
+	"reflect"
+)
+
+func (c Collection) MustDeleteWhere(predicate interface{}) int {
+	n, err := c.DeleteWhere(predicate)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// DeleteWhere removes every record for which predicate, a func(T) bool or
+// func(*T) bool, returns true, writes the collection and returns the number
+// of records removed. Nothing is written if no record matches.
+func (c Collection) DeleteWhere(predicate interface{}) (int, error) {
+	frv := reflect.ValueOf(predicate)
+	ft := frv.Type()
+	if ft.Kind() != reflect.Func || ft.NumIn() != 1 || ft.NumOut() != 1 || ft.Out(0).Kind() != reflect.Bool {
+		return 0, fmt.Errorf("DeleteWhere: predicate must be func(T) bool, got %s", ft)
+	}
+	elemType, byAddr := recordType(ft.In(0))
+
+	defer c.lock()()
+
+	records := reflect.New(reflect.SliceOf(elemType))
+	if err := c.Read(records.Interface()); err != nil {
+		return 0, err
+	}
+	all := records.Elem()
+	kept := reflect.MakeSlice(all.Type(), 0, all.Len())
+	for i := 0; i < all.Len(); i++ {
+		item := all.Index(i)
+		arg := item
+		if byAddr {
+			arg = item.Addr()
+		}
+		if !frv.Call([]reflect.Value{arg})[0].Bool() {
+			kept = reflect.Append(kept, item)
+		}
+	}
+	n := all.Len() - kept.Len()
+	if n == 0 {
+		return 0, nil
+	}
+	return n, c.Write(kept.Interface())
+}
+
+// recordType returns the slice element type for a callback taking t, and
+// whether the callback expects a pointer to the element.
+func recordType(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+		return t.Elem(), true
+	}
+	return t, false
+}
@@ -0,0 +1,274 @@
+package gitdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// draft is a record of the drafts of a collection.
+	draft struct {
+		ID        string          `json:"id"`
+		Record    json.RawMessage `json:"record"`
+		PublishAt *time.Time      `json:"publishAt,omitempty"`
+	}
+)
+
+func (c Collection) MustDraft(id string, record interface{}) {
+	if err := c.Draft(id, record); err != nil {
+		panic(err)
+	}
+}
+
+// Draft saves record as the draft id of the collection and commits it,
+// without changing the collection. Drafts are kept in their own file next
+// to the collection, like products.drafts.json for products.json, until
+// Publish moves them into the collection. Saving a draft again replaces
+// it and cancels its scheduled publication.
+func (c Collection) Draft(id string, record interface{}) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("Draft: %v", err)
+	}
+	return c.updateDrafts("draft", id, func(drafts []draft) ([]draft, error) {
+		for i := range drafts {
+			if drafts[i].ID == id {
+				drafts[i] = draft{ID: id, Record: b}
+				return drafts, nil
+			}
+		}
+		return append(drafts, draft{ID: id, Record: b}), nil
+	})
+}
+
+func (c Collection) MustSchedulePublish(id string, at time.Time) {
+	if err := c.SchedulePublish(id, at); err != nil {
+		panic(err)
+	}
+}
+
+// SchedulePublish makes PublishDue, and so PublishEvery, publish the draft
+// id at or after at.
+func (c Collection) SchedulePublish(id string, at time.Time) error {
+	return c.updateDrafts("schedule", id, func(drafts []draft) ([]draft, error) {
+		for i := range drafts {
+			if drafts[i].ID == id {
+				at := at.UTC()
+				drafts[i].PublishAt = &at
+				return drafts, nil
+			}
+		}
+		return nil, fmt.Errorf("SchedulePublish: no draft %s in %s", id, c.Path)
+	})
+}
+
+func (c Collection) MustPublish(id string) {
+	if err := c.Publish(id); err != nil {
+		panic(err)
+	}
+}
+
+// Publish moves the draft id into the collection, replacing the record
+// with the same key, by RecordKeyField or else by an id field, or adding
+// it, and commits both files at once. The other records of the collection,
+// expired ones included, are kept as they are.
+func (c Collection) Publish(id string) error {
+	published, err := c.publish(func(d draft) bool {
+		return d.ID == id
+	})
+	if err == nil && len(published) == 0 {
+		err = fmt.Errorf("Publish: no draft %s in %s", id, c.Path)
+	}
+	return err
+}
+
+func (c Collection) MustPublishDue() []string {
+	ids, err := c.PublishDue()
+	if err != nil {
+		panic(err)
+	}
+	return ids
+}
+
+// PublishDue publishes, in one commit, the drafts whose scheduled time has
+// come and returns their IDs.
+func (c Collection) PublishDue() ([]string, error) {
+	now := time.Now()
+	return c.publish(func(d draft) bool {
+		return d.PublishAt != nil && !d.PublishAt.After(now)
+	})
+}
+
+// PublishEvery runs PublishDue at every interval in the background, until
+// the returned function is called. Errors are logged.
+func (c Collection) PublishEvery(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if _, err := c.PublishDue(); err != nil {
+					log.Println("error publishing drafts of", c.Path, err)
+					c.db.reportError("publish", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+// publish moves the drafts matching match into the collection and returns
+// their IDs.
+func (c Collection) publish(match func(draft) bool) ([]string, error) {
+	defer c.lock()()
+	drafts, err := c.readDrafts()
+	if err != nil {
+		return nil, err
+	}
+	var kept []draft
+	var publishing []draft
+	for _, d := range drafts {
+		if match(d) {
+			publishing = append(publishing, d)
+		} else {
+			kept = append(kept, d)
+		}
+	}
+	if len(publishing) == 0 {
+		return nil, nil
+	}
+	records, err := c.adminRecords()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, d := range publishing {
+		if records, err = c.publishRecord(records, d); err != nil {
+			return nil, fmt.Errorf("Publish: %s: %v", d.ID, err)
+		}
+		ids = append(ids, d.ID)
+	}
+	if err := c.Write(records.Interface()); err != nil {
+		return nil, err
+	}
+	if err := c.writeDrafts(kept); err != nil {
+		return nil, err
+	}
+	path := c.draftsPath()
+	if err := c.db.Add(c.Path, path); err != nil {
+		return nil, err
+	}
+	err = c.db.commitOp(CommitInfo{
+		Op:      "publish",
+		Paths:   []string{c.Path, path},
+		Records: len(ids),
+		Message: fmt.Sprintf("publish %s: %s", c.Path, strings.Join(ids, ", ")),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// publishRecord decodes the record of d as a record of records and
+// replaces the one with the same key, or appends it.
+func (c Collection) publishRecord(records reflect.Value, d draft) (reflect.Value, error) {
+	record := reflect.New(records.Type().Elem())
+	dec := json.NewDecoder(bytes.NewReader(d.Record))
+	dec.UseNumber()
+	if err := dec.Decode(record.Interface()); err != nil {
+		return records, err
+	}
+	keyField := c.RecordKeyField
+	if keyField == "" {
+		keyField = recordsKeyField([]json.RawMessage{d.Record})
+	}
+	return replaceRecord(records, record.Elem(), keyField), nil
+}
+
+// replaceRecord replaces the record of records with the same keyField as
+// record, or appends it if there is none or keyField is empty.
+func replaceRecord(records, record reflect.Value, keyField string) reflect.Value {
+	if keyField != "" {
+		key, _ := keyOf(record, keyField)
+		for i := 0; i < records.Len(); i++ {
+			if k, ok := keyOf(records.Index(i), keyField); ok && k == key {
+				records.Index(i).Set(record)
+				return records
+			}
+		}
+	}
+	return reflect.Append(records, record)
+}
+
+// updateDrafts passes the drafts to fn and writes and commits the ones it
+// returns.
+func (c Collection) updateDrafts(op, id string, fn func([]draft) ([]draft, error)) error {
+	defer c.lock()()
+	drafts, err := c.readDrafts()
+	if err != nil {
+		return err
+	}
+	if drafts, err = fn(drafts); err != nil {
+		return err
+	}
+	if err := c.writeDrafts(drafts); err != nil {
+		return err
+	}
+	path := c.draftsPath()
+	if err := c.db.Add(path); err != nil {
+		return err
+	}
+	return c.db.commitOp(CommitInfo{
+		Op:      op,
+		Paths:   []string{path},
+		Records: 1,
+		Message: fmt.Sprintf("%s %s of %s", op, id, c.Path),
+	})
+}
+
+func (c Collection) readDrafts() ([]draft, error) {
+	var drafts []draft
+	err := c.drafts().Read(&drafts)
+	return drafts, err
+}
+
+func (c Collection) writeDrafts(drafts []draft) error {
+	sort.SliceStable(drafts, func(i, j int) bool {
+		return drafts[i].ID < drafts[j].ID
+	})
+	return c.drafts().Write(drafts)
+}
+
+// drafts returns the collection of the drafts of c, which is managed.
+func (c Collection) drafts() Collection {
+	path := c.draftsPath()
+	if !c.db.state().managedPath(path) {
+		c.db.Manage(filepath.ToSlash(path))
+	}
+	return Collection{db: c.db, Path: path, JSON: c.JSON}
+}
+
+// draftsPath returns the path of the drafts of the collection, like
+// products.drafts.json for products.json.
+func (c Collection) draftsPath() string {
+	path := filepath.Clean(c.Path)
+	ext := filepath.Ext(path)
+	if ext == "" {
+		ext = recordFileExt
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".drafts" + ext
+}
@@ -0,0 +1,45 @@
+package gitdb
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublishKeepsOtherRecords(t *testing.T) {
+	db := newTestDB(t)
+	c := db.NewCollection("posts.json")
+	c.ExpiresAtField = "expires_at"
+	content := `[
+{"id":9007199254740993,"title":"big"},
+{"expires_at":1,"id":2,"title":"expired"},
+{"id":3,"title":"old"},
+null
+]
+`
+	if err := ioutil.WriteFile(filepath.Join(db.Local, c.Path), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Add(c.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Commit("init"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Draft("d1", map[string]interface{}{"id": 3, "title": "new", "views": 9007199254740995}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Publish("d1"); err != nil {
+		t.Fatal(err)
+	}
+	got := readTestFile(t, db, c.Path)
+	for _, want := range []string{`"id":9007199254740993`, `"title":"expired"`, `"title":"new"`, `"views":9007199254740995`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%s not found in %s", want, got)
+		}
+	}
+	if strings.Contains(got, `"title":"old"`) {
+		t.Errorf("draft did not replace its record: %s", got)
+	}
+}
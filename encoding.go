@@ -0,0 +1,367 @@
+package gitdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// JSONOptions controls how a Collection encodes and decodes its
+	// records.
+	JSONOptions struct {
+		// DisableHTMLEscape keeps <, > and & in strings as is instead of
+		// escaping them as \u003c, \u003e and \u0026.
+		DisableHTMLEscape bool
+
+		// UseNumber decodes numbers into interface{} values as
+		// json.Number instead of float64.
+		UseNumber bool
+
+		// Int64AsString encodes the int64 and uint64 fields of struct
+		// records as JSON strings, so consumers that parse numbers as
+		// float64 do not lose precision. They are decoded back on Read.
+		// Structs with unexported or embedded fields holding such numbers
+		// cannot be rebuilt to do so, and fail to be written and read.
+		Int64AsString bool
+
+		timeFormat TimeFormat
+		timeZone   *time.Location
+	}
+
+	// TimeFormat is how time.Time fields of struct records are encoded.
+	TimeFormat int
+
+	recordTypeKey struct {
+		t             reflect.Type
+		int64AsString bool
+		timeFormat    TimeFormat
+		timeZone      bool
+	}
+
+	recordTypeValue struct {
+		t   reflect.Type
+		ok  bool
+		err error
+	}
+
+	unixTime      time.Time
+	unixMilliTime time.Time
+)
+
+const (
+	// TimeRFC3339 encodes times as RFC 3339 strings, like encoding/json.
+	TimeRFC3339 TimeFormat = iota
+	// TimeUnix encodes times as seconds since the Unix epoch.
+	TimeUnix
+	// TimeUnixMilli encodes times as milliseconds since the Unix epoch.
+	TimeUnixMilli
+)
+
+var (
+	recordTypes = sync.Map{}
+
+	timeType          = reflect.TypeOf(time.Time{})
+	unixTimeType      = reflect.TypeOf(unixTime{})
+	unixMilliTimeType = reflect.TypeOf(unixMilliTime{})
+)
+
+// SetTimeFormat sets how the time.Time fields of struct records are
+// written by every Collection and Object of db. If zone is not nil, times
+// are converted to it when written and read. Other than with TimeRFC3339
+// and a nil zone, struct records with time fields and unexported or
+// embedded fields fail to be written and read.
+func (db *DB) SetTimeFormat(format TimeFormat, zone *time.Location) {
+	db.TimeFormat = format
+	db.TimeZone = zone
+}
+
+func (db DB) jsonOptions() JSONOptions {
+	return JSONOptions{timeFormat: db.TimeFormat, timeZone: db.TimeZone}
+}
+
+func (c Collection) jsonOptions() JSONOptions {
+	opts := c.JSON
+	opts.timeFormat, opts.timeZone = c.db.TimeFormat, c.db.TimeZone
+	return opts
+}
+
+func (opts JSONOptions) marshal(v interface{}) []byte {
+	if rv := reflect.ValueOf(v); rv.IsValid() {
+		if t, ok, _ := opts.recordType(rv.Type()); ok {
+			v = opts.convertValue(rv, t).Interface()
+		}
+	}
+	if !opts.DisableHTMLEscape {
+		j, _ := json.Marshal(v)
+		return j
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+func (opts JSONOptions) newDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	if opts.UseNumber {
+		dec.UseNumber()
+	}
+	return dec
+}
+
+func (opts JSONOptions) readCollection(path string, dest interface{}) error {
+	return opts.decode(dest, func(dest interface{}) error {
+		return opts.readChunks(path, dest)
+	})
+}
+
+// decode calls read with dest, or with a value of the record type of
+// opts if dest is a pointer to a struct or a slice of structs, converting
+// the result back into dest.
+func (opts JSONOptions) decode(dest interface{}, read func(interface{}) error) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return read(dest)
+	}
+	target := rv.Elem()
+	if target.Kind() == reflect.Slice {
+		alt, ok, err := opts.recordType(target.Type().Elem())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return read(dest)
+		}
+		records := reflect.New(reflect.SliceOf(alt))
+		if err := read(records.Interface()); err != nil {
+			return err
+		}
+		n := records.Elem().Len()
+		out := reflect.MakeSlice(target.Type(), n, n)
+		for i := 0; i < n; i++ {
+			out.Index(i).Set(opts.convertValue(records.Elem().Index(i), target.Type().Elem()))
+		}
+		target.Set(out)
+		return nil
+	}
+	alt, ok, err := opts.recordType(target.Type())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return read(dest)
+	}
+	record := reflect.New(alt)
+	record.Elem().Set(opts.convertValue(target, alt))
+	if err := read(record.Interface()); err != nil {
+		return err
+	}
+	target.Set(opts.convertValue(record.Elem(), target.Type()))
+	return nil
+}
+
+// check returns an error if the records of v, a record or a slice of
+// them, cannot be encoded with opts.
+func (opts JSONOptions) check(v interface{}) error {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	_, _, err := opts.recordType(t)
+	return err
+}
+
+// recordType returns the type that records of type t, a struct or a
+// pointer to one, are encoded as, and false if they are encoded as is.
+// Structs with their own JSON marshaling are always encoded as is. It
+// returns an error if the options apply to t but it has unexported or
+// embedded fields, which cannot be rebuilt.
+func (opts JSONOptions) recordType(t reflect.Type) (reflect.Type, bool, error) {
+	key := recordTypeKey{t, opts.Int64AsString, opts.timeFormat, opts.timeZone != nil}
+	if !key.int64AsString && key.timeFormat == TimeRFC3339 && !key.timeZone {
+		return t, false, nil
+	}
+	if v, ok := recordTypes.Load(key); ok {
+		return v.(recordTypeValue).t, v.(recordTypeValue).ok, v.(recordTypeValue).err
+	}
+	alt, ok, err := t, false, error(nil)
+	if t.Kind() == reflect.Ptr {
+		var elem reflect.Type
+		if elem, ok, err = opts.recordType(t.Elem()); ok {
+			alt = reflect.PtrTo(elem)
+		}
+	} else if t.Kind() == reflect.Struct && !hasJSONMethods(t) {
+		alt, ok, err = opts.buildRecordType(t)
+	}
+	recordTypes.Store(key, recordTypeValue{alt, ok, err})
+	return alt, ok, err
+}
+
+func (opts JSONOptions) buildRecordType(t reflect.Type) (reflect.Type, bool, error) {
+	fields := make([]reflect.StructField, t.NumField())
+	var changed bool
+	var unsupported string
+	for i := range fields {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			_, ok, err := opts.recordType(sf.Type)
+			if ok || err != nil || opts.Int64AsString && (ft.Kind() == reflect.Int64 || ft.Kind() == reflect.Uint64) {
+				return t, false, fmt.Errorf("cannot encode embedded field %s of %s with the JSON options", sf.Name, t)
+			}
+			unsupported = sf.Name
+			continue
+		}
+		if sf.PkgPath != "" {
+			unsupported = sf.Name
+			continue
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft == timeType {
+			if alt := opts.timeType(); alt != timeType {
+				sf.Type = alt
+				if sf.Type.Kind() != reflect.Ptr && t.Field(i).Type.Kind() == reflect.Ptr {
+					sf.Type = reflect.PtrTo(alt)
+				}
+			}
+			changed = changed || sf.Type != t.Field(i).Type || opts.timeZone != nil
+		}
+		tag := sf.Tag.Get("json")
+		if opts.Int64AsString && (ft.Kind() == reflect.Int64 || ft.Kind() == reflect.Uint64) &&
+			tag != "-" && !strings.Contains(tag, ",string") {
+			name := tag
+			if name == "" {
+				name = sf.Name
+			}
+			rest := strings.TrimSpace(strings.Replace(string(sf.Tag), `json:"`+tag+`"`, "", 1))
+			sf.Tag = reflect.StructTag(strings.TrimSpace(rest + ` json:"` + name + `,string"`))
+			changed = true
+		}
+		fields[i] = sf
+	}
+	if !changed {
+		return t, false, nil
+	}
+	if unsupported != "" {
+		return t, false, fmt.Errorf("cannot encode %s with the JSON options: field %s is unexported or embedded", t, unsupported)
+	}
+	return reflect.StructOf(fields), true, nil
+}
+
+func (opts JSONOptions) timeType() reflect.Type {
+	switch opts.timeFormat {
+	case TimeUnix:
+		return unixTimeType
+	case TimeUnixMilli:
+		return unixMilliTimeType
+	}
+	return timeType
+}
+
+// convertValue converts v, a record or one of its fields, to t, a type
+// returned by recordType or the original type of such a type.
+func (opts JSONOptions) convertValue(v reflect.Value, t reflect.Type) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(t)
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(opts.convertValue(v.Elem(), t.Elem()))
+		return p
+	case reflect.Struct:
+		switch v.Type() {
+		case timeType, unixTimeType, unixMilliTimeType:
+			tm := v.Convert(timeType).Interface().(time.Time)
+			if opts.timeZone != nil && !tm.IsZero() {
+				tm = tm.In(opts.timeZone)
+			}
+			return reflect.ValueOf(tm).Convert(t)
+		}
+		out := reflect.New(t).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			out.Field(i).Set(opts.convertValue(v.Field(i), t.Field(i).Type))
+		}
+		return out
+	}
+	return v.Convert(t)
+}
+
+func hasJSONMethods(t reflect.Type) bool {
+	marshaler := reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	unmarshaler := reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	gitdbMarshaler := reflect.TypeOf((*Marshaler)(nil)).Elem()
+	for _, i := range []reflect.Type{marshaler, unmarshaler, gitdbMarshaler} {
+		if t.Implements(i) || reflect.PtrTo(t).Implements(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t unixTime) MarshalJSON() ([]byte, error) {
+	if time.Time(t).IsZero() {
+		return []byte("0"), nil
+	}
+	return strconv.AppendInt(nil, time.Time(t).Unix(), 10), nil
+}
+
+func (t *unixTime) UnmarshalJSON(b []byte) error {
+	tm, err := unmarshalTime(b, time.Second)
+	*t = unixTime(tm)
+	return err
+}
+
+func (t unixMilliTime) MarshalJSON() ([]byte, error) {
+	if time.Time(t).IsZero() {
+		return []byte("0"), nil
+	}
+	return strconv.AppendInt(nil, time.Time(t).UnixNano()/int64(time.Millisecond), 10), nil
+}
+
+func (t *unixMilliTime) UnmarshalJSON(b []byte) error {
+	tm, err := unmarshalTime(b, time.Millisecond)
+	*t = unixMilliTime(tm)
+	return err
+}
+
+// unmarshalTime decodes a number of units since the Unix epoch, also
+// accepting RFC 3339 strings written before the format was changed.
+func unmarshalTime(b []byte, unit time.Duration) (time.Time, error) {
+	var tm time.Time
+	if bytes.HasPrefix(b, []byte(`"`)) {
+		err := tm.UnmarshalJSON(b)
+		return tm, err
+	}
+	s := string(b)
+	if s == "null" || s == "0" {
+		return tm, nil
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return tm, err
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(0, 0).Add(time.Duration(i) * unit), nil
+	}
+	return time.Unix(0, int64(n*float64(unit))), nil
+}
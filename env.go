@@ -0,0 +1,67 @@
+package gitdb
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func MustFromEnv() *DB {
+	db, err := FromEnv()
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
+// FromEnv builds a DB from these environment variables:
+//
+//	GITDB_REMOTE, GITDB_LOCAL     remote URL and local directory
+//	GITDB_FALLBACK_REMOTES        comma separated fallback remote URLs
+//	GITDB_REMOTE_NAME             remote name
+//	GITDB_BRANCH                  branch name
+//	GITDB_USER, GITDB_EMAIL       commit author
+//	GITDB_SSH_USER                SSH user, "git" by default
+//	GITDB_SSH_KEY                 SSH private key, as PEM or base64 of PEM
+//	GITDB_SSH_KEY_FILE            path of the SSH private key
+//	GITDB_SSH_KEY_PASSWORD        password of the SSH private key
+func FromEnv() (*DB, error) {
+	db := NewDB(os.Getenv("GITDB_REMOTE"), os.Getenv("GITDB_LOCAL"))
+	if remotes := os.Getenv("GITDB_FALLBACK_REMOTES"); remotes != "" {
+		db.SetFallbackRemotes(strings.Split(remotes, ",")...)
+	}
+	db.SetRemoteName(os.Getenv("GITDB_REMOTE_NAME"))
+	db.SetBranchName(os.Getenv("GITDB_BRANCH"))
+	db.SetUser(os.Getenv("GITDB_USER"), os.Getenv("GITDB_EMAIL"))
+
+	var pem []byte
+	if key := os.Getenv("GITDB_SSH_KEY"); key != "" {
+		if strings.Contains(key, "-----BEGIN") {
+			pem = []byte(key)
+		} else {
+			b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(key))
+			if err != nil {
+				return nil, fmt.Errorf("GITDB_SSH_KEY: %w", err)
+			}
+			pem = b
+		}
+	} else if file := os.Getenv("GITDB_SSH_KEY_FILE"); file != "" {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("GITDB_SSH_KEY_FILE: %w", err)
+		}
+		pem = b
+	}
+	if pem != nil {
+		user := os.Getenv("GITDB_SSH_USER")
+		if user == "" {
+			user = "git"
+		}
+		if err := db.SetSSHKey(user, pem, os.Getenv("GITDB_SSH_KEY_PASSWORD")); err != nil {
+			return nil, err
+		}
+	}
+	return db, nil
+}
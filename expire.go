@@ -0,0 +1,152 @@
+package gitdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+func (c Collection) MustExpire() int {
+	n, err := c.Expire()
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// Expire removes the records whose ExpiresAtField is in the past, then
+// writes and commits the collection. It returns the number of records
+// removed. Records are decoded into the Model of the collection, if any,
+// so ExpiresAtField matches the same Go field name or JSON name as in
+// Read, and the kept ones are written back as such, going through
+// AddComputed and SortBy like any Write.
+func (c Collection) Expire() (int, error) {
+	if c.ExpiresAtField == "" {
+		return 0, fmt.Errorf("Expire: %s has no ExpiresAtField", c.Path)
+	}
+
+	defer c.lock()()
+
+	var raws []json.RawMessage
+	if err := c.readAll(&raws); err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	opts := c.jsonOptions()
+	if c.Model == nil {
+		// keep numbers of generic records as they are
+		opts.UseNumber = true
+	}
+	t := c.recordType()
+	var n int
+	records := reflect.MakeSlice(reflect.SliceOf(t), 0, len(raws))
+	for _, raw := range raws {
+		if string(raw) == "null" {
+			continue
+		}
+		record := reflect.New(t)
+		err := opts.decode(record.Interface(), func(dest interface{}) error {
+			return opts.newDecoder(bytes.NewReader(raw)).Decode(dest)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("Expire: %s: %w", c.Path, err)
+		}
+		if expired(record.Elem(), c.ExpiresAtField, now) {
+			n++
+			continue
+		}
+		records = reflect.Append(records, record.Elem())
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	if err := c.Write(records.Interface()); err != nil {
+		return 0, err
+	}
+	if err := c.db.Add(c.Path); err != nil {
+		return 0, err
+	}
+	return n, c.db.commitOp(CommitInfo{
+		Op:      "expire",
+		Paths:   []string{c.Path},
+		Records: n,
+		Message: fmt.Sprintf("expire %s: %d records", c.Path, n),
+	})
+}
+
+// removeExpired removes from the slice pointed to by dest the records whose
+// field holds a time before now, and returns how many were removed.
+func removeExpired(dest interface{}, field string, now time.Time) int {
+	rv := reflect.Indirect(reflect.ValueOf(dest))
+	if rv.Kind() != reflect.Slice {
+		return 0
+	}
+	kept := rv.Slice(0, 0)
+	for i := 0; i < rv.Len(); i++ {
+		if !expired(rv.Index(i), field, now) {
+			kept = reflect.Append(kept, rv.Index(i))
+		}
+	}
+	n := rv.Len() - kept.Len()
+	rv.Set(kept)
+	return n
+}
+
+// expired reports whether field of record, matched by Go field name or
+// JSON name, holds a time before now.
+func expired(record reflect.Value, field string, now time.Time) bool {
+	f, ok := fieldOf(record, field)
+	if !ok {
+		return false
+	}
+	at, ok := expiresAt(f)
+	return ok && at.Before(now)
+}
+
+// expiresAtKey returns the JSON name of ExpiresAtField in records of type
+// t, for the reads matching fields by JSON name only.
+func (c Collection) expiresAtKey(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		if sf, ok := t.FieldByName(c.ExpiresAtField); ok {
+			if name := jsonName(sf); name != "" {
+				return name
+			}
+		}
+	}
+	return c.ExpiresAtField
+}
+
+// expiresAt interprets v as a time.Time, an RFC 3339 string or a Unix time
+// in seconds. Zero values never expire.
+func expiresAt(v reflect.Value) (time.Time, bool) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return time.Time{}, false
+		}
+		v = v.Elem()
+	}
+	switch x := v.Interface().(type) {
+	case time.Time:
+		return x, !x.IsZero()
+	case json.Number:
+		n, err := x.Float64()
+		return time.Unix(int64(n), 0), err == nil && n != 0
+	case string:
+		t, err := time.Parse(time.RFC3339, x)
+		return t, err == nil && !t.IsZero()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return time.Unix(v.Int(), 0), v.Int() != 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return time.Unix(int64(v.Uint()), 0), v.Uint() != 0
+	case reflect.Float32, reflect.Float64:
+		return time.Unix(int64(v.Float()), 0), v.Float() != 0
+	}
+	return time.Time{}, false
+}
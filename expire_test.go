@@ -0,0 +1,85 @@
+package gitdb
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type expiringRecord struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Upper     string    `json:"upper"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func TestExpireComputed(t *testing.T) {
+	db := newTestDB(t)
+	c := db.NewCollection("sessions.json")
+	c.Model = expiringRecord{}
+	c.ExpiresAtField = "ExpiresAt"
+	c.AddComputed(func(r *expiringRecord) {
+		r.Upper = strings.ToUpper(r.Name)
+	})
+	now := time.Now()
+	records := []expiringRecord{
+		{ID: "1", Name: "a", ExpiresAt: now.Add(-time.Hour)},
+		{ID: "2", Name: "b", ExpiresAt: now.Add(time.Hour)},
+		{ID: "3", Name: "c"},
+	}
+	if err := c.Write(records); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Add(c.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Commit("init"); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := c.Expire()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("Expire removed %d records, want 1", n)
+	}
+	var got []expiringRecord
+	if err := c.Read(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "2" || got[1].ID != "3" {
+		t.Fatalf("got %+v", got)
+	}
+	if got[0].Upper != "B" || got[1].Upper != "C" {
+		t.Errorf("computed fields not kept: %+v", got)
+	}
+}
+
+func TestExpireGenericNumbers(t *testing.T) {
+	db := newTestDB(t)
+	c := db.NewCollection("events.json")
+	c.ExpiresAtField = "expires_at"
+	if err := c.Write([]map[string]interface{}{
+		{"id": 9007199254740993, "expires_at": 1},
+		{"id": 2, "expires_at": 0},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.Expire(); err != nil || n != 1 {
+		t.Fatalf("Expire = %d, %v, want 1", n, err)
+	}
+	if err := c.Write([]map[string]interface{}{
+		{"id": 9007199254740993, "expires_at": 0},
+		{"id": 2, "expires_at": 1},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := c.Expire(); err != nil || n != 1 {
+		t.Fatalf("Expire = %d, %v, want 1", n, err)
+	}
+	content := readTestFile(t, db, c.Path)
+	if !strings.Contains(content, "9007199254740993") {
+		t.Errorf("large number not kept: %s", content)
+	}
+}
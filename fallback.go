@@ -0,0 +1,64 @@
+package gitdb
+
+import (
+	"context"
+	"log"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+)
+
+// cloneFallback clones from the fallback remotes in order after cloning
+// from Remote failed with err, leaving the remote configured with the URL
+// of Remote so pushes and later fetches still go there first.
+func (db DB) cloneFallback(err error) (*git.Repository, error) {
+	failed := db.Remote
+	for _, url := range db.FallbackRemotes {
+		log.Println("error cloning", failed, err, "- trying", url)
+		failed = url
+		var r *git.Repository
+		r, err = git.PlainClone(db.Local, false, &git.CloneOptions{
+			URL:  url,
+			Auth: db.authMethod(),
+		})
+		if err != nil {
+			continue
+		}
+		cfg, e := r.Config()
+		if e != nil {
+			return nil, e
+		}
+		if remote, ok := cfg.Remotes[git.DefaultRemoteName]; ok {
+			remote.URLs = []string{db.Remote}
+			if e := r.SetConfig(cfg); e != nil {
+				return nil, e
+			}
+		}
+		return r, nil
+	}
+	return nil, err
+}
+
+// fetchFallback fetches from the fallback remotes in order into the
+// tracking branches of the remote after fetching from it failed with err.
+func (db DB) fetchFallback(ctx context.Context, r *git.Repository, err error) error {
+	failed := db.GetRemoteName()
+	for _, url := range db.FallbackRemotes {
+		log.Println("error fetching", failed, err, "- trying", url)
+		failed = url
+		remote := git.NewRemote(r.Storer, &config.RemoteConfig{
+			Name:  db.GetRemoteName(),
+			URLs:  []string{url},
+			Fetch: []config.RefSpec{config.RefSpec("+refs/heads/*:refs/remotes/" + db.GetRemoteName() + "/*")},
+		})
+		err = remote.FetchContext(ctx, &git.FetchOptions{
+			RemoteName: db.GetRemoteName(),
+			Auth:       db.authMethod(),
+			Force:      true,
+		})
+		if err == nil || err == git.NoErrAlreadyUpToDate {
+			return err
+		}
+	}
+	return err
+}
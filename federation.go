@@ -0,0 +1,276 @@
+package gitdb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type (
+	// Federation puts several DBs, usually with different remotes, behind
+	// one interface. Paths are routed to the DB of the first route they
+	// match, otherwise to the first DB that has them, otherwise to the
+	// first DB.
+	Federation struct {
+		dbs    []*DB
+		routes []federationRoute
+	}
+
+	federationRoute struct {
+		pattern string
+		db      *DB
+	}
+
+	// FederatedFile is a file listed by Federation.List.
+	FederatedFile struct {
+		DB   *DB
+		Path string
+	}
+
+	// FederationError holds the errors of the DBs of a Federation, by local
+	// directory.
+	FederationError map[string]error
+)
+
+func NewFederation(dbs ...*DB) *Federation {
+	return &Federation{dbs: dbs}
+}
+
+// Add adds db to the federation, after the existing ones.
+func (f *Federation) Add(db *DB) {
+	f.dbs = append(f.dbs, db)
+}
+
+// Route makes the paths matching pattern belong to db, which is added to
+// the federation if needed. Patterns are matched like RouteBranch: one
+// ending with a slash matches everything under that directory, otherwise
+// it is matched with path.Match.
+func (f *Federation) Route(pattern string, db *DB) {
+	if !f.has(db) {
+		f.Add(db)
+	}
+	f.routes = append(f.routes, federationRoute{
+		pattern: filepath.ToSlash(pattern),
+		db:      db,
+	})
+}
+
+// DBs returns the DBs of the federation.
+func (f *Federation) DBs() []*DB {
+	return append([]*DB(nil), f.dbs...)
+}
+
+// DB returns the DB the path belongs to, or nil if the federation is
+// empty.
+func (f *Federation) DB(path string) *DB {
+	if db := f.routedDB(path); db != nil {
+		return db
+	}
+	for _, db := range f.dbs {
+		if _, err := os.Stat(filepath.Join(db.Local, path)); err == nil {
+			return db
+		}
+	}
+	if len(f.dbs) == 0 {
+		return nil
+	}
+	return f.dbs[0]
+}
+
+func (f *Federation) routedDB(path string) *DB {
+	path = filepath.ToSlash(filepath.Clean(path))
+	for _, route := range f.routes {
+		if matchPath(route.pattern, path) {
+			return route.db
+		}
+	}
+	return nil
+}
+
+func (f *Federation) has(db *DB) bool {
+	for _, existing := range f.dbs {
+		if existing == db {
+			return true
+		}
+	}
+	return false
+}
+
+// NewCollection creates the collection at path in the DB it belongs to.
+func (f *Federation) NewCollection(path string) *Collection {
+	return f.DB(path).NewCollection(path)
+}
+
+// NewObject creates the object at path in the DB it belongs to.
+func (f *Federation) NewObject(path string) *Object {
+	return f.DB(path).NewObject(path)
+}
+
+func (f *Federation) MustRead(path string, dest interface{}) {
+	if err := f.Read(path, dest); err != nil {
+		panic(err)
+	}
+}
+
+// Read reads the collection at path from the DB it belongs to, with the
+// options of the collection if it was created with NewCollection.
+func (f *Federation) Read(path string, dest interface{}) error {
+	db := f.DB(path)
+	if db == nil {
+		return fmt.Errorf("Read: empty federation")
+	}
+	for _, c := range db.Collections() {
+		if filepath.Clean(c.Path) == filepath.Clean(path) {
+			return c.Read(dest)
+		}
+	}
+	return Collection{db: db, Path: path}.Read(dest)
+}
+
+func (f *Federation) MustList() []FederatedFile {
+	files, err := f.List()
+	if err != nil {
+		panic(err)
+	}
+	return files
+}
+
+// List returns the JSON files committed in every DB, sorted by path. A
+// path present in several DBs is listed once, for the DB it belongs to.
+func (f *Federation) List() ([]FederatedFile, error) {
+	seen := map[string]bool{}
+	var files []FederatedFile
+	for _, db := range f.dbs {
+		paths, err := jsonFiles(db.Local)
+		if err != nil {
+			return nil, fmt.Errorf("List: %s: %v", db.Local, err)
+		}
+		for _, path := range paths {
+			if seen[path] {
+				continue
+			}
+			if routed := f.routedDB(path); routed != nil && routed != db {
+				continue
+			}
+			seen[path] = true
+			files = append(files, FederatedFile{DB: db, Path: path})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// jsonFiles returns the paths of the JSON files tracked at HEAD.
+func jsonFiles(local string) ([]string, error) {
+	r, err := git.PlainOpen(local)
+	if err != nil {
+		return nil, err
+	}
+	head, err := r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	err = tree.Files().ForEach(func(file *object.File) error {
+		if strings.HasSuffix(file.Name, ".json") {
+			paths = append(paths, file.Name)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+func (f *Federation) MustInit() {
+	if err := f.Init(); err != nil {
+		panic(err)
+	}
+}
+
+// Init runs Init on every DB.
+func (f *Federation) Init() error {
+	return f.each(DB.Init)
+}
+
+func (f *Federation) MustForceUpdate() {
+	if err := f.ForceUpdate(); err != nil {
+		panic(err)
+	}
+}
+
+// ForceUpdate runs ForceUpdate on every DB.
+func (f *Federation) ForceUpdate() error {
+	return f.each(DB.ForceUpdate)
+}
+
+func (f *Federation) MustCommit(message ...string) {
+	if err := f.Commit(message...); err != nil {
+		panic(err)
+	}
+}
+
+// Commit runs Commit on every DB.
+func (f *Federation) Commit(message ...string) error {
+	return f.each(func(db DB) error {
+		return db.Commit(message...)
+	})
+}
+
+func (f *Federation) MustPush() {
+	if err := f.Push(); err != nil {
+		panic(err)
+	}
+}
+
+// Push runs Push on every DB.
+func (f *Federation) Push() error {
+	return f.each(DB.Push)
+}
+
+// each runs fn on every DB, even if some of them fail.
+func (f *Federation) each(fn func(DB) error) error {
+	errs := FederationError{}
+	for _, db := range f.dbs {
+		if err := fn(*db); err != nil {
+			errs[db.Local] = err
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (e FederationError) Error() string {
+	locals := make([]string, 0, len(e))
+	for local := range e {
+		locals = append(locals, local)
+	}
+	sort.Strings(locals)
+	var b strings.Builder
+	for i, local := range locals {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(&b, "%s: %v", local, e[local])
+	}
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package gitdb
+
+import (
+	"context"
+	"time"
+)
+
+func (c Collection) MustReadFresh(ctx context.Context, dest interface{}, maxStaleness time.Duration) {
+	if err := c.ReadFresh(ctx, dest, maxStaleness); err != nil {
+		panic(err)
+	}
+}
+
+// ReadFresh is like Read, but first updates the local repository from the
+// remote with Pull if the last successful fetch made by this process is
+// older than maxStaleness, so local commits not pushed yet are kept, and a
+// MergeConflict is returned if they cannot be merged. Concurrent callers
+// share a single fetch.
+func (c Collection) ReadFresh(ctx context.Context, dest interface{}, maxStaleness time.Duration) error {
+	fetched, err := c.db.ensureFresh(ctx, maxStaleness)
+	if err != nil {
+		return err
+	}
+	if fetched {
+		c.db.state().refreshCaches()
+	}
+	return c.Read(dest)
+}
+
+// ensureFresh updates the repository if it is older than maxStaleness and
+// reports whether it did.
+func (db DB) ensureFresh(ctx context.Context, maxStaleness time.Duration) (bool, error) {
+	s := db.state()
+	if s.fresh(maxStaleness) {
+		return false, nil
+	}
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+	// another caller may have fetched while we were waiting
+	if s.fresh(maxStaleness) {
+		return false, nil
+	}
+	// pull takes the lock of the repository and merges instead of
+	// resetting, so pending pushes and commit batches are never lost
+	return true, db.pull(ctx)
+}
+
+func (s *repoState) fresh(maxStaleness time.Duration) bool {
+	fetch, _ := s.syncTimes()
+	return !fetch.IsZero() && time.Since(fetch) <= maxStaleness
+}
@@ -0,0 +1,268 @@
+//go:build go1.16
+// +build go1.16
+
+package gitdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type (
+	// dataFS is the fs.FS of DB.FS, reading the worktree, or of DB.FSAt,
+	// reading the tree of a commit.
+	dataFS struct {
+		db      DB
+		tree    *object.Tree
+		modTime time.Time
+	}
+
+	fsFile struct {
+		*bytes.Reader
+		info fs.FileInfo
+	}
+
+	fsDir struct {
+		info    fs.FileInfo
+		entries []fs.FileInfo
+		offset  int
+	}
+
+	fsInfo struct {
+		name    string
+		size    int64
+		mode    fs.FileMode
+		modTime time.Time
+	}
+
+	fsDirEntry struct {
+		fs.FileInfo
+	}
+)
+
+// FS returns the managed files of the worktree as an fs.FS, to be used with
+// http.FS, template.ParseFS or fs.WalkDir. Only the files of collections,
+// objects and paths given to Manage, and the directories leading to them,
+// can be opened or listed. Files are read as they are on disk, with their
+// JSONP callback if any.
+func (db DB) FS() fs.FS {
+	return dataFS{db: db}
+}
+
+func (db DB) MustFSAt(rev string) fs.FS {
+	fsys, err := db.FSAt(rev)
+	if err != nil {
+		panic(err)
+	}
+	return fsys
+}
+
+// FSAt is like FS but returns the managed files at revision rev, such as a
+// commit hash, a branch or a tag, read from the repository. Files have the
+// commit time as modification time.
+func (db DB) FSAt(rev string) (fs.FS, error) {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("FSAt: %s: %v", rev, err)
+	}
+	commit, err := r.CommitObject(*hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	return dataFS{db: db, tree: tree, modTime: commit.Committer.When}, nil
+}
+
+func (f dataFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if !f.visible(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	var file fs.File
+	var err error
+	if f.tree == nil {
+		file, err = f.openWorktree(name)
+	} else {
+		file, err = f.openTree(name)
+	}
+	if err != nil {
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return file, nil
+}
+
+func (f dataFS) visible(name string) bool {
+	return name == "." || f.db.state().managedPath(name)
+}
+
+func (f dataFS) openWorktree(name string) (fs.File, error) {
+	file, err := os.Open(filepath.Join(f.db.Local, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if !info.IsDir() {
+		return file, nil
+	}
+	defer file.Close()
+	infos, err := file.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	return f.dir(name, info, infos), nil
+}
+
+func (f dataFS) openTree(name string) (fs.File, error) {
+	if name == "." {
+		return f.treeDir(name, f.tree)
+	}
+	entry, err := f.tree.FindEntry(name)
+	if err != nil || entry.Mode == filemode.Submodule {
+		return nil, fs.ErrNotExist
+	}
+	if entry.Mode == filemode.Dir {
+		tree, err := f.tree.Tree(name)
+		if err != nil {
+			return nil, err
+		}
+		return f.treeDir(name, tree)
+	}
+	file, err := f.tree.TreeEntryFile(entry)
+	if err != nil {
+		return nil, err
+	}
+	r, err := file.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return &fsFile{
+		Reader: bytes.NewReader(content),
+		info:   f.treeInfo(*entry, file.Size),
+	}, nil
+}
+
+func (f dataFS) treeDir(name string, tree *object.Tree) (fs.File, error) {
+	var infos []fs.FileInfo
+	for _, entry := range tree.Entries {
+		if entry.Mode == filemode.Submodule {
+			continue
+		}
+		var size int64
+		if entry.Mode != filemode.Dir {
+			file, err := tree.TreeEntryFile(&entry)
+			if err != nil {
+				return nil, err
+			}
+			size = file.Size
+		}
+		infos = append(infos, f.treeInfo(entry, size))
+	}
+	dir := object.TreeEntry{Name: path.Base(name), Mode: filemode.Dir}
+	return f.dir(name, f.treeInfo(dir, 0), infos), nil
+}
+
+func (f dataFS) treeInfo(entry object.TreeEntry, size int64) fs.FileInfo {
+	mode, _ := entry.Mode.ToOSFileMode()
+	return fsInfo{name: entry.Name, size: size, mode: mode, modTime: f.modTime}
+}
+
+// dir returns the directory name, listing the visible entries of infos.
+func (f dataFS) dir(name string, info fs.FileInfo, infos []fs.FileInfo) *fsDir {
+	d := &fsDir{info: info}
+	for _, i := range infos {
+		if f.visible(path.Join(name, i.Name())) {
+			d.entries = append(d.entries, i)
+		}
+	}
+	sort.Slice(d.entries, func(i, j int) bool {
+		return d.entries[i].Name() < d.entries[j].Name()
+	})
+	return d
+}
+
+func (f *fsFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *fsFile) Close() error {
+	return nil
+}
+
+func (d *fsDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *fsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+func (d *fsDir) Close() error {
+	return nil
+}
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	d.offset += len(rest)
+	entries := make([]fs.DirEntry, len(rest))
+	for i, info := range rest {
+		entries[i] = fsDirEntry{info}
+	}
+	return entries, nil
+}
+
+func (i fsInfo) Name() string       { return i.name }
+func (i fsInfo) Size() int64        { return i.size }
+func (i fsInfo) Mode() fs.FileMode  { return i.mode }
+func (i fsInfo) ModTime() time.Time { return i.modTime }
+func (i fsInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i fsInfo) Sys() interface{}   { return nil }
+
+func (e fsDirEntry) Type() fs.FileMode {
+	return e.Mode().Type()
+}
+
+func (e fsDirEntry) Info() (fs.FileInfo, error) {
+	return e.FileInfo, nil
+}
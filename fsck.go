@@ -0,0 +1,203 @@
+package gitdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+type (
+	// FsckProblem describes an integrity problem found by Fsck. Path is
+	// the file or object it concerns.
+	FsckProblem struct {
+		Path string
+		Err  error
+	}
+
+	FsckProblems []FsckProblem
+)
+
+func (p FsckProblem) Error() string {
+	return p.Path + ": " + p.Err.Error()
+}
+
+func (problems FsckProblems) Error() string {
+	msgs := make([]string, len(problems))
+	for i, p := range problems {
+		msgs[i] = p.Error()
+	}
+	return "fsck: " + strings.Join(msgs, "; ")
+}
+
+func (db DB) MustFsck() FsckProblems {
+	problems, err := db.Fsck()
+	if err != nil {
+		panic(err)
+	}
+	return problems
+}
+
+// Fsck verifies that every object in the repository matches its hash and
+// that the history of HEAD is complete, that the files of the collections
+// created by NewCollection match HEAD in the worktree, and that they parse
+// with the JSON options of their collection. All problems found are
+// returned; the error is only set if the checks could not run.
+func (db DB) Fsck() (FsckProblems, error) {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return nil, err
+	}
+	var problems FsckProblems
+	objects, err := fsckObjects(r)
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, objects...)
+	history, err := fsckHistory(r)
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, history...)
+
+	collections := db.state().managedCollections()
+	w, err := r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	s, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fs := s[name]
+		if fs.Staging == git.Untracked || fs.Staging == git.Unmodified && fs.Worktree == git.Unmodified {
+			continue
+		}
+		for _, c := range collections {
+			if c.owns(name) {
+				problems = append(problems, FsckProblem{Path: name, Err: fmt.Errorf("differs from HEAD (staged %c, worktree %c)", fs.Staging, fs.Worktree)})
+				break
+			}
+		}
+	}
+	for _, c := range collections {
+		var records []json.RawMessage
+		if err := c.jsonOptions().readCollection(filepath.Join(db.Local, c.Path), &records); err != nil {
+			problems = append(problems, FsckProblem{Path: c.Path, Err: err})
+		}
+	}
+	return problems, nil
+}
+
+// owns reports whether the file name, relative to the repository, belongs
+// to the collection.
+func (c Collection) owns(name string) bool {
+	name = filepath.ToSlash(name)
+	path := filepath.ToSlash(filepath.Clean(c.Path))
+	if name == path || isChunkOf(name, path) {
+		return true
+	}
+	return c.RecordKeyField != "" && strings.HasPrefix(name, path+"/")
+}
+
+// fsckObjects reads every object of r and checks its content hashes to its
+// name.
+func fsckObjects(r *git.Repository) (FsckProblems, error) {
+	iter, err := r.Storer.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return nil, err
+	}
+	var problems FsckProblems
+	err = iter.ForEach(func(obj plumbing.EncodedObject) error {
+		rd, err := obj.Reader()
+		if err != nil {
+			problems = append(problems, FsckProblem{Path: obj.Hash().String(), Err: err})
+			return nil
+		}
+		content, err := ioutil.ReadAll(rd)
+		rd.Close()
+		if err != nil {
+			problems = append(problems, FsckProblem{Path: obj.Hash().String(), Err: err})
+			return nil
+		}
+		if h := plumbing.ComputeHash(obj.Type(), content); h != obj.Hash() {
+			problems = append(problems, FsckProblem{Path: obj.Hash().String(), Err: fmt.Errorf("%s content hashes to %s", obj.Type(), h)})
+		}
+		return nil
+	})
+	return problems, err
+}
+
+// fsckHistory checks that the commits reachable from HEAD and their trees
+// and blobs are all present and decode.
+func fsckHistory(r *git.Repository) (FsckProblems, error) {
+	head, err := r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var problems FsckProblems
+	seen := map[plumbing.Hash]bool{}
+	pending := []plumbing.Hash{head.Hash()}
+	for len(pending) > 0 {
+		h := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		commit, err := r.CommitObject(h)
+		if err != nil {
+			problems = append(problems, FsckProblem{Path: h.String(), Err: fmt.Errorf("commit: %w", err)})
+			continue
+		}
+		pending = append(pending, commit.ParentHashes...)
+		if seen[commit.TreeHash] {
+			continue
+		}
+		problems = append(problems, fsckTree(r.Storer, commit.TreeHash, seen)...)
+	}
+	return problems, nil
+}
+
+func fsckTree(s storer.EncodedObjectStorer, h plumbing.Hash, seen map[plumbing.Hash]bool) FsckProblems {
+	seen[h] = true
+	tree, err := object.GetTree(s, h)
+	if err != nil {
+		return FsckProblems{{Path: h.String(), Err: fmt.Errorf("tree: %w", err)}}
+	}
+	var problems FsckProblems
+	for _, e := range tree.Entries {
+		if seen[e.Hash] {
+			continue
+		}
+		if e.Mode == filemode.Dir {
+			problems = append(problems, fsckTree(s, e.Hash, seen)...)
+			continue
+		}
+		if !e.Mode.IsFile() {
+			continue
+		}
+		seen[e.Hash] = true
+		if _, err := s.EncodedObject(plumbing.BlobObject, e.Hash); err != nil {
+			problems = append(problems, FsckProblem{Path: e.Hash.String(), Err: fmt.Errorf("blob %s: %w", e.Name, err)})
+		}
+	}
+	return problems
+}
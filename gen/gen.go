@@ -0,0 +1,461 @@
+// Package gen infers the JSON schema of the records of an existing
+// collection and generates the Go struct, and the TypeScript interface,
+// to read them with, to get started with a repository of legacy data:
+//
+//	schema, err := gen.InferCollection(db.NewCollection("products.json"))
+//	src, err := gen.GoStruct(schema, "Product", "main")
+//
+// See the gen subcommand of the gitdb command.
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/caiguanhao/gitdb"
+)
+
+type (
+	// Schema is the inferred JSON schema of a value, in the subset of JSON
+	// Schema gen needs.
+	Schema struct {
+		// Types lists the JSON types seen: "object", "array", "string",
+		// "integer", "number", "boolean" and "null".
+		Types []string
+		// Format is "date-time" if every string is an RFC 3339 time.
+		Format     string
+		Properties map[string]*Schema
+		// Required lists the properties present and not null in every
+		// object.
+		Required []string
+		Items    *Schema
+
+		objects   int
+		present   map[string]int
+		strings   int
+		dateTimes int
+	}
+)
+
+// MarshalJSON encodes s as a JSON Schema document.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{}
+	if len(s.Types) == 1 {
+		m["type"] = s.Types[0]
+	} else if len(s.Types) > 1 {
+		m["type"] = s.Types
+	}
+	if s.Format != "" {
+		m["format"] = s.Format
+	}
+	if len(s.Properties) > 0 {
+		m["properties"] = s.Properties
+	}
+	if len(s.Required) > 0 {
+		m["required"] = s.Required
+	}
+	if s.Items != nil {
+		m["items"] = s.Items
+	}
+	return json.Marshal(m)
+}
+
+// InferCollection reads the records of c and infers their schema.
+func InferCollection(c *gitdb.Collection) (*Schema, error) {
+	c.JSON.UseNumber = true
+	var records []interface{}
+	if err := c.Read(&records); err != nil {
+		return nil, err
+	}
+	return Infer(records), nil
+}
+
+// Infer returns the schema of the records, values decoded from JSON with
+// UseNumber, so integers are told from other numbers.
+func Infer(records []interface{}) *Schema {
+	s := &Schema{}
+	for _, record := range records {
+		s.add(record)
+	}
+	s.finish()
+	return s
+}
+
+func (s *Schema) add(v interface{}) {
+	switch x := v.(type) {
+	case nil:
+		s.addType("null")
+	case bool:
+		s.addType("boolean")
+	case json.Number:
+		if _, err := x.Int64(); err == nil {
+			s.addType("integer")
+		} else {
+			s.addType("number")
+		}
+	case float64:
+		if x == float64(int64(x)) {
+			s.addType("integer")
+		} else {
+			s.addType("number")
+		}
+	case string:
+		s.addType("string")
+		s.strings++
+		if _, err := time.Parse(time.RFC3339, x); err == nil {
+			s.dateTimes++
+		}
+	case []interface{}:
+		s.addType("array")
+		if s.Items == nil {
+			s.Items = &Schema{}
+		}
+		for _, item := range x {
+			s.Items.add(item)
+		}
+	case map[string]interface{}:
+		s.addType("object")
+		if s.Properties == nil {
+			s.Properties = map[string]*Schema{}
+			s.present = map[string]int{}
+		}
+		s.objects++
+		for key, value := range x {
+			p := s.Properties[key]
+			if p == nil {
+				p = &Schema{}
+				s.Properties[key] = p
+			}
+			p.add(value)
+			if value != nil {
+				s.present[key]++
+			}
+		}
+	}
+}
+
+func (s *Schema) addType(t string) {
+	for _, existing := range s.Types {
+		if existing == t {
+			return
+		}
+	}
+	s.Types = append(s.Types, t)
+}
+
+// finish sorts the types, merging integer into number, and sets Format and
+// Required.
+func (s *Schema) finish() {
+	if s.has("integer") && s.has("number") {
+		s.remove("integer")
+	}
+	sort.Strings(s.Types)
+	if s.strings > 0 && s.dateTimes == s.strings {
+		s.Format = "date-time"
+	}
+	s.Required = nil
+	for key, p := range s.Properties {
+		p.finish()
+		if s.present[key] == s.objects {
+			s.Required = append(s.Required, key)
+		}
+	}
+	sort.Strings(s.Required)
+	if s.Items != nil {
+		s.Items.finish()
+	}
+}
+
+func (s *Schema) has(t string) bool {
+	for _, existing := range s.Types {
+		if existing == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) remove(t string) {
+	for i, existing := range s.Types {
+		if existing == t {
+			s.Types = append(s.Types[:i], s.Types[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Schema) required(key string) bool {
+	for _, r := range s.Required {
+		if r == key {
+			return true
+		}
+	}
+	return false
+}
+
+// types returns the types of s other than null, and whether null is one
+// of them.
+func (s *Schema) types() ([]string, bool) {
+	var types []string
+	for _, t := range s.Types {
+		if t != "null" {
+			types = append(types, t)
+		}
+	}
+	return types, s.has("null")
+}
+
+// GoStruct returns the source of the Go struct named name for the records
+// of schema s, in package pkg, with a struct for each nested object,
+// formatted with gofmt. Properties missing from some records get
+// omitempty, and the ones that can be null are pointers.
+func GoStruct(s *Schema, name, pkg string) ([]byte, error) {
+	g := &generator{names: map[string]bool{}}
+	if s.has("array") && s.Items != nil {
+		s = s.Items
+	}
+	g.goType(s, exportedName(name), true)
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	if g.usesTime {
+		b.WriteString("import \"time\"\n\n")
+	}
+	for _, decl := range g.decls {
+		b.WriteString(decl)
+	}
+	return format.Source(b.Bytes())
+}
+
+type generator struct {
+	names    map[string]bool
+	decls    []string
+	usesTime bool
+}
+
+// goType returns the Go type of s, declaring a struct named name for
+// objects.
+func (g *generator) goType(s *Schema, name string, required bool) string {
+	types, null := s.types()
+	if len(types) != 1 {
+		return "interface{}"
+	}
+	var t string
+	switch types[0] {
+	case "string":
+		t = "string"
+		if s.Format == "date-time" {
+			t = "time.Time"
+			g.usesTime = true
+		}
+	case "integer":
+		t = "int64"
+	case "number":
+		t = "float64"
+	case "boolean":
+		t = "bool"
+	case "array":
+		if s.Items == nil {
+			return "[]interface{}"
+		}
+		return "[]" + g.goType(s.Items, singular(name), true)
+	case "object":
+		t = g.declare(s, name)
+	}
+	if null || !required && types[0] == "object" {
+		return "*" + t
+	}
+	return t
+}
+
+// declare declares the struct of the object schema s and returns its
+// name, made unique.
+func (g *generator) declare(s *Schema, name string) string {
+	base := name
+	for i := 2; g.names[name]; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	g.names[name] = true
+	index := len(g.decls)
+	g.decls = append(g.decls, "")
+	var b strings.Builder
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	fields := map[string]bool{}
+	for _, key := range sortedKeys(s.Properties) {
+		field := exportedName(key)
+		for i := 2; fields[field]; i++ {
+			field = fmt.Sprintf("%s%d", exportedName(key), i)
+		}
+		fields[field] = true
+		required := s.required(key)
+		tag := key
+		if !required {
+			tag += ",omitempty"
+		}
+		t := g.goType(s.Properties[key], name+field, required)
+		fmt.Fprintf(&b, "\t%s %s `json:%q`\n", field, t, tag)
+	}
+	b.WriteString("}\n\n")
+	g.decls[index] = b.String()
+	return name
+}
+
+// TypeScript returns the TypeScript interfaces for the records of schema
+// s, the one of the records named name.
+func TypeScript(s *Schema, name string) string {
+	g := &tsGenerator{names: map[string]bool{}}
+	if s.has("array") && s.Items != nil {
+		s = s.Items
+	}
+	g.tsType(s, exportedName(name))
+	return strings.Join(g.decls, "\n")
+}
+
+type tsGenerator struct {
+	names map[string]bool
+	decls []string
+}
+
+func (g *tsGenerator) tsType(s *Schema, name string) string {
+	types, null := s.types()
+	var parts []string
+	for _, t := range types {
+		switch t {
+		case "string":
+			parts = append(parts, "string")
+		case "integer", "number":
+			parts = append(parts, "number")
+		case "boolean":
+			parts = append(parts, "boolean")
+		case "array":
+			item := "unknown"
+			if s.Items != nil {
+				item = g.tsType(s.Items, singular(name))
+			}
+			if strings.Contains(item, " ") {
+				item = "(" + item + ")"
+			}
+			parts = append(parts, item+"[]")
+		case "object":
+			parts = append(parts, g.declare(s, name))
+		}
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "unknown")
+	}
+	if null {
+		parts = append(parts, "null")
+	}
+	return strings.Join(parts, " | ")
+}
+
+func (g *tsGenerator) declare(s *Schema, name string) string {
+	base := name
+	for i := 2; g.names[name]; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	g.names[name] = true
+	index := len(g.decls)
+	g.decls = append(g.decls, "")
+	var b strings.Builder
+	fmt.Fprintf(&b, "export interface %s {\n", name)
+	for _, key := range sortedKeys(s.Properties) {
+		optional := ""
+		if !s.required(key) {
+			optional = "?"
+		}
+		property := key
+		if !isIdentifier(key) {
+			property = fmt.Sprintf("%q", key)
+		}
+		t := g.tsType(s.Properties[key], name+exportedName(key))
+		fmt.Fprintf(&b, "  %s%s: %s;\n", property, optional, t)
+	}
+	b.WriteString("}\n")
+	g.decls[index] = b.String()
+	return name
+}
+
+func sortedKeys(m map[string]*Schema) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// initialisms are the words written in capitals in Go names.
+var initialisms = map[string]bool{
+	"ID": true, "URL": true, "URI": true, "API": true, "HTTP": true,
+	"HTML": true, "JSON": true, "UUID": true, "IP": true, "SKU": true,
+}
+
+// exportedName returns key, like "created_at" or "userId", as an exported
+// Go name, like "CreatedAt" or "UserID".
+func exportedName(key string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	runes := []rune(key)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) ||
+			i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])):
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+	var b strings.Builder
+	for _, w := range words {
+		if upper := strings.ToUpper(w); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		rs := []rune(w)
+		b.WriteRune(unicode.ToUpper(rs[0]))
+		b.WriteString(string(rs[1:]))
+	}
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
+
+// singular returns the name of an item of the list name.
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies"):
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "ses"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss"):
+		return strings.TrimSuffix(name, "s")
+	}
+	return name + "Item"
+}
+
+func isIdentifier(key string) bool {
+	for i, r := range key {
+		if !(r == '_' || r == '$' || unicode.IsLetter(r) || i > 0 && unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return key != ""
+}
@@ -2,13 +2,14 @@ package gitdb
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/go-git/go-git/v5"
@@ -17,8 +18,7 @@ import (
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
-	xssh "golang.org/x/crypto/ssh"
+	"golang.org/x/text/collate"
 )
 
 type (
@@ -26,13 +26,68 @@ type (
 		Remote string
 		Local  string
 
+		// FallbackRemotes are tried in order by Init and ForceUpdate when
+		// Remote is unreachable. Pushes always go to Remote.
+		FallbackRemotes []string
+
 		RemoteName string
 		BranchName string
 
 		UserName  string
 		UserEmail string
 
-		publicKey *ssh.PublicKeys
+		EnforceReferences bool
+
+		// Submodules makes Init and ForceUpdate update the submodules of
+		// the repository. See SetSubmodules.
+		Submodules bool
+
+		// Root is the directory of the repository, without a trailing
+		// slash, that holds every Collection and Object. See SetRoot.
+		Root string
+
+		// Unmanaged is what Commit does with staged changes to paths of
+		// no Collection or Object. See SetUnmanagedPolicy.
+		Unmanaged UnmanagedPolicy
+
+		// LineEndings is what Add does with the line endings of the files
+		// it stages. See SetLineEndings.
+		LineEndings LineEndings
+
+		// ChecksumManifest is the path, relative to Root, of the file
+		// listing the checksums of the managed files written by every
+		// Commit. See SetChecksumManifest.
+		ChecksumManifest string
+
+		// MaxFileSize, if positive, is the size limit in bytes of every
+		// file written by a Collection or Object that sets no limit of
+		// its own.
+		MaxFileSize int64
+
+		TimeFormat TimeFormat
+		TimeZone   *time.Location
+
+		sshKeys      *sshKeys
+		auth         transport.AuthMethod
+		pushPolicy   PushPolicy
+		commitBatch  time.Duration
+		branchRoutes []branchRoute
+		pullRequests PullRequestProvider
+
+		messageFormatter MessageFormatter
+		priority         Priority
+		preCommitHooks   []Hook
+		prePushHooks     []Hook
+		policy           PolicyEngine
+		trailers         []Trailer
+		allowedHosts     []string
+		quota            Quota
+		writeMemoryLimit int64
+		collation        string
+		onError          func(op string, err error)
+
+		// asOf is the commit of a read-only DB returned by AsOf.
+		asOf string
 	}
 
 	Collection struct {
@@ -41,6 +96,55 @@ type (
 		Path string
 
 		JSONPCallbackName string
+
+		Model interface{}
+
+		// ExpiresAtField, if set, is the Go field name or JSON name of
+		// the field holding the time records expire at, as a time.Time,
+		// an RFC 3339 string or a Unix time in seconds. Read hides
+		// expired records and Expire deletes them.
+		ExpiresAtField string
+
+		// ChunkSize, if positive, splits the collection into numbered
+		// chunk files of about this many bytes when it grows larger,
+		// leaving a manifest listing them at Path.
+		ChunkSize int64
+
+		// RecordKeyField, if set, stores each record in its own file
+		// inside the directory Path, named after the value of this
+		// field, so writers changing different records never touch the
+		// same file and Pull can merge their changes.
+		RecordKeyField string
+
+		// RecordFileName, if set, names the record files of a collection
+		// with RecordKeyField instead of EscapedKeyNames, like SlugNames
+		// or HashedNames.
+		RecordFileName RecordFileNamer
+
+		// NewRecordKey, if set, makes Write give the records without a
+		// RecordKeyField the key it returns, like NewULID. The records
+		// passed to Write are left untouched, read them back to get
+		// their keys.
+		NewRecordKey func() string
+
+		MaxFileSize   int64
+		MaxRecordSize int64
+
+		// Quarantine makes Read skip the records that fail to parse or
+		// decode, usually after a manual edit, instead of failing, saving
+		// them with the position of their errors in
+		// .git/gitdb/quarantine.
+		Quarantine bool
+
+		JSON JSONOptions
+
+		computed  []interface{}
+		less      func(a, b reflect.Value, col *collate.Collator) bool
+		collation string
+		dedup     *dedup
+		normalize bool
+		priority  Priority
+		ctx       context.Context
 	}
 
 	Object struct {
@@ -49,11 +153,17 @@ type (
 		Path string
 
 		JSONPCallbackName string
+
+		MaxFileSize int64
 	}
 
 	Marshaler interface {
 		GITDBMarshalJSON() []byte
 	}
+
+	Computer interface {
+		Compute()
+	}
 )
 
 func NewDB(remote, local string) *DB {
@@ -63,13 +173,15 @@ func NewDB(remote, local string) *DB {
 	}
 }
 
+// SetSSHKey sets the SSH key used to talk to the remote, replacing the keys
+// set before. See AddSSHKey and RotateSSHKey.
 func (db *DB) SetSSHKey(user string, pemBytes []byte, password string) error {
-	publicKey, err := ssh.NewPublicKeys(user, pemBytes, password)
-	if err == nil {
-		publicKey.HostKeyCallback = xssh.InsecureIgnoreHostKey()
-		db.publicKey = publicKey
+	keys := &sshKeys{}
+	if err := keys.add(user, pemBytes, password); err != nil {
+		return err
 	}
-	return err
+	db.sshKeys = keys
+	return nil
 }
 
 func (db *DB) SetUser(name, email string) {
@@ -89,6 +201,10 @@ func (db *DB) SetRemoteName(name string) {
 	db.RemoteName = name
 }
 
+func (db *DB) SetFallbackRemotes(urls ...string) {
+	db.FallbackRemotes = urls
+}
+
 func (db DB) GetBranchName() string {
 	branch := db.BranchName
 	if branch == "" {
@@ -107,12 +223,19 @@ func (db DB) MustInit() {
 	}
 }
 
-func (db DB) Init() error {
+func (db DB) Init() (err error) {
+	defer func() { db.reportError("init", err) }()
+	if err := db.checkRemotes(nil); err != nil {
+		return err
+	}
 	log.Println("initializing", db.Remote)
 	r, err := git.PlainClone(db.Local, false, &git.CloneOptions{
 		URL:  db.Remote,
-		Auth: db.publicKey,
+		Auth: db.authMethod(),
 	})
+	if err != nil && err != transport.ErrEmptyRemoteRepository && err != git.ErrRepositoryAlreadyExists {
+		r, err = db.cloneFallback(err)
+	}
 	if err == transport.ErrEmptyRemoteRepository {
 		log.Println("init", db.Local)
 		r, err = git.PlainInit(db.Local, false)
@@ -125,6 +248,9 @@ func (db DB) Init() error {
 	}
 	if err == git.ErrRepositoryAlreadyExists {
 		_, err = git.PlainOpen(db.Local)
+	} else if err == nil {
+		db.state().fetched()
+		err = db.updateSubmodules(context.Background(), r)
 	}
 	return err
 }
@@ -136,6 +262,14 @@ func (db DB) MustForceUpdate() {
 }
 
 func (db DB) ForceUpdate() error {
+	if err := db.forceUpdate(context.Background()); err != nil {
+		return db.reportError("update", err)
+	}
+	db.state().refreshCaches()
+	return nil
+}
+
+func (db DB) forceUpdate(ctx context.Context) error {
 	r, err := git.PlainOpen(db.Local)
 	if err != nil {
 		return err
@@ -144,18 +278,28 @@ func (db DB) ForceUpdate() error {
 	if err != nil {
 		return err
 	}
+	if err := db.checkRemotes(r); err != nil {
+		return err
+	}
 	log.Println("fetching", db.GetRemoteName())
-	err = r.Fetch(&git.FetchOptions{
+	err = r.FetchContext(ctx, &git.FetchOptions{
 		RemoteName: db.GetRemoteName(),
-		Auth:       db.publicKey,
+		Auth:       db.authMethod(),
 		Force:      true,
 	})
+	if err != nil && err != git.NoErrAlreadyUpToDate && err != transport.ErrEmptyRemoteRepository {
+		err = db.fetchFallback(ctx, r, err)
+	}
 	if err == transport.ErrEmptyRemoteRepository {
 		return nil
 	}
 	if err != nil && err != git.NoErrAlreadyUpToDate {
 		return err
 	}
+	db.state().fetched()
+	if err := db.updateRoutedBranches(r); err != nil {
+		return err
+	}
 	ref, e := r.Reference(plumbing.NewRemoteReferenceName(db.GetRemoteName(), db.GetBranchName()), true)
 	if e != nil {
 		return e
@@ -171,21 +315,34 @@ func (db DB) ForceUpdate() error {
 		Mode:   git.HardReset,
 		Commit: ref.Hash(),
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	return db.updateSubmodules(ctx, r)
 }
 
 func (db *DB) NewCollection(path string) *Collection {
-	return &Collection{
+	c := &Collection{
 		db:   db,
-		Path: path,
+		Path: db.resolve(path),
 	}
+	db.state().addCollection(c)
+	return c
+}
+
+// Collections returns the collections created by NewCollection, including
+// the ones backing views.
+func (db DB) Collections() []*Collection {
+	return db.state().managedCollections()
 }
 
 func (db *DB) NewObject(path string) *Object {
-	return &Object{
+	o := &Object{
 		db:   db,
-		Path: path,
+		Path: db.resolve(path),
 	}
+	db.state().addObject(o)
+	return o
 }
 
 func (db DB) MustAdd(message ...string) {
@@ -195,6 +352,9 @@ func (db DB) MustAdd(message ...string) {
 }
 
 func (db DB) Add(files ...string) error {
+	if err := db.checkWritable("Add"); err != nil {
+		return err
+	}
 	r, err := git.PlainOpen(db.Local)
 	if err != nil {
 		return err
@@ -203,7 +363,23 @@ func (db DB) Add(files ...string) error {
 	if err != nil {
 		return err
 	}
+	files = db.withViews(files)
+	if files, err = db.withChunks(w, files); err != nil {
+		return err
+	}
+	if files, err = db.withRecords(w, files); err != nil {
+		return err
+	}
+	if err := db.checkRoot("Add", files); err != nil {
+		return err
+	}
+	if files, err = db.addToSubmodules(r, files); err != nil {
+		return err
+	}
 	for _, file := range files {
+		if err := db.normalizeLineEndings(file); err != nil {
+			return err
+		}
 		if _, err := w.Add(file); err != nil {
 			return err
 		}
@@ -218,14 +394,41 @@ func (db DB) MustCommit(message ...string) {
 }
 
 func (db DB) Commit(message ...string) error {
+	if err := db.checkWritable("Commit"); err != nil {
+		return err
+	}
+	var msg string
+	if len(message) > 0 {
+		msg = message[0]
+	} else if db.messageFormatter != nil {
+		paths, err := db.stagedPaths()
+		if err != nil {
+			return err
+		}
+		msg = db.formatMessage(CommitInfo{Op: "update", Paths: paths, Message: "update"})
+	} else {
+		msg = "update"
+	}
+	if db.commitBatch > 0 {
+		db.state().scheduleCommit(db, msg)
+		return nil
+	}
+	return db.reportError("commit", db.commit(msg))
+}
+
+func (db DB) commit(msg string) error {
 	r, err := git.PlainOpen(db.Local)
 	if err != nil {
 		return err
 	}
+	msg = db.addTrailers(msg)
 	w, err := r.Worktree()
 	if err != nil {
 		return err
 	}
+	if err := db.commitSubmodules(r, msg); err != nil {
+		return err
+	}
 	s, err := w.Status()
 	if err != nil {
 		return err
@@ -234,25 +437,53 @@ func (db DB) Commit(message ...string) error {
 		log.Println("nothing to commit")
 		return nil
 	}
-	var msg string
-	if len(message) > 0 {
-		msg = message[0]
-	} else {
-		msg = "update"
+	if err := db.checkCommit(r, s); err != nil {
+		return err
 	}
+	routed, err := db.commitRoutes(r, s, msg)
+	if err != nil {
+		return err
+	}
+	if routed {
+		if s, err = w.Status(); err != nil {
+			return err
+		}
+		if !hasStagedChanges(s) {
+			return db.pruneJournal(s)
+		}
+	}
+	if err := db.writeChecksumManifest(r, w); err != nil {
+		return err
+	}
+	author := db.signature()
 	hash, err := w.Commit(msg, &git.CommitOptions{
-		Author: &object.Signature{
-			Name:  db.UserName,
-			Email: db.UserEmail,
-			When:  time.Now(),
-		},
+		Author: &author,
 	})
-	if err == nil {
-		log.Println("added commit", hash.String()[:8])
-	} else {
+	if err != nil {
 		log.Println("error adding commit", err)
+		return err
 	}
-	return err
+	log.Println("added commit", hash.String()[:8])
+	if s, err = w.Status(); err != nil {
+		return err
+	}
+	return db.pruneJournal(s)
+}
+
+// checkCommit runs the checks of the staged changes s that every commit
+// goes through: the root, the unmanaged files policy, the pre-commit hooks
+// and the quota.
+func (db DB) checkCommit(r *git.Repository, s git.Status) error {
+	if err := db.stagedOutsideRoot(s); err != nil {
+		return err
+	}
+	if err := db.checkUnmanaged(s); err != nil {
+		return err
+	}
+	if err := db.runPreCommitHooks(r, s); err != nil {
+		return err
+	}
+	return db.checkQuota("Commit")
 }
 
 func (db DB) MustUnpushedCommits() []string {
@@ -300,13 +531,58 @@ func (db DB) MustPush() {
 }
 
 func (db DB) Push() error {
+	if err := db.checkWritable("Push"); err != nil {
+		return err
+	}
+	if db.commitBatch > 0 && db.state().pushAfterCommit() {
+		return nil
+	}
+	if db.pushPolicy.enabled() {
+		db.state().schedulePush(db)
+		return nil
+	}
+	return db.reportError("push", db.push(context.Background()))
+}
+
+func (db DB) push(ctx context.Context) error {
 	r, err := git.PlainOpen(db.Local)
 	if err != nil {
 		return err
 	}
-	return r.Push(&git.PushOptions{
-		Auth: db.publicKey,
+	head, err := headTree(r)
+	if err != nil {
+		return err
+	}
+	if err := db.checkPush(r, head); err != nil {
+		return err
+	}
+	if err := db.pushSubmodules(ctx, r); err != nil {
+		return err
+	}
+	err = r.PushContext(ctx, &git.PushOptions{
+		Auth: db.authMethod(),
 	})
+	if err == nil || err == git.NoErrAlreadyUpToDate {
+		db.state().pushed()
+	}
+	// go-git does not wrap ErrNonFastForwardUpdate when pushing
+	if msg := git.ErrNonFastForwardUpdate.Error(); err != nil && strings.HasPrefix(err.Error(), msg) {
+		return fmt.Errorf("%w%s", git.ErrNonFastForwardUpdate, strings.TrimPrefix(err.Error(), msg))
+	}
+	return err
+}
+
+// checkPush runs the checks that every push goes through: the allowed
+// remotes, the quota and the pre-push hooks of the changes from the remote
+// branch to tree.
+func (db DB) checkPush(r *git.Repository, tree *object.Tree) error {
+	if err := db.checkRemotes(r); err != nil {
+		return err
+	}
+	if err := db.checkQuota("Push"); err != nil {
+		return err
+	}
+	return db.runPrePushHooks(r, tree)
 }
 
 func (c Collection) MustRead(dest interface{}) {
@@ -315,10 +591,33 @@ func (c Collection) MustRead(dest interface{}) {
 	}
 }
 
-func (c Collection) Read(dest interface{}) error {
+func (c Collection) Read(dest interface{}) (err error) {
+	defer func() { c.db.reportError("read", err) }()
+	if handles, ok := dest.(*[]RecordHandle); ok {
+		var err error
+		*handles, err = c.ReadHandles(c.RecordKeyField)
+		return err
+	}
+	if err := c.readAll(dest); err != nil {
+		return err
+	}
+	if c.ExpiresAtField != "" {
+		removeExpired(dest, c.ExpiresAtField, time.Now())
+	}
+	return nil
+}
+
+func (c Collection) readAll(dest interface{}) error {
+	if c.Quarantine {
+		skipped, err := c.readTolerant(dest)
+		if err != nil {
+			return err
+		}
+		return c.quarantine(skipped)
+	}
 	defer removeNulls(dest)
 	path := filepath.Join(c.db.Local, c.Path)
-	return readJson(path, dest)
+	return c.jsonOptions().readCollection(path, dest)
 }
 
 func (c Collection) MustWrite(content interface{}, funcs ...interface{}) {
@@ -327,22 +626,71 @@ func (c Collection) MustWrite(content interface{}, funcs ...interface{}) {
 	}
 }
 
-func (c Collection) Write(content interface{}, funcs ...interface{}) (err error) {
+func (c Collection) Write(content interface{}, funcs ...interface{}) error {
+	if err := c.writeFile(content, funcs...); err != nil {
+		return c.db.reportError("write", err)
+	}
+	return c.db.reportError("write", c.db.updateViews(c.Path, map[string]bool{c.Path: true}))
+}
+
+func (c Collection) writeFile(content interface{}, funcs ...interface{}) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("Write: %w", r)
+			err = fmt.Errorf("Write: %v", r)
 		}
 	}()
-	w := write(c.JSONPCallbackName, content, funcs...)
-	path := filepath.Join(c.db.Local, c.Path)
-	os.MkdirAll(filepath.Dir(path), 0755)
-	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
+	if err := c.db.checkWritable("Write"); err != nil {
 		return err
 	}
-	defer f.Close()
-	_, err = io.Copy(f, w)
-	return err
+	content = c.prepare(content)
+	if err := c.jsonOptions().check(content); err != nil {
+		return fmt.Errorf("Write: %v", err)
+	}
+	if c.db.EnforceReferences {
+		if err := c.db.checkReferences(c.Path, content); err != nil {
+			return err
+		}
+	}
+	if c.db.policy != nil {
+		if err := c.checkPolicy(content, funcs...); err != nil {
+			return err
+		}
+	}
+	defer c.db.reserveFiles(c.Path)()
+	if kind := reflect.ValueOf(content).Kind(); kind == reflect.Slice || kind == reflect.Array {
+		if c.RecordKeyField != "" {
+			if c.ChunkSize > 0 {
+				return fmt.Errorf("Write: %s cannot have both ChunkSize and RecordKeyField", c.Path)
+			}
+			return c.writeRecords(content, funcs...)
+		}
+		if c.ChunkSize > 0 || c.MaxRecordSize > 0 {
+			records, err := c.marshalRecords(content, funcs...)
+			if err != nil {
+				return err
+			}
+			if c.ChunkSize > 0 {
+				return c.writeChunks(records)
+			}
+			content, funcs = records, nil
+		}
+	} else if c.ChunkSize > 0 {
+		return fmt.Errorf("Write: chunked collection %s must be a slice", c.Path)
+	} else if c.RecordKeyField != "" {
+		return fmt.Errorf("Write: per-record collection %s must be a slice", c.Path)
+	}
+	w := writeWith(c.JSONPCallbackName, c.jsonOptions(), content, funcs...)
+	defer putBuffer(w)
+	if err := c.db.checkFileSize(c.Path, w.Len(), c.MaxFileSize); err != nil {
+		return err
+	}
+	if err := c.context().Err(); err != nil {
+		return err
+	}
+	if err := c.db.journal("write", c.Path); err != nil {
+		return err
+	}
+	return c.db.writeTracked(c.context(), c.Path, w)
 }
 
 func (o Object) MustDelete() {
@@ -352,6 +700,12 @@ func (o Object) MustDelete() {
 }
 
 func (o Object) Delete() error {
+	if err := o.db.checkWritable("Delete"); err != nil {
+		return err
+	}
+	if err := o.db.journal("delete", o.Path); err != nil {
+		return err
+	}
 	path := filepath.Join(o.db.Local, o.Path)
 	return os.Remove(path)
 }
@@ -364,7 +718,10 @@ func (o Object) MustRead(dest interface{}) {
 
 func (o Object) Read(dest interface{}) error {
 	path := filepath.Join(o.db.Local, o.Path)
-	return readJson(path, dest)
+	opts := o.db.jsonOptions()
+	return o.db.reportError("read", opts.decode(dest, func(dest interface{}) error {
+		return readJsonWith(path, dest, opts)
+	}))
 }
 
 func (o Object) MustWrite(content interface{}) {
@@ -374,31 +731,77 @@ func (o Object) MustWrite(content interface{}) {
 }
 
 func (o Object) Write(content interface{}) (err error) {
+	defer func() { o.db.reportError("write", err) }()
 	defer func() {
 		if r := recover(); r != nil {
-			err = fmt.Errorf("Write: %w", r)
+			err = fmt.Errorf("Write: %v", r)
 		}
 	}()
-	w := write(o.JSONPCallbackName, content)
-	path := filepath.Join(o.db.Local, o.Path)
+	if err := o.db.checkWritable("Write"); err != nil {
+		return err
+	}
+	content = prepare(content, nil)
+	if err := o.db.jsonOptions().check(content); err != nil {
+		return fmt.Errorf("Write: %v", err)
+	}
+	defer o.db.reserveFiles(o.Path)()
+	w := writeWith(o.JSONPCallbackName, o.db.jsonOptions(), content)
+	defer putBuffer(w)
+	if o.db.policy != nil {
+		if err := o.checkPolicy(w.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := o.db.checkFileSize(o.Path, w.Len(), o.MaxFileSize); err != nil {
+		return err
+	}
+	if err := o.db.journal("write", o.Path); err != nil {
+		return err
+	}
+	if err := o.db.writeTracked(context.Background(), o.Path, w); err != nil {
+		return err
+	}
+	return o.db.updateViews(o.Path, map[string]bool{o.Path: true})
+}
+
+func writeFile(path string, r io.Reader) error {
 	os.MkdirAll(filepath.Dir(path), 0755)
 	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	_, err = io.Copy(f, w)
+	_, err = io.Copy(f, r)
 	return err
 }
 
 func readJson(path string, dest interface{}) error {
-	f, err := os.Open(path)
+	return readJsonWith(path, dest, JSONOptions{})
+}
+
+func readJsonWith(path string, dest interface{}, opts JSONOptions) error {
+	f, r, err := openJson(path)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
 		}
 		return err
 	}
+	defer f.Close()
+	start, _ := f.Seek(0, io.SeekCurrent)
+	if err := opts.newDecoder(r).Decode(dest); err != nil {
+		return newParseError(path, start, err)
+	}
+	return nil
+}
+
+// openJson opens the JSON file at path, returning a reader of its content
+// without the JSONP callback around it, if any.
+func openJson(path string) (*os.File, io.Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	var start int64
 	buf := make([]byte, 100)
@@ -416,13 +819,19 @@ func readJson(path string, dest interface{}) error {
 
 	f.Seek(start, 0)
 	if y > -1 && y > b {
-		return json.NewDecoder(&io.LimitedReader{R: f, N: n + int64(y) - start}).Decode(dest)
+		return f, &io.LimitedReader{R: f, N: n + int64(y) - start}, nil
 	}
-	return json.NewDecoder(f).Decode(dest)
+	return f, f, nil
+}
+
+func write(jsonpName string, content interface{}, funcs ...interface{}) *bytes.Buffer {
+	return writeWith(jsonpName, JSONOptions{}, content, funcs...)
 }
 
-func write(jsonpName string, content interface{}, funcs ...interface{}) io.Reader {
-	w := &bytes.Buffer{}
+// writeWith serializes content into a buffer from the pool, which callers
+// done with it may return with putBuffer.
+func writeWith(jsonpName string, opts JSONOptions, content interface{}, funcs ...interface{}) *bytes.Buffer {
+	w := getBuffer()
 	if jsonpName != "" {
 		fmt.Fprintln(w, "// Generated by gitdb. DO NOT EDIT.")
 		fmt.Fprintln(w, jsonpName+"(")
@@ -431,36 +840,18 @@ func write(jsonpName string, content interface{}, funcs ...interface{}) io.Reade
 	kind := rv.Kind()
 	if kind == reflect.Slice || kind == reflect.Array {
 		fmt.Fprintln(w, "[")
-	outer:
 		for i := 0; i < rv.Len(); i++ {
-			item := rv.Index(i)
-			for j := 0; j < len(funcs); j++ {
-				frv := reflect.ValueOf(funcs[j])
-				ret := frv.Call([]reflect.Value{item.Addr()})
-				if ret[0].IsNil() {
-					continue outer
-				}
-				item = ret[0].Elem()
-			}
-			elem := item.Interface()
-			if p, ok := elem.(Marshaler); ok {
-				fmt.Fprint(w, string(p.GITDBMarshalJSON()), ",")
-			} else {
-				j, _ := json.Marshal(elem)
-				fmt.Fprint(w, string(j), ",")
+			item, ok := applyFuncs(rv.Index(i), funcs)
+			if !ok {
+				continue
 			}
+			fmt.Fprint(w, string(marshalRecord(item.Interface(), opts)), ",")
 			fmt.Fprintln(w)
 		}
 		fmt.Fprintln(w, "null")
 		fmt.Fprint(w, "]")
-	} else if kind == reflect.Struct {
-		elem := rv.Interface()
-		if p, ok := elem.(Marshaler); ok {
-			fmt.Fprint(w, string(p.GITDBMarshalJSON()))
-		} else {
-			j, _ := json.Marshal(elem)
-			fmt.Fprint(w, string(j))
-		}
+	} else if kind == reflect.Struct || kind == reflect.Map {
+		fmt.Fprint(w, string(marshalRecord(rv.Interface(), opts)))
 	}
 	fmt.Fprintln(w)
 	if jsonpName != "" {
@@ -469,6 +860,27 @@ func write(jsonpName string, content interface{}, funcs ...interface{}) io.Reade
 	return w
 }
 
+// applyFuncs passes item through funcs, each a func(*T) *T, and reports
+// false if one of them dropped it by returning nil.
+func applyFuncs(item reflect.Value, funcs []interface{}) (reflect.Value, bool) {
+	for j := 0; j < len(funcs); j++ {
+		frv := reflect.ValueOf(funcs[j])
+		ret := frv.Call([]reflect.Value{item.Addr()})
+		if ret[0].IsNil() {
+			return item, false
+		}
+		item = ret[0].Elem()
+	}
+	return item, true
+}
+
+func marshalRecord(elem interface{}, opts JSONOptions) []byte {
+	if p, ok := elem.(Marshaler); ok {
+		return p.GITDBMarshalJSON()
+	}
+	return opts.marshal(elem)
+}
+
 func removeNulls(dest interface{}) {
 	rv := reflect.Indirect(reflect.ValueOf(dest))
 	for i := 0; i < rv.Len(); i++ {
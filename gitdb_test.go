@@ -0,0 +1,59 @@
+package gitdb
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	log.SetOutput(ioutil.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	db := NewDB("", dir)
+	db.SetUser("test", "test@example.com")
+	return db
+}
+
+// readTestFile returns the content of the file at path in db.
+func readTestFile(t *testing.T, db *DB, path string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(filepath.Join(db.Local, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func currentBranchOf(t *testing.T, db *DB) string {
+	t.Helper()
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return head.Name().Short()
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
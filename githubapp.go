@@ -0,0 +1,135 @@
+package gitdb
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// TokenSource returns a valid token for the hosting service API,
+	// refreshing it as needed.
+	TokenSource interface {
+		Token(ctx context.Context) (string, error)
+	}
+
+	// GitHubApp authenticates as a GitHub App installation. It can be used
+	// both as the auth method of a DB (see SetAuth) for HTTPS remotes and
+	// as the TokenSource of GitHub. Installation tokens are requested with
+	// a JWT signed by the app's private key and refreshed before they
+	// expire.
+	GitHubApp struct {
+		AppID          int64
+		InstallationID int64
+		PrivateKey     *rsa.PrivateKey
+		BaseURL        string
+		Client         *http.Client
+
+		mu      sync.Mutex
+		token   string
+		expires time.Time
+	}
+)
+
+func NewGitHubApp(appID, installationID int64, pemBytes []byte) (*GitHubApp, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("github app: no PEM data found")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		k, e := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if e != nil {
+			return nil, err
+		}
+		var ok bool
+		if key, ok = k.(*rsa.PrivateKey); !ok {
+			return nil, errors.New("github app: private key is not an RSA key")
+		}
+	}
+	return &GitHubApp{
+		AppID:          appID,
+		InstallationID: installationID,
+		PrivateKey:     key,
+	}, nil
+}
+
+func (a *GitHubApp) Name() string {
+	return "github-app"
+}
+
+func (a *GitHubApp) String() string {
+	return fmt.Sprintf("%s - app %d installation %d", a.Name(), a.AppID, a.InstallationID)
+}
+
+// SetAuth implements the AuthMethod of go-git's HTTP transport.
+func (a *GitHubApp) SetAuth(r *http.Request) {
+	token, err := a.Token(r.Context())
+	if err != nil {
+		log.Println("error getting github app token", err)
+		return
+	}
+	r.SetBasicAuth("x-access-token", token)
+}
+
+// Token returns the installation token, requesting a new one if the current
+// one expires in less than a minute.
+func (a *GitHubApp) Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && time.Until(a.expires) > time.Minute {
+		return a.token, nil
+	}
+	jwt, err := a.jwt()
+	if err != nil {
+		return "", err
+	}
+	base := a.BaseURL
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	var res struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	err = postJSON(ctx, a.Client, fmt.Sprintf("%s/app/installations/%d/access_tokens", strings.TrimSuffix(base, "/"), a.InstallationID), map[string]string{
+		"Authorization": "Bearer " + jwt,
+		"Accept":        "application/vnd.github+json",
+	}, struct{}{}, &res)
+	if err != nil {
+		return "", err
+	}
+	a.token, a.expires = res.Token, res.ExpiresAt
+	return a.token, nil
+}
+
+func (a *GitHubApp) jwt() (string, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	claims, _ := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.AppID,
+	})
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.PrivateKey, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
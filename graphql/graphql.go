@@ -0,0 +1,760 @@
+// Package graphql serves read-only GraphQL queries over the collections of
+// a gitdb.DB.
+//
+// Every collection created by NewCollection with a struct Model becomes a
+// query field named after its file, e.g. products for products.json,
+// returning a list of objects typed after the model:
+//
+//	{
+//	  products(where: {category: "books"}, orderBy: "-price", first: 10) {
+//	    id
+//	    name
+//	    category { name }
+//	  }
+//	}
+//
+// Fields tagged `gitdb:"ref=path#key"` also get a relation field resolving
+// to the referenced record, named after the field without its ID suffix.
+// Only queries are supported; fragments, directives and introspection are
+// not, use Schema.String for the schema.
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caiguanhao/gitdb"
+)
+
+type (
+	// Schema is the GraphQL schema generated from the typed collections of
+	// a DB. It is also an http.Handler serving queries.
+	Schema struct {
+		queries []*query
+		types   []*objectType
+	}
+
+	query struct {
+		name       string
+		collection *gitdb.Collection
+		typ        *objectType
+	}
+
+	objectType struct {
+		name   string
+		fields []*field
+	}
+
+	field struct {
+		name string
+		// typ is the GraphQL type, e.g. String or [Tag!].
+		typ    string
+		object *objectType
+		ref    *relation
+	}
+
+	relation struct {
+		// source is the field holding the keys of the related records.
+		source string
+		path   string
+		key    string
+		list   bool
+		query  *query
+	}
+
+	// Request is a GraphQL request as sent over HTTP.
+	Request struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}
+
+	// Response is the result of a query. Data is nil if there are errors.
+	Response struct {
+		Data   interface{} `json:"data"`
+		Errors []Error     `json:"errors,omitempty"`
+	}
+
+	Error struct {
+		Message string `json:"message"`
+	}
+
+	execution struct {
+		schema    *Schema
+		variables map[string]interface{}
+		records   map[string][]interface{}
+		indexes   map[string]map[string]interface{}
+	}
+
+	// orderedMap keeps the fields of a result object in the order they
+	// were selected.
+	orderedMap struct {
+		keys   []string
+		values map[string]interface{}
+	}
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// NewSchema builds the schema of the collections of db that have a struct
+// Model. Call it after the collections are set up.
+func NewSchema(db *gitdb.DB) (*Schema, error) {
+	s := &Schema{}
+	types := map[reflect.Type]*objectType{}
+	names := map[string]bool{"Query": true}
+	for _, c := range db.Collections() {
+		if c.Model == nil {
+			continue
+		}
+		t := reflect.TypeOf(c.Model)
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			continue
+		}
+		name := fieldName(strings.TrimSuffix(filepath.Base(c.Path), filepath.Ext(c.Path)))
+		for _, q := range s.queries {
+			if q.name == name {
+				return nil, fmt.Errorf("graphql: collections %s and %s are both named %s", q.collection.Path, c.Path, name)
+			}
+		}
+		s.queries = append(s.queries, &query{
+			name:       name,
+			collection: c,
+			typ:        s.objectType(t, types, names),
+		})
+	}
+	for _, typ := range s.types {
+		for _, f := range typ.fields {
+			if f.ref == nil {
+				continue
+			}
+			for _, q := range s.queries {
+				if filepath.Clean(q.collection.Path) == filepath.Clean(f.ref.path) {
+					f.ref.query = q
+				}
+			}
+			if f.ref.query == nil {
+				return nil, fmt.Errorf("graphql: %s.%s refers to %s, which has no typed collection", typ.name, f.name, f.ref.path)
+			}
+			f.object = f.ref.query.typ
+			if f.ref.list {
+				f.typ = "[" + f.object.name + "!]"
+			} else {
+				f.typ = f.object.name
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *Schema) objectType(t reflect.Type, types map[reflect.Type]*objectType, names map[string]bool) *objectType {
+	if typ, ok := types[t]; ok {
+		return typ
+	}
+	name := t.Name()
+	if name == "" {
+		name = "Object"
+	}
+	for n := 2; names[name]; n++ {
+		name = fmt.Sprintf("%s%d", strings.TrimRight(name, "0123456789"), n)
+	}
+	names[name] = true
+	typ := &objectType{name: name}
+	types[t] = typ
+	s.types = append(s.types, typ)
+	s.addFields(typ, t, types, names)
+	return typ
+}
+
+func (s *Schema) addFields(typ *objectType, t reflect.Type, types map[reflect.Type]*objectType, names map[string]bool) {
+	taken := map[string]bool{}
+	var refs []*field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Tag.Get("json") == "" {
+			s.addFields(typ, sf.Type, types, names)
+			continue
+		}
+		name := jsonName(sf)
+		if sf.PkgPath != "" || name == "" {
+			continue
+		}
+		f := &field{name: name}
+		f.typ, f.object = s.typeOf(sf.Type, types, names)
+		typ.fields = append(typ.fields, f)
+		taken[name] = true
+		if ref := refTag(sf); ref != "" {
+			path, key := ref, "id"
+			if i := strings.LastIndexByte(ref, '#'); i > -1 {
+				path, key = ref[:i], ref[i+1:]
+			}
+			ft := sf.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			list := ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array
+			refs = append(refs, &field{
+				name: relationName(name, list),
+				ref:  &relation{source: name, path: path, key: key, list: list},
+			})
+		}
+	}
+	for _, f := range refs {
+		for taken[f.name] {
+			f.name += "Ref"
+		}
+		taken[f.name] = true
+		typ.fields = append(typ.fields, f)
+	}
+}
+
+// typeOf returns the GraphQL type of Go type t, and the object type if it
+// is a struct or a list of them.
+func (s *Schema) typeOf(t reflect.Type, types map[reflect.Type]*objectType, names map[string]bool) (string, *objectType) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return "String", nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "Boolean", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "Int", nil
+	case reflect.Float32, reflect.Float64:
+		return "Float", nil
+	case reflect.String:
+		return "String", nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "String", nil
+		}
+		elem, object := s.typeOf(t.Elem(), types, names)
+		return "[" + elem + "]", object
+	case reflect.Struct:
+		if _, ok := reflect.New(t).Interface().(json.Marshaler); ok {
+			return "JSON", nil
+		}
+		typ := s.objectType(t, types, names)
+		return typ.name, typ
+	}
+	return "JSON", nil
+}
+
+// String returns the schema in the GraphQL schema definition language.
+func (s *Schema) String() string {
+	var b strings.Builder
+	b.WriteString("scalar JSON\n\ntype Query {\n")
+	for _, q := range s.queries {
+		fmt.Fprintf(&b, "  %s(where: %sWhere, orderBy: String, first: Int, offset: Int): [%s!]!\n", q.name, q.typ.name, q.typ.name)
+	}
+	b.WriteString("}\n")
+	for _, typ := range s.types {
+		fmt.Fprintf(&b, "\ntype %s {\n", typ.name)
+		for _, f := range typ.fields {
+			fmt.Fprintf(&b, "  %s: %s\n", f.name, f.typ)
+		}
+		b.WriteString("}\n")
+	}
+	for _, q := range s.queries {
+		fmt.Fprintf(&b, "\ninput %sWhere {\n", q.typ.name)
+		for _, f := range q.typ.fields {
+			if f.filterable() {
+				fmt.Fprintf(&b, "  %s: [%s]\n", f.name, f.typ)
+			}
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+// Execute runs the query of req against the current content of the
+// collections.
+func (s *Schema) Execute(req Request) Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return Response{Errors: []Error{{err.Error()}}}
+	}
+	e := &execution{
+		schema:    s,
+		variables: doc.variables,
+		records:   map[string][]interface{}{},
+		indexes:   map[string]map[string]interface{}{},
+	}
+	for k, v := range req.Variables {
+		e.variables[k] = v
+	}
+	data, err := e.root(doc.selection)
+	if err != nil {
+		return Response{Errors: []Error{{err.Error()}}}
+	}
+	return Response{Data: data}
+}
+
+// ServeHTTP serves queries sent as JSON in a POST body or in the query
+// and variables parameters of a GET request.
+func (s *Schema) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	switch r.Method {
+	case http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		if v := r.URL.Query().Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Execute(req))
+}
+
+func (e *execution) root(selections []selection) (*orderedMap, error) {
+	result := newOrderedMap()
+	for _, sel := range selections {
+		if sel.name == "__typename" {
+			result.set(sel.key(), "Query")
+			continue
+		}
+		var q *query
+		for _, candidate := range e.schema.queries {
+			if candidate.name == sel.name {
+				q = candidate
+			}
+		}
+		if q == nil {
+			return nil, fmt.Errorf("Query has no field %s", sel.name)
+		}
+		if sel.selection == nil {
+			return nil, fmt.Errorf("field %s must have a selection of subfields", sel.name)
+		}
+		records, err := e.list(q, sel.arguments)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, len(records))
+		for i, record := range records {
+			if values[i], err = e.object(q.typ, record, sel.selection); err != nil {
+				return nil, err
+			}
+		}
+		result.set(sel.key(), values)
+	}
+	return result, nil
+}
+
+// load returns the records of the collection of q as generic JSON values.
+func (e *execution) load(q *query) ([]interface{}, error) {
+	if records, ok := e.records[q.name]; ok {
+		return records, nil
+	}
+	typed := reflect.New(reflect.SliceOf(reflect.TypeOf(q.collection.Model)))
+	if err := q.collection.Read(typed.Interface()); err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(typed.Interface())
+	if err != nil {
+		return nil, err
+	}
+	var records []interface{}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&records); err != nil {
+		return nil, err
+	}
+	e.records[q.name] = records
+	return records, nil
+}
+
+func (e *execution) list(q *query, args map[string]interface{}) ([]interface{}, error) {
+	all, err := e.load(q)
+	if err != nil {
+		return nil, err
+	}
+	var where map[string]interface{}
+	var orderBy string
+	var first, offset int64 = -1, 0
+	for name, arg := range args {
+		value, err := e.resolve(arg)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+		var ok bool
+		switch name {
+		case "where":
+			where, ok = value.(map[string]interface{})
+		case "orderBy":
+			orderBy, ok = value.(string)
+		case "first":
+			first, ok = toInt(value)
+		case "offset":
+			offset, ok = toInt(value)
+		default:
+			return nil, fmt.Errorf("field %s has no argument %s", q.name, name)
+		}
+		if !ok {
+			return nil, fmt.Errorf("invalid value for argument %s of %s", name, q.name)
+		}
+	}
+	for key := range where {
+		var f *field
+		for _, candidate := range q.typ.fields {
+			if candidate.name == key {
+				f = candidate
+			}
+		}
+		if f == nil || !f.filterable() {
+			return nil, fmt.Errorf("cannot filter %s by %s", q.name, key)
+		}
+	}
+	records := make([]interface{}, 0, len(all))
+	for _, record := range all {
+		if matches(record, where) {
+			records = append(records, record)
+		}
+	}
+	if orderBy != "" {
+		desc := strings.HasPrefix(orderBy, "-")
+		key := strings.TrimPrefix(orderBy, "-")
+		sort.SliceStable(records, func(i, j int) bool {
+			c := compare(get(records[i], key), get(records[j], key))
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		})
+	}
+	if offset > int64(len(records)) {
+		offset = int64(len(records))
+	}
+	records = records[offset:]
+	if first >= 0 && first < int64(len(records)) {
+		records = records[:first]
+	}
+	return records, nil
+}
+
+// matches reports whether every field of where equals the one of record,
+// or one of them if it is a list.
+func matches(record interface{}, where map[string]interface{}) bool {
+	for key, want := range where {
+		got := jsonString(get(record, key))
+		list, ok := want.([]interface{})
+		if !ok {
+			list = []interface{}{want}
+		}
+		found := false
+		for _, w := range list {
+			if jsonString(w) == got {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *execution) object(typ *objectType, record interface{}, selections []selection) (interface{}, error) {
+	if record == nil {
+		return nil, nil
+	}
+	result := newOrderedMap()
+	for _, sel := range selections {
+		if sel.name == "__typename" {
+			result.set(sel.key(), typ.name)
+			continue
+		}
+		var f *field
+		for _, candidate := range typ.fields {
+			if candidate.name == sel.name {
+				f = candidate
+			}
+		}
+		if f == nil {
+			return nil, fmt.Errorf("%s has no field %s", typ.name, sel.name)
+		}
+		if len(sel.arguments) > 0 {
+			return nil, fmt.Errorf("field %s of %s takes no arguments", sel.name, typ.name)
+		}
+		if f.object == nil && sel.selection != nil {
+			return nil, fmt.Errorf("field %s of %s has no subfields", sel.name, typ.name)
+		}
+		if f.object != nil && sel.selection == nil {
+			return nil, fmt.Errorf("field %s of %s must have a selection of subfields", sel.name, typ.name)
+		}
+		value := get(record, f.name)
+		if f.ref != nil {
+			var err error
+			if value, err = e.related(f, get(record, f.ref.source)); err != nil {
+				return nil, err
+			}
+		}
+		if f.object != nil {
+			var err error
+			if value, err = e.objects(f.object, value, sel.selection); err != nil {
+				return nil, err
+			}
+		}
+		result.set(sel.key(), value)
+	}
+	return result, nil
+}
+
+// objects resolves the selection on value, an object or a list of them.
+func (e *execution) objects(typ *objectType, value interface{}, selections []selection) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return e.object(typ, value, selections)
+	}
+	values := make([]interface{}, len(list))
+	for i, item := range list {
+		var err error
+		if values[i], err = e.objects(typ, item, selections); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// related returns the records referenced by value through f.
+func (e *execution) related(f *field, value interface{}) (interface{}, error) {
+	q := f.ref.query
+	indexKey := q.name + "#" + f.ref.key
+	index, ok := e.indexes[indexKey]
+	if !ok {
+		records, err := e.load(q)
+		if err != nil {
+			return nil, err
+		}
+		index = map[string]interface{}{}
+		for _, record := range records {
+			if k := get(record, f.ref.key); k != nil {
+				index[jsonString(k)] = record
+			}
+		}
+		e.indexes[indexKey] = index
+	}
+	if list, ok := value.([]interface{}); ok {
+		records := []interface{}{}
+		for _, item := range list {
+			if record, ok := index[jsonString(item)]; ok {
+				records = append(records, record)
+			}
+		}
+		return records, nil
+	}
+	if value == nil {
+		return nil, nil
+	}
+	return index[jsonString(value)], nil
+}
+
+// resolve replaces the variables in an argument value with their values.
+func (e *execution) resolve(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case variable:
+		value, ok := e.variables[string(v)]
+		if !ok {
+			return nil, fmt.Errorf("variable $%s is not defined", v)
+		}
+		return value, nil
+	case enum:
+		return string(v), nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if list[i], err = e.resolve(item); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case map[string]interface{}:
+		object := map[string]interface{}{}
+		for k, item := range v {
+			var err error
+			if object[k], err = e.resolve(item); err != nil {
+				return nil, err
+			}
+		}
+		return object, nil
+	}
+	return value, nil
+}
+
+// filterable reports whether f holds a scalar usable in where.
+func (f *field) filterable() bool {
+	return f.ref == nil && f.object == nil && !strings.HasPrefix(f.typ, "[") && f.typ != "JSON"
+}
+
+func (s selection) key() string {
+	if s.alias != "" {
+		return s.alias
+	}
+	return s.name
+}
+
+func get(record interface{}, key string) interface{} {
+	if m, ok := record.(map[string]interface{}); ok {
+		return m[key]
+	}
+	return nil
+}
+
+func toInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, n >= 0
+	case float64:
+		return int64(n), n >= 0 && n == float64(int64(n))
+	}
+	return 0, false
+}
+
+// jsonString returns the JSON encoding of v, so numbers compare equal
+// however they were decoded.
+func jsonString(v interface{}) string {
+	if n, ok := v.(json.Number); ok {
+		if f, err := n.Float64(); err == nil {
+			v = f
+		}
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func compare(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	x, xok := a.(json.Number)
+	y, yok := b.(json.Number)
+	if xok && yok {
+		fx, _ := x.Float64()
+		fy, _ := y.Float64()
+		switch {
+		case fx < fy:
+			return -1
+		case fx > fy:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: map[string]interface{}{}}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func jsonName(sf reflect.StructField) string {
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if i := strings.IndexByte(tag, ','); i > -1 {
+		tag = tag[:i]
+	}
+	if tag == "" {
+		return sf.Name
+	}
+	return tag
+}
+
+func refTag(sf reflect.StructField) string {
+	for _, opt := range strings.Split(sf.Tag.Get("gitdb"), ",") {
+		if strings.HasPrefix(opt, "ref=") {
+			return strings.TrimPrefix(opt, "ref=")
+		}
+	}
+	return ""
+}
+
+// relationName derives the name of the relation field of the reference
+// field name, e.g. category for categoryId and tags for tagIds.
+func relationName(name string, list bool) string {
+	suffixes := []string{"ID", "Id", "_id"}
+	if list {
+		suffixes = []string{"IDs", "Ids", "_ids"}
+	}
+	for _, suffix := range suffixes {
+		if base := strings.TrimSuffix(name, suffix); base != name && base != "" {
+			if list {
+				return base + "s"
+			}
+			return base
+		}
+	}
+	return name + "Ref"
+}
+
+// fieldName turns a file name into a GraphQL name.
+func fieldName(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,294 @@
+package graphql
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caiguanhao/gitdb"
+)
+
+type (
+	category struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+
+	tag struct {
+		Slug  string `json:"slug"`
+		Label string `json:"label"`
+	}
+
+	size struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	}
+
+	product struct {
+		ID         int               `json:"id"`
+		Name       string            `json:"name"`
+		Price      float64           `json:"price"`
+		InStock    bool              `json:"inStock"`
+		CategoryID int               `json:"categoryId" gitdb:"ref=categories.json"`
+		TagIds     []string          `json:"tagIds" gitdb:"ref=tags.json#slug"`
+		Size       *size             `json:"size"`
+		Meta       map[string]string `json:"meta"`
+		CreatedAt  time.Time         `json:"createdAt"`
+		secret     string
+	}
+)
+
+var testFiles = map[string]string{
+	"categories.json": `[
+{"id":1,"name":"books"},
+{"id":2,"name":"games"}
+]
+`,
+	"tags.json": `[
+{"slug":"new","label":"New"},
+{"slug":"sale","label":"On sale"}
+]
+`,
+	"products.json": `[
+{"id":1,"name":"Go","price":30,"inStock":true,"categoryId":1,"tagIds":["new","sale"],"size":{"width":20,"height":25}},
+{"id":2,"name":"Chess","price":15.5,"inStock":false,"categoryId":2,"tagIds":["sale","gone"]},
+{"id":3,"name":"Rust","price":40,"inStock":true,"categoryId":1,"tagIds":[]},
+{"id":4,"name":"Cards","price":5,"inStock":true,"categoryId":9}
+]
+`,
+}
+
+func newTestSchema(t *testing.T) *Schema {
+	t.Helper()
+	db := gitdb.NewDB("", t.TempDir())
+	for name, content := range testFiles {
+		if err := ioutil.WriteFile(filepath.Join(db.Local, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.NewCollection("products.json").Model = product{}
+	db.NewCollection("categories.json").Model = category{}
+	db.NewCollection("tags.json").Model = tag{}
+	db.NewCollection("untyped.json")
+	s, err := NewSchema(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// execute runs query and returns its data as JSON.
+func execute(t *testing.T, s *Schema, query string, variables map[string]interface{}) string {
+	t.Helper()
+	res := s.Execute(Request{Query: query, Variables: variables})
+	if res.Errors != nil {
+		t.Fatalf("%s: %v", query, res.Errors)
+	}
+	b, err := json.Marshal(res.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSchemaString(t *testing.T) {
+	s := newTestSchema(t)
+	want := `scalar JSON
+
+type Query {
+  products(where: productWhere, orderBy: String, first: Int, offset: Int): [product!]!
+  categories(where: categoryWhere, orderBy: String, first: Int, offset: Int): [category!]!
+  tags(where: tagWhere, orderBy: String, first: Int, offset: Int): [tag!]!
+}
+
+type product {
+  id: Int
+  name: String
+  price: Float
+  inStock: Boolean
+  categoryId: Int
+  tagIds: [String]
+  size: size
+  meta: JSON
+  createdAt: String
+  category: category
+  tags: [tag!]
+}
+
+type size {
+  width: Int
+  height: Int
+}
+
+type category {
+  id: Int
+  name: String
+}
+
+type tag {
+  slug: String
+  label: String
+}
+
+input productWhere {
+  id: [Int]
+  name: [String]
+  price: [Float]
+  inStock: [Boolean]
+  categoryId: [Int]
+  createdAt: [String]
+}
+
+input categoryWhere {
+  id: [Int]
+  name: [String]
+}
+
+input tagWhere {
+  slug: [String]
+  label: [String]
+}
+`
+	if got := s.String(); got != want {
+		t.Errorf("got schema:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	db := gitdb.NewDB("", t.TempDir())
+	db.NewCollection("products.json").Model = product{}
+	db.NewCollection("categories.json").Model = category{}
+	if _, err := NewSchema(db); err == nil || !strings.Contains(err.Error(), "refers to tags.json") {
+		t.Errorf("got error %v for a missing referenced collection", err)
+	}
+
+	db = gitdb.NewDB("", t.TempDir())
+	db.NewCollection("tags.json").Model = tag{}
+	db.NewCollection("old/tags.json").Model = tag{}
+	if _, err := NewSchema(db); err == nil || !strings.Contains(err.Error(), "are both named tags") {
+		t.Errorf("got error %v for collections with the same name", err)
+	}
+}
+
+func TestArguments(t *testing.T) {
+	s := newTestSchema(t)
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`{ products { id } }`, `{"products":[{"id":1},{"id":2},{"id":3},{"id":4}]}`},
+		{`{ products(where: {inStock: true}) { id } }`, `{"products":[{"id":1},{"id":3},{"id":4}]}`},
+		{`{ products(where: {categoryId: [2, 9]}) { id } }`, `{"products":[{"id":2},{"id":4}]}`},
+		{`{ products(where: {categoryId: 1, name: "Rust"}) { id } }`, `{"products":[{"id":3}]}`},
+		{`{ products(where: {price: 15.5}) { name } }`, `{"products":[{"name":"Chess"}]}`},
+		{`{ products(where: {name: "Nothing"}) { id } }`, `{"products":[]}`},
+		{`{ products(orderBy: "price") { id } }`, `{"products":[{"id":4},{"id":2},{"id":1},{"id":3}]}`},
+		{`{ products(orderBy: "-name") { name } }`, `{"products":[{"name":"Rust"},{"name":"Go"},{"name":"Chess"},{"name":"Cards"}]}`},
+		{`{ products(first: 2) { id } }`, `{"products":[{"id":1},{"id":2}]}`},
+		{`{ products(first: 2, offset: 1) { id } }`, `{"products":[{"id":2},{"id":3}]}`},
+		{`{ products(offset: 3) { id } }`, `{"products":[{"id":4}]}`},
+		{`{ products(offset: 10) { id } }`, `{"products":[]}`},
+		{`{ products(first: 0) { id } }`, `{"products":[]}`},
+		{`{ products(where: {inStock: true}, orderBy: "-price", first: 1) { name } }`, `{"products":[{"name":"Rust"}]}`},
+		{`{ cheap: products(where: {price: [5, 15.5]}) { id } all: categories { name } }`, `{"cheap":[{"id":2},{"id":4}],"all":[{"name":"books"},{"name":"games"}]}`},
+		{`query Page($n: Int = 3, $c: [Int!]) { products(first: $n, where: {categoryId: $c}) { id } }`, `{"products":[{"id":1},{"id":3}]}`},
+		{`{ __typename products(first: 1) { __typename title: name } }`, `{"__typename":"Query","products":[{"__typename":"product","title":"Go"}]}`},
+	}
+	for _, test := range tests {
+		variables := map[string]interface{}{"c": []interface{}{1}}
+		if got := execute(t, s, test.query, variables); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.query, got, test.want)
+		}
+	}
+}
+
+func TestRelations(t *testing.T) {
+	s := newTestSchema(t)
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`{ products(first: 2) { name category { name } } }`, `{"products":[{"name":"Go","category":{"name":"books"}},{"name":"Chess","category":{"name":"games"}}]}`},
+		{`{ products(where: {id: 4}) { category { id } } }`, `{"products":[{"category":null}]}`},
+		{`{ products { id tags { label } } }`, `{"products":[{"id":1,"tags":[{"label":"New"},{"label":"On sale"}]},{"id":2,"tags":[{"label":"On sale"}]},{"id":3,"tags":[]},{"id":4,"tags":null}]}`},
+		{`{ products(first: 2) { size { width } } }`, `{"products":[{"size":{"width":20}},{"size":null}]}`},
+		{`{ products(first: 1) { tagIds meta } }`, `{"products":[{"tagIds":["new","sale"],"meta":null}]}`},
+	}
+	for _, test := range tests {
+		if got := execute(t, s, test.query, nil); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.query, got, test.want)
+		}
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	s := newTestSchema(t)
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{`{ orders { id } }`, "Query has no field orders"},
+		{`{ untyped { id } }`, "Query has no field untyped"},
+		{`{ products }`, "field products must have a selection of subfields"},
+		{`{ products { price { value } } }`, "field price of product has no subfields"},
+		{`{ products { category } }`, "field category of product must have a selection of subfields"},
+		{`{ products { secret } }`, "product has no field secret"},
+		{`{ products { name(upper: true) } }`, "field name of product takes no arguments"},
+		{`{ products(limit: 1) { id } }`, "field products has no argument limit"},
+		{`{ products(first: -1) { id } }`, "invalid value for argument first of products"},
+		{`{ products(where: "id") { id } }`, "invalid value for argument where of products"},
+		{`{ products(where: {tagIds: "new"}) { id } }`, "cannot filter products by tagIds"},
+		{`{ products(where: {category: 1}) { id } }`, "cannot filter products by category"},
+		{`{ products(first: $n) { id } }`, "variable $n is not defined"},
+	}
+	for _, test := range tests {
+		res := s.Execute(Request{Query: test.query})
+		if len(res.Errors) != 1 || res.Errors[0].Message != test.err || res.Data != nil {
+			t.Errorf("%s: got %+v, want error %s", test.query, res, test.err)
+		}
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	srv := httptest.NewServer(newTestSchema(t))
+	defer srv.Close()
+	want := `{"data":{"products":[{"name":"Chess"}]}}` + "\n"
+
+	query := `query($id: Int) { products(where: {id: $id}) { name } }`
+	res, err := http.Get(srv.URL + "?query=" + url.QueryEscape(query) + "&variables=" + url.QueryEscape(`{"id":2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(b) != want {
+		t.Errorf("GET: got %s, want %s", b, want)
+	}
+
+	body, _ := json.Marshal(Request{Query: query, Variables: map[string]interface{}{"id": 2}})
+	res, err = http.Post(srv.URL, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if string(b) != want {
+		t.Errorf("POST: got %s, want %s", b, want)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL, nil)
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE: got %d, want %d", res.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,303 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+type (
+	document struct {
+		variables map[string]interface{}
+		selection []selection
+	}
+
+	selection struct {
+		alias     string
+		name      string
+		arguments map[string]interface{}
+		selection []selection
+	}
+
+	// variable is an argument value referring to a query variable.
+	variable string
+
+	// enum is an unquoted argument value such as ASC.
+	enum string
+
+	token struct {
+		kind  byte // one of the punctuators, 'n' for names, '0' for numbers, '"' for strings or 0 at the end
+		value string
+		pos   int
+	}
+
+	parser struct {
+		src string
+		pos int
+		tok token
+	}
+)
+
+// parse parses a query document with a single anonymous or named query
+// operation. Fragments and directives are not supported.
+func parse(src string) (doc *document, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(syntaxError); ok {
+				doc, err = nil, e
+				return
+			}
+			panic(r)
+		}
+	}()
+	p := &parser{src: src}
+	p.next()
+	doc = &document{variables: map[string]interface{}{}}
+	if p.tok.kind == 'n' {
+		switch p.tok.value {
+		case "query":
+			p.next()
+		case "mutation", "subscription":
+			p.fail("%s operations are not supported", p.tok.value)
+		case "fragment":
+			p.fail("fragments are not supported")
+		default:
+			p.fail("unexpected %s", p.tok.value)
+		}
+		if p.tok.kind == 'n' {
+			p.next()
+		}
+		if p.tok.kind == '(' {
+			p.variableDefinitions(doc)
+		}
+	}
+	doc.selection = p.selectionSet()
+	if p.tok.kind != 0 {
+		p.fail("only one operation is supported")
+	}
+	return doc, nil
+}
+
+type syntaxError string
+
+func (e syntaxError) Error() string {
+	return string(e)
+}
+
+func (p *parser) fail(format string, args ...interface{}) {
+	panic(syntaxError(fmt.Sprintf("syntax error at %d: ", p.tok.pos) + fmt.Sprintf(format, args...)))
+}
+
+func (p *parser) expect(kind byte) token {
+	if p.tok.kind != kind {
+		if p.tok.kind == 0 {
+			p.fail("unexpected end of query, expecting %s", tokenName(kind))
+		}
+		p.fail("unexpected %q, expecting %s", p.tok.value, tokenName(kind))
+	}
+	tok := p.tok
+	p.next()
+	return tok
+}
+
+func (p *parser) name() string {
+	return p.expect('n').value
+}
+
+// variableDefinitions records the default values of the variables; their
+// types are not checked.
+func (p *parser) variableDefinitions(doc *document) {
+	p.expect('(')
+	for p.tok.kind != ')' {
+		p.expect('$')
+		name := p.name()
+		p.expect(':')
+		p.typeRef()
+		if p.tok.kind == '=' {
+			p.next()
+			doc.variables[name] = p.value()
+		}
+	}
+	p.next()
+}
+
+func (p *parser) typeRef() {
+	if p.tok.kind == '[' {
+		p.next()
+		p.typeRef()
+		p.expect(']')
+	} else {
+		p.name()
+	}
+	if p.tok.kind == '!' {
+		p.next()
+	}
+}
+
+func (p *parser) selectionSet() []selection {
+	p.expect('{')
+	var selections []selection
+	for p.tok.kind != '}' {
+		if p.tok.kind == '.' {
+			p.fail("fragments are not supported")
+		}
+		s := selection{name: p.name()}
+		if p.tok.kind == ':' {
+			p.next()
+			s.alias, s.name = s.name, p.name()
+		}
+		if p.tok.kind == '(' {
+			p.next()
+			s.arguments = map[string]interface{}{}
+			for p.tok.kind != ')' {
+				name := p.name()
+				p.expect(':')
+				s.arguments[name] = p.value()
+			}
+			p.next()
+		}
+		if p.tok.kind == '@' {
+			p.fail("directives are not supported")
+		}
+		if p.tok.kind == '{' {
+			s.selection = p.selectionSet()
+		}
+		selections = append(selections, s)
+	}
+	p.next()
+	return selections
+}
+
+func (p *parser) value() interface{} {
+	switch tok := p.tok; tok.kind {
+	case '$':
+		p.next()
+		return variable(p.name())
+	case '0':
+		if i, err := strconv.ParseInt(tok.value, 10, 64); err == nil {
+			p.next()
+			return i
+		}
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			p.fail("invalid number %s", tok.value)
+		}
+		p.next()
+		return f
+	case '"':
+		p.next()
+		return tok.value
+	case 'n':
+		p.next()
+		switch tok.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return enum(tok.value)
+	case '[':
+		p.next()
+		list := []interface{}{}
+		for p.tok.kind != ']' {
+			list = append(list, p.value())
+		}
+		p.next()
+		return list
+	case '{':
+		p.next()
+		object := map[string]interface{}{}
+		for p.tok.kind != '}' {
+			name := p.name()
+			p.expect(':')
+			object[name] = p.value()
+		}
+		p.next()
+		return object
+	}
+	p.fail("unexpected %q, expecting a value", p.tok.value)
+	return nil
+}
+
+func (p *parser) next() {
+	// skip ignored tokens: white space, line terminators, commas, comments
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != ',' {
+			break
+		}
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{pos: start}
+		return
+	}
+	c := p.src[p.pos]
+	switch {
+	case c == '.':
+		if len(p.src) < p.pos+3 || p.src[p.pos:p.pos+3] != "..." {
+			p.tok = token{kind: c, value: ".", pos: start}
+			p.fail("unexpected .")
+		}
+		p.pos += 3
+		p.tok = token{kind: '.', value: "...", pos: start}
+	case isNameStart(c):
+		for p.pos < len(p.src) && (isNameStart(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: 'n', value: p.src[start:p.pos], pos: start}
+	case c == '-' || isDigit(c):
+		p.pos++
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.' || p.src[p.pos] == 'e' || p.src[p.pos] == 'E' ||
+			(p.src[p.pos] == '+' || p.src[p.pos] == '-') && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E')) {
+			p.pos++
+		}
+		p.tok = token{kind: '0', value: p.src[start:p.pos], pos: start}
+	case c == '"':
+		p.pos++
+		for p.pos < len(p.src) && p.src[p.pos] != '"' {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		if p.pos >= len(p.src) {
+			p.tok = token{kind: '"', pos: start}
+			p.fail("unterminated string")
+		}
+		p.pos++
+		var s string
+		if err := json.Unmarshal([]byte(p.src[start:p.pos]), &s); err != nil {
+			p.tok = token{kind: '"', pos: start}
+			p.fail("invalid string %s", p.src[start:p.pos])
+		}
+		p.tok = token{kind: '"', value: s, pos: start}
+	default:
+		p.pos++
+		p.tok = token{kind: c, value: string(c), pos: start}
+	}
+}
+
+func tokenName(kind byte) string {
+	if kind == 'n' {
+		return "a name"
+	}
+	return strconv.Quote(string(kind))
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
@@ -0,0 +1,76 @@
+package graphql
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	doc, err := parse(`
+# products on sale
+query Sale($first: Int = 10, $tags: [String!]! = ["sale"]) {
+  p: products(where: {tagIds: $tags, price: 1.5e1, inStock: true, note: null}, orderBy: DESC, first: $first, offset: -2) {
+    id, name
+    category { name }
+  }
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := jsonDump(doc.variables); got != `{"first":10,"tags":["sale"]}` {
+		t.Errorf("got variables %s", got)
+	}
+	if len(doc.selection) != 1 {
+		t.Fatalf("got %d selections, want 1", len(doc.selection))
+	}
+	s := doc.selection[0]
+	if s.alias != "p" || s.name != "products" || s.key() != "p" {
+		t.Errorf("got alias %q and name %q", s.alias, s.name)
+	}
+	if got := jsonDump(s.arguments); got != `{"first":"first","offset":-2,"orderBy":"DESC","where":{"inStock":true,"note":null,"price":15,"tagIds":"tags"}}` {
+		t.Errorf("got arguments %s", got)
+	}
+	if _, ok := s.arguments["first"].(variable); !ok {
+		t.Errorf("first is %T, want a variable", s.arguments["first"])
+	}
+	if _, ok := s.arguments["orderBy"].(enum); !ok {
+		t.Errorf("orderBy is %T, want an enum", s.arguments["orderBy"])
+	}
+	if len(s.selection) != 3 || s.selection[2].name != "category" || len(s.selection[2].selection) != 1 {
+		t.Errorf("got selection %+v", s.selection)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{``, `syntax error at 0: unexpected end of query, expecting "{"`},
+		{`{ products { id }`, `syntax error at 17: unexpected end of query, expecting a name`},
+		{`{ products(first 1) { id } }`, `syntax error at 17: unexpected "1", expecting ":"`},
+		{`{ products(first: ) { id } }`, `syntax error at 18: unexpected ")", expecting a value`},
+		{`{ products(where: {name: "a) { id } }`, `syntax error at 25: unterminated string`},
+		{`{ products(where: {name: "\x"}) { id } }`, `syntax error at 25: invalid string "\x"`},
+		{`{ products(first: 1.2.3) { id } }`, `syntax error at 18: invalid number 1.2.3`},
+		{`{ products { ..info } }`, `syntax error at 13: unexpected .`},
+		{`{ products { ...info } }`, `syntax error at 13: fragments are not supported`},
+		{`{ products @skip(if: true) { id } }`, `syntax error at 11: directives are not supported`},
+		{`mutation { products { id } }`, `syntax error at 0: mutation operations are not supported`},
+		{`fragment f on product { id }`, `syntax error at 0: fragments are not supported`},
+		{`schema { query: Query }`, `syntax error at 0: unexpected schema`},
+		{`query($n: ) { products { id } }`, `syntax error at 10: unexpected ")", expecting a name`},
+		{`{ products { id } } { tags { slug } }`, `syntax error at 20: only one operation is supported`},
+	}
+	for _, test := range tests {
+		_, err := parse(test.query)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %s", test.query, err, test.err)
+		}
+	}
+}
+
+func jsonDump(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
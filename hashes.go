@@ -0,0 +1,88 @@
+package gitdb
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+type (
+	// recordHashes is the sidecar of a collection kept inside the .git
+	// directory, holding the hashes of its records by key, so Upsert can
+	// tell that records are unchanged without reading the collection.
+	recordHashes struct {
+		KeyField string            `json:"keyField"`
+		File     string            `json:"file"`
+		Records  map[string]string `json:"records"`
+	}
+)
+
+func (c Collection) hashesPath() string {
+	return filepath.Join(gitDir(c.db.Local), "gitdb", "hashes", url.PathEscape(filepath.ToSlash(c.Path))+".json")
+}
+
+// hashRecords returns the hashes of records by key, as they would be
+// written.
+func (c Collection) hashRecords(records reflect.Value, keyField string) (map[string]string, bool) {
+	prepared := reflect.ValueOf(prepare(records.Interface(), c.computed))
+	hashes := make(map[string]string, prepared.Len())
+	for i := 0; i < prepared.Len(); i++ {
+		key, ok := keyOf(prepared.Index(i), keyField)
+		if !ok {
+			return nil, false
+		}
+		sum := sha256.Sum256(marshalRecord(prepared.Index(i).Interface(), c.jsonOptions()))
+		hashes[key] = hex.EncodeToString(sum[:])
+	}
+	return hashes, true
+}
+
+// unchangedRecords reports whether the sidecar shows that upserting
+// records would change nothing. Only single file collections have one.
+func (c Collection) unchangedRecords(records reflect.Value, keyField string, deleteMissing bool) bool {
+	b, err := ioutil.ReadFile(c.hashesPath())
+	if err != nil {
+		return false
+	}
+	var sidecar recordHashes
+	if json.Unmarshal(b, &sidecar) != nil || sidecar.KeyField != keyField {
+		return false
+	}
+	if sum, err := fileChecksum(filepath.Join(c.db.Local, c.Path)); err != nil || sum != sidecar.File {
+		return false
+	}
+	hashes, ok := c.hashRecords(records, keyField)
+	if !ok {
+		return false
+	}
+	for key, hash := range hashes {
+		if sidecar.Records[key] != hash {
+			return false
+		}
+	}
+	return !deleteMissing || len(hashes) == len(sidecar.Records)
+}
+
+// saveRecordHashes writes the sidecar of records, the content of the
+// collection, or removes it if the collection is chunked or split into
+// record files.
+func (c Collection) saveRecordHashes(records reflect.Value, keyField string) {
+	path := filepath.Join(c.db.Local, c.Path)
+	sum, err := fileChecksum(path)
+	hashes, ok := c.hashRecords(records, keyField)
+	if m, _ := readManifest(path); err != nil || m != nil || !ok {
+		os.Remove(c.hashesPath())
+		return
+	}
+	b, err := json.Marshal(recordHashes{KeyField: keyField, File: sum, Records: hashes})
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(c.hashesPath()), 0755)
+	ioutil.WriteFile(c.hashesPath(), b, 0644)
+}
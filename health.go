@@ -0,0 +1,107 @@
+package gitdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+type (
+	HealthReport struct {
+		Checks []HealthCheck
+	}
+
+	HealthCheck struct {
+		Name     string
+		Err      error
+		Duration time.Duration
+	}
+)
+
+func (r HealthReport) Healthy() bool {
+	return r.Err() == nil
+}
+
+// Err returns an error describing every failed check, or nil.
+func (r HealthReport) Err() error {
+	var failed []string
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			failed = append(failed, c.Name+": "+c.Err.Error())
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return errors.New("unhealthy: " + strings.Join(failed, "; "))
+}
+
+func (r *HealthReport) run(name string, check func() error) bool {
+	start := time.Now()
+	err := check()
+	r.Checks = append(r.Checks, HealthCheck{
+		Name:     name,
+		Err:      err,
+		Duration: time.Since(start),
+	})
+	return err == nil
+}
+
+// HealthCheck verifies that the local repository opens, HEAD resolves, the
+// remote is reachable with the configured credentials and the worktree is
+// clean. Checks depending on the local repository are skipped when it cannot
+// be opened.
+func (db DB) HealthCheck(ctx context.Context) HealthReport {
+	var report HealthReport
+	var r *git.Repository
+	if !report.run("open", func() (err error) {
+		r, err = git.PlainOpen(db.Local)
+		return
+	}) {
+		return report
+	}
+	report.run("head", func() error {
+		_, err := r.Head()
+		return err
+	})
+	report.run("remote", func() error {
+		if err := db.checkRemotes(r); err != nil {
+			return err
+		}
+		remote, err := r.Remote(db.GetRemoteName())
+		if db.Remote != "" {
+			remote, err = git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+				Name: db.GetRemoteName(),
+				URLs: []string{db.Remote},
+			}), nil
+		}
+		if err != nil {
+			return err
+		}
+		_, err = remote.ListContext(ctx, &git.ListOptions{
+			Auth: db.authMethod(),
+		})
+		return err
+	})
+	report.run("worktree", func() error {
+		w, err := r.Worktree()
+		if err != nil {
+			return err
+		}
+		s, err := w.Status()
+		if err != nil {
+			return err
+		}
+		if !s.IsClean() {
+			return fmt.Errorf("%d uncommitted changes", len(s))
+		}
+		return nil
+	})
+	return report
+}
@@ -0,0 +1,178 @@
+package gitdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type (
+	// FileChange is a file changed by a commit or a push.
+	FileChange struct {
+		Path string
+		// Before and After are the contents of the file, nil if it did
+		// not exist.
+		Before []byte
+		After  []byte
+
+		// Records are the record changes of a file holding records,
+		// as returned by ChangesSince.
+		Records *CollectionChanges
+	}
+
+	// Hook is called with the changes about to be committed or pushed,
+	// sorted by path. An error stops the commit or the push.
+	Hook func(changes []FileChange) error
+)
+
+// AddPreCommitHook makes Commit, and every operation committing, call hook
+// with the staged changes before committing them.
+func (db *DB) AddPreCommitHook(hook Hook) {
+	db.preCommitHooks = append(db.preCommitHooks, hook)
+}
+
+// AddPrePushHook makes Push call hook with the changes between the remote
+// branch and HEAD before pushing them.
+func (db *DB) AddPrePushHook(hook Hook) {
+	db.prePushHooks = append(db.prePushHooks, hook)
+}
+
+// runPreCommitHooks calls the pre-commit hooks with the staged changes of
+// s.
+func (db DB) runPreCommitHooks(r *git.Repository, s git.Status) error {
+	if len(db.preCommitHooks) == 0 {
+		return nil
+	}
+	tree, err := headTree(r)
+	if err != nil {
+		return err
+	}
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return err
+	}
+	staged := map[string]plumbing.Hash{}
+	for _, e := range idx.Entries {
+		staged[e.Name] = e.Hash
+	}
+	var changes []FileChange
+	for name, fs := range s {
+		if fs.Staging == git.Unmodified || fs.Staging == git.Untracked {
+			continue
+		}
+		change := FileChange{Path: name}
+		if tree != nil {
+			if change.Before, err = fileContents(tree.File(name)); err != nil {
+				return err
+			}
+		}
+		if hash, ok := staged[name]; ok && fs.Staging != git.Deleted {
+			blob, err := r.BlobObject(hash)
+			if err != nil {
+				return err
+			}
+			if change.After, err = blobContents(blob); err != nil {
+				return err
+			}
+		}
+		changes = append(changes, change)
+	}
+	return runHooks("pre-commit", db.preCommitHooks, changes)
+}
+
+// runPrePushHooks calls the pre-push hooks with the changes between the
+// remote branch, if any, and the tree to push.
+func (db DB) runPrePushHooks(r *git.Repository, to *object.Tree) error {
+	if len(db.prePushHooks) == 0 || to == nil {
+		return nil
+	}
+	from := &object.Tree{}
+	remote := plumbing.NewRemoteReferenceName(db.GetRemoteName(), db.GetBranchName())
+	if ref, err := r.Reference(remote, true); err == nil {
+		commit, err := r.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+		if from, err = commit.Tree(); err != nil {
+			return err
+		}
+	} else if err != plumbing.ErrReferenceNotFound {
+		return err
+	}
+	changes, err := treeChanges(from, to)
+	if err != nil {
+		return err
+	}
+	return runHooks("pre-push", db.prePushHooks, changes)
+}
+
+// treeChanges returns the files changed between the trees from and to.
+func treeChanges(from, to *object.Tree) ([]FileChange, error) {
+	diff, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var changes []FileChange
+	for _, d := range diff {
+		before, after, err := d.Files()
+		if err != nil {
+			return nil, err
+		}
+		change := FileChange{Path: d.To.Name}
+		if change.Path == "" {
+			change.Path = d.From.Name
+		}
+		if before != nil {
+			if change.Before, err = blobContents(&before.Blob); err != nil {
+				return nil, err
+			}
+		}
+		if after != nil {
+			if change.After, err = blobContents(&after.Blob); err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func runHooks(name string, hooks []Hook, changes []FileChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	for _, hook := range hooks {
+		if err := hook(changes); err != nil {
+			return fmt.Errorf("%s hook: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// fileContents returns the contents of the file returned by tree.File, nil
+// if it does not exist.
+func fileContents(f *object.File, err error) ([]byte, error) {
+	if err == object.ErrFileNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return blobContents(&f.Blob)
+}
+
+func blobContents(blob *object.Blob) ([]byte, error) {
+	rd, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	return ioutil.ReadAll(rd)
+}
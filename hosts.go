@@ -0,0 +1,72 @@
+package gitdb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// ErrHostNotAllowed is returned when a remote URL points to a host missing
+// from the hosts set by SetAllowedHosts.
+var ErrHostNotAllowed = errors.New("host not allowed")
+
+// SetAllowedHosts restricts the remotes to URLs of hosts, like
+// "github.com", or of subdomains for hosts starting with "*.", like
+// "*.internal". Init, Pull, ForceUpdate, Push and the other operations
+// talking to a remote check Remote, FallbackRemotes and the URLs of the
+// remote of the local repository first, failing with ErrHostNotAllowed, so
+// a misconfigured or injected URL never receives data. Local paths and
+// file URLs need "localhost" to be allowed. No hosts allows every URL.
+func (db *DB) SetAllowedHosts(hosts ...string) {
+	db.allowedHosts = hosts
+}
+
+// checkRemotes checks Remote, FallbackRemotes and, if r is not nil, the
+// URLs of the remote of r against the allowed hosts.
+func (db DB) checkRemotes(r *git.Repository) error {
+	if len(db.allowedHosts) == 0 {
+		return nil
+	}
+	urls := append([]string{db.Remote}, db.FallbackRemotes...)
+	if r != nil {
+		if remote, err := r.Remote(db.GetRemoteName()); err == nil {
+			urls = append(urls, remote.Config().URLs...)
+		} else if err != git.ErrRemoteNotFound {
+			return err
+		}
+	}
+	for _, url := range urls {
+		if url == "" {
+			continue
+		}
+		if err := db.checkHost(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db DB) checkHost(url string) error {
+	ep, err := transport.NewEndpoint(url)
+	if err != nil {
+		return err
+	}
+	host := strings.ToLower(ep.Host)
+	if ep.Protocol == "file" {
+		host = "localhost"
+	}
+	for _, allowed := range db.allowedHosts {
+		allowed = strings.ToLower(allowed)
+		if host == allowed || strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return nil
+		}
+	}
+	if ep.Protocol == "file" {
+		return fmt.Errorf("remote %s: %w", ep.Path, ErrHostNotAllowed)
+	}
+	// the URL itself may hold credentials
+	return fmt.Errorf("remote %s://%s: %w", ep.Protocol, ep.Host, ErrHostNotAllowed)
+}
@@ -0,0 +1,236 @@
+package gitdb
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type (
+	journalEntry struct {
+		Op   string    `json:"op"`
+		Path string    `json:"path"`
+		Time time.Time `json:"time"`
+	}
+
+	RecoveryReport struct {
+		// Replayed lists the paths whose pending changes were committed.
+		Replayed []string
+
+		// Discarded lists the paths restored to HEAD because their
+		// content could not be parsed.
+		Discarded []string
+	}
+)
+
+// The journal lives inside the .git directory so it is never committed.
+func (db DB) journalPath() string {
+	return filepath.Join(gitDir(db.Local), "gitdb", "journal")
+}
+
+// gitDir returns the git directory of the repository at local, following
+// the .git file of a submodule.
+func gitDir(local string) string {
+	dir := filepath.Join(local, ".git")
+	b, err := ioutil.ReadFile(dir)
+	if err != nil {
+		return dir
+	}
+	line := strings.TrimSpace(string(b))
+	if !strings.HasPrefix(line, "gitdir:") {
+		return dir
+	}
+	path := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(local, path)
+	}
+	return path
+}
+
+// journal records that path is about to be changed by op, before the change
+// is made.
+func (db DB) journal(op, path string) error {
+	s := db.state()
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+	name := db.journalPath()
+	if _, err := os.Stat(filepath.Join(db.Local, ".git")); err != nil {
+		// not a repository yet, nothing could be committed
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	b, err := json.Marshal(journalEntry{Op: op, Path: path, Time: time.Now()})
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func (db DB) readJournal() ([]journalEntry, error) {
+	f, err := os.Open(db.journalPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []journalEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry journalEntry
+		// a torn last line means the change it announced never started
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func (db DB) writeJournal(entries []journalEntry) error {
+	s := db.state()
+	s.journalMu.Lock()
+	defer s.journalMu.Unlock()
+	if len(entries) == 0 {
+		err := os.Remove(db.journalPath())
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var buf strings.Builder
+	for _, entry := range entries {
+		b, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(b, '\n'))
+	}
+	return ioutil.WriteFile(db.journalPath(), []byte(buf.String()), 0644)
+}
+
+// pruneJournal drops the entries of paths that no longer differ from HEAD.
+func (db DB) pruneJournal(status git.Status) error {
+	entries, err := db.readJournal()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	var pending []journalEntry
+	for _, entry := range entries {
+		if fs, ok := status[filepath.ToSlash(entry.Path)]; ok && (fs.Staging != git.Unmodified || fs.Worktree != git.Unmodified) {
+			pending = append(pending, entry)
+		}
+	}
+	return db.writeJournal(pending)
+}
+
+func (db DB) MustRecover() RecoveryReport {
+	report, err := db.Recover()
+	if err != nil {
+		panic(err)
+	}
+	return report
+}
+
+// Recover finishes the changes recorded in the journal that were never
+// committed, for example because the process crashed between Write and
+// Commit. Files that still parse are committed; files left unparsable by a
+// partial write are restored from HEAD.
+func (db DB) Recover() (report RecoveryReport, err error) {
+	entries, err := db.readJournal()
+	if err != nil || len(entries) == 0 {
+		return
+	}
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return
+	}
+	status, err := w.Status()
+	if err != nil {
+		return
+	}
+	var head *object.Tree
+	if ref, e := r.Head(); e == nil {
+		if commit, e := r.CommitObject(ref.Hash()); e == nil {
+			head, _ = commit.Tree()
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		path := filepath.ToSlash(entry.Path)
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		if fs, ok := status[path]; !ok || (fs.Staging == git.Unmodified && fs.Worktree == git.Unmodified) {
+			continue
+		}
+		full := filepath.Join(db.Local, entry.Path)
+		if _, e := os.Stat(full); e == nil {
+			var v interface{}
+			if e := readJson(full, &v); e != nil {
+				if err = restoreFile(head, path, full); err != nil {
+					return
+				}
+				report.Discarded = append(report.Discarded, entry.Path)
+				continue
+			}
+		}
+		if _, err = w.Add(path); err != nil {
+			return
+		}
+		report.Replayed = append(report.Replayed, entry.Path)
+	}
+	if len(report.Replayed) > 0 {
+		log.Println("recovering", strings.Join(report.Replayed, ", "))
+		err = db.commitOp(CommitInfo{
+			Op:      "recover",
+			Paths:   report.Replayed,
+			Message: fmt.Sprintf("recover %s", strings.Join(report.Replayed, ", ")),
+		})
+		if err != nil {
+			return
+		}
+	}
+	err = db.writeJournal(nil)
+	return
+}
+
+// restoreFile writes the content path has in tree to full, or removes full
+// if tree does not contain path.
+func restoreFile(tree *object.Tree, path, full string) error {
+	if tree != nil {
+		if f, err := tree.File(path); err == nil {
+			content, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			return ioutil.WriteFile(full, []byte(content), 0644)
+		}
+	}
+	return os.Remove(full)
+}
@@ -0,0 +1,819 @@
+package gitdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type (
+	// jqExpr is a parsed jq filter. eval returns its outputs for input.
+	jqExpr interface {
+		eval(input interface{}) ([]interface{}, error)
+	}
+
+	jqIdentity struct{}
+	jqLiteral  struct{ value interface{} }
+	jqField    struct {
+		x        jqExpr
+		name     string
+		optional bool
+	}
+	jqIndex struct {
+		x, index jqExpr
+		optional bool
+	}
+	jqIterate struct {
+		x        jqExpr
+		optional bool
+	}
+	jqPipe   struct{ x, y jqExpr }
+	jqComma  struct{ x, y jqExpr }
+	jqBinary struct {
+		op   string
+		x, y jqExpr
+	}
+	jqObject struct {
+		keys   []string
+		values []jqExpr
+	}
+	jqArray struct{ x jqExpr }
+	jqCall  struct {
+		name string
+		arg  jqExpr
+	}
+
+	jqParser struct {
+		src string
+		pos int
+	}
+
+	// jqStep is one step of a simple path, a field name or an index.
+	jqStep struct {
+		name  string
+		index int
+		field bool
+	}
+)
+
+func (o Object) MustReadPath(expr string, dest interface{}) {
+	if err := o.ReadPath(expr, dest); err != nil {
+		panic(err)
+	}
+}
+
+// ReadPath decodes into dest the part of the object selected by the jq
+// filter expr, e.g. ".settings.theme". Filters made of field names and
+// indexes only are decoded while reading, skipping the rest of the file;
+// others are evaluated on the whole document like Collection.Select. A
+// path that does not exist leaves dest unchanged, like null.
+func (o Object) ReadPath(expr string, dest interface{}) error {
+	path := filepath.Join(o.db.Local, o.Path)
+	if steps, ok := simpleJQPath(expr); ok {
+		return readJsonPath(path, steps, dest, o.db.jsonOptions())
+	}
+	x, err := parseJQ(expr)
+	if err != nil {
+		return err
+	}
+	var doc interface{}
+	if err := readJsonWith(path, &doc, JSONOptions{UseNumber: true}); err != nil {
+		return err
+	}
+	outputs, err := x.eval(doc)
+	if err != nil {
+		return err
+	}
+	return decodeJQOutputs(outputs, dest, o.db.jsonOptions())
+}
+
+func (c Collection) MustSelect(expr string, dest interface{}) {
+	if err := c.Select(expr, dest); err != nil {
+		panic(err)
+	}
+}
+
+// Select runs the jq filter expr on the records of the collection, as an
+// array, and decodes its outputs into dest, e.g. ".[] | {id, name}". It
+// supports paths (.a.b, .[0], .[], ."key"), the ? operator, pipes, commas,
+// object and array construction, literals, comparisons, and, or, and the
+// select, map, length, keys and not functions. If dest is a pointer to a
+// slice, it receives every output, otherwise there must be exactly one.
+func (c Collection) Select(expr string, dest interface{}) error {
+	x, err := parseJQ(expr)
+	if err != nil {
+		return err
+	}
+	records := []interface{}{}
+	generic := c
+	generic.JSON.UseNumber = true
+	if err := generic.Read(&records); err != nil {
+		return err
+	}
+	outputs, err := x.eval(records)
+	if err != nil {
+		return err
+	}
+	return decodeJQOutputs(outputs, dest, c.jsonOptions())
+}
+
+func decodeJQOutputs(outputs []interface{}, dest interface{}, opts JSONOptions) error {
+	var value interface{}
+	if rv := reflect.ValueOf(dest); rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Slice {
+		if outputs == nil {
+			outputs = []interface{}{}
+		}
+		value = outputs
+	} else if len(outputs) == 1 {
+		value = outputs[0]
+	} else {
+		return fmt.Errorf("jq filter returned %d values, decode them into a slice", len(outputs))
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return opts.decode(dest, func(dest interface{}) error {
+		return json.Unmarshal(b, dest)
+	})
+}
+
+// simpleJQPath returns the steps of expr if it only accesses fields and
+// indexes, like .a.b[0]."c d".
+func simpleJQPath(expr string) ([]jqStep, bool) {
+	x, err := parseJQ(expr)
+	if err != nil {
+		return nil, false
+	}
+	var steps []jqStep
+	for {
+		switch e := x.(type) {
+		case jqIdentity:
+			for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+				steps[i], steps[j] = steps[j], steps[i]
+			}
+			return steps, true
+		case jqField:
+			if e.optional {
+				return nil, false
+			}
+			steps = append(steps, jqStep{name: e.name, field: true})
+			x = e.x
+		case jqIndex:
+			lit, ok := e.index.(jqLiteral)
+			if !ok || e.optional {
+				return nil, false
+			}
+			switch v := lit.value.(type) {
+			case json.Number:
+				n, err := strconv.Atoi(v.String())
+				if err != nil || n < 0 {
+					return nil, false
+				}
+				steps = append(steps, jqStep{index: n})
+			case string:
+				steps = append(steps, jqStep{name: v, field: true})
+			default:
+				return nil, false
+			}
+			x = e.x
+		default:
+			return nil, false
+		}
+	}
+}
+
+// readJsonPath decodes the value at steps of the JSON file at path into
+// dest without decoding anything else.
+func readJsonPath(path string, steps []jqStep, dest interface{}, opts JSONOptions) error {
+	f, r, err := openJson(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(r)
+	for _, step := range steps {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		delim, _ := t.(json.Delim)
+		if step.field && delim != '{' || !step.field && delim != '[' {
+			if delim == '{' || delim == '[' {
+				return fmt.Errorf("cannot index %s with %s", jqTypeName(delim), step)
+			}
+			if t == nil {
+				// null has no fields or elements
+				return nil
+			}
+			return fmt.Errorf("cannot index %s with %s", jqTypeName(t), step)
+		}
+		found := false
+		for i := 0; dec.More(); i++ {
+			if step.field {
+				key, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				found = key == step.name
+			} else {
+				found = i == step.index
+			}
+			if found {
+				break
+			}
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return opts.decode(dest, func(dest interface{}) error {
+		return dec.Decode(dest)
+	})
+}
+
+func (s jqStep) String() string {
+	if s.field {
+		return strconv.Quote(s.name)
+	}
+	return strconv.Itoa(s.index)
+}
+
+func jqTypeName(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return "null"
+	case json.Delim:
+		if x == '{' {
+			return "object"
+		}
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	}
+	return "number"
+}
+
+func (jqIdentity) eval(input interface{}) ([]interface{}, error) {
+	return []interface{}{input}, nil
+}
+
+func (e jqLiteral) eval(input interface{}) ([]interface{}, error) {
+	return []interface{}{e.value}, nil
+}
+
+func (e jqField) eval(input interface{}) ([]interface{}, error) {
+	return jqEach(e.x, input, func(v interface{}) ([]interface{}, error) {
+		switch m := v.(type) {
+		case nil:
+			return []interface{}{nil}, nil
+		case map[string]interface{}:
+			return []interface{}{m[e.name]}, nil
+		}
+		if e.optional {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot index %s with %q", jqTypeName(v), e.name)
+	})
+}
+
+func (e jqIndex) eval(input interface{}) ([]interface{}, error) {
+	indexes, err := e.index.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return jqEach(e.x, input, func(v interface{}) ([]interface{}, error) {
+		var out []interface{}
+		for _, index := range indexes {
+			switch k := index.(type) {
+			case string:
+				if m, ok := v.(map[string]interface{}); ok || v == nil {
+					out = append(out, m[k])
+					continue
+				}
+			case json.Number:
+				n, err := strconv.Atoi(k.String())
+				if list, ok := v.([]interface{}); ok && err == nil {
+					if n < 0 {
+						n += len(list)
+					}
+					if n >= 0 && n < len(list) {
+						out = append(out, list[n])
+					} else {
+						out = append(out, nil)
+					}
+					continue
+				}
+				if v == nil && err == nil {
+					out = append(out, nil)
+					continue
+				}
+			}
+			if !e.optional {
+				return nil, fmt.Errorf("cannot index %s with %s", jqTypeName(v), jqTypeName(index))
+			}
+		}
+		return out, nil
+	})
+}
+
+func (e jqIterate) eval(input interface{}) ([]interface{}, error) {
+	return jqEach(e.x, input, func(v interface{}) ([]interface{}, error) {
+		switch x := v.(type) {
+		case []interface{}:
+			return x, nil
+		case map[string]interface{}:
+			keys := sortedKeys(x)
+			out := make([]interface{}, len(keys))
+			for i, k := range keys {
+				out[i] = x[k]
+			}
+			return out, nil
+		}
+		if e.optional {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("cannot iterate over %s", jqTypeName(v))
+	})
+}
+
+func (e jqPipe) eval(input interface{}) ([]interface{}, error) {
+	return jqEach(e.x, input, e.y.eval)
+}
+
+func (e jqComma) eval(input interface{}) ([]interface{}, error) {
+	x, err := e.x.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	y, err := e.y.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	return append(x, y...), nil
+}
+
+func (e jqBinary) eval(input interface{}) ([]interface{}, error) {
+	xs, err := e.x.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	for _, x := range xs {
+		if e.op == "and" && !jqTruthy(x) {
+			out = append(out, false)
+			continue
+		}
+		if e.op == "or" && jqTruthy(x) {
+			out = append(out, true)
+			continue
+		}
+		ys, err := e.y.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		for _, y := range ys {
+			switch e.op {
+			case "and", "or":
+				out = append(out, jqTruthy(y))
+			case "==":
+				out = append(out, jqCompare(x, y) == 0)
+			case "!=":
+				out = append(out, jqCompare(x, y) != 0)
+			case "<":
+				out = append(out, jqCompare(x, y) < 0)
+			case "<=":
+				out = append(out, jqCompare(x, y) <= 0)
+			case ">":
+				out = append(out, jqCompare(x, y) > 0)
+			case ">=":
+				out = append(out, jqCompare(x, y) >= 0)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (e jqObject) eval(input interface{}) ([]interface{}, error) {
+	objects := []map[string]interface{}{{}}
+	for i, key := range e.keys {
+		values, err := e.values[i].eval(input)
+		if err != nil {
+			return nil, err
+		}
+		var next []map[string]interface{}
+		for _, object := range objects {
+			for _, v := range values {
+				o := make(map[string]interface{}, len(object)+1)
+				for k, x := range object {
+					o[k] = x
+				}
+				o[key] = v
+				next = append(next, o)
+			}
+		}
+		objects = next
+	}
+	out := make([]interface{}, len(objects))
+	for i, o := range objects {
+		out[i] = o
+	}
+	return out, nil
+}
+
+func (e jqArray) eval(input interface{}) ([]interface{}, error) {
+	if e.x == nil {
+		return []interface{}{[]interface{}{}}, nil
+	}
+	values, err := e.x.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = []interface{}{}
+	}
+	return []interface{}{values}, nil
+}
+
+func (e jqCall) eval(input interface{}) ([]interface{}, error) {
+	switch e.name {
+	case "select":
+		conds, err := e.arg.eval(input)
+		if err != nil {
+			return nil, err
+		}
+		var out []interface{}
+		for _, cond := range conds {
+			if jqTruthy(cond) {
+				out = append(out, input)
+			}
+		}
+		return out, nil
+	case "map":
+		return jqArray{jqPipe{jqIterate{x: jqIdentity{}}, e.arg}}.eval(input)
+	case "not":
+		return []interface{}{!jqTruthy(input)}, nil
+	case "length":
+		switch x := input.(type) {
+		case nil:
+			return []interface{}{json.Number("0")}, nil
+		case string:
+			return []interface{}{json.Number(strconv.Itoa(len([]rune(x))))}, nil
+		case []interface{}:
+			return []interface{}{json.Number(strconv.Itoa(len(x)))}, nil
+		case map[string]interface{}:
+			return []interface{}{json.Number(strconv.Itoa(len(x)))}, nil
+		}
+		return nil, fmt.Errorf("%s has no length", jqTypeName(input))
+	case "keys":
+		m, ok := input.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s has no keys", jqTypeName(input))
+		}
+		var keys []interface{}
+		for _, k := range sortedKeys(m) {
+			keys = append(keys, k)
+		}
+		return []interface{}{keys}, nil
+	}
+	return nil, fmt.Errorf("unknown function %s", e.name)
+}
+
+// jqEach runs fn on every output of x for input and concatenates the
+// results.
+func jqEach(x jqExpr, input interface{}, fn func(interface{}) ([]interface{}, error)) ([]interface{}, error) {
+	values, err := x.eval(input)
+	if err != nil {
+		return nil, err
+	}
+	var out []interface{}
+	for _, v := range values {
+		o, err := fn(v)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, o...)
+	}
+	return out, nil
+}
+
+func jqTruthy(v interface{}) bool {
+	return v != nil && v != false
+}
+
+func jqCompare(a, b interface{}) int {
+	return compareSQL(normalizeSQL(a), normalizeSQL(b))
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func parseJQ(src string) (x jqExpr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(jqError); ok {
+				x, err = nil, e
+				return
+			}
+			panic(r)
+		}
+	}()
+	p := &jqParser{src: src}
+	x = p.pipe()
+	if p.skip(); p.pos < len(p.src) {
+		p.fail("unexpected %q", p.src[p.pos:])
+	}
+	return x, nil
+}
+
+type jqError string
+
+func (e jqError) Error() string {
+	return string(e)
+}
+
+func (p *jqParser) fail(format string, args ...interface{}) {
+	panic(jqError(fmt.Sprintf("jq: at %d: ", p.pos) + fmt.Sprintf(format, args...)))
+}
+
+func (p *jqParser) skip() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) > -1 {
+		p.pos++
+	}
+}
+
+// accept consumes s if it comes next. Words must not be followed by a name
+// character.
+func (p *jqParser) accept(s string) bool {
+	p.skip()
+	if !strings.HasPrefix(p.src[p.pos:], s) {
+		return false
+	}
+	end := p.pos + len(s)
+	if isJQNameChar(s[len(s)-1]) && end < len(p.src) && isJQNameChar(p.src[end]) {
+		return false
+	}
+	p.pos = end
+	return true
+}
+
+func (p *jqParser) expect(s string) {
+	if !p.accept(s) {
+		if p.pos >= len(p.src) {
+			p.fail("unexpected end of filter, expecting %s", s)
+		}
+		p.fail("expecting %s", s)
+	}
+}
+
+func isJQNameChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (p *jqParser) name() string {
+	p.skip()
+	start := p.pos
+	for p.pos < len(p.src) && isJQNameChar(p.src[p.pos]) && (p.pos > start || p.src[p.pos] < '0' || p.src[p.pos] > '9') {
+		p.pos++
+	}
+	if start == p.pos {
+		return ""
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *jqParser) pipe() jqExpr {
+	x := p.comma()
+	for p.accept("|") {
+		x = jqPipe{x, p.comma()}
+	}
+	return x
+}
+
+func (p *jqParser) comma() jqExpr {
+	x := p.or()
+	for p.accept(",") {
+		x = jqComma{x, p.or()}
+	}
+	return x
+}
+
+func (p *jqParser) or() jqExpr {
+	x := p.and()
+	for p.accept("or") {
+		x = jqBinary{"or", x, p.and()}
+	}
+	return x
+}
+
+func (p *jqParser) and() jqExpr {
+	x := p.comparison()
+	for p.accept("and") {
+		x = jqBinary{"and", x, p.comparison()}
+	}
+	return x
+}
+
+func (p *jqParser) comparison() jqExpr {
+	x := p.postfix()
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.accept(op) {
+			return jqBinary{op, x, p.postfix()}
+		}
+	}
+	return x
+}
+
+func (p *jqParser) postfix() jqExpr {
+	x := p.term()
+	for {
+		p.skip()
+		switch {
+		case p.accept("["):
+			if p.accept("]") {
+				x = jqIterate{x: x}
+			} else {
+				index := p.pipe()
+				p.expect("]")
+				x = jqIndex{x: x, index: index}
+			}
+		case strings.HasPrefix(p.src[p.pos:], ".") && !strings.HasPrefix(p.src[p.pos:], ".."):
+			p.pos++
+			x = p.fieldAfterDot(x)
+		default:
+			return x
+		}
+		if p.accept("?") {
+			switch e := x.(type) {
+			case jqField:
+				e.optional = true
+				x = e
+			case jqIndex:
+				e.optional = true
+				x = e
+			case jqIterate:
+				e.optional = true
+				x = e
+			}
+		}
+	}
+}
+
+// fieldAfterDot parses what follows a dot: a name, a quoted key or an
+// index.
+func (p *jqParser) fieldAfterDot(x jqExpr) jqExpr {
+	if p.pos < len(p.src) && p.src[p.pos] == '"' {
+		return jqField{x: x, name: p.str()}
+	}
+	if p.pos < len(p.src) && p.src[p.pos] == '[' {
+		p.pos++
+		if p.accept("]") {
+			return jqIterate{x: x}
+		}
+		index := p.pipe()
+		p.expect("]")
+		return jqIndex{x: x, index: index}
+	}
+	if name := p.name(); name != "" {
+		return jqField{x: x, name: name}
+	}
+	return x
+}
+
+func (p *jqParser) str() string {
+	p.skip()
+	start := p.pos
+	p.pos++
+	for p.pos < len(p.src) && p.src[p.pos] != '"' {
+		if p.src[p.pos] == '\\' {
+			p.pos++
+		}
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		p.fail("unterminated string")
+	}
+	p.pos++
+	var s string
+	if err := json.Unmarshal([]byte(p.src[start:p.pos]), &s); err != nil {
+		p.fail("invalid string %s", p.src[start:p.pos])
+	}
+	return s
+}
+
+func (p *jqParser) term() jqExpr {
+	p.skip()
+	if p.pos >= len(p.src) {
+		p.fail("unexpected end of filter")
+	}
+	c := p.src[p.pos]
+	switch {
+	case c == '.':
+		if strings.HasPrefix(p.src[p.pos:], "..") {
+			p.fail("recursive descent is not supported")
+		}
+		p.pos++
+		return p.fieldAfterDot(jqIdentity{})
+	case c == '"':
+		return jqLiteral{p.str()}
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		p.pos++
+		for p.pos < len(p.src) && strings.IndexByte("0123456789.eE+-", p.src[p.pos]) > -1 {
+			p.pos++
+		}
+		n := p.src[start:p.pos]
+		if _, err := strconv.ParseFloat(n, 64); err != nil {
+			p.fail("invalid number %s", n)
+		}
+		return jqLiteral{json.Number(n)}
+	case c == '(':
+		p.pos++
+		x := p.pipe()
+		p.expect(")")
+		return x
+	case c == '[':
+		p.pos++
+		if p.accept("]") {
+			return jqArray{}
+		}
+		x := p.pipe()
+		p.expect("]")
+		return jqArray{x}
+	case c == '{':
+		p.pos++
+		return p.object()
+	}
+	start := p.pos
+	name := p.name()
+	switch name {
+	case "true":
+		return jqLiteral{true}
+	case "false":
+		return jqLiteral{false}
+	case "null":
+		return jqLiteral{nil}
+	case "not", "length", "keys":
+		return jqCall{name: name}
+	case "select", "map":
+		p.expect("(")
+		arg := p.pipe()
+		p.expect(")")
+		return jqCall{name: name, arg: arg}
+	case "":
+		p.fail("unexpected %q", p.src[p.pos:])
+	}
+	p.pos = start
+	p.fail("unknown function %s", name)
+	return nil
+}
+
+func (p *jqParser) object() jqExpr {
+	var o jqObject
+	if p.accept("}") {
+		return o
+	}
+	for {
+		p.skip()
+		var key string
+		if p.pos < len(p.src) && p.src[p.pos] == '"' {
+			key = p.str()
+		} else if key = p.name(); key == "" {
+			p.fail("expecting an object key")
+		}
+		var value jqExpr = jqField{x: jqIdentity{}, name: key}
+		if p.accept(":") {
+			value = p.or()
+		}
+		o.keys = append(o.keys, key)
+		o.values = append(o.values, value)
+		if p.accept("}") {
+			return o
+		}
+		p.expect(",")
+	}
+}
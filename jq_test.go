@@ -0,0 +1,188 @@
+package gitdb
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const jqSettings = `{
+"theme":{"name":"dark","colors":["black","gray"]},
+"font size":12,
+"plugins":[{"name":"a","enabled":true},{"name":"b","enabled":false}],
+"empty":null
+}
+`
+
+const jqUsers = `[
+{"id":1,"name":"alice","age":30,"roles":["admin","dev"]},
+{"id":2,"name":"bob","age":25,"roles":["dev"]},
+{"id":3,"name":"carol","age":35,"roles":[]}
+]
+`
+
+func newJQDB(t *testing.T) *DB {
+	t.Helper()
+	db := newTestDB(t)
+	for name, content := range map[string]string{"settings.json": jqSettings, "users.json": jqUsers} {
+		if err := ioutil.WriteFile(filepath.Join(db.Local, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+func TestReadPath(t *testing.T) {
+	db := newJQDB(t)
+	o := db.NewObject("settings.json")
+	tests := []struct {
+		expr string
+		want string
+	}{
+		// field paths
+		{`.`, `{"empty":null,"font size":12,"plugins":[{"enabled":true,"name":"a"},{"enabled":false,"name":"b"}],"theme":{"colors":["black","gray"],"name":"dark"}}`},
+		{`.theme.name`, `"dark"`},
+		{`.theme.colors[1]`, `"gray"`},
+		{`.theme["name"]`, `"dark"`},
+		{`."font size"`, `12`},
+		{`.plugins[-1].name`, `"b"`},
+		{`.missing`, `null`},
+		{`.empty.name`, `null`},
+		{`.theme.colors[5]`, `null`},
+
+		// .[]
+		{`[.theme.colors[]]`, `["black","gray"]`},
+		{`[.plugins[].name]`, `["a","b"]`},
+		{`[.theme[]?]`, `[["black","gray"],"dark"]`},
+
+		// object construction
+		{`.theme | {name}`, `{"name":"dark"}`},
+		{`{theme: .theme.name, size: ."font size"}`, `{"size":12,"theme":"dark"}`},
+		{`{"first plugin": .plugins[0].name}`, `{"first plugin":"a"}`},
+
+		// pipes
+		{`.plugins | map(select(.enabled)) | map(.name)`, `["a"]`},
+		{`.plugins | length`, `2`},
+		{`.theme | keys`, `["colors","name"]`},
+		{`.plugins[0] | .enabled and (.name == "a")`, `true`},
+	}
+	for _, test := range tests {
+		var got interface{}
+		if err := o.ReadPath(test.expr, &got); err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		if s := jsonText(got); s != test.want {
+			t.Errorf("%s: got %s, want %s", test.expr, s, test.want)
+		}
+	}
+}
+
+func TestReadPathErrors(t *testing.T) {
+	db := newJQDB(t)
+	o := db.NewObject("settings.json")
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{`.theme.name.first`, `cannot index string with "first"`},
+		{`.theme[0]`, `cannot index object with 0`},
+		{`."font size"[]`, `cannot iterate over number`},
+		{`.theme | length | keys`, `number has no keys`},
+		{`.theme.colors[]`, `jq filter returned 2 values, decode them into a slice`},
+	}
+	for _, test := range tests {
+		var got interface{}
+		err := o.ReadPath(test.expr, &got)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %s", test.expr, err, test.err)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	db := newJQDB(t)
+	c := db.NewCollection("users.json")
+	tests := []struct {
+		expr string
+		want string
+	}{
+		// field paths
+		{`.[0].name`, `["alice"]`},
+		{`.[1]["roles"][0]`, `["dev"]`},
+		{`.[].id`, `[1,2,3]`},
+		{`length`, `[3]`},
+
+		// .[]
+		{`.[]`, `[{"age":30,"id":1,"name":"alice","roles":["admin","dev"]},{"age":25,"id":2,"name":"bob","roles":["dev"]},{"age":35,"id":3,"name":"carol","roles":[]}]`},
+		{`.[].roles[]`, `["admin","dev","dev"]`},
+		{`.[] | .name, .id`, `["alice",1,"bob",2,"carol",3]`},
+
+		// object construction
+		{`.[] | {id, name}`, `[{"id":1,"name":"alice"},{"id":2,"name":"bob"},{"id":3,"name":"carol"}]`},
+		{`.[0] | {name, role: .roles[]}`, `[{"name":"alice","role":"admin"},{"name":"alice","role":"dev"}]`},
+		{`{count: length, names: map(.name)}`, `[{"count":3,"names":["alice","bob","carol"]}]`},
+
+		// pipes
+		{`.[] | select(.age > 28) | .name`, `["alice","carol"]`},
+		{`.[] | select(.age >= 25 and .age < 35) | .id`, `[1,2]`},
+		{`.[] | select(.roles | length == 0) | .name`, `["carol"]`},
+		{`.[] | select(.name == "bob" or .id == 3) | .id`, `[2,3]`},
+		{`.[] | select(.name != "bob" | not) | .id`, `[2]`},
+		{`map(.age) | .[2]`, `[35]`},
+		{`.[] | select(.id > 5)`, `[]`},
+	}
+	for _, test := range tests {
+		var got []interface{}
+		if err := c.Select(test.expr, &got); err != nil {
+			t.Errorf("%s: %v", test.expr, err)
+			continue
+		}
+		if s := jsonText(got); s != test.want {
+			t.Errorf("%s: got %s, want %s", test.expr, s, test.want)
+		}
+	}
+
+	var names []struct {
+		Name string `json:"name"`
+	}
+	if err := c.Select(`.[] | {name}`, &names); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 || names[2].Name != "carol" {
+		t.Errorf("got %+v", names)
+	}
+	var count int
+	if err := c.Select(`length`, &count); err != nil || count != 3 {
+		t.Errorf("got %d, %v, want 3", count, err)
+	}
+}
+
+func TestSelectErrors(t *testing.T) {
+	db := newJQDB(t)
+	c := db.NewCollection("users.json")
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{``, `jq: at 0: unexpected end of filter`},
+		{`.[] |`, `jq: at 5: unexpected end of filter`},
+		{`.[0`, `jq: at 3: unexpected end of filter, expecting ]`},
+		{`.[] | {id name}`, `jq: at 10: expecting ,`},
+		{`.[] | {1: .id}`, `jq: at 7: expecting an object key`},
+		{`."name`, `jq: at 6: unterminated string`},
+		{`.[] | select(.id`, `jq: at 16: unexpected end of filter, expecting )`},
+		{`.[] | sort`, `jq: at 6: unknown function sort`},
+		{`..`, `jq: at 0: recursive descent is not supported`},
+		{`.[] | @csv`, `jq: at 6: unexpected "@csv"`},
+		{`.[] .name )`, `jq: at 10: unexpected ")"`},
+		{`.[0].name[]`, `cannot iterate over string`},
+	}
+	for _, test := range tests {
+		var got []interface{}
+		err := c.Select(test.expr, &got)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%q: got error %v, want %s", test.expr, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package gitdb
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	xssh "golang.org/x/crypto/ssh"
+)
+
+type (
+	// KeyProvider fetches a secret, such as a PEM encoded SSH private key
+	// or an access token, at runtime.
+	KeyProvider interface {
+		Key(ctx context.Context) ([]byte, error)
+	}
+
+	KeyProviderFunc func(ctx context.Context) ([]byte, error)
+
+	cachedKey struct {
+		provider KeyProvider
+		ttl      time.Duration
+
+		mu      sync.Mutex
+		key     []byte
+		fetched time.Time
+	}
+
+	sshKeyAuth struct {
+		user     string
+		key      *cachedKey
+		password string
+
+		mu     sync.Mutex
+		pem    []byte
+		public *ssh.PublicKeys
+	}
+
+	tokenAuth struct {
+		user string
+		key  *cachedKey
+	}
+
+	keyTokenSource struct {
+		key *cachedKey
+	}
+)
+
+const keyFetchTimeout = 30 * time.Second
+
+func (f KeyProviderFunc) Key(ctx context.Context) ([]byte, error) {
+	return f(ctx)
+}
+
+// CacheKey returns a KeyProvider that fetches the key from p at most once
+// per ttl, or only once if ttl is not positive. If a refresh fails, the
+// previous key keeps being used.
+func CacheKey(p KeyProvider, ttl time.Duration) KeyProvider {
+	return newCachedKey(p, ttl)
+}
+
+func newCachedKey(p KeyProvider, ttl time.Duration) *cachedKey {
+	if c, ok := p.(*cachedKey); ok && c.ttl == ttl {
+		return c
+	}
+	return &cachedKey{provider: p, ttl: ttl}
+}
+
+func (c *cachedKey) Key(ctx context.Context) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.key != nil && (c.ttl <= 0 || time.Since(c.fetched) < c.ttl) {
+		return c.key, nil
+	}
+	key, err := c.provider.Key(ctx)
+	if err != nil {
+		if c.key != nil {
+			log.Println("error refreshing key, using previous one", err)
+			return c.key, nil
+		}
+		return nil, err
+	}
+	c.key, c.fetched = key, time.Now()
+	return key, nil
+}
+
+func (c *cachedKey) fetch() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyFetchTimeout)
+	defer cancel()
+	return c.Key(ctx)
+}
+
+// SSHKeyProviderAuth returns the auth method for SSH remotes that uses the
+// PEM encoded private key from p, fetched again every refresh. Use it with
+// DB.SetAuth.
+func SSHKeyProviderAuth(user string, p KeyProvider, password string, refresh time.Duration) transport.AuthMethod {
+	return &sshKeyAuth{
+		user:     user,
+		key:      newCachedKey(p, refresh),
+		password: password,
+	}
+}
+
+func (a *sshKeyAuth) Name() string {
+	return ssh.PublicKeysName
+}
+
+func (a *sshKeyAuth) String() string {
+	return "user: " + a.user + ", name: " + a.Name()
+}
+
+func (a *sshKeyAuth) ClientConfig() (*xssh.ClientConfig, error) {
+	pem, err := a.key.fetch()
+	if err != nil {
+		return nil, err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.public == nil || string(pem) != string(a.pem) {
+		public, err := ssh.NewPublicKeys(a.user, pem, a.password)
+		if err != nil {
+			return nil, err
+		}
+		public.HostKeyCallback = xssh.InsecureIgnoreHostKey()
+		a.pem, a.public = pem, public
+	}
+	return a.public.ClientConfig()
+}
+
+// TokenAuth returns the auth method for HTTPS remotes that sends the token
+// from p as the basic auth password of user, fetched again every refresh.
+// Use it with DB.SetAuth.
+func TokenAuth(user string, p KeyProvider, refresh time.Duration) transport.AuthMethod {
+	return &tokenAuth{
+		user: user,
+		key:  newCachedKey(p, refresh),
+	}
+}
+
+func (a *tokenAuth) Name() string {
+	return "http-token-provider"
+}
+
+func (a *tokenAuth) String() string {
+	return a.Name()
+}
+
+func (a *tokenAuth) SetAuth(r *http.Request) {
+	token, err := a.key.fetch()
+	if err != nil {
+		log.Println("error getting token", err)
+		return
+	}
+	r.SetBasicAuth(a.user, string(token))
+}
+
+// KeyTokenSource adapts p to a TokenSource, e.g. for the Tokens of GitHub,
+// fetching the token again every refresh.
+func KeyTokenSource(p KeyProvider, refresh time.Duration) TokenSource {
+	return keyTokenSource{newCachedKey(p, refresh)}
+}
+
+func (s keyTokenSource) Token(ctx context.Context) (string, error) {
+	token, err := s.key.Key(ctx)
+	return string(token), err
+}
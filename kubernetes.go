@@ -0,0 +1,97 @@
+package gitdb
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+type (
+	// KubernetesExport describes the ConfigMap or Secret written by
+	// ExportKubernetes.
+	KubernetesExport struct {
+		Name      string
+		Namespace string
+		Labels    map[string]string
+		// Secret makes the manifest a Secret of type Opaque instead of a
+		// ConfigMap.
+		Secret bool
+	}
+
+	kubernetesManifest struct {
+		APIVersion string             `json:"apiVersion"`
+		Kind       string             `json:"kind"`
+		Metadata   kubernetesMetadata `json:"metadata"`
+		Type       string             `json:"type,omitempty"`
+		Data       interface{}        `json:"data"`
+	}
+
+	kubernetesMetadata struct {
+		Name      string            `json:"name"`
+		Namespace string            `json:"namespace,omitempty"`
+		Labels    map[string]string `json:"labels,omitempty"`
+	}
+)
+
+// ExportKubernetes defines a view at path holding a ConfigMap, or a Secret,
+// with one key per source, named after the base name of its path and
+// holding its JSON content, like "products.json". Sources are the paths of
+// collections or objects; the manifest is rewritten whenever one of them is
+// written and staged by Add together with it, so that kubectl apply or a
+// GitOps tool watching the repository can roll the data out to workloads.
+// Manifests are JSON, which Kubernetes accepts like YAML.
+func (db *DB) ExportKubernetes(path string, export KubernetesExport, sources ...string) *View {
+	var collections []*Collection
+	for _, source := range sources {
+		collections = append(collections, db.findCollection(source))
+	}
+	return db.DefineView(path, collections, func(sources ...*Collection) (interface{}, error) {
+		return export.manifest(sources)
+	})
+}
+
+func (e KubernetesExport) manifest(sources []*Collection) (interface{}, error) {
+	m := kubernetesManifest{
+		APIVersion: "v1",
+		Kind:       "ConfigMap",
+		Metadata: kubernetesMetadata{
+			Name:      e.Name,
+			Namespace: e.Namespace,
+			Labels:    e.Labels,
+		},
+	}
+	strs := map[string]string{}
+	binary := map[string][]byte{}
+	for _, c := range sources {
+		content, err := kubernetesData(c)
+		if err != nil {
+			return nil, err
+		}
+		if content == nil {
+			continue
+		}
+		key := filepath.Base(c.Path)
+		strs[key] = string(content)
+		binary[key] = content
+	}
+	m.Data = strs
+	if e.Secret {
+		m.Kind, m.Type, m.Data = "Secret", "Opaque", binary
+	}
+	return m, nil
+}
+
+// kubernetesData returns the JSON content of c as it is on disk, or encoded
+// again if it is chunked or stored as record files, or nil if it does not
+// exist.
+func kubernetesData(c *Collection) ([]byte, error) {
+	raw, _, err := c.ReadRaw()
+	if err == nil || os.IsNotExist(err) {
+		return raw, nil
+	}
+	var records []interface{}
+	if err := c.Read(&records); err != nil {
+		return nil, err
+	}
+	return json.Marshal(records)
+}
@@ -0,0 +1,175 @@
+// Package kv provides a key-value store over a directory of a gitdb.DB,
+// with one file per key so that every key has its own git history.
+//
+// Keys are spread over 256 subdirectories by the first byte of their SHA-1
+// hash, and each file is named after its escaped key and holds the JSON
+// encoded value:
+//
+//	store := kv.New(db, "settings")
+//	store.Set("theme", "dark") // settings/dc/theme.json
+//	var theme string
+//	found, _ := store.Get("theme", &theme)
+//
+// Set and Delete commit their change, but do not push it.
+package kv
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/caiguanhao/gitdb"
+)
+
+const fileExt = ".json"
+
+type (
+	// Store is a key-value store over a directory.
+	Store struct {
+		db  *gitdb.DB
+		dir string
+	}
+)
+
+// New returns the store in dir of db, relative to the root of db (see
+// SetRoot). The files of the store are managed (see Manage).
+func New(db *gitdb.DB, dir string) *Store {
+	dir = filepath.Join(filepath.FromSlash(db.Root), dir)
+	db.Manage(filepath.ToSlash(dir) + "/")
+	return &Store{db: db, dir: dir}
+}
+
+func (s *Store) MustGet(key string, dest interface{}) bool {
+	found, err := s.Get(key, dest)
+	if err != nil {
+		panic(err)
+	}
+	return found
+}
+
+// Get decodes the value of key into dest and reports whether the key
+// exists.
+func (s *Store) Get(key string, dest interface{}) (bool, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.db.Local, s.file(key)))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(b, dest); err != nil {
+		return true, fmt.Errorf("Get: %s: %v", key, err)
+	}
+	return true, nil
+}
+
+func (s *Store) MustSet(key string, value interface{}) {
+	if err := s.Set(key, value); err != nil {
+		panic(err)
+	}
+}
+
+// Set writes the value of key, encoded as JSON, and commits it if it
+// changed.
+func (s *Store) Set(key string, value interface{}) error {
+	if key == "" {
+		return fmt.Errorf("Set: empty key")
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("Set: %s: %v", key, err)
+	}
+	b = append(b, '\n')
+	name := s.file(key)
+	full := filepath.Join(s.db.Local, name)
+	if old, err := ioutil.ReadFile(full); err == nil && string(old) == string(b) {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(full, b, 0644); err != nil {
+		return err
+	}
+	return s.commit(name, "set "+key)
+}
+
+func (s *Store) MustDelete(key string) {
+	if err := s.Delete(key); err != nil {
+		panic(err)
+	}
+}
+
+// Delete removes key and commits it. Deleting a missing key does nothing.
+func (s *Store) Delete(key string) error {
+	name := s.file(key)
+	err := os.Remove(filepath.Join(s.db.Local, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.commit(name, "delete "+key)
+}
+
+func (s *Store) MustList(prefix string) []string {
+	keys, err := s.List(prefix)
+	if err != nil {
+		panic(err)
+	}
+	return keys
+}
+
+// List returns the sorted keys starting with prefix.
+func (s *Store) List(prefix string) ([]string, error) {
+	dirs, err := ioutil.ReadDir(filepath.Join(s.db.Local, s.dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(s.db.Local, s.dir, dir.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() || !strings.HasSuffix(name, fileExt) {
+				continue
+			}
+			key, err := url.PathUnescape(strings.TrimSuffix(name, fileExt))
+			if err == nil && strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// file returns the path of the file of key in the repository.
+func (s *Store) file(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:1]), url.PathEscape(key)+fileExt)
+}
+
+func (s *Store) commit(name, msg string) error {
+	if err := s.db.Add(name); err != nil {
+		return err
+	}
+	return s.db.Commit(msg)
+}
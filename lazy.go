@@ -0,0 +1,165 @@
+package gitdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+type (
+	// RecordHandle locates a record of a collection in its file without
+	// decoding it. Load decodes the record.
+	RecordHandle struct {
+		// ID is the value of the key field of the record.
+		ID string
+		// Path is the file of the record, relative to the repository.
+		Path   string
+		Offset int64
+		Length int64
+
+		file    string
+		size    int64
+		modTime time.Time
+		opts    JSONOptions
+	}
+)
+
+func (c Collection) MustReadHandles(keyField string) []RecordHandle {
+	handles, err := c.ReadHandles(keyField)
+	if err != nil {
+		panic(err)
+	}
+	return handles
+}
+
+// ReadHandles returns a handle with the value of keyField and the position
+// of each record of the collection, in order, decoding only keyField and
+// ExpiresAtField, for scanning many records but decoding few of them with
+// Load. Expired records are dropped like in Read. Read into a pointer to a
+// slice of RecordHandle does the same with RecordKeyField.
+func (c Collection) ReadHandles(keyField string) ([]RecordHandle, error) {
+	path := filepath.Join(c.db.Local, c.Path)
+	files, perRecord, err := dataFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	wanted := map[string]bool{keyField: true}
+	if c.ExpiresAtField != "" {
+		wanted[c.expiresAtKey(c.recordType())] = true
+	}
+	now := time.Now()
+	var handles []RecordHandle
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(c.db.Local, file)
+		if err != nil {
+			return nil, err
+		}
+		f, r, err := openJson(file)
+		if err != nil {
+			return nil, err
+		}
+		start, _ := f.Seek(0, io.SeekCurrent)
+		err = eachRecordIn(json.NewDecoder(r), perRecord, func(dec *json.Decoder) error {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return err
+			}
+			if bytes.Equal(raw, []byte("null")) {
+				return nil
+			}
+			fields, err := projectRecord(json.NewDecoder(bytes.NewReader(raw)), wanted)
+			if err != nil {
+				return err
+			}
+			id, expired := c.handleFields(fields, keyField, now)
+			if expired {
+				return nil
+			}
+			handles = append(handles, RecordHandle{
+				ID:      id,
+				Path:    filepath.ToSlash(rel),
+				Offset:  start + dec.InputOffset() - int64(len(raw)),
+				Length:  int64(len(raw)),
+				file:    file,
+				size:    info.Size(),
+				modTime: info.ModTime(),
+				opts:    c.jsonOptions(),
+			})
+			return nil
+		})
+		f.Close()
+		if err != nil {
+			return nil, newParseError(file, start, err)
+		}
+	}
+	return handles, nil
+}
+
+// handleFields returns the value of keyField in the projected record
+// fields and whether the record is expired.
+func (c Collection) handleFields(fields []byte, keyField string, now time.Time) (string, bool) {
+	var m map[string]json.RawMessage
+	json.Unmarshal(fields, &m)
+	var id string
+	if raw, ok := m[keyField]; ok {
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		dec.Decode(&v)
+		id = fmt.Sprint(v)
+	}
+	if raw, ok := m[c.expiresAtKey(c.recordType())]; ok && c.ExpiresAtField != "" {
+		var v interface{}
+		json.Unmarshal(raw, &v)
+		if at, ok := expiresAt(reflect.ValueOf(v)); ok && at.Before(now) {
+			return id, true
+		}
+	}
+	return id, false
+}
+
+func (h RecordHandle) MustLoad(dest interface{}) {
+	if err := h.Load(dest); err != nil {
+		panic(err)
+	}
+}
+
+// Load decodes the record into dest. It fails with an error satisfying
+// errors.Is(err, ErrStaleRead) if the file of the record changed since
+// the handle was read.
+func (h RecordHandle) Load(dest interface{}) error {
+	f, err := os.Open(h.file)
+	if err != nil {
+		return fmt.Errorf("Load: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("Load: %w", err)
+	}
+	if info.Size() != h.size || !info.ModTime().Equal(h.modTime) {
+		return fmt.Errorf("Load: %s: %w", h.Path, ErrStaleRead)
+	}
+	data := make([]byte, h.Length)
+	if _, err := f.ReadAt(data, h.Offset); err != nil {
+		return fmt.Errorf("Load: %w", err)
+	}
+	return h.opts.decode(dest, func(dest interface{}) error {
+		if err := h.opts.newDecoder(bytes.NewReader(data)).Decode(dest); err != nil {
+			return fmt.Errorf("Load: %s: %w", h.Path, err)
+		}
+		return nil
+	})
+}
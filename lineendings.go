@@ -0,0 +1,65 @@
+package gitdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// LineEndings tells Add what to do with the line endings of the files
+	// it stages.
+	LineEndings int
+)
+
+const (
+	KeepLineEndings LineEndings = iota
+	LFLineEndings
+)
+
+// SetLineEndings sets what Add does with the line endings of the files it
+// stages: keep them, or convert CRLF to LF in text files first, so files
+// edited by hand on Windows do not rewrite every line of a collection.
+// Collections and objects are always written with LF.
+func (db *DB) SetLineEndings(e LineEndings) {
+	db.LineEndings = e
+}
+
+// normalizeLineEndings converts the CRLF line endings of the file at path,
+// relative to the repository, to LF if the DB asks for it. Deleted files,
+// directories and binary files, holding a NUL byte, are left alone.
+func (db DB) normalizeLineEndings(path string) error {
+	if db.LineEndings != LFLineEndings {
+		return nil
+	}
+	full := filepath.Join(db.Local, path)
+	info, err := os.Stat(full)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil || info.IsDir() {
+		return err
+	}
+	content, err := ioutil.ReadFile(full)
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(content, []byte("\r\n")) || bytes.IndexByte(content, 0) > -1 {
+		return nil
+	}
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	return ioutil.WriteFile(full, content, info.Mode())
+}
+
+func parseLineEndings(s string) (LineEndings, error) {
+	switch strings.ToLower(s) {
+	case "", "keep":
+		return KeepLineEndings, nil
+	case "lf":
+		return LFLineEndings, nil
+	}
+	return 0, fmt.Errorf("unknown line endings %s", s)
+}
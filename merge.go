@@ -0,0 +1,248 @@
+package gitdb
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+type (
+	// MergeConflict is returned by Pull when both sides changed the same
+	// files in different ways. Nothing is changed locally.
+	MergeConflict struct {
+		Paths []string
+	}
+)
+
+func (e MergeConflict) Error() string {
+	return "merge conflict: " + strings.Join(e.Paths, ", ")
+}
+
+func (db DB) MustPull() {
+	if err := db.Pull(); err != nil {
+		panic(err)
+	}
+}
+
+// Pull fetches the remote branch and merges it into the current branch,
+// unlike ForceUpdate which discards local commits. Files are merged as a
+// whole: a file changed on one side only takes that change, files added on
+// either side are kept and files deleted on one side are deleted unless
+// the other side changed them. Collections with a RecordKeyField keep one
+// file per record, so writers changing different records always merge
+// cleanly. Files changed differently on both sides make Pull return a
+// MergeConflict.
+func (db DB) Pull() error {
+	if err := db.pull(context.Background()); err != nil {
+		return db.reportError("pull", err)
+	}
+	db.state().refreshCaches()
+	return nil
+}
+
+func (db DB) pull(ctx context.Context) error {
+	defer db.lock()()
+
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return err
+	}
+	if err := db.checkRemotes(r); err != nil {
+		return err
+	}
+	log.Println("fetching", db.GetRemoteName())
+	err = r.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: db.GetRemoteName(),
+		Auth:       db.authMethod(),
+	})
+	if err == transport.ErrEmptyRemoteRepository {
+		return nil
+	}
+	if err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	db.state().fetched()
+
+	ref, err := r.Reference(plumbing.NewRemoteReferenceName(db.GetRemoteName(), db.GetBranchName()), true)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return db.forceUpdate(ctx)
+	}
+	if err != nil {
+		return err
+	}
+	ours, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+	theirs, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return err
+	}
+	if ours.Hash == theirs.Hash {
+		return nil
+	}
+	bases, err := ours.MergeBase(theirs)
+	if err != nil {
+		return err
+	}
+	var base *object.Commit
+	if len(bases) > 0 {
+		base = bases[0]
+	}
+	if base != nil && base.Hash == theirs.Hash {
+		// nothing new on the remote
+		return nil
+	}
+
+	result := theirs
+	if base == nil || base.Hash != ours.Hash {
+		if result, err = db.mergeCommits(r, base, ours, theirs); err != nil {
+			return err
+		}
+	}
+	return db.moveHead(r, head.Name(), ours, result)
+}
+
+// mergeCommits writes the commit merging theirs into ours.
+func (db DB) mergeCommits(r *git.Repository, base, ours, theirs *object.Commit) (*object.Commit, error) {
+	baseFiles := map[string]plumbing.Hash{}
+	if base != nil {
+		var err error
+		if baseFiles, err = commitFiles(base); err != nil {
+			return nil, err
+		}
+	}
+	ourFiles, err := commitFiles(ours)
+	if err != nil {
+		return nil, err
+	}
+	theirFiles, err := commitFiles(theirs)
+	if err != nil {
+		return nil, err
+	}
+	changes, conflicts := mergeFiles(baseFiles, ourFiles, theirFiles)
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, MergeConflict{Paths: conflicts}
+	}
+	tree, err := ours.Tree()
+	if err != nil {
+		return nil, err
+	}
+	treeHash, err := buildTree(r.Storer, tree, changes)
+	if err != nil {
+		return nil, err
+	}
+	msg := db.addTrailers(fmt.Sprintf("merge %s/%s", db.GetRemoteName(), db.GetBranchName()))
+	hash, err := db.commitTree(r.Storer, treeHash, []plumbing.Hash{ours.Hash, theirs.Hash}, msg)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("added merge commit", hash.String()[:8])
+	return r.CommitObject(hash)
+}
+
+// mergeFiles returns the changes to make to ours to merge theirs, keyed by
+// path with a zero hash for deletions, and the paths in conflict.
+func mergeFiles(base, ours, theirs map[string]plumbing.Hash) (map[string]plumbing.Hash, []string) {
+	changes := map[string]plumbing.Hash{}
+	var conflicts []string
+	for path, t := range theirs {
+		o, b := ours[path], base[path]
+		switch {
+		case t == o, t == b:
+		case o == b, o.IsZero():
+			// changed only by them, or deleted by us but changed by them
+			changes[path] = t
+		default:
+			conflicts = append(conflicts, path)
+		}
+	}
+	for path, b := range base {
+		if _, ok := theirs[path]; ok {
+			continue
+		}
+		// deleted by them: follow unless we changed it
+		if o, ok := ours[path]; ok && o == b {
+			changes[path] = plumbing.ZeroHash
+		}
+	}
+	return changes, conflicts
+}
+
+func commitFiles(c *object.Commit) (map[string]plumbing.Hash, error) {
+	tree, err := c.Tree()
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]plumbing.Hash{}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		files[f.Name] = f.Hash
+		return nil
+	})
+	return files, err
+}
+
+// moveHead points branch at to and updates the index and worktree files
+// that differ from from, refusing to overwrite uncommitted changes.
+func (db DB) moveHead(r *git.Repository, branch plumbing.ReferenceName, from, to *object.Commit) error {
+	fromFiles, err := commitFiles(from)
+	if err != nil {
+		return err
+	}
+	toFiles, err := commitFiles(to)
+	if err != nil {
+		return err
+	}
+	var changed []string
+	for path, h := range toFiles {
+		if fromFiles[path] != h {
+			changed = append(changed, path)
+		}
+	}
+	for path := range fromFiles {
+		if _, ok := toFiles[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	s, err := w.Status()
+	if err != nil {
+		return err
+	}
+	for _, path := range changed {
+		if fs, ok := s[path]; ok && (fs.Staging != git.Unmodified || fs.Worktree != git.Unmodified) {
+			return fmt.Errorf("Pull: uncommitted changes to %s would be overwritten", path)
+		}
+	}
+	if err := r.Storer.SetReference(plumbing.NewHashReference(branch, to.Hash)); err != nil {
+		return err
+	}
+	tree, err := to.Tree()
+	if err != nil {
+		return err
+	}
+	for _, path := range changed {
+		if err := checkoutPath(r, db.Local, tree, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
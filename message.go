@@ -0,0 +1,106 @@
+package gitdb
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+type (
+	// CommitInfo describes a commit made by an operation of gitdb, for a
+	// MessageFormatter.
+	CommitInfo struct {
+		// Op is the operation, like "update" for Commit without a
+		// message, "upsert", "expire", "recover", or "add" and "delete"
+		// for records edited with AdminHandler.
+		Op string
+
+		// Paths are the collections and objects changed.
+		Paths []string
+
+		// Records is the number of records changed, if known.
+		Records int
+
+		// Message is the message gitdb uses without a formatter.
+		Message string
+	}
+
+	// MessageFormatter returns the commit message of a commit.
+	MessageFormatter func(CommitInfo) string
+)
+
+// SetMessageFormatter makes the commits of gitdb operations, and Commit
+// when called without a message, use the messages returned by formatter.
+// Messages given to Commit are kept as they are.
+func (db *DB) SetMessageFormatter(formatter MessageFormatter) {
+	db.messageFormatter = formatter
+}
+
+// ConventionalMessage formats messages in the Conventional Commits style,
+// like "data(products): upsert 12 records", the scope being the file names
+// of the paths without their extensions.
+func ConventionalMessage(info CommitInfo) string {
+	var scopes []string
+	seen := map[string]bool{}
+	for _, p := range info.Paths {
+		scope := path.Base(filepath.ToSlash(p))
+		scope = strings.TrimSuffix(scope, path.Ext(scope))
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	sort.Strings(scopes)
+	msg := "data"
+	if len(scopes) > 0 {
+		msg += "(" + strings.Join(scopes, ",") + ")"
+	}
+	msg += ": " + info.Op
+	switch {
+	case info.Records == 1:
+		msg += " 1 record"
+	case info.Records > 1:
+		msg += fmt.Sprintf(" %d records", info.Records)
+	}
+	return msg
+}
+
+// commitOp commits with the message of info.
+func (db DB) commitOp(info CommitInfo) error {
+	return db.Commit(db.formatMessage(info))
+}
+
+func (db DB) formatMessage(info CommitInfo) string {
+	if db.messageFormatter == nil {
+		return info.Message
+	}
+	return db.messageFormatter(info)
+}
+
+// stagedPaths returns the paths of the staged changes.
+func (db DB) stagedPaths() ([]string, error) {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return nil, err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	s, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for name, fs := range s {
+		if fs.Staging != git.Unmodified && fs.Staging != git.Untracked {
+			paths = append(paths, name)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -0,0 +1,149 @@
+package gitdb
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// notesRef is the ref of the notes, the default one of git notes.
+const notesRef = plumbing.ReferenceName("refs/notes/commits")
+
+func (db DB) MustAnnotate(rev, note string) {
+	if err := db.Annotate(rev, note); err != nil {
+		panic(err)
+	}
+}
+
+// Annotate attaches note to the commit rev, like "HEAD" or a hash, with
+// git notes, without changing the commit. A note already attached is kept
+// and note is appended to it after a blank line, like git notes append.
+// The notes are stored in refs/notes/commits, which Push does not push.
+func (db DB) Annotate(rev, note string) error {
+	if err := db.checkWritable("Annotate"); err != nil {
+		return err
+	}
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return fmt.Errorf("Annotate: empty note")
+	}
+	defer db.lock()()
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return err
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return fmt.Errorf("Annotate: %s: %v", rev, err)
+	}
+	notes, tree, err := notesTree(r)
+	if err != nil {
+		return err
+	}
+	existing, err := noteContent(tree, hash.String())
+	if err != nil {
+		return err
+	}
+	if existing != "" {
+		note = existing + "\n\n" + note
+	}
+	blob, err := storeBlob(r.Storer, []byte(note+"\n"))
+	if err != nil {
+		return err
+	}
+	name := hash.String()
+	treeHash, err := buildTree(r.Storer, tree, map[string]plumbing.Hash{
+		name:                      blob,
+		name[:2] + "/" + name[2:]: plumbing.ZeroHash,
+	})
+	if err != nil {
+		return err
+	}
+	var parents []plumbing.Hash
+	if notes != nil {
+		parents = append(parents, notes.Hash)
+	}
+	commit, err := db.commitTree(r.Storer, treeHash, parents, "Notes added by 'gitdb Annotate'\n")
+	if err != nil {
+		return err
+	}
+	return r.Storer.SetReference(plumbing.NewHashReference(notesRef, commit))
+}
+
+func (db DB) MustNotes(rev string) []string {
+	notes, err := db.Notes(rev)
+	if err != nil {
+		panic(err)
+	}
+	return notes
+}
+
+// Notes returns the notes attached to the commit rev by Annotate, or git
+// notes, split at blank lines. It returns nil if there are none.
+func (db DB) Notes(rev string) ([]string, error) {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := r.ResolveRevision(plumbing.Revision(rev))
+	if err != nil {
+		return nil, fmt.Errorf("Notes: %s: %v", rev, err)
+	}
+	_, tree, err := notesTree(r)
+	if err != nil {
+		return nil, err
+	}
+	content, err := noteContent(tree, hash.String())
+	if err != nil || content == "" {
+		return nil, err
+	}
+	return strings.Split(content, "\n\n"), nil
+}
+
+// notesTree returns the commit of the notes ref and its tree, nil if there
+// are no notes.
+func notesTree(r *git.Repository) (*object.Commit, *object.Tree, error) {
+	ref, err := r.Reference(notesRef, true)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	commit, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		return nil, nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, nil, err
+	}
+	return commit, tree, nil
+}
+
+// noteContent returns the note of the commit hash in tree, which git may
+// have split into a fanout directory named after the first two characters
+// of the hash.
+func noteContent(tree *object.Tree, hash string) (string, error) {
+	if tree == nil {
+		return "", nil
+	}
+	for _, name := range []string{hash, hash[:2] + "/" + hash[2:]} {
+		f, err := tree.File(name)
+		if err == object.ErrFileNotFound {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		content, err := f.Contents()
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(content), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,26 @@
+package gitdb
+
+import (
+	"github.com/go-git/go-git/v5"
+)
+
+// OnError makes gitdb call fn with the name of the operation, like "push",
+// "pull", "commit", "read" or "write", and the error of every failure of
+// Init, ForceUpdate, Pull, Commit, Push, and of Read and Write of
+// collections and objects, as well as of the work done in the background
+// by push policies, batched commits, AutoSync, PublishEvery and
+// RunScheduledEvery, so applications can alert on rejected pushes, auth
+// failures or files that fail to parse without checking every call. fn
+// is called synchronously, possibly from several goroutines at once.
+func (db *DB) OnError(fn func(op string, err error)) {
+	db.onError = fn
+}
+
+// reportError calls the OnError callback with op and err, unless err is
+// nil or means there was nothing to push or pull, and returns err.
+func (db DB) reportError(op string, err error) error {
+	if err != nil && err != git.NoErrAlreadyUpToDate && db.onError != nil {
+		db.onError(op, err)
+	}
+	return err
+}
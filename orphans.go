@@ -0,0 +1,257 @@
+package gitdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+type (
+	// Orphans are the files found by DB.Orphans.
+	Orphans struct {
+		// Files are the JSON files next to collections and objects that
+		// belong to none of them nor to a path given to Manage, and the
+		// files next to attachments that no record refers to.
+		Files []string
+		// MissingAttachments are the attachments referred to by records
+		// whose file does not exist.
+		MissingAttachments []MissingAttachment
+	}
+
+	// MissingAttachment describes a record field tagged
+	// `gitdb:"attachment"` whose file does not exist.
+	MissingAttachment struct {
+		Collection string
+		Index      int
+		Field      string
+		Path       string
+	}
+)
+
+func (a MissingAttachment) Error() string {
+	return fmt.Sprintf("%s[%d].%s: %s not found", a.Collection, a.Index, a.Field, a.Path)
+}
+
+func (db DB) MustOrphans() *Orphans {
+	orphans, err := db.Orphans()
+	if err != nil {
+		panic(err)
+	}
+	return orphans
+}
+
+// Orphans lists the files left behind in the directories of the
+// collections and objects created by NewCollection and NewObject, and
+// checks the attachments of their records. Attachments are the paths,
+// relative to the root, held by string fields, or slices of strings, of
+// the Model of a collection tagged `gitdb:"attachment"`.
+func (db DB) Orphans() (*Orphans, error) {
+	s := db.state()
+	orphans := &Orphans{}
+	referenced := map[string]bool{}
+	attachmentDirs := map[string]bool{}
+	for _, c := range s.managedCollections() {
+		missing, err := c.attachments(referenced)
+		if err != nil {
+			return nil, err
+		}
+		orphans.MissingAttachments = append(orphans.MissingAttachments, missing...)
+	}
+	for name := range referenced {
+		attachmentDirs[filepath.Dir(name)] = true
+	}
+	dirs := map[string]bool{}
+	for _, c := range s.managedCollections() {
+		dirs[filepath.Dir(filepath.Clean(c.Path))] = true
+	}
+	s.registry.Lock()
+	for _, o := range s.objects {
+		dirs[filepath.Dir(filepath.FromSlash(o))] = true
+	}
+	s.registry.Unlock()
+	seen := map[string]bool{}
+	for dir := range dirs {
+		err := db.eachFile(dir, func(name string) {
+			if filepath.Ext(name) == recordFileExt && !s.managedPath(name) && !referenced[name] {
+				seen[name] = true
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for dir := range attachmentDirs {
+		err := db.eachFile(dir, func(name string) {
+			if !referenced[name] && !s.claimedPath(name) {
+				seen[name] = true
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for name := range seen {
+		orphans.Files = append(orphans.Files, name)
+	}
+	sort.Strings(orphans.Files)
+	return orphans, nil
+}
+
+func (db DB) MustRemoveOrphans() []string {
+	files, err := db.RemoveOrphans()
+	if err != nil {
+		panic(err)
+	}
+	return files
+}
+
+// RemoveOrphans removes the files listed by Orphans, commits it and
+// returns them.
+func (db DB) RemoveOrphans() ([]string, error) {
+	orphans, err := db.Orphans()
+	if err != nil || len(orphans.Files) == 0 {
+		return nil, err
+	}
+	for _, name := range orphans.Files {
+		if err := os.Remove(filepath.Join(db.Local, name)); err != nil {
+			return nil, err
+		}
+	}
+	if err := db.Add(orphans.Files...); err != nil {
+		return nil, err
+	}
+	err = db.commitOp(CommitInfo{
+		Op:      "remove",
+		Paths:   orphans.Files,
+		Message: "remove orphans " + strings.Join(orphans.Files, ", "),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return orphans.Files, nil
+}
+
+// eachFile calls fn with the path of every file in dir, a directory of
+// the repository.
+func (db DB) eachFile(dir string, fn func(name string)) error {
+	infos, err := ioutil.ReadDir(filepath.Join(db.Local, dir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		if !info.IsDir() {
+			fn(filepath.Join(dir, info.Name()))
+		}
+	}
+	return nil
+}
+
+// claimedPath reports whether name belongs to a collection or an object.
+func (s *repoState) claimedPath(name string) bool {
+	for _, c := range s.managedCollections() {
+		if c.owns(name) {
+			return true
+		}
+	}
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	for _, o := range s.objects {
+		if o == filepath.ToSlash(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// attachments adds the attachments of the records of c to referenced and
+// returns the missing ones.
+func (c Collection) attachments(referenced map[string]bool) ([]MissingAttachment, error) {
+	if c.Model == nil {
+		return nil, nil
+	}
+	fields := attachmentFields(reflect.TypeOf(c.Model))
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	records, err := c.adminRecords()
+	if err != nil {
+		return nil, err
+	}
+	var missing []MissingAttachment
+	for i := 0; i < records.Len(); i++ {
+		record := indirectValue(records.Index(i))
+		if !record.IsValid() {
+			continue
+		}
+		for _, f := range fields {
+			for _, value := range attachmentValues(record.FieldByIndex(f.index)) {
+				name := c.db.resolve(filepath.FromSlash(value))
+				referenced[name] = true
+				if _, err := os.Stat(filepath.Join(c.db.Local, name)); os.IsNotExist(err) {
+					missing = append(missing, MissingAttachment{
+						Collection: c.Path,
+						Index:      i,
+						Field:      f.name,
+						Path:       value,
+					})
+				}
+			}
+		}
+	}
+	return missing, nil
+}
+
+// attachmentFields returns the fields of t tagged `gitdb:"attachment"`.
+func attachmentFields(t reflect.Type) []refField {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var fields []refField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			for _, f := range attachmentFields(sf.Type) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		if _, ok := gitdbTag(sf)["attachment"]; ok {
+			fields = append(fields, refField{index: []int{i}, name: sf.Name})
+		}
+	}
+	return fields
+}
+
+// attachmentValues returns the non-empty strings held by v, a string, a
+// pointer to one or a slice of them.
+func attachmentValues(v reflect.Value) []string {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return attachmentValues(v.Elem())
+	case reflect.Slice, reflect.Array:
+		var values []string
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, attachmentValues(v.Index(i))...)
+		}
+		return values
+	case reflect.String:
+		if v.String() != "" {
+			return []string{v.String()}
+		}
+	}
+	return nil
+}
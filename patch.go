@@ -0,0 +1,155 @@
+package gitdb
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+)
+
+func (o Object) MustSetPath(expr string, value interface{}) {
+	if err := o.SetPath(expr, value); err != nil {
+		panic(err)
+	}
+}
+
+// SetPath sets the value at the path expr of the object, like
+// ".features.darkMode", creating the missing objects on the way, and
+// writes the object back. The path is made of field names and indexes,
+// like for ReadPath, starting with a field; an index may be the length of
+// its array to append.
+// The rest of the document is kept as it is, with its keys sorted.
+func (o Object) SetPath(expr string, value interface{}) error {
+	steps, ok := simpleJQPath(expr)
+	if !ok || len(steps) == 0 || !steps[0].field {
+		return fmt.Errorf("SetPath: invalid path %s", expr)
+	}
+	doc, err := o.readGeneric()
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	if err := decodeGeneric(o.db.jsonOptions().marshal(value), &v); err != nil {
+		return fmt.Errorf("SetPath: %v", err)
+	}
+	if doc, err = setPath(doc, steps, v); err != nil {
+		return fmt.Errorf("SetPath: %v", err)
+	}
+	return o.Write(doc)
+}
+
+func (o Object) MustDeletePath(expr string) {
+	if err := o.DeletePath(expr); err != nil {
+		panic(err)
+	}
+}
+
+// DeletePath removes the value at the path expr of the object and writes
+// the object back. Removing an array element shifts the following ones.
+// Nothing is written if the path does not exist.
+func (o Object) DeletePath(expr string) error {
+	steps, ok := simpleJQPath(expr)
+	if !ok || len(steps) == 0 || !steps[0].field {
+		return fmt.Errorf("DeletePath: invalid path %s", expr)
+	}
+	doc, err := o.readGeneric()
+	if err != nil {
+		return err
+	}
+	doc, deleted, err := deletePath(doc, steps)
+	if err != nil {
+		return fmt.Errorf("DeletePath: %v", err)
+	}
+	if !deleted {
+		return nil
+	}
+	return o.Write(doc)
+}
+
+// readGeneric reads the object without a Go type, keeping numbers as they
+// are written.
+func (o Object) readGeneric() (interface{}, error) {
+	var doc interface{}
+	err := readJsonWith(filepath.Join(o.db.Local, o.Path), &doc, JSONOptions{UseNumber: true})
+	return doc, err
+}
+
+func decodeGeneric(b []byte, dest interface{}) error {
+	if b == nil {
+		return fmt.Errorf("value cannot be encoded")
+	}
+	return JSONOptions{UseNumber: true}.newDecoder(bytes.NewReader(b)).Decode(dest)
+}
+
+func setPath(doc interface{}, steps []jqStep, value interface{}) (interface{}, error) {
+	if len(steps) == 0 {
+		return value, nil
+	}
+	step := steps[0]
+	if step.field {
+		m, ok := doc.(map[string]interface{})
+		if doc == nil {
+			m = map[string]interface{}{}
+		} else if !ok {
+			return nil, fmt.Errorf("cannot index %s with %s", jqTypeName(doc), step)
+		}
+		v, err := setPath(m[step.name], steps[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		m[step.name] = v
+		return m, nil
+	}
+	list, ok := doc.([]interface{})
+	if doc != nil && !ok {
+		return nil, fmt.Errorf("cannot index %s with %s", jqTypeName(doc), step)
+	}
+	if step.index > len(list) {
+		return nil, fmt.Errorf("index %d out of range", step.index)
+	}
+	if step.index == len(list) {
+		list = append(list, nil)
+	}
+	v, err := setPath(list[step.index], steps[1:], value)
+	if err != nil {
+		return nil, err
+	}
+	list[step.index] = v
+	return list, nil
+}
+
+func deletePath(doc interface{}, steps []jqStep) (interface{}, bool, error) {
+	step := steps[0]
+	switch x := doc.(type) {
+	case nil:
+		return nil, false, nil
+	case map[string]interface{}:
+		if !step.field {
+			break
+		}
+		v, ok := x[step.name]
+		if !ok {
+			return x, false, nil
+		}
+		if len(steps) == 1 {
+			delete(x, step.name)
+			return x, true, nil
+		}
+		v, deleted, err := deletePath(v, steps[1:])
+		x[step.name] = v
+		return x, deleted, err
+	case []interface{}:
+		if step.field {
+			break
+		}
+		if step.index >= len(x) {
+			return x, false, nil
+		}
+		if len(steps) == 1 {
+			return append(x[:step.index], x[step.index+1:]...), true, nil
+		}
+		v, deleted, err := deletePath(x[step.index], steps[1:])
+		x[step.index] = v
+		return x, deleted, err
+	}
+	return nil, false, fmt.Errorf("cannot index %s with %s", jqTypeName(doc), step)
+}
@@ -0,0 +1,171 @@
+package gitdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	// PolicyInput is a change evaluated by a PolicyEngine: a record added
+	// to, removed from or modified in a collection, or the content of an
+	// object or of a collection not holding an array.
+	PolicyInput struct {
+		Path string `json:"path"`
+		// Key is the key of the record, empty for whole files.
+		Key string `json:"key,omitempty"`
+		// Old and New are null for added and removed records.
+		Old         json.RawMessage `json:"old"`
+		New         json.RawMessage `json:"new"`
+		Author      string          `json:"author"`
+		AuthorEmail string          `json:"authorEmail"`
+	}
+
+	// PolicyEngine evaluates the changes of writes and returns the reasons
+	// why a change is denied, none if it is allowed.
+	PolicyEngine interface {
+		Evaluate(ctx context.Context, input PolicyInput) ([]string, error)
+	}
+
+	// OPA evaluates Rego policies with the REST API of an Open Policy
+	// Agent server.
+	OPA struct {
+		// URL is the address of the server, like "http://localhost:8181".
+		URL string
+		// Rule is the path of the rule to query, like "gitdb/deny". A
+		// rule holding a set or an array of messages denies a change
+		// when it is not empty; a boolean rule denies it when false.
+		Rule   string
+		Client *http.Client
+	}
+
+	// PolicyViolation is returned by Write when the policy engine denies a
+	// change.
+	PolicyViolation struct {
+		Path    string
+		Key     string
+		Reasons []string
+	}
+)
+
+func (v PolicyViolation) Error() string {
+	name := v.Path
+	if v.Key != "" {
+		name += " record " + v.Key
+	}
+	return fmt.Sprintf("%s violates policy: %s", name, strings.Join(v.Reasons, "; "))
+}
+
+// SetPolicyEngine makes the Write of every Collection and Object evaluate
+// its changes with p and fail with a PolicyViolation without writing
+// anything if one of them is denied.
+func (db *DB) SetPolicyEngine(p PolicyEngine) {
+	db.policy = p
+}
+
+func (o OPA) Evaluate(ctx context.Context, input PolicyInput) ([]string, error) {
+	var res struct {
+		Result interface{} `json:"result"`
+	}
+	u := fmt.Sprintf("%s/v1/data/%s", strings.TrimSuffix(o.URL, "/"), strings.Trim(o.Rule, "/"))
+	err := postJSON(ctx, o.Client, u, nil, map[string]interface{}{
+		"input": input,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	switch result := res.Result.(type) {
+	case nil:
+		return nil, fmt.Errorf("%s is undefined", o.Rule)
+	case bool:
+		if !result {
+			return []string{o.Rule + " is false"}, nil
+		}
+		return nil, nil
+	case []interface{}:
+		var reasons []string
+		for _, r := range result {
+			if s, ok := r.(string); ok {
+				reasons = append(reasons, s)
+			} else {
+				b, _ := json.Marshal(r)
+				reasons = append(reasons, string(b))
+			}
+		}
+		return reasons, nil
+	default:
+		return nil, fmt.Errorf("%s is not a boolean, a set or an array", o.Rule)
+	}
+}
+
+// checkPolicy evaluates the changes of writing content, already prepared,
+// to c.
+func (c Collection) checkPolicy(content interface{}, funcs ...interface{}) error {
+	_, changes, err := c.preview(content, funcs...)
+	if err != nil {
+		return err
+	}
+	var inputs []PolicyInput
+	if changes == nil {
+		before, err := c.currentContent()
+		if err != nil {
+			return err
+		}
+		after := writeWith(c.JSONPCallbackName, c.jsonOptions(), content, funcs...).Bytes()
+		inputs = append(inputs, PolicyInput{Old: jsonOrNull(jsonpContent(before)), New: jsonpContent(after)})
+	} else {
+		for _, r := range changes.Added {
+			inputs = append(inputs, PolicyInput{Key: recordKey(r, changes.KeyField), Old: jsonOrNull(nil), New: r})
+		}
+		for _, r := range changes.Removed {
+			inputs = append(inputs, PolicyInput{Key: recordKey(r, changes.KeyField), Old: r, New: jsonOrNull(nil)})
+		}
+		for _, r := range changes.Modified {
+			inputs = append(inputs, PolicyInput{Key: r.Key, Old: r.Before, New: r.After})
+		}
+	}
+	return c.db.evaluatePolicy(c.Path, inputs)
+}
+
+// checkPolicy evaluates the change of writing w to o.
+func (o Object) checkPolicy(w []byte) error {
+	before, err := ioutil.ReadFile(filepath.Join(o.db.Local, o.Path))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return o.db.evaluatePolicy(o.Path, []PolicyInput{{
+		Old: jsonOrNull(jsonpContent(before)),
+		New: jsonpContent(w),
+	}})
+}
+
+func (db DB) evaluatePolicy(path string, inputs []PolicyInput) error {
+	author := db.signature()
+	for _, input := range inputs {
+		if input.Key == "" && string(input.Old) == string(input.New) {
+			continue
+		}
+		input.Path = filepath.ToSlash(path)
+		input.Author, input.AuthorEmail = author.Name, author.Email
+		reasons, err := db.policy.Evaluate(context.Background(), input)
+		if err != nil {
+			return fmt.Errorf("Write: policy: %v", err)
+		}
+		if len(reasons) > 0 {
+			return PolicyViolation{Path: input.Path, Key: input.Key, Reasons: reasons}
+		}
+	}
+	return nil
+}
+
+func jsonOrNull(b []byte) json.RawMessage {
+	if len(strings.TrimSpace(string(b))) == 0 {
+		return json.RawMessage("null")
+	}
+	return b
+}
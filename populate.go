@@ -0,0 +1,124 @@
+package gitdb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type (
+	Populator struct {
+		field string
+		from  *Collection
+		into  string
+	}
+)
+
+// Populate returns a Populator for ReadWith that looks up the value of
+// field in from and stores the matching record in the into field. The
+// key of from is taken from the field's `gitdb:"ref=..."` tag and defaults
+// to "id". If field is a slice, into must be a slice too.
+func Populate(field string, from *Collection, into string) Populator {
+	return Populator{
+		field: field,
+		from:  from,
+		into:  into,
+	}
+}
+
+func (c Collection) MustReadWith(dest interface{}, populators ...Populator) {
+	if err := c.ReadWith(dest, populators...); err != nil {
+		panic(err)
+	}
+}
+
+func (c Collection) ReadWith(dest interface{}, populators ...Populator) error {
+	if err := c.Read(dest); err != nil {
+		return err
+	}
+	records := reflect.Indirect(reflect.ValueOf(dest))
+	for _, p := range populators {
+		if err := p.populate(records); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p Populator) populate(records reflect.Value) error {
+	elemType := records.Type().Elem()
+	for elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("Populate: records must be structs, got %s", elemType)
+	}
+	src, ok := elemType.FieldByName(p.field)
+	if !ok {
+		return fmt.Errorf("Populate: %s has no field %s", elemType, p.field)
+	}
+	dst, ok := elemType.FieldByName(p.into)
+	if !ok {
+		return fmt.Errorf("Populate: %s has no field %s", elemType, p.into)
+	}
+	key := "id"
+	if ref := gitdbTag(src)["ref"]; ref != "" {
+		if i := strings.LastIndexByte(ref, '#'); i > -1 {
+			key = ref[i+1:]
+		}
+	}
+
+	targetType := dst.Type
+	multiple := targetType.Kind() == reflect.Slice
+	if multiple {
+		targetType = targetType.Elem()
+	}
+	targets := reflect.New(reflect.SliceOf(targetType))
+	if err := p.from.Read(targets.Interface()); err != nil {
+		return err
+	}
+	byKey := map[string]reflect.Value{}
+	for i := 0; i < targets.Elem().Len(); i++ {
+		target := targets.Elem().Index(i)
+		if k, ok := keyOf(target, key); ok {
+			byKey[k] = target
+		}
+	}
+
+	for i := 0; i < records.Len(); i++ {
+		record := reflect.Indirect(records.Index(i))
+		if !record.IsValid() {
+			continue
+		}
+		value := record.FieldByIndex(src.Index)
+		into := record.FieldByIndex(dst.Index)
+		if !multiple {
+			if isNilValue(value) {
+				continue
+			}
+			if target, ok := byKey[fmt.Sprint(reflect.Indirect(value).Interface())]; ok {
+				into.Set(target)
+			}
+			continue
+		}
+		if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+			return fmt.Errorf("Populate: %s must be a slice to populate %s", p.field, p.into)
+		}
+		found := reflect.MakeSlice(into.Type(), 0, value.Len())
+		for j := 0; j < value.Len(); j++ {
+			if target, ok := byKey[fmt.Sprint(value.Index(j).Interface())]; ok {
+				found = reflect.Append(found, target)
+			}
+		}
+		into.Set(found)
+	}
+	return nil
+}
+
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,245 @@
+package gitdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+const (
+	// previewContext is the number of unchanged lines around the changes
+	// of a hunk.
+	previewContext = 3
+	// previewMaxCells limits the size of the table used to diff the
+	// changed lines; larger changes show up as all lines replaced.
+	previewMaxCells = 1 << 22
+)
+
+type diffLine struct {
+	op   byte
+	text string
+}
+
+func (c Collection) MustPreviewWrite(content interface{}, funcs ...interface{}) (string, *CollectionChanges) {
+	diff, changes, err := c.PreviewWrite(content, funcs...)
+	if err != nil {
+		panic(err)
+	}
+	return diff, changes
+}
+
+// PreviewWrite returns what Write would change without writing anything:
+// the unified diff between the current file and the one content would be
+// written as, and, if both hold arrays, their record changes. Chunked and
+// per-record collections are compared as if they were one file. The diff
+// is empty if nothing would change.
+func (c Collection) PreviewWrite(content interface{}, funcs ...interface{}) (diff string, changes *CollectionChanges, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("PreviewWrite: %v", r)
+		}
+	}()
+	return c.preview(c.prepare(content), funcs...)
+}
+
+// preview is PreviewWrite with content already prepared.
+func (c Collection) preview(content interface{}, funcs ...interface{}) (string, *CollectionChanges, error) {
+	before, err := c.currentContent()
+	if err != nil {
+		return "", nil, err
+	}
+	if kind := reflect.ValueOf(content).Kind(); kind == reflect.Slice || kind == reflect.Array {
+		if c.MaxRecordSize > 0 && c.ChunkSize == 0 && c.RecordKeyField == "" {
+			records, err := c.marshalRecords(content, funcs...)
+			if err != nil {
+				return "", nil, err
+			}
+			content, funcs = records, nil
+		}
+	}
+	after := writeWith(c.JSONPCallbackName, c.jsonOptions(), content, funcs...).Bytes()
+	name := filepath.ToSlash(c.Path)
+	diff := unifiedDiff(name, splitLines(string(before)), splitLines(string(after)))
+	var a, b []json.RawMessage
+	if len(before) > 0 && json.Unmarshal(jsonpContent(before), &a) != nil ||
+		json.Unmarshal(jsonpContent(after), &b) != nil {
+		return diff, nil, nil
+	}
+	if a, err = normalizeRecords(a); err != nil {
+		return "", nil, err
+	}
+	if b, err = normalizeRecords(b); err != nil {
+		return "", nil, err
+	}
+	cc := compareRecords(a, b, c.RecordKeyField)
+	cc.Path = name
+	return diff, &cc, nil
+}
+
+// currentContent returns the content of the file of c, or, for chunked and
+// per-record collections, its records written as one file.
+func (c Collection) currentContent() ([]byte, error) {
+	path := filepath.Join(c.db.Local, c.Path)
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && c.RecordKeyField == "" {
+		return nil, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var m chunkManifest
+	if c.RecordKeyField == "" && (json.Unmarshal(content, &m) != nil || len(m.Chunks) == 0) {
+		return content, nil
+	}
+	records, err := c.adminRecords()
+	if err != nil {
+		return nil, err
+	}
+	return writeWith(c.JSONPCallbackName, c.jsonOptions(), records.Interface()).Bytes(), nil
+}
+
+// splitLines splits s into lines without their line endings.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// unifiedDiff returns the changes from a to b, the lines of the file name,
+// in the unified format of diff -u.
+func unifiedDiff(name string, a, b []string) string {
+	lines := diffLines(a, b)
+	// positions of each line in a and b
+	aPos := make([]int, len(lines)+1)
+	bPos := make([]int, len(lines)+1)
+	for i, l := range lines {
+		aPos[i+1], bPos[i+1] = aPos[i], bPos[i]
+		if l.op != '+' {
+			aPos[i+1]++
+		}
+		if l.op != '-' {
+			bPos[i+1]++
+		}
+	}
+	var sb strings.Builder
+	for i := 0; i < len(lines); {
+		for i < len(lines) && lines[i].op == ' ' {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+		end := i + 1
+		for j := i; j < len(lines) && j-end < 2*previewContext; j++ {
+			if lines[j].op != ' ' {
+				end = j + 1
+			}
+		}
+		start := i - previewContext
+		if start < 0 {
+			start = 0
+		}
+		if end += previewContext; end > len(lines) {
+			end = len(lines)
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- a/%s\n+++ b/%s\n", name, name)
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n", hunkRange(aPos[start], aPos[end]), hunkRange(bPos[start], bPos[end]))
+		for _, l := range lines[start:end] {
+			sb.WriteByte(l.op)
+			sb.WriteString(l.text)
+			sb.WriteByte('\n')
+		}
+		i = end
+	}
+	return sb.String()
+}
+
+func hunkRange(from, to int) string {
+	if to-from == 0 {
+		return fmt.Sprintf("%d,0", from)
+	}
+	if to-from == 1 {
+		return fmt.Sprintf("%d", from+1)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// diffLines returns the lines of a and b marked as unchanged (' '),
+// removed ('-') or added ('+'), following their longest common
+// subsequence.
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	var lines []diffLine
+	for _, text := range a[:prefix] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	lines = append(lines, lcsLines(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	return lines
+}
+
+func lcsLines(a, b []string) []diffLine {
+	var lines []diffLine
+	if (len(a)+1)*(len(b)+1) > previewMaxCells {
+		for _, text := range a {
+			lines = append(lines, diffLine{'-', text})
+		}
+		for _, text := range b {
+			lines = append(lines, diffLine{'+', text})
+		}
+		return lines
+	}
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	width := len(b) + 1
+	lcs := make([]int, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else if x, y := lcs[(i+1)*width+j], lcs[i*width+j+1]; x >= y {
+				lcs[i*width+j] = x
+			} else {
+				lcs[i*width+j] = y
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[(i+1)*width+j] >= lcs[i*width+j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{'+', b[j]})
+	}
+	return lines
+}
@@ -0,0 +1,262 @@
+package gitdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+type (
+	PullRequest struct {
+		Number int
+		URL    string
+		Branch string
+	}
+
+	PullRequestOptions struct {
+		Title string
+		Body  string
+		Head  string
+		Base  string
+	}
+
+	// PullRequestProvider opens pull requests (or merge requests) on the
+	// hosting service of the remote.
+	PullRequestProvider interface {
+		OpenPullRequest(ctx context.Context, opts PullRequestOptions) (*PullRequest, error)
+	}
+
+	GitHub struct {
+		Token string
+		// Tokens, if set, is used instead of Token, e.g. a *GitHubApp.
+		Tokens  TokenSource
+		Owner   string
+		Repo    string
+		BaseURL string
+		Client  *http.Client
+	}
+
+	GitLab struct {
+		Token string
+		// Project is the numeric ID or the full path of the project.
+		Project string
+		BaseURL string
+		Client  *http.Client
+	}
+)
+
+// SetPullRequestProvider makes Save propose changes through pull requests
+// opened with p instead of committing to the branch.
+func (db *DB) SetPullRequestProvider(p PullRequestProvider) {
+	db.pullRequests = p
+}
+
+func (db DB) MustSave(ctx context.Context, message string) *PullRequest {
+	pr, err := db.Save(ctx, message)
+	if err != nil {
+		panic(err)
+	}
+	return pr
+}
+
+// Save commits and pushes the staged changes. With a pull request provider,
+// the changes are committed to a new branch instead, which is pushed and
+// proposed for merging into the branch, and are removed from the worktree;
+// the opened pull request is returned.
+func (db DB) Save(ctx context.Context, message string) (*PullRequest, error) {
+	if db.pullRequests == nil {
+		if err := db.Commit(message); err != nil {
+			return nil, err
+		}
+		err := db.Push()
+		if err == git.NoErrAlreadyUpToDate {
+			err = nil
+		}
+		return nil, err
+	}
+
+	if err := db.checkWritable("Save"); err != nil {
+		return nil, err
+	}
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return nil, err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	s, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+	if !hasStagedChanges(s) {
+		log.Println("nothing to commit")
+		return nil, nil
+	}
+	// the branch goes through the checks of commit and push
+	if err := db.checkCommit(r, s); err != nil {
+		return nil, err
+	}
+	if err := db.writeChecksumManifest(r, w); err != nil {
+		return nil, err
+	}
+	if s, err = w.Status(); err != nil {
+		return nil, err
+	}
+	changes, err := stagedChanges(r, s)
+	if err != nil {
+		return nil, err
+	}
+	branch := fmt.Sprintf("gitdb/%d", time.Now().UnixNano())
+	hash, err := db.commitToBranch(r, branch, changes, db.addTrailers(message))
+	if err != nil {
+		return nil, err
+	}
+	ref := plumbing.NewBranchReferenceName(branch)
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+	if err := db.checkPush(r, tree); err != nil {
+		// keep the changes staged
+		r.Storer.RemoveReference(ref)
+		return nil, err
+	}
+	if err := unstage(r, db.Local, changes); err != nil {
+		return nil, err
+	}
+	if s, err = w.Status(); err != nil {
+		return nil, err
+	}
+	if err := db.pruneJournal(s); err != nil {
+		return nil, err
+	}
+
+	log.Println("pushing", branch)
+	err = r.PushContext(ctx, &git.PushOptions{
+		RemoteName: db.GetRemoteName(),
+		RefSpecs:   []config.RefSpec{config.RefSpec(ref + ":" + ref)},
+		Auth:       db.authMethod(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	title := message
+	if i := strings.IndexByte(title, '\n'); i > -1 {
+		title = title[:i]
+	}
+	pr, err := db.pullRequests.OpenPullRequest(ctx, PullRequestOptions{
+		Title: title,
+		Body:  message,
+		Head:  branch,
+		Base:  db.GetBranchName(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	pr.Branch = branch
+	log.Println("opened pull request", pr.URL)
+	return pr, nil
+}
+
+func (g GitHub) OpenPullRequest(ctx context.Context, opts PullRequestOptions) (*PullRequest, error) {
+	base := g.BaseURL
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	token := g.Token
+	if g.Tokens != nil {
+		var err error
+		if token, err = g.Tokens.Token(ctx); err != nil {
+			return nil, err
+		}
+	}
+	var res struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	err := postJSON(ctx, g.Client, fmt.Sprintf("%s/repos/%s/%s/pulls", strings.TrimSuffix(base, "/"), g.Owner, g.Repo), map[string]string{
+		"Authorization": "token " + token,
+		"Accept":        "application/vnd.github+json",
+	}, map[string]string{
+		"title": opts.Title,
+		"body":  opts.Body,
+		"head":  opts.Head,
+		"base":  opts.Base,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &PullRequest{Number: res.Number, URL: res.HTMLURL}, nil
+}
+
+func (g GitLab) OpenPullRequest(ctx context.Context, opts PullRequestOptions) (*PullRequest, error) {
+	base := g.BaseURL
+	if base == "" {
+		base = "https://gitlab.com/api/v4"
+	}
+	var res struct {
+		IID    int    `json:"iid"`
+		WebURL string `json:"web_url"`
+	}
+	err := postJSON(ctx, g.Client, fmt.Sprintf("%s/projects/%s/merge_requests", strings.TrimSuffix(base, "/"), url.PathEscape(g.Project)), map[string]string{
+		"PRIVATE-TOKEN": g.Token,
+	}, map[string]string{
+		"title":         opts.Title,
+		"description":   opts.Body,
+		"source_branch": opts.Head,
+		"target_branch": opts.Base,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &PullRequest{Number: res.IID, URL: res.WebURL}, nil
+}
+
+func postJSON(ctx context.Context, client *http.Client, u string, headers map[string]string, body, dest interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("POST %s: %s: %s", u, res.Status, bytes.TrimSpace(b))
+	}
+	return json.Unmarshal(b, dest)
+}
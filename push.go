@@ -0,0 +1,100 @@
+package gitdb
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+)
+
+type (
+	PushPolicy struct {
+		// Debounce delays pushes until no Push has been requested for
+		// this long.
+		Debounce time.Duration
+
+		// MaxPerHour limits the number of pushes made in any hour.
+		MaxPerHour int
+	}
+
+	PushOption func(*PushPolicy)
+)
+
+func Debounce(d time.Duration) PushOption {
+	return func(p *PushPolicy) {
+		p.Debounce = d
+	}
+}
+
+func MaxPerHour(n int) PushOption {
+	return func(p *PushPolicy) {
+		p.MaxPerHour = n
+	}
+}
+
+// SetPushPolicy makes Push return immediately and push in the background
+// according to opts, so bursts of pushes are coalesced. Calling it without
+// options restores synchronous pushes.
+func (db *DB) SetPushPolicy(opts ...PushOption) {
+	var p PushPolicy
+	for _, opt := range opts {
+		opt(&p)
+	}
+	db.pushPolicy = p
+}
+
+func (p PushPolicy) enabled() bool {
+	return p.Debounce > 0 || p.MaxPerHour > 0
+}
+
+func (s *repoState) schedulePush(db DB) {
+	s.pushMu.Lock()
+	defer s.pushMu.Unlock()
+	now := time.Now()
+	delay := db.pushPolicy.Debounce
+	if max := db.pushPolicy.MaxPerHour; max > 0 {
+		recent := s.pushes[:0]
+		for _, t := range s.pushes {
+			if now.Sub(t) < time.Hour {
+				recent = append(recent, t)
+			}
+		}
+		s.pushes = recent
+		if len(recent) >= max {
+			if wait := recent[len(recent)-max].Add(time.Hour).Sub(now); wait > delay {
+				delay = wait
+			}
+		}
+	}
+	if s.pushTimer == nil || !s.pushTimer.Stop() {
+		s.background.Add(1)
+	}
+	s.pushTimer = time.AfterFunc(delay, func() {
+		defer s.background.Done()
+		s.pushMu.Lock()
+		s.pushTimer = nil
+		s.pushes = append(s.pushes, time.Now())
+		s.pushMu.Unlock()
+		if err := db.push(context.Background()); err != nil && err != git.NoErrAlreadyUpToDate {
+			log.Println("error pushing", err)
+			db.reportError("push", err)
+		}
+	})
+}
+
+// flushPush runs the pending background push, if any, right away.
+func (s *repoState) flushPush(ctx context.Context, db DB) error {
+	s.pushMu.Lock()
+	pending := s.pushTimer != nil && s.pushTimer.Stop()
+	s.pushTimer = nil
+	s.pushMu.Unlock()
+	if !pending {
+		return nil
+	}
+	defer s.background.Done()
+	if err := db.push(ctx); err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	return nil
+}
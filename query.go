@@ -0,0 +1,987 @@
+package gitdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/collate"
+)
+
+type (
+	// QueryResult holds the rows returned by Query, each with one value
+	// per column.
+	QueryResult struct {
+		Columns []string
+		Rows    [][]interface{}
+	}
+
+	selectStmt struct {
+		distinct bool
+		star     bool
+		items    []selectItem
+		from     string
+		where    sqlExpr
+		groupBy  []sqlExpr
+		orderBy  []orderItem
+		limit    int
+		offset   int
+		collator *collate.Collator
+	}
+
+	selectItem struct {
+		expr sqlExpr
+		name string
+	}
+
+	orderItem struct {
+		expr sqlExpr
+		desc bool
+	}
+
+	sqlToken struct {
+		kind  byte // 'i' identifier, 'k' keyword, 's' string, 'n' number, 'o' operator, 0 end
+		value string
+		pos   int
+	}
+
+	sqlParser struct {
+		src    string
+		tokens []sqlToken
+		i      int
+	}
+
+	// sqlRow is what an expression is evaluated against: a record, the
+	// records of its group for aggregates, and the output columns for
+	// ORDER BY.
+	sqlRow struct {
+		record  map[string]interface{}
+		group   []map[string]interface{}
+		columns map[string]interface{}
+	}
+
+	sqlExpr interface {
+		eval(row sqlRow) interface{}
+	}
+
+	sqlLiteral struct{ value interface{} }
+	sqlColumn  struct{ path []string }
+	sqlUnary   struct {
+		op string
+		x  sqlExpr
+	}
+	sqlBinary struct {
+		op   string
+		x, y sqlExpr
+	}
+	sqlIn struct {
+		x    sqlExpr
+		list []sqlExpr
+		not  bool
+	}
+	sqlIsNull struct {
+		x   sqlExpr
+		not bool
+	}
+	sqlLike struct {
+		x   sqlExpr
+		re  *regexp.Regexp
+		not bool
+	}
+	sqlCall struct {
+		name string
+		args []sqlExpr
+		star bool
+	}
+)
+
+var sqlKeywords = map[string]bool{
+	"SELECT": true, "DISTINCT": true, "FROM": true, "WHERE": true, "GROUP": true,
+	"BY": true, "ORDER": true, "ASC": true, "DESC": true, "LIMIT": true,
+	"OFFSET": true, "AS": true, "AND": true, "OR": true, "NOT": true,
+	"IN": true, "IS": true, "NULL": true, "LIKE": true, "TRUE": true, "FALSE": true,
+}
+
+var sqlAggregates = map[string]bool{
+	"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true,
+}
+
+func (db DB) MustQuery(query string) *QueryResult {
+	result, err := db.Query(query)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// Query runs a SQL SELECT statement over the records of a collection file,
+// for ad-hoc analysis:
+//
+//	SELECT id, name FROM 'products.json' WHERE price > 10 ORDER BY name
+//
+// It supports DISTINCT, column aliases, WHERE with comparisons, LIKE, IN,
+// IS NULL, AND, OR, NOT and arithmetic, GROUP BY with COUNT, SUM, AVG, MIN
+// and MAX, ORDER BY, LIMIT and OFFSET, and the LOWER, UPPER and LENGTH
+// functions. Nested fields are addressed as a.b. Collections created by
+// NewCollection are read with their own options. Files outside of the
+// repository, or of the root (see SetRoot), cannot be queried.
+func (db DB) Query(query string) (*QueryResult, error) {
+	stmt, err := parseSelect(query)
+	if err != nil {
+		return nil, err
+	}
+	records, err := db.queryRecords(stmt.from)
+	if err != nil {
+		return nil, err
+	}
+	stmt.collator = newCollator(db.collation)
+	return stmt.run(records), nil
+}
+
+func (db DB) queryRecords(from string) ([]map[string]interface{}, error) {
+	path := filepath.Clean(db.resolve(from))
+	if filepath.IsAbs(path) || path == ".." || strings.HasPrefix(path, ".."+string(filepath.Separator)) || !db.inRoot(path) {
+		return nil, fmt.Errorf("Query: invalid path %s", from)
+	}
+	var records []map[string]interface{}
+	for _, c := range db.state().managedCollections() {
+		if filepath.Clean(c.Path) == filepath.Clean(path) {
+			err := c.Read(&records)
+			return records, err
+		}
+	}
+	full := filepath.Join(db.Local, path)
+	if _, err := os.Stat(full); err != nil {
+		return nil, err
+	}
+	err := readCollection(full, &records)
+	return records, err
+}
+
+func (s *selectStmt) run(records []map[string]interface{}) *QueryResult {
+	var matched []map[string]interface{}
+	for _, record := range records {
+		if record == nil {
+			continue
+		}
+		if s.where == nil || truthy(s.where.eval(sqlRow{record: record})) {
+			matched = append(matched, record)
+		}
+	}
+
+	result := &QueryResult{}
+	if s.star {
+		seen := map[string]bool{}
+		for _, record := range matched {
+			for k := range record {
+				if !seen[k] {
+					seen[k] = true
+					result.Columns = append(result.Columns, k)
+				}
+			}
+		}
+		sort.Strings(result.Columns)
+		s.items = nil
+		for _, k := range result.Columns {
+			s.items = append(s.items, selectItem{expr: sqlColumn{[]string{k}}, name: k})
+		}
+	} else {
+		for _, item := range s.items {
+			result.Columns = append(result.Columns, item.name)
+		}
+	}
+
+	var rows []sqlRow
+	if len(s.groupBy) > 0 || s.hasAggregate() {
+		groups := map[string]int{}
+		for _, record := range matched {
+			var key []string
+			for _, e := range s.groupBy {
+				key = append(key, jsonText(e.eval(sqlRow{record: record})))
+			}
+			k := strings.Join(key, "\x00")
+			i, ok := groups[k]
+			if !ok {
+				i = len(rows)
+				groups[k] = i
+				rows = append(rows, sqlRow{record: record})
+			}
+			rows[i].group = append(rows[i].group, record)
+		}
+		if len(rows) == 0 && len(s.groupBy) == 0 {
+			// aggregates over no records still return one row
+			rows = append(rows, sqlRow{record: map[string]interface{}{}, group: []map[string]interface{}{}})
+		}
+	} else {
+		for _, record := range matched {
+			rows = append(rows, sqlRow{record: record})
+		}
+	}
+
+	type outputRow struct {
+		values []interface{}
+		keys   []interface{}
+	}
+	var out []outputRow
+	seen := map[string]bool{}
+	for _, row := range rows {
+		values := make([]interface{}, len(s.items))
+		row.columns = map[string]interface{}{}
+		for i, item := range s.items {
+			values[i] = item.expr.eval(row)
+			row.columns[item.name] = values[i]
+		}
+		if s.distinct {
+			k := jsonText(values)
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+		}
+		var keys []interface{}
+		for _, o := range s.orderBy {
+			keys = append(keys, o.expr.eval(row))
+		}
+		out = append(out, outputRow{values, keys})
+	}
+	if len(s.orderBy) > 0 {
+		sort.SliceStable(out, func(i, j int) bool {
+			for k, o := range s.orderBy {
+				c := s.compare(out[i].keys[k], out[j].keys[k])
+				if c == 0 {
+					continue
+				}
+				if o.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+	}
+	if s.offset > len(out) {
+		s.offset = len(out)
+	}
+	out = out[s.offset:]
+	if s.limit >= 0 && s.limit < len(out) {
+		out = out[:s.limit]
+	}
+	for _, row := range out {
+		result.Rows = append(result.Rows, row.values)
+	}
+	return result
+}
+
+func (s *selectStmt) hasAggregate() bool {
+	for _, item := range s.items {
+		if hasAggregate(item.expr) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAggregate(e sqlExpr) bool {
+	switch x := e.(type) {
+	case sqlCall:
+		return sqlAggregates[x.name]
+	case sqlUnary:
+		return hasAggregate(x.x)
+	case sqlBinary:
+		return hasAggregate(x.x) || hasAggregate(x.y)
+	}
+	return false
+}
+
+func (e sqlLiteral) eval(row sqlRow) interface{} {
+	return e.value
+}
+
+func (e sqlColumn) eval(row sqlRow) interface{} {
+	if len(e.path) == 1 && row.columns != nil {
+		if v, ok := row.columns[e.path[0]]; ok {
+			return v
+		}
+	}
+	var v interface{} = row.record
+	for _, name := range e.path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+	return normalizeSQL(v)
+}
+
+func (e sqlUnary) eval(row sqlRow) interface{} {
+	x := e.x.eval(row)
+	switch e.op {
+	case "NOT":
+		if x == nil {
+			return nil
+		}
+		return !truthy(x)
+	case "-":
+		if f, ok := x.(float64); ok {
+			return -f
+		}
+	}
+	return nil
+}
+
+func (e sqlBinary) eval(row sqlRow) interface{} {
+	x := e.x.eval(row)
+	switch e.op {
+	case "AND":
+		if x != nil && !truthy(x) {
+			return false
+		}
+		y := e.y.eval(row)
+		if x == nil || y == nil {
+			if y != nil && !truthy(y) {
+				return false
+			}
+			return nil
+		}
+		return truthy(y)
+	case "OR":
+		if truthy(x) {
+			return true
+		}
+		y := e.y.eval(row)
+		if truthy(y) {
+			return true
+		}
+		if x == nil || y == nil {
+			return nil
+		}
+		return false
+	}
+	y := e.y.eval(row)
+	if x == nil || y == nil {
+		return nil
+	}
+	switch e.op {
+	case "=":
+		return compareSQL(x, y) == 0
+	case "!=", "<>":
+		return compareSQL(x, y) != 0
+	case "<":
+		return compareSQL(x, y) < 0
+	case "<=":
+		return compareSQL(x, y) <= 0
+	case ">":
+		return compareSQL(x, y) > 0
+	case ">=":
+		return compareSQL(x, y) >= 0
+	}
+	a, aok := x.(float64)
+	b, bok := y.(float64)
+	if !aok || !bok {
+		if e.op == "+" {
+			if s, ok := x.(string); ok {
+				return s + fmt.Sprint(y)
+			}
+		}
+		return nil
+	}
+	switch e.op {
+	case "+":
+		return a + b
+	case "-":
+		return a - b
+	case "*":
+		return a * b
+	case "/":
+		if b == 0 {
+			return nil
+		}
+		return a / b
+	case "%":
+		if b == 0 {
+			return nil
+		}
+		return math.Mod(a, b)
+	}
+	return nil
+}
+
+func (e sqlIn) eval(row sqlRow) interface{} {
+	x := e.x.eval(row)
+	if x == nil {
+		return nil
+	}
+	for _, item := range e.list {
+		if y := item.eval(row); y != nil && compareSQL(x, y) == 0 {
+			return !e.not
+		}
+	}
+	return e.not
+}
+
+func (e sqlIsNull) eval(row sqlRow) interface{} {
+	return (e.x.eval(row) == nil) != e.not
+}
+
+func (e sqlLike) eval(row sqlRow) interface{} {
+	s, ok := e.x.eval(row).(string)
+	if !ok {
+		return nil
+	}
+	return e.re.MatchString(s) != e.not
+}
+
+func (e sqlCall) eval(row sqlRow) interface{} {
+	if !sqlAggregates[e.name] {
+		var x interface{}
+		if len(e.args) > 0 {
+			x = e.args[0].eval(row)
+		}
+		s, ok := x.(string)
+		if !ok {
+			return nil
+		}
+		switch e.name {
+		case "LOWER":
+			return strings.ToLower(s)
+		case "UPPER":
+			return strings.ToUpper(s)
+		case "LENGTH":
+			return float64(len([]rune(s)))
+		}
+		return nil
+	}
+	var values []interface{}
+	for _, record := range row.group {
+		if e.star {
+			values = append(values, true)
+			continue
+		}
+		if v := e.args[0].eval(sqlRow{record: record}); v != nil {
+			values = append(values, v)
+		}
+	}
+	switch e.name {
+	case "COUNT":
+		return float64(len(values))
+	case "MIN", "MAX":
+		var best interface{}
+		for _, v := range values {
+			if c := compareSQL(v, best); best == nil || e.name == "MIN" && c < 0 || e.name == "MAX" && c > 0 {
+				best = v
+			}
+		}
+		return best
+	}
+	var sum float64
+	var n int
+	for _, v := range values {
+		if f, ok := v.(float64); ok {
+			sum += f
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	if e.name == "AVG" {
+		return sum / float64(n)
+	}
+	return sum
+}
+
+func normalizeSQL(v interface{}) interface{} {
+	if n, ok := v.(json.Number); ok {
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+		return n.String()
+	}
+	return v
+}
+
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	}
+	return v != nil
+}
+
+// compareSQL orders numbers, strings and booleans among themselves and
+// anything else by its JSON encoding, with null first.
+// compare is compareSQL comparing strings with the collator of the
+// statement, if any.
+func (s *selectStmt) compare(a, b interface{}) int {
+	if s.collator != nil {
+		if x, ok := a.(string); ok {
+			if y, ok := b.(string); ok {
+				return s.collator.CompareString(x, y)
+			}
+		}
+	}
+	return compareSQL(a, b)
+}
+
+func compareSQL(a, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		}
+		return 1
+	}
+	switch x := a.(type) {
+	case float64:
+		if y, ok := b.(float64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	case string:
+		if y, ok := b.(string); ok {
+			return strings.Compare(x, y)
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case !x:
+				return -1
+			}
+			return 1
+		}
+	}
+	return strings.Compare(jsonText(a), jsonText(b))
+}
+
+func jsonText(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func parseSelect(src string) (stmt *selectStmt, err error) {
+	p := &sqlParser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(sqlError); ok {
+				stmt, err = nil, e
+				return
+			}
+			panic(r)
+		}
+	}()
+	stmt = &selectStmt{limit: -1}
+	p.keyword("SELECT")
+	if p.accept('k', "DISTINCT") {
+		stmt.distinct = true
+	}
+	if p.accept('o', "*") {
+		stmt.star = true
+	} else {
+		for {
+			start := p.peek().pos
+			item := selectItem{expr: p.expr()}
+			end := len(src)
+			if p.i < len(p.tokens) {
+				end = p.peek().pos
+			}
+			item.name = strings.TrimSpace(src[start:end])
+			if col, ok := item.expr.(sqlColumn); ok {
+				item.name = strings.Join(col.path, ".")
+			}
+			if p.accept('k', "AS") || p.peek().kind == 'i' {
+				item.name = p.identifier()
+			}
+			stmt.items = append(stmt.items, item)
+			if !p.accept('o', ",") {
+				break
+			}
+		}
+	}
+	p.keyword("FROM")
+	stmt.from = p.source()
+	if p.accept('k', "WHERE") {
+		stmt.where = p.expr()
+	}
+	if p.accept('k', "GROUP") {
+		p.keyword("BY")
+		for {
+			stmt.groupBy = append(stmt.groupBy, p.expr())
+			if !p.accept('o', ",") {
+				break
+			}
+		}
+	}
+	if p.accept('k', "ORDER") {
+		p.keyword("BY")
+		for {
+			item := orderItem{expr: p.expr()}
+			if p.accept('k', "DESC") {
+				item.desc = true
+			} else {
+				p.accept('k', "ASC")
+			}
+			stmt.orderBy = append(stmt.orderBy, item)
+			if !p.accept('o', ",") {
+				break
+			}
+		}
+	}
+	if p.accept('k', "LIMIT") {
+		stmt.limit = p.integer()
+		if p.accept('k', "OFFSET") {
+			stmt.offset = p.integer()
+		}
+	}
+	if p.peek().kind != 0 {
+		p.fail("unexpected %s", p.peek().value)
+	}
+	return stmt, nil
+}
+
+type sqlError string
+
+func (e sqlError) Error() string {
+	return string(e)
+}
+
+func (p *sqlParser) fail(format string, args ...interface{}) {
+	panic(sqlError(fmt.Sprintf("Query: at %d: ", p.peek().pos) + fmt.Sprintf(format, args...)))
+}
+
+func (p *sqlParser) peek() sqlToken {
+	if p.i < len(p.tokens) {
+		return p.tokens[p.i]
+	}
+	return sqlToken{pos: len(p.src), value: "end of query"}
+}
+
+func (p *sqlParser) accept(kind byte, value string) bool {
+	if t := p.peek(); t.kind == kind && t.value == value {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *sqlParser) keyword(value string) {
+	if !p.accept('k', value) {
+		p.fail("expecting %s, got %s", value, p.peek().value)
+	}
+}
+
+func (p *sqlParser) identifier() string {
+	t := p.peek()
+	if t.kind != 'i' {
+		p.fail("expecting a name, got %s", t.value)
+	}
+	p.i++
+	return t.value
+}
+
+func (p *sqlParser) integer() int {
+	t := p.peek()
+	n, err := strconv.Atoi(t.value)
+	if t.kind != 'n' || err != nil || n < 0 {
+		p.fail("expecting a number, got %s", t.value)
+	}
+	p.i++
+	return n
+}
+
+// source parses the file name after FROM, quoted or not.
+func (p *sqlParser) source() string {
+	t := p.peek()
+	if t.kind == 's' || t.kind == 'i' && p.src[t.pos] == '"' {
+		p.i++
+		return t.value
+	}
+	var name strings.Builder
+	for t := p.peek(); t.kind == 'i' || t.kind == 'n' || t.kind == 'o' && strings.Contains("./-", t.value); t = p.peek() {
+		name.WriteString(t.value)
+		p.i++
+	}
+	if name.Len() == 0 {
+		p.fail("expecting a file name, got %s", t.value)
+	}
+	return name.String()
+}
+
+func (p *sqlParser) expr() sqlExpr {
+	x := p.and()
+	for p.accept('k', "OR") {
+		x = sqlBinary{"OR", x, p.and()}
+	}
+	return x
+}
+
+func (p *sqlParser) and() sqlExpr {
+	x := p.not()
+	for p.accept('k', "AND") {
+		x = sqlBinary{"AND", x, p.not()}
+	}
+	return x
+}
+
+func (p *sqlParser) not() sqlExpr {
+	if p.accept('k', "NOT") {
+		return sqlUnary{"NOT", p.not()}
+	}
+	return p.comparison()
+}
+
+func (p *sqlParser) comparison() sqlExpr {
+	x := p.additive()
+	for _, op := range []string{"=", "!=", "<>", "<=", ">=", "<", ">"} {
+		if p.accept('o', op) {
+			return sqlBinary{op, x, p.additive()}
+		}
+	}
+	if p.accept('k', "IS") {
+		not := p.accept('k', "NOT")
+		p.keyword("NULL")
+		return sqlIsNull{x, not}
+	}
+	not := p.accept('k', "NOT")
+	if p.accept('k', "LIKE") {
+		t := p.peek()
+		if t.kind != 's' {
+			p.fail("expecting a pattern, got %s", t.value)
+		}
+		p.i++
+		return sqlLike{x, likePattern(t.value), not}
+	}
+	if p.accept('k', "IN") {
+		if !p.accept('o', "(") {
+			p.fail("expecting (, got %s", p.peek().value)
+		}
+		var list []sqlExpr
+		for {
+			list = append(list, p.expr())
+			if !p.accept('o', ",") {
+				break
+			}
+		}
+		if !p.accept('o', ")") {
+			p.fail("expecting ), got %s", p.peek().value)
+		}
+		return sqlIn{x, list, not}
+	}
+	if not {
+		p.fail("expecting LIKE or IN, got %s", p.peek().value)
+	}
+	return x
+}
+
+func (p *sqlParser) additive() sqlExpr {
+	x := p.multiplicative()
+	for {
+		switch {
+		case p.accept('o', "+"):
+			x = sqlBinary{"+", x, p.multiplicative()}
+		case p.accept('o', "-"):
+			x = sqlBinary{"-", x, p.multiplicative()}
+		default:
+			return x
+		}
+	}
+}
+
+func (p *sqlParser) multiplicative() sqlExpr {
+	x := p.unary()
+	for {
+		switch {
+		case p.accept('o', "*"):
+			x = sqlBinary{"*", x, p.unary()}
+		case p.accept('o', "/"):
+			x = sqlBinary{"/", x, p.unary()}
+		case p.accept('o', "%"):
+			x = sqlBinary{"%", x, p.unary()}
+		default:
+			return x
+		}
+	}
+}
+
+func (p *sqlParser) unary() sqlExpr {
+	if p.accept('o', "-") {
+		return sqlUnary{"-", p.unary()}
+	}
+	return p.primary()
+}
+
+func (p *sqlParser) primary() sqlExpr {
+	t := p.peek()
+	switch t.kind {
+	case 'n':
+		p.i++
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			p.fail("invalid number %s", t.value)
+		}
+		return sqlLiteral{f}
+	case 's':
+		p.i++
+		return sqlLiteral{t.value}
+	case 'k':
+		switch t.value {
+		case "NULL":
+			p.i++
+			return sqlLiteral{nil}
+		case "TRUE":
+			p.i++
+			return sqlLiteral{true}
+		case "FALSE":
+			p.i++
+			return sqlLiteral{false}
+		}
+	case 'o':
+		if t.value == "(" {
+			p.i++
+			x := p.expr()
+			if !p.accept('o', ")") {
+				p.fail("expecting ), got %s", p.peek().value)
+			}
+			return x
+		}
+	case 'i':
+		p.i++
+		if p.accept('o', "(") {
+			call := sqlCall{name: strings.ToUpper(t.value)}
+			switch call.name {
+			case "COUNT", "SUM", "AVG", "MIN", "MAX", "LOWER", "UPPER", "LENGTH":
+			default:
+				p.i -= 2
+				p.fail("unknown function %s", t.value)
+			}
+			if p.accept('o', "*") {
+				if call.name != "COUNT" {
+					p.fail("%s(*) is not supported", call.name)
+				}
+				call.star = true
+			} else {
+				call.args = append(call.args, p.expr())
+			}
+			if !p.accept('o', ")") {
+				p.fail("expecting ), got %s", p.peek().value)
+			}
+			return call
+		}
+		col := sqlColumn{[]string{t.value}}
+		for p.accept('o', ".") {
+			col.path = append(col.path, p.identifier())
+		}
+		return col
+	}
+	p.fail("unexpected %s", t.value)
+	return nil
+}
+
+// likePattern compiles a LIKE pattern, where % matches any text and _ any
+// character.
+func likePattern(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	for _, r := range pattern {
+		switch r {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+func (p *sqlParser) lex() error {
+	src := p.src
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"':
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(src) {
+					return fmt.Errorf("Query: at %d: unterminated string", i)
+				}
+				if src[j] == c {
+					if j+1 < len(src) && src[j+1] == c {
+						b.WriteByte(c)
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteByte(src[j])
+				j++
+			}
+			kind := byte('s')
+			if c == '"' {
+				kind = 'i'
+			}
+			p.tokens = append(p.tokens, sqlToken{kind, b.String(), i})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.' && j+1 < len(src) && src[j+1] >= '0' && src[j+1] <= '9') {
+				j++
+			}
+			p.tokens = append(p.tokens, sqlToken{'n', src[i:j], i})
+			i = j
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			word := src[i:j]
+			if upper := strings.ToUpper(word); sqlKeywords[upper] {
+				p.tokens = append(p.tokens, sqlToken{'k', upper, i})
+			} else {
+				p.tokens = append(p.tokens, sqlToken{'i', word, i})
+			}
+			i = j
+		default:
+			op := string(c)
+			if i+1 < len(src) {
+				switch two := src[i : i+2]; two {
+				case "!=", "<>", "<=", ">=":
+					op = two
+				}
+			}
+			if !strings.Contains("=<>!+-*/%(),.", op[:1]) || op == "!" {
+				return fmt.Errorf("Query: at %d: unexpected %s", i, op)
+			}
+			p.tokens = append(p.tokens, sqlToken{'o', op, i})
+			i += len(op)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package gitdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const queryProducts = `[
+{"id":1,"name":"Apple","category":"fruit","price":3,"tags":{"color":"red"}},
+{"id":2,"name":"banana","category":"fruit","price":1.5},
+{"id":3,"name":"Carrot","category":"vegetable","price":null},
+{"id":4,"name":"date","category":"fruit","price":6},
+{"id":5,"name":"eggplant","category":null,"price":2}
+]
+`
+
+func newQueryDB(t *testing.T) *DB {
+	t.Helper()
+	db := newTestDB(t)
+	if err := ioutil.WriteFile(filepath.Join(db.Local, "products.json"), []byte(queryProducts), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestQuery(t *testing.T) {
+	db := newQueryDB(t)
+	tests := []struct {
+		query string
+		rows  string
+	}{
+		// WHERE
+		{`SELECT id FROM products.json WHERE price > 2`, `[[1],[4]]`},
+		{`SELECT id FROM products.json WHERE price >= 1.5 AND category = 'fruit'`, `[[1],[2],[4]]`},
+		{`SELECT id FROM products.json WHERE category = 'vegetable' OR price < 2`, `[[2],[3]]`},
+		{`SELECT id FROM products.json WHERE id IN (1, 3, 9)`, `[[1],[3]]`},
+		{`SELECT id FROM products.json WHERE id NOT IN (1, 3)`, `[[2],[4],[5]]`},
+		{`SELECT id FROM products.json WHERE price * 2 = 3`, `[[2]]`},
+		{`SELECT id FROM products.json WHERE tags.color = 'red'`, `[[1]]`},
+		{`SELECT id FROM products.json WHERE (id = 1 OR id = 2) AND NOT name = 'Apple'`, `[[2]]`},
+
+		// NULL
+		{`SELECT id FROM products.json WHERE price = NULL`, `null`},
+		{`SELECT id FROM products.json WHERE price IS NULL`, `[[3]]`},
+		{`SELECT id FROM products.json WHERE category IS NOT NULL AND price IS NOT NULL`, `[[1],[2],[4]]`},
+		{`SELECT id FROM products.json WHERE price != 3`, `[[2],[4],[5]]`},
+		{`SELECT id FROM products.json WHERE NOT category = 'fruit'`, `[[3]]`},
+		{`SELECT id FROM products.json WHERE price > 2 OR category IS NULL`, `[[1],[4],[5]]`},
+		{`SELECT id, price + 1 FROM products.json WHERE id = 3`, `[[3,null]]`},
+		{`SELECT COUNT(*), COUNT(price), SUM(price), AVG(price) FROM products.json`, `[[5,4,12.5,3.125]]`},
+		{`SELECT COUNT(*), SUM(price) FROM products.json WHERE id > 10`, `[[0,null]]`},
+
+		// LIKE
+		{`SELECT id FROM products.json WHERE name LIKE 'b%'`, `[[2]]`},
+		{`SELECT id FROM products.json WHERE name LIKE '%a_e'`, `[[4]]`},
+		{`SELECT id FROM products.json WHERE name NOT LIKE '%a%'`, `[[1]]`},
+		{`SELECT id FROM products.json WHERE LOWER(name) LIKE 'c%'`, `[[3]]`},
+		{`SELECT id FROM products.json WHERE name LIKE '.%'`, `null`},
+		{`SELECT id FROM products.json WHERE price LIKE '3'`, `null`},
+
+		// GROUP BY
+		{`SELECT category, COUNT(*) AS n FROM products.json GROUP BY category ORDER BY category`, `[[null,1],["fruit",3],["vegetable",1]]`},
+		{`SELECT category, MIN(price), MAX(price) FROM products.json WHERE category = 'fruit' GROUP BY category`, `[["fruit",1.5,6]]`},
+		{`SELECT category, SUM(price) AS total FROM products.json GROUP BY category ORDER BY total DESC`, `[["fruit",10.5],[null,2],["vegetable",null]]`},
+
+		// ORDER BY
+		{`SELECT id FROM products.json ORDER BY price`, `[[3],[2],[5],[1],[4]]`},
+		{`SELECT id FROM products.json ORDER BY price DESC LIMIT 2`, `[[4],[1]]`},
+		{`SELECT id FROM products.json ORDER BY category, name DESC`, `[[5],[4],[2],[1],[3]]`},
+		{`SELECT id FROM products.json ORDER BY id LIMIT 2 OFFSET 3`, `[[4],[5]]`},
+		{`SELECT DISTINCT category FROM products.json ORDER BY category`, `[[null],["fruit"],["vegetable"]]`},
+		{`SELECT name FROM products.json ORDER BY LENGTH(name), name LIMIT 2`, `[["date"],["Apple"]]`},
+	}
+	for _, test := range tests {
+		result, err := db.Query(test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		if got := jsonText(result.Rows); got != test.rows {
+			t.Errorf("%s: got %s, want %s", test.query, got, test.rows)
+		}
+	}
+}
+
+func TestQueryColumns(t *testing.T) {
+	db := newQueryDB(t)
+	result, err := db.Query(`SELECT name AS n, price * 2, tags.color FROM 'products.json' LIMIT 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := result.Columns, []string{"n", "price * 2", "tags.color"}; !equalStrings(got, want) {
+		t.Errorf("got columns %v, want %v", got, want)
+	}
+	result, err = db.Query(`SELECT * FROM products.json WHERE id = 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := result.Columns, []string{"category", "id", "name", "price"}; !equalStrings(got, want) {
+		t.Errorf("got columns %v, want %v", got, want)
+	}
+}
+
+func TestQueryErrors(t *testing.T) {
+	db := newQueryDB(t)
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{`SELECT FROM products.json`, "Query: at 7: unexpected FROM"},
+		{`SELECT id FROM products.json WHERE`, "Query: at 34: unexpected end of query"},
+		{`SELECT id FROM products.json WHERE name LIKE 1`, "Query: at 45: expecting a pattern, got 1"},
+		{`SELECT id FROM products.json WHERE id NOT 1`, "Query: at 42: expecting LIKE or IN, got 1"},
+		{`SELECT id FROM 'products.json`, "Query: at 15: unterminated string"},
+		{`SELECT id FROM products.json LIMIT -1`, "Query: at 35: expecting a number, got -"},
+		{`SELECT FOO(id) FROM products.json`, "Query: at 7: unknown function FOO"},
+		{`SELECT id FROM products.json ORDER BY id extra`, "Query: at 41: unexpected extra"},
+	}
+	for _, test := range tests {
+		_, err := db.Query(test.query)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: got error %v, want %s", test.query, err, test.err)
+		}
+	}
+}
+
+func TestQueryOutsideRepository(t *testing.T) {
+	db := newQueryDB(t)
+	outside := filepath.Join(filepath.Dir(db.Local), "outside.json")
+	if err := ioutil.WriteFile(outside, []byte(`[{"secret":1}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(outside)
+	for _, from := range []string{"../outside.json", "a/../../outside.json"} {
+		_, err := db.Query(`SELECT * FROM '` + from + `'`)
+		if err == nil || !strings.Contains(err.Error(), "invalid path") {
+			t.Errorf("%s: got error %v", from, err)
+		}
+	}
+
+	db.SetRoot("data")
+	if err := os.MkdirAll(filepath.Join(db.Local, "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Query(`SELECT * FROM '../products.json'`); err == nil {
+		t.Error("query outside of the root succeeded")
+	}
+	b, _ := json.Marshal([]map[string]int{{"id": 1}})
+	if err := ioutil.WriteFile(filepath.Join(db.Local, "data", "items.json"), b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Query(`SELECT id FROM items.json`); err != nil {
+		t.Errorf("query under the root: %v", err)
+	}
+}
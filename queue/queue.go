@@ -0,0 +1,213 @@
+// Package queue provides durable work queues stored in collections of a
+// gitdb.DB, for low volumes of work where a message broker is overkill.
+//
+// Every message is a record of the collection, one per line, so the
+// history of the queue is the git history of its file:
+//
+//	q := queue.New(db, "jobs.json", time.Minute)
+//	q.Enqueue(Job{URL: "https://example.com"})
+//	msg, _ := q.Dequeue("worker-1")
+//	if msg != nil {
+//		var job Job
+//		msg.Decode(&job)
+//		// work...
+//		q.Ack(msg.ID, "worker-1")
+//	}
+//
+// A dequeued message is invisible to other consumers for the visibility
+// timeout; if it is not acked in time it is delivered again. Every change
+// is committed, but not pushed.
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/caiguanhao/gitdb"
+)
+
+var (
+	// ErrNotHeld is returned by Ack when the message does not exist or is
+	// not held by the consumer, because its visibility timeout has passed.
+	ErrNotHeld = errors.New("message not held by consumer")
+)
+
+type (
+	// Queue is a work queue stored in a collection. It is safe for
+	// concurrent use; use one Queue per collection.
+	Queue struct {
+		db         *gitdb.DB
+		collection *gitdb.Collection
+		visibility time.Duration
+		mu         sync.Mutex
+	}
+
+	// Message is a message of a queue.
+	Message struct {
+		ID         string          `json:"id"`
+		Body       json.RawMessage `json:"body"`
+		EnqueuedAt time.Time       `json:"enqueuedAt"`
+		// Consumer is the consumer that last dequeued the message.
+		Consumer string `json:"consumer,omitempty"`
+		// InvisibleUntil is when the message can be dequeued again if it
+		// is not acked.
+		InvisibleUntil *time.Time `json:"invisibleUntil,omitempty"`
+		// Attempts is the number of times the message has been dequeued.
+		Attempts int `json:"attempts"`
+	}
+)
+
+// New returns the queue stored in the collection at path of db. Dequeued
+// messages are redelivered after visibilityTimeout unless acked.
+func New(db *gitdb.DB, path string, visibilityTimeout time.Duration) *Queue {
+	return &Queue{
+		db:         db,
+		collection: db.NewCollection(path),
+		visibility: visibilityTimeout,
+	}
+}
+
+func (q *Queue) MustEnqueue(body interface{}) string {
+	id, err := q.Enqueue(body)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// Enqueue appends a message with body, encoded as JSON, to the queue and
+// returns its ID.
+func (q *Queue) Enqueue(body interface{}) (string, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("Enqueue: %v", err)
+	}
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	err = q.update("enqueue", func(messages []Message) ([]Message, error) {
+		return append(messages, Message{
+			ID:         id,
+			Body:       b,
+			EnqueuedAt: time.Now(),
+		}), nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (q *Queue) MustDequeue(consumer string) *Message {
+	msg, err := q.Dequeue(consumer)
+	if err != nil {
+		panic(err)
+	}
+	return msg
+}
+
+// Dequeue returns the oldest visible message, made invisible to other
+// consumers for the visibility timeout, or nil if there is none.
+func (q *Queue) Dequeue(consumer string) (*Message, error) {
+	var msg *Message
+	now := time.Now()
+	err := q.update("dequeue", func(messages []Message) ([]Message, error) {
+		for i := range messages {
+			m := &messages[i]
+			if m.InvisibleUntil != nil && now.Before(*m.InvisibleUntil) {
+				continue
+			}
+			until := now.Add(q.visibility)
+			m.Consumer = consumer
+			m.InvisibleUntil = &until
+			m.Attempts++
+			dequeued := *m
+			msg = &dequeued
+			return messages, nil
+		}
+		return nil, nil
+	})
+	return msg, err
+}
+
+func (q *Queue) MustAck(id, consumer string) {
+	if err := q.Ack(id, consumer); err != nil {
+		panic(err)
+	}
+}
+
+// Ack removes the message with id, which must be held by consumer.
+func (q *Queue) Ack(id, consumer string) error {
+	now := time.Now()
+	return q.update("ack", func(messages []Message) ([]Message, error) {
+		for i, m := range messages {
+			if m.ID != id {
+				continue
+			}
+			if m.Consumer != consumer || m.InvisibleUntil == nil || !now.Before(*m.InvisibleUntil) {
+				break
+			}
+			return append(messages[:i], messages[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("Ack: %s: %w", id, ErrNotHeld)
+	})
+}
+
+func (q *Queue) MustLen() int {
+	n, err := q.Len()
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// Len returns the number of messages in the queue, including the ones held
+// by consumers.
+func (q *Queue) Len() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var messages []Message
+	err := q.collection.Read(&messages)
+	return len(messages), err
+}
+
+// Decode decodes the body of the message into dest.
+func (m Message) Decode(dest interface{}) error {
+	return json.Unmarshal(m.Body, dest)
+}
+
+// update passes the messages of the queue to fn and writes and commits the
+// ones it returns, unless it returns nil.
+func (q *Queue) update(op string, fn func([]Message) ([]Message, error)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var messages []Message
+	if err := q.collection.Read(&messages); err != nil {
+		return err
+	}
+	messages, err := fn(messages)
+	if err != nil || messages == nil {
+		return err
+	}
+	if err := q.collection.Write(messages); err != nil {
+		return err
+	}
+	if err := q.db.Add(q.collection.Path); err != nil {
+		return err
+	}
+	return q.db.Commit(op + " " + q.collection.Path)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%s", time.Now().UnixNano(), hex.EncodeToString(b)), nil
+}
@@ -0,0 +1,165 @@
+package gitdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrQuotaExceeded is returned by Commit and Push when the repository is
+// over the quota set by SetQuota.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+const (
+	// quotaSampleInterval is the minimum time between two recorded sizes
+	// of the repository.
+	quotaSampleInterval = time.Hour
+	quotaWindow         = 24 * time.Hour
+)
+
+type (
+	// Quota limits the size of the git directory of the repository, in
+	// bytes. Zero means no limit.
+	Quota struct {
+		MaxRepoSize int64
+		// MaxGrowthPerDay limits how much the git directory grows in 24
+		// hours, measured from the sizes recorded by the checks of Commit
+		// and Push in the git directory.
+		MaxGrowthPerDay int64
+	}
+
+	// QuotaUsage is the size of the git directory and its growth in the
+	// last 24 hours, in bytes.
+	QuotaUsage struct {
+		Size   int64
+		Growth int64
+	}
+
+	// QuotaViolation describes the limit of a Quota that is exceeded.
+	QuotaViolation struct {
+		// Limit is "size" or "growth".
+		Limit string
+		Size  int64
+		Max   int64
+	}
+
+	quotaSample struct {
+		Time time.Time `json:"time"`
+		Size int64     `json:"size"`
+	}
+)
+
+func (v QuotaViolation) Error() string {
+	if v.Limit == "growth" {
+		return fmt.Sprintf("repository grew %d bytes in 24 hours, limit is %d: %s", v.Size, v.Max, ErrQuotaExceeded)
+	}
+	return fmt.Sprintf("repository is %d bytes, limit is %d: %s", v.Size, v.Max, ErrQuotaExceeded)
+}
+
+func (v QuotaViolation) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// SetQuota makes Commit and Push fail with a QuotaViolation, before
+// committing or pushing, when the repository is over q, protecting shared
+// hosting from runaway writers.
+func (db *DB) SetQuota(q Quota) {
+	db.quota = q
+}
+
+func (db DB) MustQuotaUsage() *QuotaUsage {
+	usage, err := db.QuotaUsage()
+	if err != nil {
+		panic(err)
+	}
+	return usage
+}
+
+// QuotaUsage returns the size of the git directory, without the files of
+// gitdb in it, and how much it grew in the last 24 hours, as far as the
+// recorded sizes tell, recording the current size.
+func (db DB) QuotaUsage() (*QuotaUsage, error) {
+	s := db.state()
+	s.quotaMu.Lock()
+	defer s.quotaMu.Unlock()
+	size, err := dirSize(gitDir(db.Local), filepath.Join(gitDir(db.Local), "gitdb"))
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(gitDir(db.Local), "gitdb", "quota.json")
+	var samples []quotaSample
+	if b, err := ioutil.ReadFile(path); err == nil {
+		json.Unmarshal(b, &samples)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	now := time.Now()
+	// keep the last sample before the window as the baseline
+	for len(samples) > 1 && now.Sub(samples[1].Time) >= quotaWindow {
+		samples = samples[1:]
+	}
+	usage := &QuotaUsage{Size: size}
+	if len(samples) > 0 {
+		usage.Growth = size - samples[0].Size
+	}
+	if len(samples) == 0 || now.Sub(samples[len(samples)-1].Time) >= quotaSampleInterval {
+		samples = append(samples, quotaSample{Time: now, Size: size})
+		b, err := json.Marshal(samples)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeFile(path, bytes.NewReader(b)); err != nil {
+			return nil, err
+		}
+	}
+	return usage, nil
+}
+
+// checkQuota returns a QuotaViolation if the repository is over the quota.
+func (db DB) checkQuota(op string) error {
+	if db.quota.MaxRepoSize <= 0 && db.quota.MaxGrowthPerDay <= 0 {
+		return nil
+	}
+	usage, err := db.QuotaUsage()
+	if err != nil {
+		return err
+	}
+	var v *QuotaViolation
+	if db.quota.MaxRepoSize > 0 && usage.Size > db.quota.MaxRepoSize {
+		v = &QuotaViolation{Limit: "size", Size: usage.Size, Max: db.quota.MaxRepoSize}
+	} else if db.quota.MaxGrowthPerDay > 0 && usage.Growth > db.quota.MaxGrowthPerDay {
+		v = &QuotaViolation{Limit: "growth", Size: usage.Growth, Max: db.quota.MaxGrowthPerDay}
+	}
+	if v != nil {
+		return fmt.Errorf("%s: %w", op, v)
+	}
+	return nil
+}
+
+// dirSize returns the total size of the files in dir, except the ones in
+// skip, where gitdb keeps its own files.
+func dirSize(dir, skip string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			// lock files come and go
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path == skip {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,132 @@
+package gitdb
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type (
+	// RateLimiter decides whether a request may be served now.
+	RateLimiter interface {
+		Allow(r *http.Request) bool
+	}
+
+	// RateLimiterFunc is a function used as a RateLimiter.
+	RateLimiterFunc func(r *http.Request) bool
+
+	// Limit is the rate of a token bucket: Rate requests per second on
+	// average, up to Burst at once. The zero Limit allows everything.
+	Limit struct {
+		Rate  float64
+		Burst int
+	}
+
+	// RateLimit is a RateLimiter with a token bucket shared by every
+	// request and one per client, so a misbehaving client cannot take the
+	// repository lock or push over and over, nor starve the others.
+	RateLimit struct {
+		global    Limit
+		perClient Limit
+		client    func(r *http.Request) string
+		now       func() time.Time
+
+		mu        sync.Mutex
+		all       bucket
+		clients   map[string]*bucket
+		lastSweep time.Time
+	}
+
+	bucket struct {
+		tokens float64
+		last   time.Time
+	}
+)
+
+// rateLimitSweepInterval is how often the buckets of clients back to full
+// are forgotten.
+const rateLimitSweepInterval = time.Minute
+
+func (f RateLimiterFunc) Allow(r *http.Request) bool {
+	return f(r)
+}
+
+// NewRateLimit returns a RateLimit allowing requests at the global limit
+// and, for each client, at the perClient limit. client names the client
+// of a request, the host of its RemoteAddr if nil; use a header like
+// X-Forwarded-For behind a proxy, or the user.
+func NewRateLimit(global, perClient Limit, client func(r *http.Request) string) *RateLimit {
+	if client == nil {
+		client = remoteHost
+	}
+	return &RateLimit{
+		global:    global,
+		perClient: perClient,
+		client:    client,
+		now:       time.Now,
+		clients:   map[string]*bucket{},
+	}
+}
+
+// Allow takes a token from the global bucket and the one of the client of
+// r, if both have one.
+func (l *RateLimit) Allow(r *http.Request) bool {
+	name := l.client(r)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastSweep) > rateLimitSweepInterval {
+		for k, b := range l.clients {
+			if l.perClient.refill(b, now) >= float64(l.perClient.burst()) {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+	var client *bucket
+	if l.perClient.Rate > 0 {
+		if client = l.clients[name]; client == nil {
+			client = &bucket{}
+			l.clients[name] = client
+		}
+	}
+	if l.global.Rate > 0 && l.global.refill(&l.all, now) < 1 ||
+		client != nil && l.perClient.refill(client, now) < 1 {
+		return false
+	}
+	if l.global.Rate > 0 {
+		l.all.tokens--
+	}
+	if client != nil {
+		client.tokens--
+	}
+	return true
+}
+
+func (l Limit) burst() int {
+	if l.Burst < 1 {
+		return 1
+	}
+	return l.Burst
+}
+
+// refill adds to b the tokens earned since it was last refilled and
+// returns how many it has.
+func (l Limit) refill(b *bucket, now time.Time) float64 {
+	if b.last.IsZero() {
+		b.tokens = float64(l.burst())
+	} else if b.tokens += now.Sub(b.last).Seconds() * l.Rate; b.tokens > float64(l.burst()) {
+		b.tokens = float64(l.burst())
+	}
+	b.last = now
+	return b.tokens
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
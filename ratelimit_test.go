@@ -0,0 +1,63 @@
+package gitdb
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Now()
+	l := NewRateLimit(Limit{Rate: 2, Burst: 4}, Limit{Rate: 1, Burst: 2}, nil)
+	l.now = func() time.Time { return now }
+	a := &http.Request{RemoteAddr: "10.0.0.1:1234"}
+	a2 := &http.Request{RemoteAddr: "10.0.0.1:5678"}
+	b := &http.Request{RemoteAddr: "10.0.0.2:1234"}
+	c := &http.Request{RemoteAddr: "10.0.0.3:1234"}
+	steps := []struct {
+		wait time.Duration
+		r    *http.Request
+		want bool
+	}{
+		{0, a, true},
+		{0, a2, true},
+		{0, a, false}, // burst of the client used
+		{0, b, true},
+		{0, b, true},
+		{0, c, false}, // burst of every client used
+		{time.Second, a, true},
+		{0, a, false},
+		{0, c, true},
+		{0, b, false},
+		{time.Hour, c, true},
+		{0, c, true},
+		{0, c, false},
+	}
+	for i, step := range steps {
+		now = now.Add(step.wait)
+		if got := l.Allow(step.r); got != step.want {
+			t.Errorf("step %d: Allow(%s) = %v, want %v", i, step.r.RemoteAddr, got, step.want)
+		}
+	}
+	if len(l.clients) != 1 {
+		t.Errorf("got %d buckets of clients, want the one of 10.0.0.3", len(l.clients))
+	}
+}
+
+func TestRateLimitUnlimited(t *testing.T) {
+	global := NewRateLimit(Limit{Rate: 1}, Limit{}, nil)
+	perClient := NewRateLimit(Limit{}, Limit{Rate: 1}, func(r *http.Request) string {
+		return r.Header.Get("X-User")
+	})
+	a := &http.Request{RemoteAddr: "10.0.0.1:1", Header: http.Header{"X-User": {"a"}}}
+	b := &http.Request{RemoteAddr: "10.0.0.1:1", Header: http.Header{"X-User": {"b"}}}
+	if !global.Allow(a) || global.Allow(b) {
+		t.Error("global limit not applied")
+	}
+	if len(global.clients) != 0 {
+		t.Errorf("got %d buckets of clients without a limit per client", len(global.clients))
+	}
+	if !perClient.Allow(a) || perClient.Allow(a) || !perClient.Allow(b) {
+		t.Error("limit per client not applied")
+	}
+}
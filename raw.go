@@ -0,0 +1,62 @@
+package gitdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func (c Collection) MustReadRaw() ([]byte, string) {
+	content, hash, err := c.ReadRaw()
+	if err != nil {
+		panic(err)
+	}
+	return content, hash
+}
+
+// ReadRaw returns the JSON content of the collection file as it is on disk,
+// without the JSONP callback around it, and the git blob hash of the file,
+// so that it can be served without decoding it. Options of the collection
+// such as ExpiresAtField are not applied. Chunked collections and
+// collections stored as record files have no single file and return an
+// error, and so does a missing file.
+func (c Collection) ReadRaw() ([]byte, string, error) {
+	path := filepath.Join(c.db.Local, c.Path)
+	if isDir(path) {
+		return nil, "", fmt.Errorf("ReadRaw: %s is stored as record files", c.Path)
+	}
+	m, err := readManifest(path)
+	if err != nil {
+		return nil, "", err
+	}
+	if m != nil {
+		return nil, "", fmt.Errorf("ReadRaw: %s is chunked", c.Path)
+	}
+	return readRaw(path)
+}
+
+func (o Object) MustReadRaw() ([]byte, string) {
+	content, hash, err := o.ReadRaw()
+	if err != nil {
+		panic(err)
+	}
+	return content, hash
+}
+
+// ReadRaw returns the JSON content of the object file as it is on disk,
+// without the JSONP callback around it, and the git blob hash of the file.
+// A missing file returns an error satisfying os.IsNotExist.
+func (o Object) ReadRaw() ([]byte, string, error) {
+	return readRaw(filepath.Join(o.db.Local, o.Path))
+}
+
+func readRaw(path string) ([]byte, string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	hash := plumbing.ComputeHash(plumbing.BlobObject, content)
+	return jsonpContent(content), hash.String(), nil
+}
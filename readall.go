@@ -0,0 +1,107 @@
+package gitdb
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// ReadErrors holds the errors of ReadAll by path.
+	ReadErrors map[string]error
+)
+
+func (db DB) MustReadAll(dests map[string]interface{}) {
+	if err := db.ReadAll(dests); err != nil {
+		panic(err)
+	}
+}
+
+// ReadAll reads the collections or objects at the paths of dests into the
+// values they point to, concurrently with as many workers as CPUs. Pointers
+// to slices are read like Collection.Read, with the options of the
+// collection created with NewCollection for the path if any, others like
+// Object.Read. The errors of all paths are returned together as
+// ReadErrors.
+func (db DB) ReadAll(dests map[string]interface{}) error {
+	paths := make(chan string)
+	var mu sync.Mutex
+	errs := ReadErrors{}
+	var wg sync.WaitGroup
+	workers := runtime.NumCPU()
+	if workers > len(dests) {
+		workers = len(dests)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if err := db.read(path, dests[path]); err != nil {
+					mu.Lock()
+					errs[path] = err
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for path := range dests {
+		paths <- path
+	}
+	close(paths)
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (db DB) read(path string, dest interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("destination must be a non-nil pointer, got %T", dest)
+	}
+	if rv.Elem().Kind() != reflect.Slice {
+		return Object{db: &db, Path: db.resolve(path)}.Read(dest)
+	}
+	return db.findCollection(path).Read(dest)
+}
+
+// findCollection returns the collection created with NewCollection for
+// path, relative to the root, or a collection with default options.
+func (db DB) findCollection(path string) *Collection {
+	return db.collectionAt(db.resolve(path))
+}
+
+// collectionAt is like findCollection for a path of the repository.
+func (db DB) collectionAt(path string) *Collection {
+	path = filepath.Clean(path)
+	for _, c := range db.Collections() {
+		if filepath.Clean(c.Path) == path {
+			return c
+		}
+	}
+	return &Collection{db: &db, Path: path}
+}
+
+func (e ReadErrors) Error() string {
+	paths := make([]string, 0, len(e))
+	for path := range e {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	msgs := make([]string, len(paths))
+	for i, path := range paths {
+		msgs[i] = fmt.Sprintf("%s: %v", path, e[path])
+	}
+	return "ReadAll: " + strings.Join(msgs, "; ")
+}
@@ -0,0 +1,205 @@
+package gitdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+// skipValue is decoded from any JSON value without keeping it.
+type skipValue struct{}
+
+func (*skipValue) UnmarshalJSON([]byte) error {
+	return nil
+}
+
+func (c Collection) MustReadFields(dest interface{}, fields ...string) {
+	if err := c.ReadFields(dest, fields...); err != nil {
+		panic(err)
+	}
+}
+
+// ReadFields reads only the given fields, by their JSON names, of the
+// records of the collection into dest, a pointer to a slice, leaving the
+// other fields of the records in dest zero. The records are streamed from
+// the files and the values of the other fields skipped without being
+// decoded, cutting memory and time when only a few fields of wide records
+// are needed. Like Read, it drops expired records, reading ExpiresAtField
+// too.
+func (c Collection) ReadFields(dest interface{}, fields ...string) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("ReadFields: dest must be a pointer to a slice, got %T", dest)
+	}
+	wanted := map[string]bool{}
+	for _, field := range fields {
+		wanted[field] = true
+	}
+	if c.ExpiresAtField != "" {
+		wanted[c.expiresAtKey(reflect.TypeOf(dest))] = true
+	}
+	opts := c.jsonOptions()
+	path := filepath.Join(c.db.Local, c.Path)
+	err := opts.decode(dest, func(dest interface{}) error {
+		return opts.readFields(path, wanted, dest)
+	})
+	if err != nil {
+		return err
+	}
+	removeNulls(dest)
+	if c.ExpiresAtField != "" {
+		removeExpired(dest, c.ExpiresAtField, time.Now())
+	}
+	return nil
+}
+
+func (opts JSONOptions) readFields(path string, wanted map[string]bool, dest interface{}) error {
+	files, perRecord, err := dataFiles(path)
+	if err != nil {
+		return err
+	}
+	rv := reflect.Indirect(reflect.ValueOf(dest))
+	rv.Set(reflect.MakeSlice(rv.Type(), 0, len(files)))
+	add := func(record []byte) error {
+		if record == nil {
+			return nil
+		}
+		elem := reflect.New(rv.Type().Elem())
+		if err := opts.newDecoder(bytes.NewReader(record)).Decode(elem.Interface()); err != nil {
+			return err
+		}
+		rv.Set(reflect.Append(rv, elem.Elem()))
+		return nil
+	}
+	for _, file := range files {
+		err := eachRecord(file, perRecord, func(dec *json.Decoder) error {
+			record, err := projectRecord(dec, wanted)
+			if err != nil {
+				return err
+			}
+			return add(record)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dataFiles returns the files holding the records of the collection at
+// path: the record files of a collection with RecordKeyField, for which
+// perRecord is true, the chunks of a chunked collection or the file at
+// path itself.
+func dataFiles(path string) (files []string, perRecord bool, err error) {
+	if isDir(path) {
+		names, err := recordFiles(path)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, name := range names {
+			files = append(files, filepath.Join(path, name))
+		}
+		return files, true, nil
+	}
+	m, err := readManifest(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if m == nil {
+		return []string{path}, false, nil
+	}
+	for _, chunk := range m.Chunks {
+		files = append(files, filepath.Join(filepath.Dir(path), chunk))
+	}
+	return files, false, nil
+}
+
+// eachRecord calls fn with a decoder at the start of each record of the
+// file at path, an array of records, or a single record if perRecord is
+// true. fn must consume the record.
+func eachRecord(path string, perRecord bool, fn func(dec *json.Decoder) error) error {
+	f, r, err := openJson(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	start, _ := f.Seek(0, io.SeekCurrent)
+	err = eachRecordIn(json.NewDecoder(r), perRecord, fn)
+	if err != nil {
+		return newParseError(path, start, err)
+	}
+	return nil
+}
+
+func eachRecordIn(dec *json.Decoder, perRecord bool, fn func(dec *json.Decoder) error) error {
+	if perRecord {
+		return fn(dec)
+	}
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("expected an array of records, got %v", tok)
+	}
+	for dec.More() {
+		if err := fn(dec); err != nil {
+			return err
+		}
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// projectRecord reads the next record from dec and returns it as a JSON
+// object with only the wanted fields, or nil if the record is null.
+func projectRecord(dec *json.Decoder, wanted map[string]bool) ([]byte, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		return nil, nil
+	}
+	if tok != json.Delim('{') {
+		return nil, fmt.Errorf("expected a record, got %v", tok)
+	}
+	buf := []byte{'{'}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := tok.(string)
+		if !wanted[key] {
+			if err := dec.Decode(&skipValue{}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		name, _ := json.Marshal(key)
+		buf = append(append(append(buf, name...), ':'), value...)
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return append(buf, '}'), nil
+}
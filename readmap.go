@@ -0,0 +1,44 @@
+package gitdb
+
+import (
+	"fmt"
+	"reflect"
+)
+
+func (c Collection) MustReadMap(keyField string, dest interface{}) {
+	if err := c.ReadMap(keyField, dest); err != nil {
+		panic(err)
+	}
+}
+
+// ReadMap reads the collection into dest, a pointer to a map from strings
+// to records, keyed by the value of keyField of each record, the JSON name
+// or the name of a field. Like Read, it drops expired records. Records
+// missing keyField or sharing their key with another one fail the read.
+func (c Collection) ReadMap(keyField string, dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Map ||
+		rv.Elem().Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("ReadMap: dest must be a pointer to a map with string keys, got %T", dest)
+	}
+	m := rv.Elem()
+	records := reflect.New(reflect.SliceOf(m.Type().Elem()))
+	if err := c.Read(records.Interface()); err != nil {
+		return err
+	}
+	list := records.Elem()
+	out := reflect.MakeMapWithSize(m.Type(), list.Len())
+	for i := 0; i < list.Len(); i++ {
+		key, ok := keyOf(list.Index(i), keyField)
+		if !ok {
+			return fmt.Errorf("ReadMap: record %d of %s has no %s", i, c.Path, keyField)
+		}
+		k := reflect.ValueOf(key).Convert(m.Type().Key())
+		if out.MapIndex(k).IsValid() {
+			return fmt.Errorf("ReadMap: duplicate %s %q in %s", keyField, key, c.Path)
+		}
+		out.SetMapIndex(k, list.Index(i))
+	}
+	m.Set(out)
+	return nil
+}
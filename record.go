@@ -0,0 +1,192 @@
+package gitdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+const recordFileExt = ".json"
+
+func (c Collection) writeRecords(content interface{}, funcs ...interface{}) error {
+	rv := reflect.ValueOf(content)
+	opts := c.jsonOptions()
+	files := map[string][]byte{}
+	keys := map[string]string{}
+	for i := 0; i < rv.Len(); i++ {
+		item, ok := applyFuncs(rv.Index(i), funcs)
+		if !ok {
+			continue
+		}
+		key, ok := keyOf(item, c.RecordKeyField)
+		if (!ok || key == "") && c.NewRecordKey != nil {
+			var err error
+			if item, key, err = c.setRecordKey(item); err != nil {
+				return fmt.Errorf("Write: record %d of %s: %w", i, c.Path, err)
+			}
+			ok = true
+		}
+		if !ok || key == "" {
+			return fmt.Errorf("Write: record %d of %s has no %s", i, c.Path, c.RecordKeyField)
+		}
+		name := c.recordFileName(key)
+		if other, ok := keys[name]; ok {
+			if other == key {
+				return fmt.Errorf("Write: duplicate %s %q in %s", c.RecordKeyField, key, c.Path)
+			}
+			return fmt.Errorf("Write: %s %q and %q of %s have the same file name", c.RecordKeyField, other, key, c.Path)
+		}
+		keys[name] = key
+		record := marshalRecord(item.Interface(), opts)
+		if c.MaxRecordSize > 0 && int64(len(record)) > c.MaxRecordSize {
+			return &SizeViolation{Path: c.Path, Index: i, Size: int64(len(record)), Limit: c.MaxRecordSize}
+		}
+		if err := c.db.checkFileSize(name, len(record)+1, c.MaxFileSize); err != nil {
+			return err
+		}
+		files[name] = append(record, '\n')
+	}
+
+	old, err := recordFiles(filepath.Join(c.db.Local, c.Path))
+	if err != nil {
+		return err
+	}
+	for name, content := range files {
+		full := filepath.Join(c.db.Local, name)
+		// leave untouched records alone so their files never conflict
+		if current, err := ioutil.ReadFile(full); err == nil && string(current) == string(content) {
+			continue
+		}
+		if err := c.db.journal("write", name); err != nil {
+			return err
+		}
+		if err := writeFileContext(c.context(), full, strings.NewReader(string(content))); err != nil {
+			return err
+		}
+	}
+	for _, file := range old {
+		name := filepath.Join(c.Path, file)
+		if _, ok := files[name]; ok {
+			continue
+		}
+		if err := c.db.journal("delete", name); err != nil {
+			return err
+		}
+		if err := os.Remove(filepath.Join(c.db.Local, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		// remove the subdirectories left empty
+		for dir := filepath.Dir(name); dir != filepath.Clean(c.Path) && dir != "."; dir = filepath.Dir(dir) {
+			if os.Remove(filepath.Join(c.db.Local, dir)) != nil {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// recordFileName returns the path of the file named name, as returned by
+// a RecordFileNamer, in the collection at path.
+func recordFileName(path, name string) string {
+	return filepath.Join(path, filepath.FromSlash(name)+recordFileExt)
+}
+
+// recordFiles returns the sorted names of the record files in dir,
+// including the ones in its subdirectories, relative to dir.
+func recordFiles(dir string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != recordFileExt {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		names = append(names, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readRecords reads the record files in dir into the slice pointed to by
+// dest, in file name order.
+func (opts JSONOptions) readRecords(dir string, dest interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(dest))
+	if rv.Kind() != reflect.Slice {
+		return fmt.Errorf("%s holds one file per record, read it into a slice", dir)
+	}
+	names, err := recordFiles(dir)
+	if err != nil {
+		return err
+	}
+	rv.Set(reflect.MakeSlice(rv.Type(), 0, len(names)))
+	for _, name := range names {
+		record := reflect.New(rv.Type().Elem())
+		if err := readJsonWith(filepath.Join(dir, name), record.Interface(), opts); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		rv.Set(reflect.Append(rv, record.Elem()))
+	}
+	return nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// withRecords replaces in files the directories of the per-record
+// collections among them with their changed record files, including
+// deleted ones.
+func (db DB) withRecords(w *git.Worktree, files []string) ([]string, error) {
+	dirs := map[string]bool{}
+	for _, c := range db.state().managedCollections() {
+		if c.RecordKeyField != "" {
+			dirs[filepath.Clean(c.Path)] = true
+		}
+	}
+	if len(dirs) == 0 {
+		return files, nil
+	}
+	var expand []string
+	var kept []string
+	for _, file := range files {
+		if dirs[filepath.Clean(file)] {
+			expand = append(expand, filepath.ToSlash(filepath.Clean(file))+"/")
+		} else {
+			kept = append(kept, file)
+		}
+	}
+	if len(expand) == 0 {
+		return files, nil
+	}
+	s, err := w.Status()
+	if err != nil {
+		return nil, err
+	}
+	for name, fs := range s {
+		if fs.Staging == git.Unmodified && fs.Worktree == git.Unmodified {
+			continue
+		}
+		for _, dir := range expand {
+			if strings.HasPrefix(name, dir) {
+				kept = append(kept, name)
+			}
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,146 @@
+package gitdb
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type (
+	// RecordFileNamer returns the name of the file of the record with key
+	// in a collection with RecordKeyField, without its .json extension,
+	// relative to the directory of the collection. Names may contain
+	// slashes to spread the records over subdirectories.
+	RecordFileNamer func(key string) string
+)
+
+// EscapedKeyNames names record files after their keys, escaped to be
+// valid file names. It is the default.
+func EscapedKeyNames(key string) string {
+	return url.PathEscape(key)
+}
+
+// SlugNames names record files after their keys in lower case, with runs
+// of characters other than letters and digits replaced by a dash, like
+// "hello-world" for "Hello, World!". Writing keys with the same slug
+// fails.
+func SlugNames(key string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(key) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return url.PathEscape(key)
+	}
+	return url.PathEscape(b.String())
+}
+
+// HashedNames returns a RecordFileNamer putting record files, named after
+// their escaped keys, in levels of subdirectories named after the bytes of
+// the SHA-1 of their keys, like "ab/cd/key" for 2 levels, so no directory
+// gets too large for the file system with hundreds of thousands of
+// records. 2 levels make 65536 directories.
+func HashedNames(levels int) RecordFileNamer {
+	return func(key string) string {
+		sum := sha1.Sum([]byte(key))
+		dirs := make([]string, 0, levels+1)
+		for i := 0; i < levels && i < len(sum); i++ {
+			dirs = append(dirs, hex.EncodeToString(sum[i:i+1]))
+		}
+		return path.Join(append(dirs, url.PathEscape(key))...)
+	}
+}
+
+// recordFileName returns the path of the file of the record with key in
+// c.
+func (c Collection) recordFileName(key string) string {
+	namer := c.RecordFileName
+	if namer == nil {
+		namer = EscapedKeyNames
+	}
+	return recordFileName(c.Path, namer(key))
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a new ULID, a 26 character key sorting by the time it
+// was made, to use as the NewRecordKey of a collection so records are
+// read in the order they were created.
+func NewULID() string {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], uint64(time.Now().UnixNano()/int64(time.Millisecond))<<16)
+	if _, err := rand.Read(id[6:]); err != nil {
+		panic(err)
+	}
+	// 128 bits in 26 characters of 5 bits, the first one holding 3 bits
+	var b [26]byte
+	var acc uint64
+	bits := 2
+	j := 0
+	for _, x := range id {
+		acc = acc<<8 | uint64(x)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			b[j] = crockford[acc>>uint(bits)&31]
+			j++
+		}
+	}
+	return string(b[:])
+}
+
+// setRecordKey returns record with its RecordKeyField set to a new key
+// from c.NewRecordKey, copying maps and structs to leave the ones of the
+// caller untouched.
+func (c Collection) setRecordKey(record reflect.Value) (reflect.Value, string, error) {
+	key := c.NewRecordKey()
+	rv := record
+	ptr := false
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return record, "", fmt.Errorf("nil record")
+		}
+		ptr = rv.Kind() == reflect.Ptr
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Map {
+		if rv.Type().Key().Kind() != reflect.String {
+			return record, "", fmt.Errorf("cannot set %s", c.RecordKeyField)
+		}
+		m := reflect.MakeMapWithSize(rv.Type(), rv.Len()+1)
+		iter := rv.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), iter.Value())
+		}
+		m.SetMapIndex(reflect.ValueOf(c.RecordKeyField).Convert(rv.Type().Key()), reflect.ValueOf(key))
+		return m, key, nil
+	}
+	copied := reflect.New(rv.Type())
+	copied.Elem().Set(rv)
+	f, ok := fieldOf(copied, c.RecordKeyField)
+	if !ok || !f.CanSet() || f.Kind() != reflect.String {
+		return record, "", fmt.Errorf("cannot set %s", c.RecordKeyField)
+	}
+	f.SetString(key)
+	if ptr {
+		return copied, key, nil
+	}
+	return copied.Elem(), key, nil
+}
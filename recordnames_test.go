@@ -0,0 +1,55 @@
+package gitdb
+
+import (
+	"strconv"
+	"testing"
+)
+
+type keyedRecord struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+func TestNewRecordKeyLeavesRecords(t *testing.T) {
+	db := newTestDB(t)
+	c := db.NewCollection("notes")
+	c.RecordKeyField = "key"
+	n := 0
+	c.NewRecordKey = func() string {
+		n++
+		return "k" + strconv.Itoa(n)
+	}
+
+	pointers := []*keyedRecord{{Name: "a"}, {Key: "x", Name: "b"}}
+	if err := c.Write(pointers); err != nil {
+		t.Fatal(err)
+	}
+	if pointers[0].Key != "" || pointers[1].Key != "x" {
+		t.Errorf("records of []*T changed: %+v %+v", pointers[0], pointers[1])
+	}
+	values := []keyedRecord{{Name: "c"}}
+	if err := c.Write(values); err != nil {
+		t.Fatal(err)
+	}
+	if values[0].Key != "" {
+		t.Errorf("records of []T changed: %+v", values[0])
+	}
+	maps := []map[string]interface{}{{"name": "d"}}
+	if err := c.Write(maps); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := maps[0]["key"]; ok {
+		t.Errorf("records of []map changed: %v", maps[0])
+	}
+
+	if err := c.Write(pointers); err != nil {
+		t.Fatal(err)
+	}
+	var got []keyedRecord
+	if err := c.Read(&got); err != nil {
+		t.Fatal(err)
+	}
+	if jsonText(got) != `[{"key":"k4","name":"a"},{"key":"x","name":"b"}]` {
+		t.Errorf("got %s", jsonText(got))
+	}
+}
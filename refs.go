@@ -0,0 +1,223 @@
+package gitdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+type (
+	// DanglingReference describes a record field tagged with
+	// `gitdb:"ref=path#key"` whose value matches no record in path, a path
+	// relative to the root like the ones of NewCollection (see SetRoot).
+	DanglingReference struct {
+		Collection string
+		Index      int
+		Field      string
+		Ref        string
+		Value      string
+	}
+
+	DanglingReferences []DanglingReference
+
+	refField struct {
+		index []int
+		name  string
+		path  string
+		key   string
+	}
+)
+
+func (d DanglingReference) Error() string {
+	return fmt.Sprintf("%s[%d].%s: %s not found in %s", d.Collection, d.Index, d.Field, d.Value, d.Ref)
+}
+
+func (refs DanglingReferences) Error() string {
+	msgs := make([]string, len(refs))
+	for i, ref := range refs {
+		msgs[i] = ref.Error()
+	}
+	return "dangling references: " + strings.Join(msgs, "; ")
+}
+
+func (db *DB) SetEnforceReferences(enforce bool) {
+	db.EnforceReferences = enforce
+}
+
+func (db DB) MustCheckReferences() DanglingReferences {
+	refs, err := db.CheckReferences()
+	if err != nil {
+		panic(err)
+	}
+	return refs
+}
+
+// CheckReferences validates the references of every collection created by
+// NewCollection that has a Model.
+func (db DB) CheckReferences() (DanglingReferences, error) {
+	var all DanglingReferences
+	keys := map[string]map[string]bool{}
+	for _, c := range db.state().managedCollections() {
+		if c.Model == nil {
+			continue
+		}
+		records := reflect.New(reflect.SliceOf(reflect.TypeOf(c.Model)))
+		if err := c.Read(records.Interface()); err != nil {
+			return nil, err
+		}
+		refs, err := db.danglingReferences(c.Path, records.Elem(), keys)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, refs...)
+	}
+	return all, nil
+}
+
+func (db DB) checkReferences(path string, content interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(content))
+	if rv.Kind() == reflect.Struct {
+		rv = reflect.Append(reflect.MakeSlice(reflect.SliceOf(rv.Type()), 0, 1), rv)
+	}
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+	refs, err := db.danglingReferences(path, rv, map[string]map[string]bool{})
+	if err != nil {
+		return err
+	}
+	if len(refs) > 0 {
+		return refs
+	}
+	return nil
+}
+
+func (db DB) danglingReferences(path string, records reflect.Value, keys map[string]map[string]bool) (DanglingReferences, error) {
+	fields := referenceFields(records.Type().Elem())
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	var refs DanglingReferences
+	for i := 0; i < records.Len(); i++ {
+		record := reflect.Indirect(records.Index(i))
+		if !record.IsValid() {
+			continue
+		}
+		for _, f := range fields {
+			target := f.path + "#" + f.key
+			known, ok := keys[target]
+			if !ok {
+				var err error
+				if known, err = db.referenceKeys(f.path, f.key); err != nil {
+					return nil, err
+				}
+				keys[target] = known
+			}
+			for _, value := range referenceValues(record.FieldByIndex(f.index)) {
+				if !known[value] {
+					refs = append(refs, DanglingReference{
+						Collection: path,
+						Index:      i,
+						Field:      f.name,
+						Ref:        target,
+						Value:      value,
+					})
+				}
+			}
+		}
+	}
+	return refs, nil
+}
+
+func (db DB) referenceKeys(path, key string) (map[string]bool, error) {
+	var rows []map[string]json.RawMessage
+	if err := readCollection(filepath.Join(db.Local, db.resolve(path)), &rows); err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for _, row := range rows {
+		if v, ok := row[key]; ok {
+			keys[string(v)] = true
+		}
+	}
+	return keys, nil
+}
+
+// referenceValues returns the JSON encodings of the non-zero values held by
+// a reference field, which may be a scalar, a pointer or a slice.
+func referenceValues(v reflect.Value) []string {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return referenceValues(v.Elem())
+	case reflect.Slice, reflect.Array:
+		var values []string
+		for i := 0; i < v.Len(); i++ {
+			values = append(values, referenceValues(v.Index(i))...)
+		}
+		return values
+	}
+	if v.IsZero() {
+		return nil
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil
+	}
+	return []string{string(b)}
+}
+
+func referenceFields(t reflect.Type) []refField {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var fields []refField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct {
+			for _, f := range referenceFields(sf.Type) {
+				f.index = append([]int{i}, f.index...)
+				fields = append(fields, f)
+			}
+			continue
+		}
+		ref := gitdbTag(sf)["ref"]
+		if ref == "" {
+			continue
+		}
+		path, key := ref, "id"
+		if i := strings.LastIndexByte(ref, '#'); i > -1 {
+			path, key = ref[:i], ref[i+1:]
+		}
+		fields = append(fields, refField{
+			index: []int{i},
+			name:  sf.Name,
+			path:  path,
+			key:   key,
+		})
+	}
+	return fields
+}
+
+// gitdbTag parses a `gitdb:"a=b,c"` struct tag into a map.
+func gitdbTag(sf reflect.StructField) map[string]string {
+	opts := map[string]string{}
+	for _, opt := range strings.Split(sf.Tag.Get("gitdb"), ",") {
+		if opt == "" {
+			continue
+		}
+		if i := strings.IndexByte(opt, '='); i > -1 {
+			opts[opt[:i]] = opt[i+1:]
+		} else {
+			opts[opt] = ""
+		}
+	}
+	return opts
+}
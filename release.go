@@ -0,0 +1,273 @@
+package gitdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type (
+	// ReleaseManifest is the content of the VERSION file written by
+	// Release.
+	ReleaseManifest struct {
+		Version string `json:"version"`
+
+		// Checksums are the SHA-256 checksums of the collections and
+		// objects, by path, covering the chunks and record files of
+		// collections.
+		Checksums map[string]string `json:"checksums"`
+	}
+)
+
+const releaseManifestName = "VERSION"
+
+var semverPattern = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+func (db DB) MustRelease(version string) {
+	if err := db.Release(version); err != nil {
+		panic(err)
+	}
+}
+
+// Release writes the VERSION file with the checksums of the collections
+// created by NewCollection and the objects created by NewObject as
+// committed at HEAD, commits it, tags the commit "v" followed by version,
+// a semantic version like 1.2.0, and pushes the commit and the tag.
+// Nothing else may be staged.
+func (db DB) Release(version string) error {
+	if !semverPattern.MatchString(version) {
+		return fmt.Errorf("Release: invalid semantic version %s", version)
+	}
+	version = strings.TrimPrefix(version, "v")
+	tag := "v" + version
+
+	unlock := db.lock()
+	err := db.release(version, tag)
+	unlock()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if err := db.push(ctx); err != nil && err != git.NoErrAlreadyUpToDate {
+		return err
+	}
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return err
+	}
+	ref := config.RefSpec(plumbing.NewTagReferenceName(tag) + ":" + plumbing.NewTagReferenceName(tag))
+	log.Println("pushing", tag)
+	return r.PushContext(ctx, &git.PushOptions{
+		RemoteName: db.GetRemoteName(),
+		RefSpecs:   []config.RefSpec{ref},
+		Auth:       db.authMethod(),
+	})
+}
+
+func (db DB) release(version, tag string) error {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Tag(tag); err == nil {
+		return fmt.Errorf("Release: %s already exists", tag)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	s, err := w.Status()
+	if err != nil {
+		return err
+	}
+	if hasStagedChanges(s) {
+		return fmt.Errorf("Release: commit or discard the staged changes first")
+	}
+	tree, err := headTree(r)
+	if err != nil {
+		return err
+	}
+	if tree == nil {
+		return fmt.Errorf("Release: nothing committed")
+	}
+	checksums, err := releaseChecksums(tree, db.releasePaths())
+	if err != nil {
+		return err
+	}
+	manifest := db.resolve(releaseManifestName)
+	content, err := json.MarshalIndent(ReleaseManifest{Version: version, Checksums: checksums}, "", "  ")
+	if err != nil {
+		return err
+	}
+	db.Manage(filepath.ToSlash(manifest))
+	if err := writeFile(filepath.Join(db.Local, manifest), strings.NewReader(string(content)+"\n")); err != nil {
+		return err
+	}
+	if _, err := w.Add(filepath.ToSlash(manifest)); err != nil {
+		return err
+	}
+	// commit directly, a batched commit would not exist yet to be tagged
+	if err := db.commit("release " + tag); err != nil {
+		return err
+	}
+	head, err := r.Head()
+	if err != nil {
+		return err
+	}
+	sig := db.signature()
+	_, err = r.CreateTag(tag, head.Hash(), &git.CreateTagOptions{
+		Tagger:  &sig,
+		Message: "release " + tag,
+	})
+	return err
+}
+
+// releasePaths returns the paths of the managed collections and objects.
+func (db DB) releasePaths() []string {
+	s := db.state()
+	var paths []string
+	for _, c := range s.managedCollections() {
+		paths = append(paths, filepath.ToSlash(filepath.Clean(c.Path)))
+	}
+	s.registry.Lock()
+	paths = append(paths, s.objects...)
+	s.registry.Unlock()
+	return paths
+}
+
+// releaseChecksums returns the checksums of paths in tree, each covering
+// the chunks and record files of a collection. Missing paths are left out.
+func releaseChecksums(tree *object.Tree, paths []string) (map[string]string, error) {
+	files := map[string][]*object.File{}
+	err := tree.Files().ForEach(func(f *object.File) error {
+		for _, path := range paths {
+			if ownedBy([]string{path}, f.Name) {
+				files[path] = append(files[path], f)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	checksums := map[string]string{}
+	for path, list := range files {
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+		h := sha256.New()
+		for _, f := range list {
+			content, err := f.Contents()
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(h, "%s\x00%d\x00", f.Name, len(content))
+			h.Write([]byte(content))
+		}
+		checksums[path] = hex.EncodeToString(h.Sum(nil))
+	}
+	return checksums, nil
+}
+
+func (db DB) MustCheckoutRelease(version string) {
+	if err := db.CheckoutRelease(version); err != nil {
+		panic(err)
+	}
+}
+
+// CheckoutRelease checks out the commit tagged by Release for version,
+// fetching the tags if needed, and verifies the checksums of its VERSION
+// file. HEAD is left detached; ForceUpdate returns to the branch.
+func (db DB) CheckoutRelease(version string) error {
+	tag := "v" + strings.TrimPrefix(version, "v")
+	defer db.lock()()
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return err
+	}
+	ref, err := r.Tag(tag)
+	if err == git.ErrTagNotFound {
+		if err := db.checkRemotes(r); err != nil {
+			return err
+		}
+		log.Println("fetching tags from", db.GetRemoteName())
+		err = r.FetchContext(context.Background(), &git.FetchOptions{
+			RemoteName: db.GetRemoteName(),
+			RefSpecs:   []config.RefSpec{"+refs/tags/*:refs/tags/*"},
+			Auth:       db.authMethod(),
+		})
+		if err != nil && err != git.NoErrAlreadyUpToDate {
+			return err
+		}
+		ref, err = r.Tag(tag)
+	}
+	if err != nil {
+		return fmt.Errorf("CheckoutRelease: %s: %v", tag, err)
+	}
+	hash := ref.Hash()
+	if t, err := r.TagObject(hash); err == nil {
+		hash = t.Target
+	}
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+	if err := db.verifyRelease(tree); err != nil {
+		return err
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	return w.Checkout(&git.CheckoutOptions{Hash: hash, Force: true})
+}
+
+// verifyRelease checks the files of tree against the checksums of its
+// VERSION file.
+func (db DB) verifyRelease(tree *object.Tree) error {
+	content, ok, err := treeFile(tree, filepath.ToSlash(db.resolve(releaseManifestName)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("CheckoutRelease: %s not found", releaseManifestName)
+	}
+	var manifest ReleaseManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return fmt.Errorf("CheckoutRelease: %s: %v", releaseManifestName, err)
+	}
+	var paths []string
+	for path := range manifest.Checksums {
+		paths = append(paths, path)
+	}
+	checksums, err := releaseChecksums(tree, paths)
+	if err != nil {
+		return err
+	}
+	var mismatched []string
+	for path, sum := range manifest.Checksums {
+		if checksums[path] != sum {
+			mismatched = append(mismatched, path)
+		}
+	}
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		return fmt.Errorf("CheckoutRelease: checksum mismatch: %s", strings.Join(mismatched, ", "))
+	}
+	return nil
+}
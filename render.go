@@ -0,0 +1,103 @@
+package gitdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+func (db DB) MustRenderTemplates(srcGlob, destDir string, data interface{}) []string {
+	paths, err := db.RenderTemplates(srcGlob, destDir, data)
+	if err != nil {
+		panic(err)
+	}
+	return paths
+}
+
+// RenderTemplates renders the text/template files of the repository
+// matching srcGlob, like "templates/*.html.tmpl", into destDir with data as
+// dot, then commits the files that changed and returns their paths. Both
+// paths are relative to the root (see SetRoot). Each file is written with
+// its base name, without the ".tmpl" extension if any; the templates are
+// parsed together, so one can include another by its base name, and the
+// ones rendering nothing but spaces, like those only defining others, are
+// not written. Templates can read the repository with the functions
+// collection and object, which take a path and return the records or the
+// content of the file without a Go type, like
+// {{range collection "products.json"}}. The rendered files are managed (see
+// Manage).
+func (db DB) RenderTemplates(srcGlob, destDir string, data interface{}) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(db.Local, db.resolve(srcGlob)))
+	if err != nil {
+		return nil, fmt.Errorf("RenderTemplates: %v", err)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("RenderTemplates: no templates match %s", srcGlob)
+	}
+	tmpl, err := template.New("").Funcs(db.templateFuncs()).ParseFiles(files...)
+	if err != nil {
+		return nil, fmt.Errorf("RenderTemplates: %v", err)
+	}
+	dir := db.resolve(destDir)
+	if err := os.MkdirAll(filepath.Join(db.Local, dir), 0755); err != nil {
+		return nil, err
+	}
+	var changed []string
+	for _, file := range files {
+		name := filepath.Base(file)
+		var b bytes.Buffer
+		if err := tmpl.ExecuteTemplate(&b, name, data); err != nil {
+			return nil, fmt.Errorf("RenderTemplates: %v", err)
+		}
+		if len(bytes.TrimSpace(b.Bytes())) == 0 {
+			continue
+		}
+		dest := filepath.Join(dir, strings.TrimSuffix(name, ".tmpl"))
+		db.Manage(filepath.ToSlash(dest))
+		full := filepath.Join(db.Local, dest)
+		if old, err := ioutil.ReadFile(full); err == nil && bytes.Equal(old, b.Bytes()) {
+			continue
+		}
+		if err := ioutil.WriteFile(full, b.Bytes(), 0644); err != nil {
+			return nil, err
+		}
+		changed = append(changed, dest)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	sort.Strings(changed)
+	if err := db.Add(changed...); err != nil {
+		return nil, err
+	}
+	err = db.commitOp(CommitInfo{
+		Op:      "render",
+		Paths:   changed,
+		Message: "render " + strings.Join(changed, ", "),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// templateFuncs returns the functions RenderTemplates gives to templates.
+func (db DB) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"collection": func(path string) ([]interface{}, error) {
+			var records []interface{}
+			err := db.findCollection(path).Read(&records)
+			return records, err
+		},
+		"object": func(path string) (interface{}, error) {
+			var content interface{}
+			err := Object{db: &db, Path: db.resolve(path)}.Read(&content)
+			return content, err
+		},
+	}
+}
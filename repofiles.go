@@ -0,0 +1,140 @@
+package gitdb
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	repoFilesBegin = "# BEGIN gitdb"
+	repoFilesEnd   = "# END gitdb"
+)
+
+func (db DB) MustEnsureRepoFiles() {
+	if err := db.EnsureRepoFiles(); err != nil {
+		panic(err)
+	}
+}
+
+// EnsureRepoFiles writes a .gitignore ignoring temporary and lock files,
+// like the ".name.tmp123" files written by WriteContext, and a
+// .gitattributes marking the files of the collections and objects created
+// by NewCollection and NewObject as generated, then commits them if they
+// changed. Collection files keep the default merge of git: a union merge
+// would keep both versions of a record edited on both sides. Both files are
+// put in the root directory (see SetRoot), and only the part between the "# BEGIN gitdb" and
+// "# END gitdb" lines is managed, so other lines are kept.
+func (db DB) EnsureRepoFiles() error {
+	ignore := db.resolve(".gitignore")
+	attributes := db.resolve(".gitattributes")
+	db.Manage(filepath.ToSlash(ignore), filepath.ToSlash(attributes))
+	var changed []string
+	for name, lines := range map[string][]string{
+		ignore:     {".*.tmp*", "*.tmp", "*.lock"},
+		attributes: db.attributeLines(),
+	} {
+		ok, err := writeRepoFile(filepath.Join(db.Local, name), lines)
+		if err != nil {
+			return err
+		}
+		if ok {
+			changed = append(changed, name)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	sort.Strings(changed)
+	if err := db.Add(changed...); err != nil {
+		return err
+	}
+	return db.commitOp(CommitInfo{
+		Op:      "update",
+		Paths:   changed,
+		Message: "update " + strings.Join(changed, ", "),
+	})
+}
+
+// attributeLines returns the .gitattributes lines of the managed paths,
+// relative to the root.
+func (db DB) attributeLines() []string {
+	s := db.state()
+	var lines []string
+	for _, c := range s.managedCollections() {
+		p := db.relative(c.Path)
+		switch {
+		case c.RecordKeyField != "":
+			lines = append(lines, p+"/** linguist-generated=true")
+		case c.ChunkSize > 0:
+			ext := path.Ext(p)
+			lines = append(lines, p+" linguist-generated=true")
+			lines = append(lines, strings.TrimSuffix(p, ext)+".[0-9][0-9][0-9][0-9]*"+ext+" linguist-generated=true")
+		default:
+			lines = append(lines, p+" linguist-generated=true")
+		}
+	}
+	s.registry.Lock()
+	objects := append([]string(nil), s.objects...)
+	s.registry.Unlock()
+	for _, o := range objects {
+		lines = append(lines, db.relative(o)+" linguist-generated=true")
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// relative returns name, a path of the repository, relative to the root
+// and rooted so it only matches there.
+func (db DB) relative(name string) string {
+	name = filepath.ToSlash(filepath.Clean(name))
+	if db.Root != "" {
+		name = strings.TrimPrefix(name, db.Root+"/")
+	}
+	return "/" + name
+}
+
+// writeRepoFile replaces the managed block of the file at name with lines
+// and reports whether the file changed.
+func writeRepoFile(name string, lines []string) (bool, error) {
+	old, err := ioutil.ReadFile(name)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	var kept []string
+	inside := false
+	for _, line := range strings.Split(strings.TrimRight(string(old), "\n"), "\n") {
+		switch {
+		case line == repoFilesBegin:
+			inside = true
+		case line == repoFilesEnd:
+			inside = false
+		case !inside && (line != "" || len(kept) > 0):
+			kept = append(kept, line)
+		}
+	}
+	for len(kept) > 0 && kept[len(kept)-1] == "" {
+		kept = kept[:len(kept)-1]
+	}
+	var b strings.Builder
+	for _, line := range kept {
+		fmt.Fprintln(&b, line)
+	}
+	if len(kept) > 0 {
+		fmt.Fprintln(&b)
+	}
+	fmt.Fprintln(&b, repoFilesBegin)
+	for _, line := range lines {
+		fmt.Fprintln(&b, line)
+	}
+	fmt.Fprintln(&b, repoFilesEnd)
+	content := b.String()
+	if content == string(old) {
+		return false, nil
+	}
+	return true, ioutil.WriteFile(name, []byte(content), 0644)
+}
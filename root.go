@@ -0,0 +1,69 @@
+package gitdb
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+// SetRoot makes NewCollection and NewObject resolve their paths under the
+// directory root of the repository, like "data/", and makes Add and Commit
+// refuse paths outside of it, so the rest of a monorepo is never touched.
+func (db *DB) SetRoot(root string) {
+	root = path.Clean(filepath.ToSlash(root))
+	if root == "." || root == "/" {
+		root = ""
+	}
+	db.Root = strings.TrimPrefix(root, "/")
+}
+
+// resolve returns the path in the repository of name, a path relative to
+// the root, with the separators of the system, so paths written with
+// slashes work on Windows too.
+func (db DB) resolve(name string) string {
+	if db.Root == "" {
+		return filepath.FromSlash(name)
+	}
+	return filepath.Join(filepath.FromSlash(db.Root), filepath.FromSlash(name))
+}
+
+func (db DB) inRoot(name string) bool {
+	if db.Root == "" {
+		return true
+	}
+	return strings.HasPrefix(path.Clean(filepath.ToSlash(name))+"/", db.Root+"/")
+}
+
+// checkRoot returns an error naming the files outside of the root.
+func (db DB) checkRoot(op string, files []string) error {
+	var outside []string
+	for _, file := range files {
+		if !db.inRoot(file) {
+			outside = append(outside, file)
+		}
+	}
+	if len(outside) == 0 {
+		return nil
+	}
+	sort.Strings(outside)
+	return fmt.Errorf("%s: outside of root %s: %s", op, db.Root, strings.Join(outside, ", "))
+}
+
+// stagedOutsideRoot returns an error if changes outside of the root are
+// staged.
+func (db DB) stagedOutsideRoot(s git.Status) error {
+	if db.Root == "" {
+		return nil
+	}
+	var staged []string
+	for name, fs := range s {
+		if fs.Staging != git.Unmodified && fs.Staging != git.Untracked {
+			staged = append(staged, name)
+		}
+	}
+	return db.checkRoot("Commit", staged)
+}
@@ -0,0 +1,45 @@
+package gitdb
+
+import (
+	"testing"
+)
+
+type rootUser struct {
+	ID string `json:"id"`
+}
+
+type rootPost struct {
+	ID     string `json:"id"`
+	UserID string `json:"user_id" gitdb:"ref=users.json#id"`
+}
+
+func TestRootReferences(t *testing.T) {
+	db := newTestDB(t)
+	db.SetRoot("data")
+	db.EnforceReferences = true
+	if err := db.NewCollection("users.json").Write([]rootUser{{ID: "u1"}}); err != nil {
+		t.Fatal(err)
+	}
+	posts := db.NewCollection("posts.json")
+	if err := posts.Write([]rootPost{{ID: "p1", UserID: "u1"}}); err != nil {
+		t.Errorf("reference to a record under the root: %v", err)
+	}
+	if err := posts.Write([]rootPost{{ID: "p2", UserID: "u2"}}); err == nil {
+		t.Error("dangling reference accepted")
+	}
+}
+
+func TestRootRedefineView(t *testing.T) {
+	db := newTestDB(t)
+	db.SetRoot("data")
+	users := db.NewCollection("users.json")
+	count := func(...*Collection) (interface{}, error) {
+		return []int{1}, nil
+	}
+	db.DefineView("count.json", []*Collection{users}, count)
+	v := db.DefineView("count.json", []*Collection{users}, count)
+	views := db.state().views
+	if len(views) != 1 || views[0] != v {
+		t.Errorf("got %d views, want the last one only", len(views))
+	}
+}
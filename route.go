@@ -0,0 +1,307 @@
+package gitdb
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type (
+	branchRoute struct {
+		pattern string
+		branch  string
+	}
+)
+
+// RouteBranch makes Commit commit staged changes to paths matching pattern
+// to branch instead of the current branch. A pattern ending with a slash
+// matches everything under that directory, otherwise it is matched with
+// path.Match. Routed changes are removed from the worktree once committed,
+// since they no longer belong to the checked out branch.
+func (db *DB) RouteBranch(pattern, branch string) {
+	db.branchRoutes = append(db.branchRoutes, branchRoute{
+		pattern: filepath.ToSlash(pattern),
+		branch:  branch,
+	})
+}
+
+func (db DB) routedBranch(name string) string {
+	name = filepath.ToSlash(name)
+	for _, route := range db.branchRoutes {
+		if matchPath(route.pattern, name) {
+			return route.branch
+		}
+	}
+	return ""
+}
+
+func matchPath(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(name, pattern)
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+func currentBranch(r *git.Repository, fallback string) string {
+	if head, err := r.Head(); err == nil && head.Name().IsBranch() {
+		return head.Name().Short()
+	}
+	return fallback
+}
+
+func headTree(r *git.Repository) (*object.Tree, error) {
+	head, err := r.Head()
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// commitRoutes commits the staged changes routed to other branches and
+// resets them in the index and worktree. It reports whether anything was
+// routed.
+func (db DB) commitRoutes(r *git.Repository, s git.Status, msg string) (bool, error) {
+	if len(db.branchRoutes) == 0 {
+		return false, nil
+	}
+	current := currentBranch(r, db.GetBranchName())
+	staged, err := stagedChanges(r, s)
+	if err != nil {
+		return false, err
+	}
+	changes := map[string]map[string]plumbing.Hash{}
+	for name, h := range staged {
+		branch := db.routedBranch(name)
+		if branch == "" || branch == current {
+			continue
+		}
+		if changes[branch] == nil {
+			changes[branch] = map[string]plumbing.Hash{}
+		}
+		changes[branch][name] = h
+	}
+	if len(changes) == 0 {
+		return false, nil
+	}
+	for branch, files := range changes {
+		if _, err := db.commitToBranch(r, branch, files, msg); err != nil {
+			return false, err
+		}
+		if err := unstage(r, db.Local, files); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// stagedChanges returns the blob staged for each changed path, or a zero
+// hash for staged deletions.
+func stagedChanges(r *git.Repository, s git.Status) (map[string]plumbing.Hash, error) {
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+	changes := map[string]plumbing.Hash{}
+	for name, fs := range s {
+		if fs.Staging == git.Unmodified || fs.Staging == git.Untracked {
+			continue
+		}
+		h := plumbing.ZeroHash
+		if fs.Staging != git.Deleted {
+			e, err := idx.Entry(name)
+			if err != nil {
+				return nil, err
+			}
+			h = e.Hash
+		}
+		changes[name] = h
+	}
+	return changes, nil
+}
+
+// unstage resets the index entries and worktree files of changes to HEAD.
+func unstage(r *git.Repository, local string, changes map[string]plumbing.Hash) error {
+	tree, err := headTree(r)
+	if err != nil {
+		return err
+	}
+	for name := range changes {
+		if err := checkoutPath(r, local, tree, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitToBranch commits changes on top of branch, which is created from
+// HEAD if it does not exist, without touching the worktree.
+func (db DB) commitToBranch(r *git.Repository, branch string, changes map[string]plumbing.Hash, msg string) (plumbing.Hash, error) {
+	parent, err := db.branchCommit(r, branch)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if parent == nil {
+		if head, err := r.Head(); err == nil {
+			if parent, err = r.CommitObject(head.Hash()); err != nil {
+				return plumbing.ZeroHash, err
+			}
+		}
+	}
+	var base *object.Tree
+	var parents []plumbing.Hash
+	if parent != nil {
+		if base, err = parent.Tree(); err != nil {
+			return plumbing.ZeroHash, err
+		}
+		parents = []plumbing.Hash{parent.Hash}
+	}
+	tree, err := buildTree(r.Storer, base, changes)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if base != nil && tree == base.Hash {
+		log.Println("nothing to commit to", branch)
+		return parent.Hash, nil
+	}
+	hash, err := db.commitTree(r.Storer, tree, parents, msg)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), hash)); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	log.Println("added commit", hash.String()[:8], "to", branch)
+	return hash, nil
+}
+
+// updateRoutedBranches points the local routed branches other than the
+// current one at their remote tracking branches.
+func (db DB) updateRoutedBranches(r *git.Repository) error {
+	current := currentBranch(r, db.GetBranchName())
+	for _, route := range db.branchRoutes {
+		if route.branch == current {
+			continue
+		}
+		ref, err := r.Reference(plumbing.NewRemoteReferenceName(db.GetRemoteName(), route.branch), true)
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName(route.branch), ref.Hash()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c Collection) MustPromote(fromBranch, toBranch string) {
+	if err := c.Promote(fromBranch, toBranch); err != nil {
+		panic(err)
+	}
+}
+
+// Promote commits the content the collection has on fromBranch to
+// toBranch, with its chunks or record files, deleting the ones only
+// toBranch has. If toBranch is checked out, the worktree is updated as
+// well.
+func (c Collection) Promote(fromBranch, toBranch string) error {
+	r, err := git.PlainOpen(c.db.Local)
+	if err != nil {
+		return err
+	}
+	src, err := c.db.branchCommit(r, fromBranch)
+	if err != nil {
+		return err
+	}
+	if src == nil {
+		return fmt.Errorf("Promote: branch %s not found", fromBranch)
+	}
+	tree, err := src.Tree()
+	if err != nil {
+		return err
+	}
+	changes := map[string]plumbing.Hash{}
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if c.owns(f.Name) {
+			changes[f.Name] = f.Hash
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// delete the chunks and record files only toBranch has
+	dst, err := c.db.branchCommit(r, toBranch)
+	if err != nil {
+		return err
+	}
+	if dst == nil {
+		if head, err := r.Head(); err == nil {
+			if dst, err = r.CommitObject(head.Hash()); err != nil {
+				return err
+			}
+		}
+	}
+	if dst != nil {
+		dstTree, err := dst.Tree()
+		if err != nil {
+			return err
+		}
+		err = dstTree.Files().ForEach(func(f *object.File) error {
+			if _, ok := changes[f.Name]; !ok && c.owns(f.Name) {
+				changes[f.Name] = plumbing.ZeroHash
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	msg := fmt.Sprintf("promote %s from %s to %s", c.Path, fromBranch, toBranch)
+	hash, err := c.db.commitToBranch(r, toBranch, changes, msg)
+	if err != nil {
+		return err
+	}
+	if toBranch != currentBranch(r, c.db.GetBranchName()) {
+		return nil
+	}
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+	if tree, err = commit.Tree(); err != nil {
+		return err
+	}
+	for name := range changes {
+		if err := checkoutPath(r, c.db.Local, tree, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasStagedChanges(s git.Status) bool {
+	for _, fs := range s {
+		if fs.Staging != git.Unmodified && fs.Staging != git.Untracked {
+			return true
+		}
+	}
+	return false
+}
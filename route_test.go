@@ -0,0 +1,136 @@
+package gitdb
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type promoteRecord struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// branchFiles returns the names of the files of branch owned by c.
+func branchFiles(t *testing.T, db *DB, branch string, c *Collection) []string {
+	t.Helper()
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := r.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := r.CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tree.Files().ForEach(func(f *object.File) error {
+		if c.owns(f.Name) {
+			names = append(names, f.Name)
+		}
+		return nil
+	})
+	sort.Strings(names)
+	return names
+}
+
+// newProdBranch commits a file outside of the collections and creates the
+// branch prod at that commit.
+func newProdBranch(t *testing.T, db *DB) {
+	t.Helper()
+	if err := db.NewObject("settings.json").Write(map[string]int{"version": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Add("settings.json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Commit("init"); err != nil {
+		t.Fatal(err)
+	}
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Storer.SetReference(plumbing.NewHashReference(plumbing.NewBranchReferenceName("prod"), head.Hash()))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeAndCommit(t *testing.T, db *DB, c *Collection, records []promoteRecord) {
+	t.Helper()
+	if err := c.Write(records); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Add(c.Path); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Commit("update"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPromoteChunked(t *testing.T) {
+	db := newTestDB(t)
+	newProdBranch(t, db)
+	c := db.NewCollection("products.json")
+	c.ChunkSize = 40
+	writeAndCommit(t, db, c, []promoteRecord{{"1", "a"}, {"2", "b"}, {"3", "c"}})
+	main := currentBranchOf(t, db)
+	if err := c.Promote(main, "prod"); err != nil {
+		t.Fatal(err)
+	}
+	want := branchFiles(t, db, main, c)
+	if len(want) < 3 {
+		t.Fatalf("expected a manifest and chunks, got %v", want)
+	}
+	if got := branchFiles(t, db, "prod", c); !equalStrings(got, want) {
+		t.Errorf("prod has %v, want %v", got, want)
+	}
+
+	writeAndCommit(t, db, c, []promoteRecord{{"1", "a"}})
+	if err := c.Promote(main, "prod"); err != nil {
+		t.Fatal(err)
+	}
+	want = branchFiles(t, db, main, c)
+	if got := branchFiles(t, db, "prod", c); !equalStrings(got, want) {
+		t.Errorf("prod has %v after shrinking, want %v", got, want)
+	}
+}
+
+func TestPromoteRecords(t *testing.T) {
+	db := newTestDB(t)
+	newProdBranch(t, db)
+	c := db.NewCollection("users")
+	c.RecordKeyField = "id"
+	writeAndCommit(t, db, c, []promoteRecord{{"1", "a"}, {"2", "b"}})
+	main := currentBranchOf(t, db)
+	if err := c.Promote(main, "prod"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := branchFiles(t, db, "prod", c), []string{"users/1.json", "users/2.json"}; !equalStrings(got, want) {
+		t.Errorf("prod has %v, want %v", got, want)
+	}
+
+	writeAndCommit(t, db, c, []promoteRecord{{"2", "b"}, {"3", "c"}})
+	if err := c.Promote(main, "prod"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := branchFiles(t, db, "prod", c), []string{"users/2.json", "users/3.json"}; !equalStrings(got, want) {
+		t.Errorf("prod has %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,306 @@
+package gitdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// scheduleFile is the file of the scheduled mutations, relative to the
+// root.
+const scheduleFile = ".gitdb/schedule.json"
+
+type (
+	// Tx records the mutations of a scheduled write. Contents are encoded
+	// as JSON when they are recorded and decoded as the Model of the
+	// collection, or as generic values, when they are applied.
+	Tx struct {
+		db  DB
+		ops []scheduledOp
+	}
+
+	scheduledOp struct {
+		Op       string          `json:"op"`
+		Path     string          `json:"path"`
+		Content  json.RawMessage `json:"content,omitempty"`
+		KeyField string          `json:"keyField,omitempty"`
+	}
+
+	scheduledWrite struct {
+		ID  string        `json:"id"`
+		At  time.Time     `json:"at"`
+		Ops []scheduledOp `json:"ops"`
+	}
+)
+
+func (db DB) MustSchedule(at time.Time, fn func(tx *Tx) error) string {
+	id, err := db.Schedule(at, fn)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// Schedule records the mutations fn makes to tx and commits them to a
+// queue file, .gitdb/schedule.json in the root, so they survive restarts,
+// then returns the ID of the scheduled write. RunScheduled, and so
+// RunScheduledEvery, applies them once at has come, in one commit, and
+// pushes it, for embargoed content. fn runs now, not at at; what it
+// records is applied as is.
+func (db DB) Schedule(at time.Time, fn func(tx *Tx) error) (string, error) {
+	tx := &Tx{db: db}
+	if err := fn(tx); err != nil {
+		return "", err
+	}
+	if len(tx.ops) == 0 {
+		return "", fmt.Errorf("Schedule: no mutations")
+	}
+	id := fmt.Sprintf("%d", time.Now().UnixNano())
+	err := db.updateSchedule("schedule "+id, func(writes []scheduledWrite) []scheduledWrite {
+		return append(writes, scheduledWrite{ID: id, At: at.UTC(), Ops: tx.ops})
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (db DB) MustCancelScheduled(id string) {
+	if err := db.CancelScheduled(id); err != nil {
+		panic(err)
+	}
+}
+
+// CancelScheduled removes the scheduled write id, if it has not been
+// applied yet.
+func (db DB) CancelScheduled(id string) error {
+	found := false
+	err := db.updateSchedule("cancel "+id, func(writes []scheduledWrite) []scheduledWrite {
+		for i, w := range writes {
+			if w.ID == id {
+				found = true
+				return append(writes[:i], writes[i+1:]...)
+			}
+		}
+		return nil
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("CancelScheduled: no scheduled write %s", id)
+	}
+	return err
+}
+
+// Write records the write of content, a slice for a collection or a value
+// for an object, to path.
+func (tx *Tx) Write(path string, content interface{}) error {
+	return tx.record("write", path, content, "")
+}
+
+// Upsert records the merge of records into the collection at path by
+// keyField, like Collection.Upsert without deleting missing records.
+func (tx *Tx) Upsert(path string, records interface{}, keyField string) error {
+	return tx.record("upsert", path, records, keyField)
+}
+
+// Remove records the removal of the file at path.
+func (tx *Tx) Remove(path string) error {
+	return tx.record("remove", path, nil, "")
+}
+
+func (tx *Tx) record(op, path string, content interface{}, keyField string) error {
+	o := scheduledOp{Op: op, Path: filepath.ToSlash(tx.db.resolve(path)), KeyField: keyField}
+	if content != nil {
+		b, err := json.Marshal(content)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		o.Content = b
+	}
+	tx.ops = append(tx.ops, o)
+	return nil
+}
+
+func (db DB) MustRunScheduled() []string {
+	ids, err := db.RunScheduled()
+	if err != nil {
+		panic(err)
+	}
+	return ids
+}
+
+// RunScheduled applies the scheduled writes whose time has come, oldest
+// first, each in its own commit, pushes them if the DB has a remote and
+// returns their IDs.
+func (db DB) RunScheduled() ([]string, error) {
+	var ids []string
+	for {
+		id, err := db.applyNextScheduled(time.Now())
+		if err != nil || id == "" {
+			return ids, err
+		}
+		ids = append(ids, id)
+		if db.Remote != "" {
+			if err := db.Push(); err != nil {
+				return ids, err
+			}
+		}
+	}
+}
+
+// RunScheduledEvery runs RunScheduled at every interval in the background,
+// until the returned function is called. Errors are logged.
+func (db DB) RunScheduledEvery(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if _, err := db.RunScheduled(); err != nil {
+					log.Println("error running scheduled writes", err)
+					db.reportError("schedule", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+	}
+}
+
+// applyNextScheduled applies the oldest scheduled write if its time has
+// come and removes it from the queue file in one commit, then returns its
+// ID.
+func (db DB) applyNextScheduled(now time.Time) (string, error) {
+	defer db.lock()()
+	writes, err := db.readSchedule()
+	if err != nil || len(writes) == 0 || writes[0].At.After(now) {
+		return "", err
+	}
+	w := writes[0]
+	paths, err := db.applyOps(w.Ops)
+	if err != nil {
+		return "", fmt.Errorf("RunScheduled: %s: %v", w.ID, err)
+	}
+	err = db.writeSchedule("apply "+w.ID, writes[1:], paths...)
+	if err != nil {
+		return "", err
+	}
+	return w.ID, nil
+}
+
+// applyOps applies ops and returns the paths they changed.
+func (db DB) applyOps(ops []scheduledOp) ([]string, error) {
+	var paths []string
+	for _, o := range ops {
+		path := filepath.FromSlash(o.Path)
+		c := db.collectionAt(path)
+		var err error
+		switch o.Op {
+		case "write":
+			err = applyWrite(c, o.Content)
+		case "upsert":
+			err = applyUpsert(c, o.Content, o.KeyField)
+		case "remove":
+			err = os.Remove(filepath.Join(db.Local, path))
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		default:
+			err = fmt.Errorf("unknown operation %s", o.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %v", o.Op, o.Path, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func applyWrite(c *Collection, content json.RawMessage) error {
+	var probe interface{}
+	if err := decodeGeneric(content, &probe); err != nil {
+		return err
+	}
+	if _, ok := probe.([]interface{}); !ok {
+		return Object{db: c.db, Path: c.Path}.Write(probe)
+	}
+	records := reflect.New(reflect.SliceOf(c.recordType()))
+	if err := decodeGeneric(content, records.Interface()); err != nil {
+		return err
+	}
+	return c.Write(records.Elem().Interface())
+}
+
+func applyUpsert(c *Collection, content json.RawMessage, keyField string) error {
+	changes := reflect.New(reflect.SliceOf(c.recordType()))
+	if err := decodeGeneric(content, changes.Interface()); err != nil {
+		return err
+	}
+	records, err := c.adminRecords()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < changes.Elem().Len(); i++ {
+		records = replaceRecord(records, changes.Elem().Index(i), keyField)
+	}
+	return c.Write(records.Interface())
+}
+
+// updateSchedule passes the scheduled writes to fn and writes and commits
+// the ones it returns, unless it returns nil.
+func (db DB) updateSchedule(msg string, fn func([]scheduledWrite) []scheduledWrite) error {
+	defer db.lock()()
+	writes, err := db.readSchedule()
+	if err != nil {
+		return err
+	}
+	if writes = fn(writes); writes == nil {
+		return nil
+	}
+	return db.writeSchedule(msg, writes)
+}
+
+// writeSchedule writes the scheduled writes and commits them together with
+// paths.
+func (db DB) writeSchedule(msg string, writes []scheduledWrite, paths ...string) error {
+	sort.SliceStable(writes, func(i, j int) bool {
+		return writes[i].At.Before(writes[j].At)
+	})
+	c := db.scheduleCollection()
+	if err := c.Write(writes); err != nil {
+		return err
+	}
+	paths = append(paths, c.Path)
+	if err := db.Add(paths...); err != nil {
+		return err
+	}
+	return db.commit(db.formatMessage(CommitInfo{
+		Op:      "schedule",
+		Paths:   paths,
+		Message: msg,
+	}))
+}
+
+func (db DB) readSchedule() ([]scheduledWrite, error) {
+	var writes []scheduledWrite
+	err := db.scheduleCollection().Read(&writes)
+	return writes, err
+}
+
+// scheduleCollection returns the queue file, which is managed.
+func (db DB) scheduleCollection() Collection {
+	path := db.resolve(scheduleFile)
+	if !db.state().managedPath(path) {
+		db.Manage(filepath.ToSlash(path))
+	}
+	return Collection{db: &db, Path: path}
+}
@@ -0,0 +1,288 @@
+package gitdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type (
+	// Vault reads a secret from the KV version 2 secrets engine of
+	// HashiCorp Vault.
+	Vault struct {
+		// Address and Token default to VAULT_ADDR and VAULT_TOKEN.
+		Address string
+		Token   string
+
+		// Mount is the mount path of the engine, "secret" by default.
+		Mount string
+		Path  string
+		Field string
+
+		Client *http.Client
+	}
+
+	// AWSSSM reads a parameter from AWS Systems Manager Parameter Store,
+	// decrypting SecureString parameters with their KMS key.
+	AWSSSM struct {
+		Name string
+
+		// Region defaults to AWS_REGION.
+		Region string
+
+		// Credentials defaults to EnvAWSCredentials.
+		Credentials func() (AWSCredentials, error)
+
+		Client *http.Client
+	}
+
+	// AWSKMS decrypts a ciphertext with AWS KMS, such as a private key
+	// encrypted with "aws kms encrypt".
+	AWSKMS struct {
+		Ciphertext []byte
+
+		// Region defaults to AWS_REGION.
+		Region string
+
+		// Credentials defaults to EnvAWSCredentials.
+		Credentials func() (AWSCredentials, error)
+
+		Client *http.Client
+	}
+
+	// GCPSecretManager reads a secret version from Google Cloud Secret
+	// Manager.
+	GCPSecretManager struct {
+		Project string
+		Secret  string
+
+		// Version defaults to "latest".
+		Version string
+
+		// Tokens returns OAuth access tokens. It defaults to the token of
+		// the default service account from the metadata server.
+		Tokens TokenSource
+
+		Client *http.Client
+	}
+
+	gcpMetadataTokens struct {
+		client *http.Client
+	}
+)
+
+func (v Vault) Key(ctx context.Context) ([]byte, error) {
+	address, token, mount := v.Address, v.Token, v.Mount
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	if mount == "" {
+		mount = "secret"
+	}
+	var res struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	u := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimSuffix(address, "/"), strings.Trim(mount, "/"), strings.TrimPrefix(v.Path, "/"))
+	if err := getJSON(ctx, v.Client, u, map[string]string{"X-Vault-Token": token}, &res); err != nil {
+		return nil, err
+	}
+	value, ok := res.Data.Data[v.Field].(string)
+	if !ok {
+		return nil, fmt.Errorf("vault: no field %s in %s", v.Field, v.Path)
+	}
+	return []byte(value), nil
+}
+
+func (p AWSSSM) Key(ctx context.Context) ([]byte, error) {
+	var res struct {
+		Parameter struct {
+			Value string
+		}
+	}
+	err := awsJSON(ctx, p.Client, p.Credentials, "ssm", p.Region, "AmazonSSM.GetParameter", map[string]interface{}{
+		"Name":           p.Name,
+		"WithDecryption": true,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(res.Parameter.Value), nil
+}
+
+func (k AWSKMS) Key(ctx context.Context) ([]byte, error) {
+	var res struct {
+		Plaintext []byte
+	}
+	err := awsJSON(ctx, k.Client, k.Credentials, "kms", k.Region, "TrentService.Decrypt", map[string]interface{}{
+		"CiphertextBlob": k.Ciphertext,
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Plaintext, nil
+}
+
+func (s GCPSecretManager) Key(ctx context.Context) ([]byte, error) {
+	version := s.Version
+	if version == "" {
+		version = "latest"
+	}
+	tokens := s.Tokens
+	if tokens == nil {
+		tokens = gcpMetadataTokens{s.Client}
+	}
+	token, err := tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Payload struct {
+			Data []byte `json:"data"`
+		} `json:"payload"`
+	}
+	u := fmt.Sprintf("https://secretmanager.googleapis.com/v1/projects/%s/secrets/%s/versions/%s:access",
+		url.PathEscape(s.Project), url.PathEscape(s.Secret), url.PathEscape(version))
+	if err := getJSON(ctx, s.Client, u, map[string]string{"Authorization": "Bearer " + token}, &res); err != nil {
+		return nil, err
+	}
+	return res.Payload.Data, nil
+}
+
+func (m gcpMetadataTokens) Token(ctx context.Context) (string, error) {
+	var res struct {
+		AccessToken string `json:"access_token"`
+	}
+	err := getJSON(ctx, m.client, "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token",
+		map[string]string{"Metadata-Flavor": "Google"}, &res)
+	return res.AccessToken, err
+}
+
+func getJSON(ctx context.Context, client *http.Client, u string, headers map[string]string, dest interface{}) error {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("GET %s: %s: %s", u, res.Status, bytes.TrimSpace(b))
+	}
+	return json.Unmarshal(b, dest)
+}
+
+// awsJSON calls target of an AWS JSON protocol API, signing the request
+// with SigV4.
+func awsJSON(ctx context.Context, client *http.Client, credentials func() (AWSCredentials, error), service, region, target string, body, dest interface{}) error {
+	if credentials == nil {
+		credentials = EnvAWSCredentials
+	}
+	creds, err := credentials()
+	if err != nil {
+		return err
+	}
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf("https://%s.%s.amazonaws.com/", service, region)
+	req, err := http.NewRequest("POST", u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+	signAWS(req, b, creds, service, region, time.Now())
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	b, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%s: %s: %s", target, res.Status, bytes.TrimSpace(b))
+	}
+	return json.Unmarshal(b, dest)
+}
+
+// signAWS adds the SigV4 Authorization header to req, which must have no
+// query string.
+func signAWS(req *http.Request, body []byte, creds AWSCredentials, service, region string, now time.Time) {
+	now = now.UTC()
+	timestamp := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", timestamp)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+	names := []string{"content-type", "host", "x-amz-date", "x-amz-target"}
+	if creds.SessionToken != "" {
+		names = append(names, "x-amz-security-token")
+	}
+	// SigV4 requires the signed headers in order
+	sort.Strings(names)
+	var headers strings.Builder
+	for _, name := range names {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.URL.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	signed := strings.Join(names, ";")
+	bodySum := sha256.Sum256(body)
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonical := strings.Join([]string{req.Method, path, "", headers.String(), signed, hex.EncodeToString(bodySum[:])}, "\n")
+	sum := sha256.Sum256([]byte(canonical))
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	toSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%s", timestamp, scope, hex.EncodeToString(sum[:]))
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signed, signature))
+}
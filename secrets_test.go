@@ -0,0 +1,34 @@
+package gitdb
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAWSSessionToken(t *testing.T) {
+	body := []byte(`{"Name":"db","WithDecryption":true}`)
+	req, err := http.NewRequest("POST", "https://ssm.us-east-1.amazonaws.com/", strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "AmazonSSM.GetParameter")
+	creds := AWSCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		SessionToken:    "SESSIONTOKEN",
+	}
+	signAWS(req, body, creds, "ssm", "us-east-1", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/ssm/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, " +
+		"Signature=9096e96f36de93fd23b7c2ad261df37a8a07677686b1c124a400a426f9905dd5"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization = %s, want %s", got, want)
+	}
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "SESSIONTOKEN" {
+		t.Errorf("X-Amz-Security-Token = %q", got)
+	}
+}
@@ -0,0 +1,240 @@
+package gitdb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type (
+	// Session is a private copy of the files at HEAD where many changes can
+	// be made and previewed before they are committed to the repository at
+	// once, or discarded, like drafts of a CMS.
+	Session struct {
+		db   DB
+		sess DB
+		base map[string]plumbing.Hash
+	}
+
+	// SessionChange is a file changed in a session. Old is nil for added
+	// files and New is nil for deleted ones.
+	SessionChange struct {
+		Path string
+		Old  []byte
+		New  []byte
+	}
+)
+
+func (db DB) MustSession() *Session {
+	s, err := db.Session()
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Session copies the files at HEAD into a new directory inside the git
+// directory and returns a session on them. Changes are made with the
+// collections and objects of Session.Collection, Session.Object or
+// Session.DB, which never touch the repository until Commit.
+func (db DB) Session() (*Session, error) {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return nil, err
+	}
+	parent := filepath.Join(gitDir(db.Local), "gitdb", "sessions")
+	if err := os.MkdirAll(parent, 0755); err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir(parent, "")
+	if err != nil {
+		return nil, err
+	}
+	sess := db
+	sess.Local = dir
+	s := &Session{db: db, sess: sess, base: map[string]plumbing.Hash{}}
+	tree, err := headTree(r)
+	if err == nil && tree != nil {
+		err = tree.Files().ForEach(func(f *object.File) error {
+			s.base[f.Name] = f.Hash
+			content, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			return writeFile(filepath.Join(dir, f.Name), bytes.NewReader([]byte(content)))
+		})
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return s, nil
+}
+
+// DB returns the DB of the session, whose Local is the directory of the
+// session.
+func (s *Session) DB() *DB {
+	return &s.sess
+}
+
+// Collection returns a copy of c, with its options, reading and writing
+// the files of the session.
+func (s *Session) Collection(c *Collection) *Collection {
+	copy := *c
+	copy.db = &s.sess
+	return &copy
+}
+
+// Object returns a copy of o reading and writing the files of the session.
+func (s *Session) Object(o *Object) *Object {
+	copy := *o
+	copy.db = &s.sess
+	return &copy
+}
+
+func (s *Session) MustChanges() []SessionChange {
+	changes, err := s.Changes()
+	if err != nil {
+		panic(err)
+	}
+	return changes
+}
+
+// Changes returns the files changed in the session, sorted by path.
+func (s *Session) Changes() ([]SessionChange, error) {
+	seen := map[string]bool{}
+	var changes []SessionChange
+	err := filepath.Walk(s.sess.Local, func(full string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(s.sess.Local, full)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		seen[name] = true
+		content, err := ioutil.ReadFile(full)
+		if err != nil {
+			return err
+		}
+		hash, ok := s.base[name]
+		if ok && hash == plumbing.ComputeHash(plumbing.BlobObject, content) {
+			return nil
+		}
+		change := SessionChange{Path: name, New: content}
+		if ok {
+			if change.Old, err = s.baseContent(name); err != nil {
+				return err
+			}
+		}
+		changes = append(changes, change)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for name := range s.base {
+		if seen[name] {
+			continue
+		}
+		old, err := s.baseContent(name)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, SessionChange{Path: name, Old: old})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes, nil
+}
+
+func (s *Session) baseContent(name string) ([]byte, error) {
+	r, err := git.PlainOpen(s.db.Local)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := r.BlobObject(s.base[name])
+	if err != nil {
+		return nil, err
+	}
+	rd, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+	return ioutil.ReadAll(rd)
+}
+
+func (s *Session) MustCommit(message ...string) {
+	if err := s.Commit(message...); err != nil {
+		panic(err)
+	}
+}
+
+// Commit copies the changes of the session to the repository, commits them
+// and removes the session. If files changed in the session have also
+// changed in the repository since the session began, nothing is copied and
+// a MergeConflict is returned.
+func (s *Session) Commit(message ...string) error {
+	changes, err := s.Changes()
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return s.Discard()
+	}
+	defer s.db.lock()()
+	var conflicts, paths []string
+	for _, change := range changes {
+		current, err := ioutil.ReadFile(filepath.Join(s.db.Local, change.Path))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if (current == nil) != (change.Old == nil) || !bytes.Equal(current, change.Old) {
+			conflicts = append(conflicts, change.Path)
+		}
+		paths = append(paths, filepath.FromSlash(change.Path))
+	}
+	if len(conflicts) > 0 {
+		return MergeConflict{Paths: conflicts}
+	}
+	for _, change := range changes {
+		full := filepath.Join(s.db.Local, filepath.FromSlash(change.Path))
+		if change.New == nil {
+			err = os.Remove(full)
+		} else {
+			err = writeFile(full, bytes.NewReader(change.New))
+		}
+		if err != nil {
+			return fmt.Errorf("Commit: %v", err)
+		}
+	}
+	if err := s.db.Add(paths...); err != nil {
+		return err
+	}
+	if err := s.db.Commit(message...); err != nil {
+		return err
+	}
+	return s.Discard()
+}
+
+func (s *Session) MustDiscard() {
+	if err := s.Discard(); err != nil {
+		panic(err)
+	}
+}
+
+// Discard removes the session and its changes.
+func (s *Session) Discard() error {
+	states.Delete(stateKey(s.sess.Local))
+	return os.RemoveAll(s.sess.Local)
+}
@@ -0,0 +1,118 @@
+package gitdb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"golang.org/x/text/collate"
+)
+
+// SortBy makes Write sort the records with less, a func(a, b T) bool, so
+// the order of the file is stable and inserting records only adds lines.
+func (c *Collection) SortBy(less interface{}) *Collection {
+	fn := reflect.ValueOf(less)
+	c.less = func(a, b reflect.Value, _ *collate.Collator) bool {
+		in := fn.Type().In(0)
+		if in.Kind() == reflect.Ptr && a.Kind() != reflect.Ptr {
+			a, b = a.Addr(), b.Addr()
+		}
+		return fn.Call([]reflect.Value{a, b})[0].Bool()
+	}
+	return c
+}
+
+// SortByField makes Write sort the records by the value of field (Go field
+// name or JSON name), in descending order if desc is true. Strings are
+// compared by byte value unless a collation is set with SetCollation.
+func (c *Collection) SortByField(field string, desc bool) *Collection {
+	c.less = func(a, b reflect.Value, col *collate.Collator) bool {
+		x, _ := fieldOf(a, field)
+		y, _ := fieldOf(b, field)
+		if desc {
+			return compareValues(y, x, col) < 0
+		}
+		return compareValues(x, y, col) < 0
+	}
+	return c
+}
+
+// compareValues orders two values of the same kind, invalid and nil values
+// first, comparing strings with col if it is not nil.
+func compareValues(a, b reflect.Value, col *collate.Collator) int {
+	a, b = indirectValue(a), indirectValue(b)
+	if !a.IsValid() || !b.IsValid() {
+		switch {
+		case a.IsValid():
+			return 1
+		case b.IsValid():
+			return -1
+		}
+		return 0
+	}
+	if x, ok := a.Interface().(time.Time); ok {
+		if y, ok := b.Interface().(time.Time); ok {
+			switch {
+			case x.Before(y):
+				return -1
+			case x.After(y):
+				return 1
+			}
+			return 0
+		}
+	}
+	switch a.Kind() {
+	case reflect.String:
+		if b.Kind() == reflect.String {
+			if col != nil {
+				return col.CompareString(a.String(), b.String())
+			}
+			return strings.Compare(a.String(), b.String())
+		}
+	case reflect.Bool:
+		if b.Kind() == reflect.Bool {
+			switch {
+			case a.Bool() == b.Bool():
+				return 0
+			case b.Bool():
+				return -1
+			}
+			return 1
+		}
+	}
+	if x, ok := numberOf(a); ok {
+		if y, ok := numberOf(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a.Interface()), fmt.Sprint(b.Interface()))
+}
+
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+func numberOf(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,95 @@
+package gitdb
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	xssh "golang.org/x/crypto/ssh"
+)
+
+type (
+	// sshKeys are the SSH keys of a DB, shared by its copies so that
+	// RotateSSHKey reaches every one of them.
+	sshKeys struct {
+		mu       sync.RWMutex
+		user     string
+		password string
+		signers  []xssh.Signer
+	}
+)
+
+// AddSSHKey adds an SSH key to the keys set by SetSSHKey, for example the
+// next key of a rotation. The server is offered the keys in the order they
+// were added and accepts the first one it knows, so the other ones are
+// fallbacks when it rejects a key. Every key is used with the user of the
+// first one.
+func (db *DB) AddSSHKey(user string, pemBytes []byte, password string) error {
+	if db.sshKeys == nil {
+		return db.SetSSHKey(user, pemBytes, password)
+	}
+	return db.sshKeys.add(user, pemBytes, password)
+}
+
+func (db DB) MustRotateSSHKey(newPEM []byte) {
+	if err := db.RotateSSHKey(newPEM); err != nil {
+		panic(err)
+	}
+}
+
+// RotateSSHKey replaces the SSH keys of db, and of every copy of it made
+// after SetSSHKey, with newPEM, decrypted with the password of the last key
+// added if needed. Connections opened afterwards use the new key, so a
+// long-running process can rotate its key without restarting.
+func (db DB) RotateSSHKey(newPEM []byte) error {
+	if db.sshKeys == nil {
+		return fmt.Errorf("RotateSSHKey: no SSH key set")
+	}
+	k := db.sshKeys
+	k.mu.RLock()
+	user, password := k.user, k.password
+	k.mu.RUnlock()
+	public, err := ssh.NewPublicKeys(user, newPEM, password)
+	if err != nil {
+		return fmt.Errorf("RotateSSHKey: %v", err)
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.signers = []xssh.Signer{public.Signer}
+	return nil
+}
+
+func (k *sshKeys) add(user string, pemBytes []byte, password string) error {
+	public, err := ssh.NewPublicKeys(user, pemBytes, password)
+	if err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if len(k.signers) == 0 {
+		k.user = user
+	}
+	k.password = password
+	k.signers = append(k.signers, public.Signer)
+	return nil
+}
+
+func (k *sshKeys) Name() string {
+	return ssh.PublicKeysName
+}
+
+func (k *sshKeys) String() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return fmt.Sprintf("user: %s, name: %s, keys: %d", k.user, k.Name(), len(k.signers))
+}
+
+func (k *sshKeys) ClientConfig() (*xssh.ClientConfig, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return &xssh.ClientConfig{
+		User:            k.user,
+		Auth:            []xssh.AuthMethod{xssh.PublicKeys(append([]xssh.Signer(nil), k.signers...)...)},
+		HostKeyCallback: xssh.InsecureIgnoreHostKey(),
+	}, nil
+}
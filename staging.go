@@ -0,0 +1,124 @@
+package gitdb
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+)
+
+type (
+	// UnmanagedPolicy tells Commit what to do with staged changes to paths
+	// that belong to no Collection or Object.
+	UnmanagedPolicy int
+)
+
+const (
+	AllowUnmanaged UnmanagedPolicy = iota
+	WarnUnmanaged
+	RefuseUnmanaged
+)
+
+// SetUnmanagedPolicy sets what Commit does when staged changes fall outside
+// the paths of the collections and objects created by NewCollection and
+// NewObject and the paths registered with Manage: commit them anyway, log
+// a warning, or refuse to commit.
+func (db *DB) SetUnmanagedPolicy(policy UnmanagedPolicy) {
+	db.Unmanaged = policy
+}
+
+// Manage registers paths written without a Collection or Object, so Commit
+// accepts their changes. A pattern ending with a slash matches everything
+// under that directory, otherwise it is matched with path.Match.
+// Registering a pattern again has no effect.
+func (db DB) Manage(patterns ...string) {
+	s := db.state()
+	s.registry.Lock()
+	defer s.registry.Unlock()
+patterns:
+	for _, pattern := range patterns {
+		pattern = filepath.ToSlash(pattern)
+		for _, existing := range s.managed {
+			if existing == pattern {
+				continue patterns
+			}
+		}
+		s.managed = append(s.managed, pattern)
+	}
+}
+
+func (s *repoState) addObject(o *Object) {
+	path := filepath.ToSlash(filepath.Clean(o.Path))
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	for _, existing := range s.objects {
+		if existing == path {
+			return
+		}
+	}
+	s.objects = append(s.objects, path)
+}
+
+// managedPath reports whether name belongs to a collection, an object or
+// a pattern registered with Manage, or is a directory, like a submodule,
+// holding one of them.
+func (s *repoState) managedPath(name string) bool {
+	name = filepath.ToSlash(name)
+	for _, c := range s.managedCollections() {
+		path := filepath.ToSlash(filepath.Clean(c.Path))
+		if c.owns(name) || strings.HasPrefix(path, name+"/") {
+			return true
+		}
+	}
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	for _, patterns := range [][]string{s.objects, s.managed} {
+		for _, pattern := range patterns {
+			if matchPath(pattern, name) || strings.HasPrefix(pattern, name+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkUnmanaged applies the UnmanagedPolicy to the staged changes.
+func (db DB) checkUnmanaged(s git.Status) error {
+	if db.Unmanaged == AllowUnmanaged {
+		return nil
+	}
+	state := db.state()
+	var unmanaged []string
+	for name, fs := range s {
+		if fs.Staging == git.Unmodified || fs.Staging == git.Untracked {
+			continue
+		}
+		if !state.managedPath(name) {
+			unmanaged = append(unmanaged, name)
+		}
+	}
+	if len(unmanaged) == 0 {
+		return nil
+	}
+	sort.Strings(unmanaged)
+	if db.Unmanaged == WarnUnmanaged {
+		log.Println("committing unmanaged paths:", strings.Join(unmanaged, ", "))
+		return nil
+	}
+	return fmt.Errorf("Commit: unmanaged paths staged: %s", strings.Join(unmanaged, ", "))
+}
+
+func parseUnmanagedPolicy(s string) (UnmanagedPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "allow":
+		return AllowUnmanaged, nil
+	case "warn":
+		return WarnUnmanaged, nil
+	case "refuse":
+		return RefuseUnmanaged, nil
+	}
+	return 0, fmt.Errorf("unknown unmanaged policy %s", s)
+}
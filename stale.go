@@ -0,0 +1,185 @@
+package gitdb
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// ErrStaleRead is returned by ReadHandle.Write and RecordHandle.Load if
+// the file changed since it was read.
+var ErrStaleRead = errors.New("stale read")
+
+type (
+	// ReadHandle remembers the git blob hash of the file of a collection
+	// or an object when it was read, so that writing it back fails if
+	// something else wrote it in the meantime.
+	ReadHandle struct {
+		path  string
+		hash  string
+		files func() (string, error)
+		write func(content interface{}, funcs ...interface{}) error
+		mu    *sync.Mutex
+	}
+)
+
+func (c Collection) MustReadForUpdate(dest interface{}) *ReadHandle {
+	h, err := c.ReadForUpdate(dest)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// ReadForUpdate reads the collection like Read and returns a handle to
+// write it back with. The chunks and record files of the collection are
+// part of its hash.
+func (c Collection) ReadForUpdate(dest interface{}) (*ReadHandle, error) {
+	return c.db.readForUpdate(&ReadHandle{
+		path:  c.Path,
+		files: c.hashFiles,
+		write: c.Write,
+	}, func() error {
+		return c.Read(dest)
+	})
+}
+
+func (o Object) MustReadForUpdate(dest interface{}) *ReadHandle {
+	h, err := o.ReadForUpdate(dest)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// ReadForUpdate reads the object like Read and returns a handle to write
+// it back with.
+func (o Object) ReadForUpdate(dest interface{}) (*ReadHandle, error) {
+	return o.db.readForUpdate(&ReadHandle{
+		path: o.Path,
+		files: func() (string, error) {
+			return hashFile(filepath.Join(o.db.Local, o.Path))
+		},
+		write: func(content interface{}, funcs ...interface{}) error {
+			return o.Write(content)
+		},
+	}, func() error {
+		return o.Read(dest)
+	})
+}
+
+func (db DB) readForUpdate(h *ReadHandle, read func() error) (*ReadHandle, error) {
+	h.mu = &db.state().staleMu
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hash, err := h.files()
+	if err != nil {
+		return nil, err
+	}
+	if err := read(); err != nil {
+		return nil, err
+	}
+	h.hash = hash
+	return h, nil
+}
+
+// Hash returns the hash of the file when it was read, or last written
+// with h, empty if it did not exist.
+func (h *ReadHandle) Hash() string {
+	return h.hash
+}
+
+func (h *ReadHandle) MustWrite(content interface{}, funcs ...interface{}) {
+	if err := h.Write(content, funcs...); err != nil {
+		panic(err)
+	}
+}
+
+// Write writes content like the Write of the collection or object it was
+// read from, unless the file changed since then, in which case it returns
+// an error satisfying errors.Is(err, ErrStaleRead). Only writes made
+// through handles are checked and written atomically; a Write made
+// directly can still slip in between.
+func (h *ReadHandle) Write(content interface{}, funcs ...interface{}) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hash, err := h.files()
+	if err != nil {
+		return err
+	}
+	if hash != h.hash {
+		return fmt.Errorf("Write: %s: %w", h.path, ErrStaleRead)
+	}
+	if err := h.write(content, funcs...); err != nil {
+		return err
+	}
+	h.hash, err = h.files()
+	return err
+}
+
+// hashFiles returns the hash of the file of c, combined with the ones of
+// its chunks and record files, if any.
+func (c Collection) hashFiles() (string, error) {
+	path := filepath.Join(c.db.Local, c.Path)
+	var names []string
+	if isDir(path) {
+		err := filepath.Walk(path, func(name string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				names = append(names, name)
+			}
+			return err
+		})
+		if err != nil {
+			return "", err
+		}
+	} else {
+		err := c.db.eachFile(filepath.Dir(c.Path), func(name string) {
+			if c.owns(name) {
+				names = append(names, filepath.Join(c.db.Local, name))
+			}
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	if len(names) == 1 && names[0] == path {
+		return hashFile(path)
+	}
+	if len(names) == 0 {
+		return "", nil
+	}
+	sort.Strings(names)
+	var list strings.Builder
+	for _, name := range names {
+		hash, err := hashFile(name)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(c.db.Local, name)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&list, "%s %s\n", hash, filepath.ToSlash(rel))
+	}
+	return plumbing.ComputeHash(plumbing.BlobObject, []byte(list.String())).String(), nil
+}
+
+// hashFile returns the git blob hash of the file at path, empty if it does
+// not exist.
+func hashFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return plumbing.ComputeHash(plumbing.BlobObject, content).String(), nil
+}
@@ -0,0 +1,131 @@
+package gitdb
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type (
+	// repoState holds runtime state shared by every DB value that points
+	// at the same local directory, since most DB methods have value
+	// receivers.
+	repoState struct {
+		writes priorityLock
+
+		registry    sync.Mutex
+		collections []*Collection
+		views       []*View
+		caches      []refresher
+		objects     []string
+		managed     []string
+		syncers     []*Syncer
+
+		pushMu    sync.Mutex
+		pushTimer *time.Timer
+		pushes    []time.Time
+
+		commitMu       sync.Mutex
+		commitTimer    *time.Timer
+		commitMessages []string
+		commitPush     bool
+
+		background sync.WaitGroup
+
+		journalMu sync.Mutex
+
+		staleMu sync.Mutex
+
+		quotaMu sync.Mutex
+
+		amplificationMu     sync.Mutex
+		amplification       map[string]*WriteAmplification
+		amplificationWarned map[string]bool
+
+		memory writeMemory
+
+		fetchMu sync.Mutex
+
+		syncMu    sync.Mutex
+		lastFetch time.Time
+		lastPush  time.Time
+	}
+)
+
+var states sync.Map
+
+func (db DB) state() *repoState {
+	s, _ := states.LoadOrStore(stateKey(db.Local), &repoState{})
+	return s.(*repoState)
+}
+
+func stateKey(local string) string {
+	key, err := filepath.Abs(local)
+	if err != nil {
+		return filepath.Clean(local)
+	}
+	return key
+}
+
+func (db DB) lock() func() {
+	return db.state().writes.lock(db.priority)
+}
+
+func (s *repoState) addCollection(c *Collection) {
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	for i, existing := range s.collections {
+		if existing.Path == c.Path {
+			s.collections[i] = c
+			return
+		}
+	}
+	s.collections = append(s.collections, c)
+}
+
+func (s *repoState) managedCollections() []*Collection {
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	return append([]*Collection(nil), s.collections...)
+}
+
+func (s *repoState) addSyncer(syncer *Syncer) {
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	s.syncers = append(s.syncers, syncer)
+}
+
+func (s *repoState) removeSyncer(syncer *Syncer) {
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	for i, existing := range s.syncers {
+		if existing == syncer {
+			s.syncers = append(s.syncers[:i], s.syncers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *repoState) registeredSyncers() []*Syncer {
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	return append([]*Syncer(nil), s.syncers...)
+}
+
+func (s *repoState) fetched() {
+	s.syncMu.Lock()
+	s.lastFetch = time.Now()
+	s.syncMu.Unlock()
+}
+
+func (s *repoState) pushed() {
+	s.syncMu.Lock()
+	s.lastPush = time.Now()
+	s.syncMu.Unlock()
+}
+
+func (s *repoState) syncTimes() (fetch, push time.Time) {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	return s.lastFetch, s.lastPush
+}
@@ -0,0 +1,118 @@
+package gitdb
+
+import (
+	"sort"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+type (
+	Stats struct {
+		// Commits is the number of commits reachable from HEAD.
+		Commits int
+
+		// HistoryDepth is the length of the first-parent chain of HEAD.
+		HistoryDepth int
+
+		// Files and TotalSize count the files at HEAD that belong to the
+		// collections, objects and patterns of Manage of this process.
+		// Other files of the repository, like a README, are left out.
+		Files     int
+		TotalSize int64
+
+		// LargestFiles lists the largest of those files, largest first.
+		LargestFiles []FileSize
+
+		// LastFetch and LastPush are the times of the last successful
+		// fetch and push made by this process.
+		LastFetch time.Time
+		LastPush  time.Time
+
+		// WriteAmplification lists the files written by the collections
+		// and objects of this process, highest amplification first.
+		WriteAmplification []WriteAmplification
+
+		// Buffers counts the reuse of serialization buffers and the
+		// memory reserved by writes.
+		Buffers BufferStats
+	}
+
+	FileSize struct {
+		Path string
+		Size int64
+	}
+)
+
+const largestFilesCount = 10
+
+func (db DB) MustStats() Stats {
+	stats, err := db.Stats()
+	if err != nil {
+		panic(err)
+	}
+	return stats
+}
+
+func (db DB) Stats() (stats Stats, err error) {
+	stats.LastFetch, stats.LastPush = db.state().syncTimes()
+	stats.WriteAmplification = db.state().writeAmplification()
+	stats.Buffers = db.state().bufferStats()
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return
+	}
+	head, err := r.Head()
+	if err != nil {
+		return
+	}
+	commit, err := r.CommitObject(head.Hash())
+	if err != nil {
+		return
+	}
+
+	for c := commit; ; {
+		stats.HistoryDepth++
+		if c.NumParents() == 0 {
+			break
+		}
+		if c, err = c.Parent(0); err != nil {
+			return
+		}
+	}
+	err = object.NewCommitPreorderIter(commit, nil, nil).ForEach(func(*object.Commit) error {
+		stats.Commits++
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return
+	}
+	state := db.state()
+	var files []FileSize
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !state.managedPath(f.Name) {
+			return nil
+		}
+		files = append(files, FileSize{Path: f.Name, Size: f.Size})
+		stats.TotalSize += f.Size
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	stats.Files = len(files)
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].Size > files[j].Size
+	})
+	if len(files) > largestFilesCount {
+		files = files[:largestFilesCount]
+	}
+	stats.LargestFiles = files
+	return
+}
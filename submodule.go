@@ -0,0 +1,189 @@
+package gitdb
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+)
+
+type (
+	submodule struct {
+		path string
+		db   DB
+	}
+)
+
+// SetSubmodules makes Init and ForceUpdate initialize and update the git
+// submodules of the repository, recursively, and lets collections live
+// inside them: Add stages their files in the submodule, Commit commits the
+// submodule first and records its new commit, and Push pushes the
+// submodule before the repository.
+func (db *DB) SetSubmodules(enabled bool) {
+	db.Submodules = enabled
+}
+
+func (db DB) updateSubmodules(ctx context.Context, r *git.Repository) error {
+	if !db.Submodules {
+		return nil
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+	subs, err := w.Submodules()
+	if err != nil {
+		return err
+	}
+	if len(subs) == 0 {
+		return nil
+	}
+	log.Println("updating submodules of", db.Local)
+	return subs.UpdateContext(ctx, &git.SubmoduleUpdateOptions{
+		Init:              true,
+		RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+		Auth:              db.authMethod(),
+	})
+}
+
+// submodules returns the submodules of the repository, each with a DB
+// sharing the settings of db.
+func (db DB) submodules(r *git.Repository) ([]submodule, error) {
+	if !db.Submodules {
+		return nil, nil
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	subs, err := w.Submodules()
+	if err != nil {
+		return nil, err
+	}
+	var list []submodule
+	for _, s := range subs {
+		c := s.Config()
+		sub := db
+		sub.Remote = c.URL
+		sub.Local = filepath.Join(db.Local, c.Path)
+		sub.BranchName = c.Branch
+		sub.FallbackRemotes = nil
+		sub.pushPolicy = PushPolicy{}
+		sub.commitBatch = 0
+		sub.branchRoutes = nil
+		sub.pullRequests = nil
+		list = append(list, submodule{path: filepath.ToSlash(c.Path), db: sub})
+	}
+	return list, nil
+}
+
+// addToSubmodules stages the files inside submodules in them and returns
+// the other files.
+func (db DB) addToSubmodules(r *git.Repository, files []string) ([]string, error) {
+	subs, err := db.submodules(r)
+	if err != nil || len(subs) == 0 {
+		return files, err
+	}
+	var rest []string
+	for _, file := range files {
+		name := filepath.ToSlash(file)
+		added := false
+		for _, s := range subs {
+			if !strings.HasPrefix(name, s.path+"/") {
+				continue
+			}
+			if err := s.db.attachHead(); err != nil {
+				return nil, err
+			}
+			if err := s.db.Add(strings.TrimPrefix(name, s.path+"/")); err != nil {
+				return nil, err
+			}
+			added = true
+			break
+		}
+		if !added {
+			rest = append(rest, file)
+		}
+	}
+	return rest, nil
+}
+
+// attachHead points the branch of a submodule, which is usually checked
+// out at a detached HEAD, to HEAD and checks it out, so that its new
+// commits can be pushed.
+func (db DB) attachHead() error {
+	r, err := git.PlainOpen(db.Local)
+	if err != nil {
+		return err
+	}
+	head, err := r.Storer.Reference(plumbing.HEAD)
+	if err != nil || head.Type() == plumbing.SymbolicReference {
+		return err
+	}
+	branch := plumbing.NewBranchReferenceName(db.GetBranchName())
+	if err := r.Storer.SetReference(plumbing.NewHashReference(branch, head.Hash())); err != nil {
+		return err
+	}
+	return r.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, branch))
+}
+
+// commitSubmodules commits the staged changes of the submodules and stages
+// their new commits in the repository.
+func (db DB) commitSubmodules(r *git.Repository, msg string) error {
+	subs, err := db.submodules(r)
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for _, s := range subs {
+		if err := s.db.commit(msg); err != nil {
+			return err
+		}
+		sr, err := git.PlainOpen(s.db.Local)
+		if err != nil {
+			return err
+		}
+		head, err := sr.Head()
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		e, err := idx.Entry(s.path)
+		if err != nil {
+			return err
+		}
+		if e.Mode == filemode.Submodule && e.Hash != head.Hash() {
+			e.Hash = head.Hash()
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return r.Storer.SetIndex(idx)
+}
+
+// pushSubmodules pushes the submodules, so that the commits the repository
+// points to exist on their remotes.
+func (db DB) pushSubmodules(ctx context.Context, r *git.Repository) error {
+	subs, err := db.submodules(r)
+	if err != nil {
+		return err
+	}
+	for _, s := range subs {
+		if err := s.db.push(ctx); err != nil && err != git.NoErrAlreadyUpToDate {
+			return err
+		}
+	}
+	return nil
+}
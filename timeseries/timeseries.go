@@ -0,0 +1,252 @@
+// Package timeseries stores timestamped points in a directory of a
+// gitdb.DB, for metrics and activity history kept in git.
+//
+// Points are appended to one collection per day or per month, named after
+// its period in UTC, like 2026-10-15.json or 2026-10.json, so old data can
+// be dropped by removing whole files:
+//
+//	series := timeseries.New(db, "metrics/signups", timeseries.Daily)
+//	series.SetRetention(90 * 24 * time.Hour)
+//	series.Append(time.Now(), 42)
+//	points, _ := series.Range(time.Now().AddDate(0, 0, -7), time.Now())
+//
+// Append and Prune commit their changes, but do not push them.
+package timeseries
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caiguanhao/gitdb"
+)
+
+const (
+	// Daily puts the points of each day in their own file.
+	Daily Partition = iota
+	// Monthly puts the points of each month in their own file.
+	Monthly
+)
+
+const fileExt = ".json"
+
+type (
+	// Partition is the period of time covered by each file of a series.
+	Partition int
+
+	// Series is a time series stored in a directory.
+	Series struct {
+		db        *gitdb.DB
+		dir       string
+		partition Partition
+		retention time.Duration
+		mu        sync.Mutex
+	}
+
+	// Point is a value at a time.
+	Point struct {
+		Time  time.Time       `json:"time"`
+		Value json.RawMessage `json:"value"`
+	}
+)
+
+// New returns the series in dir of db, relative to the root of db (see
+// SetRoot). The files of the series are managed (see Manage).
+func New(db *gitdb.DB, dir string, partition Partition) *Series {
+	db.Manage(filepath.ToSlash(filepath.Join(filepath.FromSlash(db.Root), dir)) + "/")
+	return &Series{db: db, dir: dir, partition: partition}
+}
+
+// SetRetention makes Append drop the partitions that ended more than d
+// ago. Zero keeps every partition.
+func (s *Series) SetRetention(d time.Duration) {
+	s.mu.Lock()
+	s.retention = d
+	s.mu.Unlock()
+}
+
+func (s *Series) MustAppend(t time.Time, value interface{}) {
+	if err := s.Append(t, value); err != nil {
+		panic(err)
+	}
+}
+
+// Append adds the point of value, encoded as JSON, at t to its partition
+// and commits it, together with the partitions dropped by the retention.
+func (s *Series) Append(t time.Time, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("Append: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.collection(s.partitionName(t))
+	var points []Point
+	if err := c.Read(&points); err != nil {
+		return err
+	}
+	points = append(points, Point{Time: t.UTC(), Value: b})
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Time.Before(points[j].Time)
+	})
+	if err := c.Write(points); err != nil {
+		return err
+	}
+	changed := []string{c.Path}
+	dropped, err := s.drop(time.Now())
+	if err != nil {
+		return err
+	}
+	changed = append(changed, dropped...)
+	if err := s.db.Add(changed...); err != nil {
+		return err
+	}
+	return s.db.Commit(fmt.Sprintf("append to %s", c.Path))
+}
+
+func (s *Series) MustPrune() []string {
+	paths, err := s.Prune()
+	if err != nil {
+		panic(err)
+	}
+	return paths
+}
+
+// Prune drops the partitions past the retention, commits it and returns
+// their paths.
+func (s *Series) Prune() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	dropped, err := s.drop(time.Now())
+	if err != nil || len(dropped) == 0 {
+		return nil, err
+	}
+	if err := s.db.Add(dropped...); err != nil {
+		return nil, err
+	}
+	if err := s.db.Commit("prune " + strings.Join(dropped, ", ")); err != nil {
+		return nil, err
+	}
+	return dropped, nil
+}
+
+func (s *Series) MustRange(from, to time.Time) []Point {
+	points, err := s.Range(from, to)
+	if err != nil {
+		panic(err)
+	}
+	return points
+}
+
+// Range returns the points from from, inclusive, to to, exclusive, sorted
+// by time.
+func (s *Series) Range(from, to time.Time) ([]Point, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	partitions, err := s.partitions()
+	if err != nil {
+		return nil, err
+	}
+	var points []Point
+	for _, name := range partitions {
+		start, end, ok := s.period(name)
+		if !ok || !end.After(from) || !start.Before(to) {
+			continue
+		}
+		var list []Point
+		if err := s.collection(name).Read(&list); err != nil {
+			return nil, err
+		}
+		for _, p := range list {
+			if !p.Time.Before(from) && p.Time.Before(to) {
+				points = append(points, p)
+			}
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Time.Before(points[j].Time)
+	})
+	return points, nil
+}
+
+// Decode decodes the value of the point into dest.
+func (p Point) Decode(dest interface{}) error {
+	return json.Unmarshal(p.Value, dest)
+}
+
+// drop removes the partitions that ended before the retention and returns
+// their paths.
+func (s *Series) drop(now time.Time) ([]string, error) {
+	if s.retention <= 0 {
+		return nil, nil
+	}
+	partitions, err := s.partitions()
+	if err != nil {
+		return nil, err
+	}
+	var dropped []string
+	for _, name := range partitions {
+		_, end, ok := s.period(name)
+		if !ok || !end.Before(now.Add(-s.retention)) {
+			continue
+		}
+		path := s.collection(name).Path
+		if err := os.Remove(filepath.Join(s.db.Local, path)); err != nil {
+			return nil, err
+		}
+		dropped = append(dropped, path)
+	}
+	return dropped, nil
+}
+
+// partitions returns the sorted names of the partition files.
+func (s *Series) partitions() ([]string, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(s.db.Local, filepath.FromSlash(s.db.Root), s.dir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, info := range infos {
+		name := info.Name()
+		if !info.IsDir() && strings.HasSuffix(name, fileExt) {
+			names = append(names, strings.TrimSuffix(name, fileExt))
+		}
+	}
+	return names, nil
+}
+
+func (s *Series) collection(name string) *gitdb.Collection {
+	return s.db.NewCollection(filepath.Join(s.dir, name+fileExt))
+}
+
+func (s *Series) layout() string {
+	if s.partition == Monthly {
+		return "2006-01"
+	}
+	return "2006-01-02"
+}
+
+func (s *Series) partitionName(t time.Time) string {
+	return t.UTC().Format(s.layout())
+}
+
+// period returns the start and the end of the partition name.
+func (s *Series) period(name string) (time.Time, time.Time, bool) {
+	start, err := time.Parse(s.layout(), name)
+	if err != nil {
+		return start, start, false
+	}
+	if s.partition == Monthly {
+		return start, start.AddDate(0, 1, 0), true
+	}
+	return start, start.AddDate(0, 0, 1), true
+}
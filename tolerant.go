@@ -0,0 +1,448 @@
+package gitdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+type (
+	// ParseError is the error of reading a file that is not valid JSON or
+	// does not fit the destination, usually after a manual edit, with the
+	// position of the error in the file. Line and Column start at 1.
+	ParseError struct {
+		Path   string
+		Line   int
+		Column int
+		Err    error
+	}
+
+	// RecordError is a record of a collection that failed to parse or
+	// decode and was skipped. Index is its position in the array of the
+	// file at Path, or -1 for a record file of a collection with
+	// RecordKeyField. Raw is the text of the record as found in the file.
+	RecordError struct {
+		Path   string
+		Index  int
+		Line   int
+		Column int
+		Raw    string
+		Err    error
+	}
+
+	// rawRecord is an element of a JSON array and its offset in the array.
+	rawRecord struct {
+		offset int
+		data   []byte
+	}
+
+	quarantinedRecord struct {
+		Path   string `json:"path"`
+		Index  int    `json:"index"`
+		Line   int    `json:"line"`
+		Column int    `json:"column"`
+		Error  string `json:"error"`
+		Record string `json:"record"`
+	}
+)
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %v", e.Path, e.Line, e.Column, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func (e RecordError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("%s:%d:%d: %v", e.Path, e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("%s:%d:%d: record %d: %v", e.Path, e.Line, e.Column, e.Index, e.Err)
+}
+
+func (e RecordError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError returns err of decoding the file at path from offset start
+// as a ParseError, or err as is if it has no position.
+func newParseError(path string, start int64, err error) error {
+	var offset int64
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// the offset is after the invalid byte
+		offset = syntaxErr.Offset - 1
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset - 1
+	case err == io.ErrUnexpectedEOF:
+		offset = -1
+	default:
+		return err
+	}
+	content, readErr := ioutil.ReadFile(path)
+	if readErr != nil {
+		return err
+	}
+	pos := int(start + offset)
+	if err == io.ErrUnexpectedEOF || pos > len(content) {
+		pos = len(content)
+	}
+	line, column := lineColumn(content, pos)
+	return &ParseError{Path: path, Line: line, Column: column, Err: err}
+}
+
+// lineColumn returns the line and column, starting at 1, of the byte at
+// offset in content.
+func lineColumn(content []byte, offset int) (int, int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	before := content[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	return line, offset - bytes.LastIndexByte(before, '\n')
+}
+
+func (c Collection) MustReadLenient(dest interface{}) []RecordError {
+	skipped, err := c.ReadLenient(dest)
+	if err != nil {
+		panic(err)
+	}
+	return skipped
+}
+
+// ReadLenient reads the collection into dest, a pointer to a slice, like
+// Read, but decodes the records one by one when the file fails to decode,
+// skipping and returning the ones that fail, so one bad record from a
+// manual edit does not take the whole collection offline. Unlike
+// Quarantine, it saves nothing.
+func (c Collection) ReadLenient(dest interface{}) ([]RecordError, error) {
+	skipped, err := c.readTolerant(dest)
+	if err != nil {
+		return nil, err
+	}
+	if c.ExpiresAtField != "" {
+		removeExpired(dest, c.ExpiresAtField, time.Now())
+	}
+	return skipped, nil
+}
+
+// readTolerant reads the collection into dest like readAll, skipping the
+// records that fail to parse or decode instead of failing, and returns
+// them. Files that are not arrays, like a JSONP file missing its array,
+// still fail the read.
+func (c Collection) readTolerant(dest interface{}) ([]RecordError, error) {
+	defer removeNulls(dest)
+	path := filepath.Join(c.db.Local, c.Path)
+	opts := c.jsonOptions()
+	if rv := reflect.ValueOf(dest); rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return nil, opts.readCollection(path, dest)
+	}
+	var skipped []RecordError
+	err := opts.decode(dest, func(dest interface{}) error {
+		var err error
+		skipped, err = opts.readChunksTolerant(path, dest)
+		return err
+	})
+	for i := range skipped {
+		if rel, err := filepath.Rel(c.db.Local, skipped[i].Path); err == nil {
+			skipped[i].Path = filepath.ToSlash(rel)
+		}
+	}
+	return skipped, err
+}
+
+func (opts JSONOptions) readChunksTolerant(path string, dest interface{}) ([]RecordError, error) {
+	rv := reflect.Indirect(reflect.ValueOf(dest))
+	if isDir(path) {
+		names, err := recordFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		var skipped []RecordError
+		rv.Set(reflect.MakeSlice(rv.Type(), 0, len(names)))
+		for _, name := range names {
+			file := filepath.Join(path, name)
+			record := reflect.New(rv.Type().Elem())
+			if err := readJsonWith(file, record.Interface(), opts); err != nil {
+				raw, _ := ioutil.ReadFile(file)
+				skipped = append(skipped, recordError(file, -1, raw, err))
+				continue
+			}
+			rv.Set(reflect.Append(rv, record.Elem()))
+		}
+		return skipped, nil
+	}
+	m, err := readManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if m == nil {
+		return opts.readArrayTolerant(path, dest)
+	}
+	var skipped []RecordError
+	rv.Set(reflect.MakeSlice(rv.Type(), 0, m.Count))
+	for _, chunk := range m.Chunks {
+		part := reflect.New(rv.Type())
+		s, err := opts.readArrayTolerant(filepath.Join(filepath.Dir(path), chunk), part.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", chunk, err)
+		}
+		skipped = append(skipped, s...)
+		rv.Set(reflect.AppendSlice(rv, part.Elem()))
+	}
+	return skipped, nil
+}
+
+// readArrayTolerant reads the JSON array in the file at path into dest, a
+// pointer to a slice, one element at a time if the whole file fails to
+// decode.
+func (opts JSONOptions) readArrayTolerant(path string, dest interface{}) ([]RecordError, error) {
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	inner := jsonpContent(content)
+	// inner is a subslice of content
+	start := cap(content) - cap(inner)
+	err = opts.newDecoder(bytes.NewReader(inner)).Decode(dest)
+	if err == nil {
+		return nil, nil
+	}
+	records, ok := splitRecords(inner)
+	if !ok {
+		return nil, newParseError(path, int64(start), err)
+	}
+	rv := reflect.Indirect(reflect.ValueOf(dest))
+	rv.Set(reflect.MakeSlice(rv.Type(), 0, len(records)))
+	var skipped []RecordError
+	for i, record := range records {
+		elem := reflect.New(rv.Type().Elem())
+		if offset, err := opts.decodeRecord(record.data, elem.Interface()); err != nil {
+			if lines, ok := opts.decodeLines(record, rv.Type().Elem()); ok {
+				// records on their own lines missing the comma between them
+				rv.Set(reflect.Append(rv, lines...))
+				continue
+			}
+			line, column := lineColumn(content, start+record.offset+offset)
+			skipped = append(skipped, RecordError{
+				Path:   path,
+				Index:  i,
+				Line:   line,
+				Column: column,
+				Raw:    string(record.data),
+				Err:    err,
+			})
+			continue
+		}
+		rv.Set(reflect.Append(rv, elem.Elem()))
+	}
+	return skipped, nil
+}
+
+// decodeRecord decodes the single JSON value in data into dest, returning
+// the offset of the error in data if it fails.
+func (opts JSONOptions) decodeRecord(data []byte, dest interface{}) (int, error) {
+	dec := opts.newDecoder(bytes.NewReader(data))
+	err := dec.Decode(dest)
+	if err == nil {
+		if _, extra := dec.Token(); extra != io.EOF {
+			return int(dec.InputOffset()), errors.New("unexpected data after record, missing comma?")
+		}
+		return 0, nil
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return int(syntaxErr.Offset) - 1, err
+	case errors.As(err, &typeErr):
+		return int(typeErr.Offset) - 1, err
+	}
+	return len(data), err
+}
+
+// decodeLines decodes each line of record as a record of type t, and
+// returns false if record is on one line or any line fails.
+func (opts JSONOptions) decodeLines(record rawRecord, t reflect.Type) ([]reflect.Value, bool) {
+	if bytes.IndexByte(record.data, '\n') < 0 {
+		return nil, false
+	}
+	var values []reflect.Value
+	for _, line := range bytes.Split(record.data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		elem := reflect.New(t)
+		if _, err := opts.decodeRecord(line, elem.Interface()); err != nil {
+			return nil, false
+		}
+		values = append(values, elem.Elem())
+	}
+	return values, true
+}
+
+// recordError returns the RecordError of the record file at path failing
+// to read with err.
+func recordError(path string, index int, raw []byte, err error) RecordError {
+	e := RecordError{Path: path, Index: index, Line: 1, Column: 1, Raw: string(raw), Err: err}
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) {
+		e.Line, e.Column, e.Err = parseErr.Line, parseErr.Column, parseErr.Err
+	}
+	return e
+}
+
+// splitRecords splits the JSON array in b into its elements without
+// decoding them, so a malformed element does not hide the others. Empty
+// elements, like the one after a trailing comma, are dropped. If brackets
+// or quotes are unbalanced, it falls back to one element per line, the way
+// Write lays out collections. It returns false if b is not an array. As
+// it runs on hand-edited files, it must not panic on any input.
+func splitRecords(b []byte) ([]rawRecord, bool) {
+	open := 0
+	for open < len(b) && isSpace(b[open]) {
+		open++
+	}
+	if open == len(b) || b[open] != '[' {
+		return nil, false
+	}
+	var records []rawRecord
+	add := func(from, to int) {
+		for from < to && isSpace(b[from]) {
+			from++
+		}
+		for to > from && isSpace(b[to-1]) {
+			to--
+		}
+		if from < to {
+			records = append(records, rawRecord{offset: from, data: b[from:to]})
+		}
+	}
+	var stack []byte
+	inString, escaped := false, false
+	start := open + 1
+	for i := start; i < len(b); i++ {
+		ch := b[i]
+		if inString {
+			if escaped {
+				escaped = false
+			} else if ch == '\\' {
+				escaped = true
+			} else if ch == '"' {
+				inString = false
+			} else if ch == '\n' {
+				// strings never span lines in valid JSON
+				return splitRecordLines(b, open), true
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			stack = append(stack, ch)
+		case '}', ']':
+			if len(stack) == 0 {
+				if ch == ']' {
+					add(start, i)
+					return records, true
+				}
+				return splitRecordLines(b, open), true
+			}
+			if top := stack[len(stack)-1]; top == '{' && ch != '}' || top == '[' && ch != ']' {
+				return splitRecordLines(b, open), true
+			}
+			stack = stack[:len(stack)-1]
+		case ',':
+			if len(stack) == 0 {
+				add(start, i)
+				start = i + 1
+			}
+		}
+	}
+	return splitRecordLines(b, open), true
+}
+
+// splitRecordLines splits the array opening at open in b into one element per
+// line, trimming the commas between them.
+func splitRecordLines(b []byte, open int) []rawRecord {
+	end := bytes.LastIndexByte(b, ']')
+	if end <= open {
+		end = len(b)
+	}
+	var records []rawRecord
+	for from := open + 1; from < end; {
+		to := bytes.IndexByte(b[from:end], '\n')
+		if to < 0 {
+			to = end
+		} else {
+			to += from
+		}
+		line := b[from:to]
+		offset := from
+		for len(line) > 0 && isSpace(line[0]) {
+			line = line[1:]
+			offset++
+		}
+		line = bytes.TrimRight(line, " \t\r,")
+		if len(line) > 0 {
+			records = append(records, rawRecord{offset: offset, data: line})
+		}
+		from = to + 1
+	}
+	return records
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// quarantine saves the skipped records of the collection in the git
+// directory, or removes the saved ones if there are none.
+func (c Collection) quarantine(skipped []RecordError) error {
+	path := filepath.Join(gitDir(c.db.Local), "gitdb", "quarantine", filepath.FromSlash(c.Path)+".json")
+	if len(skipped) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	records := make([]quarantinedRecord, len(skipped))
+	for i, e := range skipped {
+		records[i] = quarantinedRecord{
+			Path:   e.Path,
+			Index:  e.Index,
+			Line:   e.Line,
+			Column: e.Column,
+			Error:  e.Err.Error(),
+			Record: e.Raw,
+		}
+	}
+	b, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFile(path, bytes.NewReader(b)); err != nil {
+		return err
+	}
+	log.Println("warning: skipped", len(skipped), "bad records of", c.Path, "saved in", path)
+	return nil
+}
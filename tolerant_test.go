@@ -0,0 +1,114 @@
+package gitdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// tolerantSeeds are collection files as found after manual edits:
+// missing and trailing commas, unbalanced brackets and quotes, comments,
+// JSONP and files cut short.
+var tolerantSeeds = []string{
+	"[\n{\"id\":1},\n{\"id\":2},\nnull\n]\n",
+	"[\n{\"id\":1}\n{\"id\":2},\nnull\n]\n",
+	"[\n{\"id\":1},\n{\"id\":2,},\n{\"id\":3}\n]\n",
+	"[\n{\"id\":1,\"name\":\"a},\n{\"id\":2}\n]\n",
+	"[\n{\"id\":1,\"tags\":[\"a\"},\n{\"id\":2}\n]\n",
+	"[\n{\"id\":1},\n// {\"id\":2},\n{\"id\":3}\n]\n",
+	"[\n{\"id\":\"a\\\"b\"},\n{\"id\":\"c\\\\\"}\n]",
+	"cb([\n{\"id\":1},\n{\"id\":2}\n]);\n",
+	"[\n{\"id\":1},\n{\"id\":",
+	"[\n{\"id\":1},\n{\"i",
+	"[\n{\"id\":1}]]]\n",
+	"[",
+	"[]",
+	"]",
+	"{\"id\":1}",
+	"",
+	"\xff[\x00,]",
+}
+
+func FuzzSplitRecords(f *testing.F) {
+	for _, seed := range tolerantSeeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		records, ok := splitRecords(b)
+		if isArray := len(bytes.TrimLeft(b, " \t\r\n")) > 0 && bytes.TrimLeft(b, " \t\r\n")[0] == '['; ok != isArray {
+			t.Fatalf("splitRecords(%q) = %v, want %v", b, ok, isArray)
+		}
+		for _, r := range records {
+			if len(r.data) == 0 {
+				t.Fatalf("empty record in %q", b)
+			}
+			if r.offset < 0 || r.offset+len(r.data) > len(b) || !bytes.Equal(b[r.offset:r.offset+len(r.data)], r.data) {
+				t.Fatalf("record %q at %d is not in %q", r.data, r.offset, b)
+			}
+		}
+		var valid []json.RawMessage
+		if json.Unmarshal(b, &valid) == nil && !bytes.Contains(b, []byte("\n")) {
+			if len(records) != len(valid) {
+				t.Fatalf("splitRecords(%q) returned %d records, want %d", b, len(records), len(valid))
+			}
+		}
+	})
+}
+
+func FuzzReadLenient(f *testing.F) {
+	for _, seed := range tolerantSeeds {
+		f.Add([]byte(seed))
+	}
+	db := NewDB("", f.TempDir())
+	c := db.NewCollection("items.json")
+	path := filepath.Join(db.Local, c.Path)
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if err := ioutil.WriteFile(path, b, 0644); err != nil {
+			t.Fatal(err)
+		}
+		var records []map[string]interface{}
+		skipped, err := c.ReadLenient(&records)
+		if err != nil {
+			return
+		}
+		for _, s := range skipped {
+			if s.Line < 1 || s.Column < 1 || s.Err == nil {
+				t.Fatalf("invalid record error %+v for %q", s, b)
+			}
+		}
+		var all []map[string]interface{}
+		if json.Unmarshal(jsonpContent(b), &all) == nil {
+			if len(skipped) > 0 {
+				t.Fatalf("records of valid %q skipped: %v", b, skipped)
+			}
+		}
+	})
+}
+
+func TestReadLenient(t *testing.T) {
+	db := newTestDB(t)
+	c := db.NewCollection("items.json")
+	content := "[\n{\"id\":1},\n{\"id\":2}\n{\"id\":3},\n{\"id\":\"x\"},\n{\"id\":4,\"name\":\"a},\n{\"id\":5}\n]\n"
+	if err := ioutil.WriteFile(filepath.Join(db.Local, c.Path), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var records []struct {
+		ID int `json:"id"`
+	}
+	skipped, err := c.ReadLenient(&records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, r := range records {
+		ids = append(ids, r.ID)
+	}
+	if got := jsonText(ids); got != "[1,2,3,5]" {
+		t.Errorf("got records %s, want [1,2,3,5]", got)
+	}
+	if len(skipped) != 2 || skipped[0].Line != 5 || skipped[1].Line != 6 {
+		t.Errorf("got skipped %v, want lines 5 and 6", skipped)
+	}
+}
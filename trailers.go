@@ -0,0 +1,108 @@
+package gitdb
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type (
+	// Trailer is a "Key: value" line added at the end of commit messages,
+	// like "Signed-off-by: Name <email>".
+	Trailer struct {
+		Key   string
+		Value string
+		// Func, if set, returns the value for each commit message instead,
+		// like ChangeID.
+		Func func(msg string) string
+	}
+)
+
+var trailerLine = regexp.MustCompile(`^[A-Za-z0-9-]+: `)
+
+// SetTrailers makes every commit made by gitdb end with trailers.
+func (db *DB) SetTrailers(trailers ...Trailer) {
+	db.trailers = trailers
+}
+
+// WithTrailers returns a copy of db whose commits end with trailers in
+// place of the trailers of db with the same keys, for the operations of a
+// single caller. A trailer without a Value and a Func removes the one of
+// db.
+func (db *DB) WithTrailers(trailers ...Trailer) *DB {
+	copy := *db
+	copy.trailers = nil
+	for _, t := range db.trailers {
+		if !hasTrailer(trailers, t.Key) {
+			copy.trailers = append(copy.trailers, t)
+		}
+	}
+	for _, t := range trailers {
+		if t.Value != "" || t.Func != nil {
+			copy.trailers = append(copy.trailers, t)
+		}
+	}
+	return &copy
+}
+
+// SignedOffBy returns the Signed-off-by trailer of the user of db.
+func (db DB) SignedOffBy() Trailer {
+	return Trailer{Key: "Signed-off-by", Value: fmt.Sprintf("%s <%s>", db.UserName, db.UserEmail)}
+}
+
+// ChangeID returns a Gerrit Change-Id for msg, to be used as the Func of a
+// Change-Id trailer.
+func ChangeID(msg string) string {
+	salt := make([]byte, 16)
+	rand.Read(salt)
+	return fmt.Sprintf("I%x", sha1.Sum([]byte(fmt.Sprintf("%s%d%x", msg, time.Now().UnixNano(), salt))))
+}
+
+// addTrailers returns msg ending with the trailers of db, skipping the lines
+// it already has.
+func (db DB) addTrailers(msg string) string {
+	if len(db.trailers) == 0 {
+		return msg
+	}
+	msg = strings.TrimRight(msg, "\n")
+	paragraphs := strings.Split(msg, "\n\n")
+	last := strings.Split(paragraphs[len(paragraphs)-1], "\n")
+	hasBlock := len(paragraphs) > 1
+	existing := map[string]bool{}
+	for _, line := range last {
+		if !trailerLine.MatchString(line) {
+			hasBlock = false
+		}
+		existing[line] = true
+	}
+	var lines []string
+	for _, t := range db.trailers {
+		value := t.Value
+		if t.Func != nil {
+			value = t.Func(msg)
+		}
+		line := t.Key + ": " + value
+		if !existing[line] {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return msg
+	}
+	if hasBlock {
+		return msg + "\n" + strings.Join(lines, "\n")
+	}
+	return msg + "\n\n" + strings.Join(lines, "\n")
+}
+
+func hasTrailer(trailers []Trailer, key string) bool {
+	for _, t := range trailers {
+		if strings.EqualFold(t.Key, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package gitdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+func (db DB) signature() object.Signature {
+	return object.Signature{
+		Name:  db.UserName,
+		Email: db.UserEmail,
+		When:  time.Now(),
+	}
+}
+
+// storeBlob writes content to s as a blob object.
+func storeBlob(s storer.EncodedObjectStorer, content []byte) (plumbing.Hash, error) {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if _, err := w.Write(content); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if err := w.Close(); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// buildTree writes a tree equal to base (which may be nil) with the blobs of
+// changes applied, where a zero hash deletes the path, and returns its hash.
+func buildTree(s storer.EncodedObjectStorer, base *object.Tree, changes map[string]plumbing.Hash) (plumbing.Hash, error) {
+	h, _, err := buildSubtree(s, base, changes, true)
+	return h, err
+}
+
+func buildSubtree(s storer.EncodedObjectStorer, base *object.Tree, changes map[string]plumbing.Hash, root bool) (plumbing.Hash, bool, error) {
+	entries := map[string]object.TreeEntry{}
+	if base != nil {
+		for _, e := range base.Entries {
+			entries[e.Name] = e
+		}
+	}
+	dirs := map[string]map[string]plumbing.Hash{}
+	for path, h := range changes {
+		path = strings.TrimPrefix(filepath.ToSlash(path), "/")
+		if i := strings.IndexByte(path, '/'); i > -1 {
+			dir := path[:i]
+			if dirs[dir] == nil {
+				dirs[dir] = map[string]plumbing.Hash{}
+			}
+			dirs[dir][path[i+1:]] = h
+			continue
+		}
+		if h.IsZero() {
+			delete(entries, path)
+		} else {
+			entries[path] = object.TreeEntry{Name: path, Mode: filemode.Regular, Hash: h}
+		}
+	}
+	for dir, sub := range dirs {
+		var subtree *object.Tree
+		if e, ok := entries[dir]; ok && e.Mode == filemode.Dir {
+			t, err := object.GetTree(s, e.Hash)
+			if err != nil {
+				return plumbing.ZeroHash, false, err
+			}
+			subtree = t
+		}
+		h, empty, err := buildSubtree(s, subtree, sub, false)
+		if err != nil {
+			return plumbing.ZeroHash, false, err
+		}
+		if empty {
+			delete(entries, dir)
+		} else {
+			entries[dir] = object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: h}
+		}
+	}
+	if len(entries) == 0 && !root {
+		return plumbing.ZeroHash, true, nil
+	}
+
+	tree := &object.Tree{}
+	for _, e := range entries {
+		tree.Entries = append(tree.Entries, e)
+	}
+	// git orders tree entries as if directory names ended with a slash
+	sortName := func(e object.TreeEntry) string {
+		if e.Mode == filemode.Dir {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(tree.Entries, func(i, j int) bool {
+		return sortName(tree.Entries[i]) < sortName(tree.Entries[j])
+	})
+	obj := s.NewEncodedObject()
+	if err := tree.Encode(obj); err != nil {
+		return plumbing.ZeroHash, false, err
+	}
+	h, err := s.SetEncodedObject(obj)
+	return h, false, err
+}
+
+// commitTree writes a commit of tree with parents and returns its hash.
+func (db DB) commitTree(s storer.EncodedObjectStorer, tree plumbing.Hash, parents []plumbing.Hash, msg string) (plumbing.Hash, error) {
+	sig := db.signature()
+	commit := &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      msg,
+		TreeHash:     tree,
+		ParentHashes: parents,
+	}
+	obj := s.NewEncodedObject()
+	if err := commit.Encode(obj); err != nil {
+		return plumbing.ZeroHash, err
+	}
+	return s.SetEncodedObject(obj)
+}
+
+// branchCommit returns the tip of the local branch, falling back to the
+// remote tracking branch. It returns nil if neither exists.
+func (db DB) branchCommit(r *git.Repository, branch string) (*object.Commit, error) {
+	ref, err := r.Reference(plumbing.NewBranchReferenceName(branch), true)
+	if err == plumbing.ErrReferenceNotFound {
+		ref, err = r.Reference(plumbing.NewRemoteReferenceName(db.GetRemoteName(), branch), true)
+	}
+	if err == plumbing.ErrReferenceNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.CommitObject(ref.Hash())
+}
+
+// checkoutPath makes the index entry and the worktree file of path match
+// their version in tree, removing them if tree does not contain path.
+func checkoutPath(r *git.Repository, local string, tree *object.Tree, path string) error {
+	idx, err := r.Storer.Index()
+	if err != nil {
+		return err
+	}
+	full := filepath.Join(local, path)
+	path = filepath.ToSlash(path)
+	var file *object.File
+	if tree != nil {
+		if file, err = tree.File(path); err == object.ErrFileNotFound {
+			file = nil
+		} else if err != nil {
+			return err
+		}
+	}
+	if file == nil {
+		idx.Remove(path)
+		if err := os.Remove(full); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		removeEmptyDirs(local, filepath.Dir(full))
+		return r.Storer.SetIndex(idx)
+	}
+	content, err := file.Contents()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(full, []byte(content), 0644); err != nil {
+		return err
+	}
+	e, err := idx.Entry(path)
+	if err != nil {
+		e = idx.Add(path)
+	}
+	e.Hash = file.Hash
+	e.Mode = file.Mode
+	e.Size = uint32(file.Size)
+	e.ModifiedAt = time.Now()
+	return r.Storer.SetIndex(idx)
+}
+
+// removeEmptyDirs removes dir and its parents up to root while they are
+// empty.
+func removeEmptyDirs(root, dir string) {
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package gitdb
+
+import (
+	"reflect"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeStrings makes Write normalize every string of the records, in
+// fields, slices and maps at any depth, to Unicode NFC, drop byte order
+// marks and zero-width spaces and trim zero-width joiners from both ends,
+// so strings from different sources that look the same are equal and
+// never show up in diffs. Map keys are left alone.
+func (c *Collection) NormalizeStrings() *Collection {
+	c.normalize = true
+	return c
+}
+
+// invisible are the characters dropped from strings by NormalizeStrings:
+// the byte order mark, zero-width space and word joiner.
+var invisible = strings.NewReplacer("\ufeff", "", "\u200b", "", "\u2060", "")
+
+func normalizeString(s string) string {
+	s = invisible.Replace(s)
+	// joiners between characters matter to emoji and some scripts
+	s = strings.Trim(s, "\u200c\u200d")
+	return norm.NFC.String(s)
+}
+
+// normalizeStrings returns a copy of v with its strings normalized,
+// leaving the values v points to untouched.
+func normalizeStrings(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.String:
+		out := reflect.New(v.Type()).Elem()
+		out.SetString(normalizeString(v.String()))
+		return out
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type().Elem())
+		out.Elem().Set(normalizeStrings(v.Elem()))
+		return out
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.New(v.Type()).Elem()
+		out.Set(normalizeStrings(v.Elem()))
+		return out
+	case reflect.Struct:
+		out := reflect.New(v.Type()).Elem()
+		out.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if out.Field(i).CanSet() {
+				out.Field(i).Set(normalizeStrings(v.Field(i)))
+			}
+		}
+		return out
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(normalizeStrings(v.Index(i)))
+		}
+		return out
+	case reflect.Array:
+		out := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			out.Index(i).Set(normalizeStrings(v.Index(i)))
+		}
+		return out
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			out.SetMapIndex(iter.Key(), normalizeStrings(iter.Value()))
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,176 @@
+package gitdb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type (
+	UpsertStats struct {
+		Inserted int
+		Updated  int
+		Deleted  int
+	}
+)
+
+func (s UpsertStats) Changed() bool {
+	return s.Inserted+s.Updated+s.Deleted > 0
+}
+
+func (c Collection) MustUpsert(records interface{}, keyField string, deleteMissing bool) UpsertStats {
+	stats, err := c.Upsert(records, keyField, deleteMissing)
+	if err != nil {
+		panic(err)
+	}
+	return stats
+}
+
+// Upsert merges records into the collection by keyField (Go field name or
+// JSON name), inserting new records, replacing existing ones and, if
+// deleteMissing is true, removing records not present in records. The
+// collection is written and committed once. The hashes of the records are
+// kept aside, so when nothing changed since, the collection is not even
+// read.
+func (c Collection) Upsert(records interface{}, keyField string, deleteMissing bool) (stats UpsertStats, err error) {
+	rv := reflect.Indirect(reflect.ValueOf(records))
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return stats, fmt.Errorf("Upsert: records must be a slice, got %s", rv.Kind())
+	}
+
+	defer c.lock()()
+
+	if c.unchangedRecords(rv, keyField, deleteMissing) {
+		return
+	}
+
+	existing := reflect.New(reflect.SliceOf(rv.Type().Elem()))
+	if err = c.Read(existing.Interface()); err != nil {
+		return
+	}
+	current := existing.Elem()
+
+	positions := map[string]int{}
+	for i := 0; i < current.Len(); i++ {
+		key, ok := keyOf(current.Index(i), keyField)
+		if !ok {
+			return stats, fmt.Errorf("Upsert: record %d has no field %q", i, keyField)
+		}
+		positions[key] = i
+	}
+
+	merged := reflect.MakeSlice(current.Type(), current.Len(), current.Len()+rv.Len())
+	reflect.Copy(merged, current)
+	seen := map[string]bool{}
+	for i := 0; i < rv.Len(); i++ {
+		item := rv.Index(i)
+		key, ok := keyOf(item, keyField)
+		if !ok {
+			return stats, fmt.Errorf("Upsert: record %d has no field %q", i, keyField)
+		}
+		seen[key] = true
+		if pos, ok := positions[key]; ok {
+			if !reflect.DeepEqual(merged.Index(pos).Interface(), item.Interface()) {
+				merged.Index(pos).Set(item)
+				stats.Updated++
+			}
+			continue
+		}
+		positions[key] = merged.Len()
+		merged = reflect.Append(merged, item)
+		stats.Inserted++
+	}
+
+	if deleteMissing {
+		kept := merged.Slice(0, 0)
+		for i := 0; i < merged.Len(); i++ {
+			key, _ := keyOf(merged.Index(i), keyField)
+			if seen[key] {
+				kept = reflect.Append(kept, merged.Index(i))
+			} else {
+				stats.Deleted++
+			}
+		}
+		merged = kept
+	}
+
+	if !stats.Changed() {
+		c.saveRecordHashes(merged, keyField)
+		return
+	}
+	if err = c.Write(merged.Interface()); err != nil {
+		return
+	}
+	c.saveRecordHashes(merged, keyField)
+	if err = c.db.Add(c.Path); err != nil {
+		return
+	}
+	err = c.db.commitOp(CommitInfo{
+		Op:      "upsert",
+		Paths:   []string{c.Path},
+		Records: stats.Inserted + stats.Updated + stats.Deleted,
+		Message: fmt.Sprintf("upsert %s: %d inserted, %d updated, %d deleted",
+			c.Path, stats.Inserted, stats.Updated, stats.Deleted),
+	})
+	return
+}
+
+// keyOf returns the string form of the field named key of a struct (or
+// pointer to struct, or map) record.
+func keyOf(record reflect.Value, key string) (string, bool) {
+	f, ok := fieldOf(record, key)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprint(f.Interface()), true
+}
+
+func fieldOf(record reflect.Value, key string) (reflect.Value, bool) {
+	for record.Kind() == reflect.Ptr || record.Kind() == reflect.Interface {
+		if record.IsNil() {
+			return reflect.Value{}, false
+		}
+		record = record.Elem()
+	}
+	switch record.Kind() {
+	case reflect.Map:
+		v := record.MapIndex(reflect.ValueOf(key))
+		if !v.IsValid() {
+			return reflect.Value{}, false
+		}
+		return v, true
+	case reflect.Struct:
+		t := record.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" && !sf.Anonymous {
+				continue
+			}
+			if sf.Name == key || jsonName(sf) == key {
+				return record.Field(i), true
+			}
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Anonymous {
+				if v, ok := fieldOf(record.Field(i), key); ok {
+					return v, true
+				}
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func jsonName(sf reflect.StructField) string {
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if i := strings.IndexByte(tag, ','); i > -1 {
+		tag = tag[:i]
+	}
+	if tag == "" {
+		return sf.Name
+	}
+	return tag
+}
@@ -0,0 +1,126 @@
+package gitdb
+
+import (
+	"fmt"
+)
+
+type (
+	View struct {
+		*Collection
+
+		sources   []*Collection
+		transform ViewFunc
+	}
+
+	// ViewFunc computes the content of a view from its source collections.
+	ViewFunc func(sources ...*Collection) (interface{}, error)
+)
+
+// DefineView registers a derived file at path that is recomputed by
+// transform whenever one of sources is written, and staged by Add together
+// with them.
+func (db *DB) DefineView(path string, sources []*Collection, transform ViewFunc) *View {
+	v := &View{
+		Collection: db.NewCollection(path),
+		sources:    sources,
+		transform:  transform,
+	}
+	s := db.state()
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	for i, existing := range s.views {
+		if existing.Path == v.Path {
+			s.views[i] = v
+			return v
+		}
+	}
+	s.views = append(s.views, v)
+	return v
+}
+
+func (v View) MustRefresh() {
+	if err := v.Refresh(); err != nil {
+		panic(err)
+	}
+}
+
+func (v View) Refresh() error {
+	content, err := v.transform(v.sources...)
+	if err != nil {
+		return fmt.Errorf("view %s: %w", v.Path, err)
+	}
+	if err := v.writeFile(content); err != nil {
+		return fmt.Errorf("view %s: %w", v.Path, err)
+	}
+	return nil
+}
+
+func (db DB) MustRefreshViews() {
+	if err := db.RefreshViews(); err != nil {
+		panic(err)
+	}
+}
+
+func (db DB) RefreshViews() error {
+	for _, v := range db.state().definedViews() {
+		if err := v.Refresh(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v View) dependsOn(path string) bool {
+	for _, src := range v.sources {
+		if src.Path == path {
+			return true
+		}
+	}
+	return false
+}
+
+// updateViews refreshes views depending on path, and the views depending on
+// those, skipping paths already refreshed.
+func (db DB) updateViews(path string, done map[string]bool) error {
+	for _, v := range db.state().definedViews() {
+		if done[v.Path] || !v.dependsOn(path) {
+			continue
+		}
+		done[v.Path] = true
+		if err := v.Refresh(); err != nil {
+			return err
+		}
+		if err := db.updateViews(v.Path, done); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withViews appends to files the views derived from them.
+func (db DB) withViews(files []string) []string {
+	views := db.state().definedViews()
+	if len(views) == 0 {
+		return files
+	}
+	files = append([]string(nil), files...)
+	done := map[string]bool{}
+	for _, file := range files {
+		done[file] = true
+	}
+	for i := 0; i < len(files); i++ {
+		for _, v := range views {
+			if !done[v.Path] && v.dependsOn(files[i]) {
+				done[v.Path] = true
+				files = append(files, v.Path)
+			}
+		}
+	}
+	return files
+}
+
+func (s *repoState) definedViews() []*View {
+	s.registry.Lock()
+	defer s.registry.Unlock()
+	return append([]*View(nil), s.views...)
+}
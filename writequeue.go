@@ -0,0 +1,154 @@
+package gitdb
+
+import (
+	"sync"
+	"time"
+)
+
+type (
+	// Priority orders the operations waiting for the repository lock,
+	// such as Upsert, DeleteWhere, Expire and Pull: waiting operations of
+	// higher priority run first, and operations of the same priority run
+	// in the order they arrived.
+	Priority int
+
+	// WriteQueueStats are the lock metrics of a priority.
+	WriteQueueStats struct {
+		Priority Priority
+
+		// Acquired is the number of times the lock was acquired, and
+		// Waiting the number of operations waiting for it.
+		Acquired int64
+		Waiting  int
+
+		// TotalWait and MaxWait are the total and longest times
+		// operations waited for the lock.
+		TotalWait time.Duration
+		MaxWait   time.Duration
+	}
+
+	// priorityLock is a mutex handed over to the waiter of highest
+	// priority when released.
+	priorityLock struct {
+		mu      sync.Mutex
+		held    bool
+		waiting map[Priority][]chan struct{}
+		stats   map[Priority]*WriteQueueStats
+	}
+)
+
+const (
+	LowPriority Priority = iota - 1
+	NormalPriority
+	HighPriority
+)
+
+var priorities = []Priority{HighPriority, NormalPriority, LowPriority}
+
+func (p Priority) String() string {
+	switch p {
+	case LowPriority:
+		return "low"
+	case NormalPriority:
+		return "normal"
+	case HighPriority:
+		return "high"
+	}
+	return "unknown"
+}
+
+// WithPriority returns a copy of c whose operations wait for the
+// repository lock with priority p, for example HighPriority for urgent
+// changes that should not wait behind LowPriority bulk imports.
+func (c *Collection) WithPriority(p Priority) *Collection {
+	copy := *c
+	copy.priority = p
+	return &copy
+}
+
+// WithPriority returns a copy of db whose operations, such as Pull, wait
+// for the repository lock with priority p.
+func (db *DB) WithPriority(p Priority) *DB {
+	copy := *db
+	copy.priority = p
+	return &copy
+}
+
+// WriteQueueStats returns the lock metrics of every priority, highest
+// first.
+func (db DB) WriteQueueStats() []WriteQueueStats {
+	l := &db.state().writes
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make([]WriteQueueStats, len(priorities))
+	for i, p := range priorities {
+		stats[i].Priority = p
+		if s := l.stats[p]; s != nil {
+			stats[i] = *s
+		}
+		stats[i].Waiting = len(l.waiting[p])
+	}
+	return stats
+}
+
+func (c Collection) lock() func() {
+	return c.db.state().writes.lock(c.priority)
+}
+
+func (l *priorityLock) lock(p Priority) func() {
+	if p < LowPriority {
+		p = LowPriority
+	} else if p > HighPriority {
+		p = HighPriority
+	}
+	start := time.Now()
+	l.mu.Lock()
+	if !l.held {
+		l.held = true
+		l.acquired(p, 0)
+		l.mu.Unlock()
+		return l.unlock
+	}
+	ch := make(chan struct{})
+	if l.waiting == nil {
+		l.waiting = map[Priority][]chan struct{}{}
+	}
+	l.waiting[p] = append(l.waiting[p], ch)
+	l.mu.Unlock()
+
+	<-ch
+	l.mu.Lock()
+	l.acquired(p, time.Since(start))
+	l.mu.Unlock()
+	return l.unlock
+}
+
+func (l *priorityLock) acquired(p Priority, wait time.Duration) {
+	if l.stats == nil {
+		l.stats = map[Priority]*WriteQueueStats{}
+	}
+	s := l.stats[p]
+	if s == nil {
+		s = &WriteQueueStats{Priority: p}
+		l.stats[p] = s
+	}
+	s.Acquired++
+	s.TotalWait += wait
+	if wait > s.MaxWait {
+		s.MaxWait = wait
+	}
+}
+
+// unlock hands the lock over to the first waiter of highest priority.
+func (l *priorityLock) unlock() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, p := range priorities {
+		if queue := l.waiting[p]; len(queue) > 0 {
+			l.waiting[p] = queue[1:]
+			close(queue[0])
+			return
+		}
+	}
+	l.held = false
+}